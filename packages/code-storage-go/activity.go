@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ActivityType identifies an org activity event.
+type ActivityType string
+
+const (
+	ActivityPush          ActivityType = "push"
+	ActivityRepoCreated   ActivityType = "repo.created"
+	ActivityBranchCreated ActivityType = "branch.created"
+	ActivityBranchDeleted ActivityType = "branch.deleted"
+)
+
+// ListActivityOptions configures ListActivity. Activity is listed newest
+// first.
+type ListActivityOptions struct {
+	InvocationOptions
+	// Types limits the feed to these event types. Empty lists all.
+	Types []ActivityType
+	// RepoID limits the feed to one repository.
+	RepoID string
+	// Since and Until bound the feed by event time when non-zero.
+	Since  time.Time
+	Until  time.Time
+	Cursor string
+	Limit  int
+}
+
+// ActivityEvent is one entry of the org activity feed. Ref, Before, and
+// After describe the ref change for push and branch events; Before is all
+// zeros when a branch is created and After when it is deleted.
+type ActivityEvent struct {
+	ID            string
+	Type          ActivityType
+	RepoID        string
+	Ref           string
+	Before        string
+	After         string
+	Actor         string
+	OccurredAt    time.Time
+	RawOccurredAt string
+}
+
+// ListActivityResult is a page of the org activity feed.
+type ListActivityResult struct {
+	Events     []ActivityEvent
+	NextCursor string
+	HasMore    bool
+}
+
+// ListActivity lists pushes, repository creations, and branch events across
+// the org, for dashboards that do not run a webhook consumer.
+func (c *Client) ListActivity(ctx context.Context, options ListActivityOptions) (ListActivityResult, error) {
+	if !options.Since.IsZero() && !options.Until.IsZero() && options.Until.Before(options.Since) {
+		return ListActivityResult{}, errors.New("listActivity until must not be before since")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := c.generateJWT("org", RemoteURLOptions{Permissions: []Permission{PermissionOrgRead}, TTL: ttl})
+	if err != nil {
+		return ListActivityResult{}, err
+	}
+
+	params := url.Values{}
+	if len(options.Types) > 0 {
+		types := make([]string, 0, len(options.Types))
+		for _, activityType := range options.Types {
+			types = append(types, string(activityType))
+		}
+		params.Set("types", strings.Join(types, ","))
+	}
+	if repoID := strings.TrimSpace(options.RepoID); repoID != "" {
+		params.Set("repo_id", repoID)
+	}
+	if !options.Since.IsZero() {
+		params.Set("since", options.Since.UTC().Format(time.RFC3339))
+	}
+	if !options.Until.IsZero() {
+		params.Set("until", options.Until.UTC().Format(time.RFC3339))
+	}
+	if options.Cursor != "" {
+		params.Set("cursor", options.Cursor)
+	}
+	if options.Limit > 0 {
+		params.Set("limit", itoa(options.Limit))
+	}
+	if len(params) == 0 {
+		params = nil
+	}
+
+	resp, err := c.api.get(ctx, "activity", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return ListActivityResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload listActivityResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return ListActivityResult{}, err
+	}
+
+	result := ListActivityResult{NextCursor: payload.NextCursor, HasMore: payload.HasMore}
+	for _, event := range payload.Events {
+		result.Events = append(result.Events, ActivityEvent{
+			ID:            event.ID,
+			Type:          ActivityType(event.Type),
+			RepoID:        event.RepoID,
+			Ref:           event.Ref,
+			Before:        event.Before,
+			After:         event.After,
+			Actor:         event.Actor,
+			OccurredAt:    parseTime(event.OccurredAt),
+			RawOccurredAt: event.OccurredAt,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListActivity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/activity" || r.Method != http.MethodGet {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		claims := parseJWTFromToken(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if claims["repo"] != "org" {
+			t.Errorf("expected org token, got %v", claims["repo"])
+		}
+		q := r.URL.Query()
+		if q.Get("types") != "push,branch.created" || q.Get("since") != "2024-01-01T00:00:00Z" || q.Get("cursor") != "c1" || q.Get("limit") != "2" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"events":[{"id":"act_2","type":"push","repo_id":"repo_1","ref":"refs/heads/main","before":"abc","after":"def","actor":"agent-7","occurred_at":"2024-01-20T10:30:00Z"},{"id":"act_1","type":"branch.created","repo_id":"repo_1","ref":"refs/heads/feature","before":"0000000000000000000000000000000000000000","after":"abc","occurred_at":"2024-01-20T10:00:00Z"}],"next_cursor":"c2","has_more":true}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	result, err := client.ListActivity(nil, ListActivityOptions{
+		Types:  []ActivityType{ActivityPush, ActivityBranchCreated},
+		Since:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Cursor: "c1",
+		Limit:  2,
+	})
+	if err != nil {
+		t.Fatalf("list activity error: %v", err)
+	}
+	if len(result.Events) != 2 || !result.HasMore || result.NextCursor != "c2" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	push := result.Events[0]
+	if push.Type != ActivityPush || push.RepoID != "repo_1" || push.After != "def" || push.Actor != "agent-7" || push.OccurredAt.IsZero() {
+		t.Fatalf("unexpected push event: %+v", push)
+	}
+	if result.Events[1].Type != ActivityBranchCreated || result.Events[1].Ref != "refs/heads/feature" {
+		t.Fatalf("unexpected branch event: %+v", result.Events[1])
+	}
+
+	since := time.Now()
+	if _, err := client.ListActivity(nil, ListActivityOptions{Since: since, Until: since.Add(-time.Hour)}); err == nil {
+		t.Fatalf("expected range error")
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const archiveSHA256Header = "Code-Storage-Archive-Sha256"
+
+// ErrArchiveChecksumMismatch is returned at the end of an archive body whose
+// SHA-256 does not match the digest the server sent with it.
+var ErrArchiveChecksumMismatch = errors.New("archive checksum mismatch")
+
+// ArchiveStreamSHA256 reports the hex SHA-256 the server computed for an
+// archive response, or "" when it sent none. Servers that stream archives
+// send it as a trailer, which is only available once the body has been
+// read to EOF.
+func ArchiveStreamSHA256(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	if sum := strings.TrimSpace(resp.Header.Get(archiveSHA256Header)); sum != "" {
+		return strings.ToLower(sum)
+	}
+	return strings.ToLower(strings.TrimSpace(resp.Trailer.Get(archiveSHA256Header)))
+}
+
+func verifyArchiveChecksum(expected string, digest hash.Hash) error {
+	if expected == "" {
+		return nil
+	}
+	if hex.EncodeToString(digest.Sum(nil)) != expected {
+		return ErrArchiveChecksumMismatch
+	}
+	return nil
+}
+
+// checksumBody hashes an archive response body and verifies it against the
+// server digest when the body reaches EOF.
+type checksumBody struct {
+	io.ReadCloser
+	resp   *http.Response
+	digest hash.Hash
+}
+
+func newChecksumBody(resp *http.Response) *checksumBody {
+	return &checksumBody{ReadCloser: resp.Body, resp: resp, digest: sha256.New()}
+}
+
+func (b *checksumBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.digest.Write(p[:n])
+	if err == io.EOF {
+		if verifyErr := verifyArchiveChecksum(ArchiveStreamSHA256(b.resp), b.digest); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveStreamChecksum(t *testing.T) {
+	content := strings.Repeat("archive-bytes", 100)
+	sum := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+	var sent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(archiveSHA256Header, sent)
+		_, _ = w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	sent = digest
+	resp, err := repo.ArchiveStream(nil, ArchiveOptions{})
+	if err != nil {
+		t.Fatalf("archive stream error: %v", err)
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	resp.Body.Close()
+	if ArchiveStreamSHA256(resp) != digest {
+		t.Fatalf("unexpected digest: %q", ArchiveStreamSHA256(resp))
+	}
+
+	sent = strings.Repeat("0", 64)
+	resp, err = repo.ArchiveStream(nil, ArchiveOptions{})
+	if err != nil {
+		t.Fatalf("archive stream error: %v", err)
+	}
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, ErrArchiveChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	resp.Body.Close()
+
+	download, err := repo.DownloadArchive(nil, DownloadArchiveOptions{})
+	if err != nil {
+		t.Fatalf("download error: %v", err)
+	}
+	if _, err := io.ReadAll(download); !errors.Is(err, ErrArchiveChecksumMismatch) {
+		t.Fatalf("expected download checksum mismatch, got %v", err)
+	}
+	download.Close()
+}
+
+func TestArchiveChecksumTrailer(t *testing.T) {
+	content := strings.Repeat("trailer", 50)
+	sum := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", archiveSHA256Header)
+		_, _ = w.Write([]byte(content))
+		w.Header().Set(archiveSHA256Header, digest)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	download, err := repo.DownloadArchive(nil, DownloadArchiveOptions{})
+	if err != nil {
+		t.Fatalf("download error: %v", err)
+	}
+	defer download.Close()
+	if download.SHA256() != "" {
+		t.Fatalf("trailer digest should not be known before EOF")
+	}
+	data, err := io.ReadAll(download)
+	if err != nil || string(data) != content {
+		t.Fatalf("unexpected download: %v", err)
+	}
+	if download.SHA256() != digest {
+		t.Fatalf("unexpected digest: %q", download.SHA256())
+	}
+}
+
+func TestDownloadArchiveParallelChecksum(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	sum := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"tree-1"`)
+		w.Header().Set(archiveSHA256Header, digest)
+		http.ServeContent(w, r, "archive.tar.gz", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "archive.tar.gz"))
+	if err != nil {
+		t.Fatalf("create error: %v", err)
+	}
+	defer file.Close()
+
+	result, err := repo.DownloadArchiveParallel(nil, ParallelArchiveDownloadOptions{Segments: 4, MinSegmentSize: 1}, file)
+	if err != nil {
+		t.Fatalf("download error: %v", err)
+	}
+	if result.Segments != 4 || result.SHA256 != digest {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
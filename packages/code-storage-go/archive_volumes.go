@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ArchiveVolumeOptions configures DownloadArchiveVolumes. MaxVolumeBytes
+// in ArchiveOptions is required; Volume is ignored.
+type ArchiveVolumeOptions struct {
+	ArchiveOptions
+	// NewVolume opens the destination for the volume at index, starting at 0.
+	// Each writer is closed before the next volume is opened.
+	NewVolume func(index int) (io.WriteCloser, error)
+	Retry     DownloadRetryPolicy
+}
+
+// ArchiveVolume describes one volume of a split archive.
+type ArchiveVolume struct {
+	Index  int    `json:"index"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ArchiveVolumeManifest describes how an archive was split. Concatenating
+// the volumes in index order reproduces the original archive, whose digest
+// is SHA256.
+type ArchiveVolumeManifest struct {
+	Format      ArchiveFormat      `json:"format"`
+	Compression ArchiveCompression `json:"compression,omitempty"`
+	CommitSHA   string             `json:"commit_sha,omitempty"`
+	ETag        string             `json:"etag,omitempty"`
+	TotalBytes  int64              `json:"total_bytes"`
+	SHA256      string             `json:"sha256"`
+	Volumes     []ArchiveVolume    `json:"volumes"`
+}
+
+const archiveVolumeCountHeader = "Code-Storage-Archive-Volumes"
+
+// ArchiveStreamVolumeCount reports how many volumes an archive requested with
+// ArchiveOptions.MaxVolumeBytes was split into, or 0 when the server did not
+// say.
+func ArchiveStreamVolumeCount(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get(archiveVolumeCountHeader)))
+	if err != nil || count < 0 {
+		return 0
+	}
+	return count
+}
+
+// DownloadArchiveVolumes has the server split an archive into sequential
+// volumes of at most MaxVolumeBytes each, so very large exports fit proxy
+// and object-store part limits, and fetches each volume as its own
+// resumable request. Volumes after the first are pinned to the commit the
+// first was built from. The returned manifest is JSON-encodable for
+// downstream reassembly.
+func (r *Repo) DownloadArchiveVolumes(ctx context.Context, options ArchiveVolumeOptions) (ArchiveVolumeManifest, error) {
+	if options.MaxVolumeBytes <= 0 {
+		return ArchiveVolumeManifest{}, errors.New("downloadArchiveVolumes maxVolumeBytes must be positive")
+	}
+	if options.NewVolume == nil {
+		return ArchiveVolumeManifest{}, errors.New("downloadArchiveVolumes newVolume is required")
+	}
+
+	archive, err := r.expandArchivePrefix(ctx, options.ArchiveOptions)
+	if err != nil {
+		return ArchiveVolumeManifest{}, err
+	}
+	manifest := ArchiveVolumeManifest{Format: archive.Format, Volumes: []ArchiveVolume{}}
+	if manifest.Format == "" {
+		manifest.Format = ArchiveFormatTarGz
+	}
+
+	total := sha256.New()
+	for index, count := 0, 1; index < count; index++ {
+		archive.Volume = index
+		download, err := r.DownloadArchive(ctx, DownloadArchiveOptions{ArchiveOptions: archive, Retry: options.Retry})
+		if err != nil {
+			return manifest, fmt.Errorf("downloadArchiveVolumes volume %d: %w", index, err)
+		}
+		if index == 0 {
+			count = ArchiveStreamVolumeCount(download.resp)
+			if count == 0 {
+				download.Close()
+				return manifest, errors.New("downloadArchiveVolumes server did not report a volume count")
+			}
+			manifest.Compression = ArchiveStreamCompression(download.resp)
+			if manifest.Format == ArchiveFormatZip {
+				manifest.Compression = ""
+			}
+			manifest.CommitSHA = ArchiveStreamCommitSHA(download.resp)
+			manifest.ETag = download.Header().Get("ETag")
+			if manifest.CommitSHA != "" {
+				archive.Ref = ""
+				archive.SHA = manifest.CommitSHA
+			}
+		}
+
+		size, sum, err := writeArchiveVolume(download, index, options.NewVolume, total)
+		if err != nil {
+			return manifest, err
+		}
+		manifest.Volumes = append(manifest.Volumes, ArchiveVolume{
+			Index:  index,
+			Offset: manifest.TotalBytes,
+			Size:   size,
+			SHA256: sum,
+		})
+		manifest.TotalBytes += size
+	}
+	manifest.SHA256 = hex.EncodeToString(total.Sum(nil))
+	return manifest, nil
+}
+
+func writeArchiveVolume(download *Download, index int, newVolume func(int) (io.WriteCloser, error), total hash.Hash) (int64, string, error) {
+	defer download.Close()
+	writer, err := newVolume(index)
+	if err != nil {
+		return 0, "", fmt.Errorf("downloadArchiveVolumes open volume %d: %w", index, err)
+	}
+	volume := sha256.New()
+	size, err := io.Copy(io.MultiWriter(writer, volume, total), download)
+	closeErr := writer.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return size, "", fmt.Errorf("downloadArchiveVolumes volume %d: %w", index, err)
+	}
+	return size, hex.EncodeToString(volume.Sum(nil)), nil
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type volumeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (v *volumeBuffer) Close() error {
+	v.closed = true
+	return nil
+}
+
+// newVolumeArchiveRepo serves archive split into volumes the way the API
+// does, recording each archive request body.
+func newVolumeArchiveRepo(t *testing.T, archive []byte, requests *[]archiveRequest) (*Repo, func()) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload archiveRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		*requests = append(*requests, payload)
+		if payload.Archive == nil || payload.Archive.MaxVolumeBytes <= 0 || payload.Archive.Volume == nil {
+			t.Errorf("expected volume request, got %+v", payload.Archive)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		size := int(payload.Archive.MaxVolumeBytes)
+		count := (len(archive) + size - 1) / size
+		start := *payload.Archive.Volume * size
+		end := start + size
+		if end > len(archive) {
+			end = len(archive)
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set(commitSHAHeader, "0123456789abcdef0123456789abcdef01234567")
+		w.Header().Set(archiveVolumeCountHeader, strconv.Itoa(count))
+		_, _ = w.Write(archive[start:end])
+	}))
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	return &Repo{ID: "repo", DefaultBranch: "main", client: client}, server.Close
+}
+
+func TestDownloadArchiveVolumes(t *testing.T) {
+	archive := []byte(strings.Repeat("abcdefghij", 5) + "xyz")
+	var requests []archiveRequest
+	repo, closeServer := newVolumeArchiveRepo(t, archive, &requests)
+	defer closeServer()
+
+	var volumes []*volumeBuffer
+	manifest, err := repo.DownloadArchiveVolumes(nil, ArchiveVolumeOptions{
+		ArchiveOptions: ArchiveOptions{Ref: "main", MaxVolumeBytes: 20},
+		NewVolume: func(index int) (io.WriteCloser, error) {
+			if index != len(volumes) {
+				t.Fatalf("unexpected volume index %d", index)
+			}
+			volume := &volumeBuffer{}
+			volumes = append(volumes, volume)
+			return volume, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("download volumes error: %v", err)
+	}
+
+	if len(volumes) != 3 || len(manifest.Volumes) != 3 {
+		t.Fatalf("expected 3 volumes, got %d (%d in manifest)", len(volumes), len(manifest.Volumes))
+	}
+	var joined []byte
+	for i, volume := range volumes {
+		if !volume.closed {
+			t.Fatalf("volume %d was not closed", i)
+		}
+		entry := manifest.Volumes[i]
+		sum := sha256.Sum256(volume.Bytes())
+		if entry.Index != i || entry.Offset != int64(len(joined)) || entry.Size != int64(volume.Len()) || entry.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Fatalf("unexpected manifest entry %d: %+v", i, entry)
+		}
+		joined = append(joined, volume.Bytes()...)
+	}
+	if !bytes.Equal(joined, archive) {
+		t.Fatalf("volumes do not reassemble the archive")
+	}
+	sum := sha256.Sum256(archive)
+	if manifest.TotalBytes != int64(len(archive)) || manifest.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected manifest totals: %+v", manifest)
+	}
+	if manifest.Format != ArchiveFormatTarGz || manifest.Compression != ArchiveCompressionGzip {
+		t.Fatalf("unexpected manifest format: %s %s", manifest.Format, manifest.Compression)
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("expected one request per volume, got %d", len(requests))
+	}
+	for i, request := range requests {
+		if *request.Archive.Volume != i || request.Archive.MaxVolumeBytes != 20 {
+			t.Fatalf("unexpected request %d: %+v", i, request.Archive)
+		}
+	}
+	if requests[0].Ref != "main" || requests[1].Ref != "" || requests[1].SHA != manifest.CommitSHA {
+		t.Fatalf("expected later volumes to be pinned to the first commit, got %+v", requests)
+	}
+}
+
+func TestDownloadArchiveVolumesExactMultiple(t *testing.T) {
+	archive := []byte(strings.Repeat("x", 40))
+	var requests []archiveRequest
+	repo, closeServer := newVolumeArchiveRepo(t, archive, &requests)
+	defer closeServer()
+
+	count := 0
+	manifest, err := repo.DownloadArchiveVolumes(nil, ArchiveVolumeOptions{
+		ArchiveOptions: ArchiveOptions{MaxVolumeBytes: 20},
+		NewVolume: func(index int) (io.WriteCloser, error) {
+			count++
+			return &volumeBuffer{}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("download volumes error: %v", err)
+	}
+	if count != 2 || len(manifest.Volumes) != 2 {
+		t.Fatalf("expected no empty trailing volume, got %d", count)
+	}
+}
+
+func TestDownloadArchiveVolumesRequiresServerSplit(t *testing.T) {
+	repo, closeServer := newArchiveRepo(t, []byte("whole archive"))
+	defer closeServer()
+
+	_, err := repo.DownloadArchiveVolumes(nil, ArchiveVolumeOptions{
+		ArchiveOptions: ArchiveOptions{MaxVolumeBytes: 4},
+		NewVolume: func(index int) (io.WriteCloser, error) {
+			t.Fatalf("no volume should be written without a volume count")
+			return nil, nil
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "volume count") {
+		t.Fatalf("expected missing volume count error, got %v", err)
+	}
+}
+
+func TestDownloadArchiveVolumesValidation(t *testing.T) {
+	repo := &Repo{ID: "repo"}
+	if _, err := repo.DownloadArchiveVolumes(nil, ArchiveVolumeOptions{NewVolume: func(int) (io.WriteCloser, error) { return nil, nil }}); err == nil {
+		t.Fatalf("expected maxVolumeBytes error")
+	}
+	if _, err := repo.DownloadArchiveVolumes(nil, ArchiveVolumeOptions{ArchiveOptions: ArchiveOptions{MaxVolumeBytes: 1}}); err == nil {
+		t.Fatalf("expected newVolume error")
+	}
+}
@@ -0,0 +1,597 @@
+// Package billyfs exposes a code.storage repository as a go-billy
+// filesystem so go-git based tooling can operate without a local clone.
+package billyfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	storage "github.com/pierrecomputer/sdk/packages/code-storage-go"
+)
+
+// Options configures a Filesystem.
+type Options struct {
+	storage.InvocationOptions
+	Ref       string
+	Ephemeral *bool
+	// Commit enables writes. Changes are buffered in memory and committed
+	// with these options when Flush is called. When nil the filesystem is
+	// read-only.
+	Commit *storage.CommitOptions
+}
+
+// Filesystem is a billy.Filesystem backed by a repository ref. billy has no
+// context parameters, so the context passed to New is used for every read.
+type Filesystem struct {
+	ctx     context.Context
+	repo    *storage.Repo
+	options Options
+
+	// flushMu serializes Flush so each commit builds on the previous one;
+	// mu is not held while a commit is sent.
+	flushMu sync.Mutex
+	// headSHA is the commit made by the last Flush, which the next one
+	// expects as the branch head when Commit.ExpectedHeadSHA is set.
+	headSHA string
+
+	mu      sync.Mutex
+	loaded  bool
+	base    map[string]storage.FileWithMetadata
+	overlay map[string]*overlayEntry
+	dirs    map[string]bool
+}
+
+type overlayEntry struct {
+	data    []byte
+	mode    storage.GitFileMode
+	deleted bool
+}
+
+var _ billy.Filesystem = (*Filesystem)(nil)
+
+// New creates a filesystem over repo at options.Ref.
+func New(ctx context.Context, repo *storage.Repo, options Options) *Filesystem {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Filesystem{
+		ctx:     ctx,
+		repo:    repo,
+		options: options,
+		overlay: make(map[string]*overlayEntry),
+		dirs:    make(map[string]bool),
+	}
+}
+
+// Capabilities reports the billy capabilities of the filesystem.
+func (fs *Filesystem) Capabilities() billy.Capability {
+	if fs.options.Commit == nil {
+		return billy.ReadCapability | billy.SeekCapability
+	}
+	return billy.WriteCapability | billy.ReadCapability | billy.ReadAndWriteCapability | billy.SeekCapability | billy.TruncateCapability
+}
+
+// Create creates or truncates the named file.
+func (fs *Filesystem) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+// Open opens the named file for reading.
+func (fs *Filesystem) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the named file. Writes are buffered until the file is
+// closed and committed on Flush.
+func (fs *Filesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	name := cleanPath(filename)
+	writable := flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0
+	if writable && fs.options.Commit == nil {
+		return nil, billy.ErrReadOnly
+	}
+
+	fs.mu.Lock()
+	if err := fs.loadLocked(); err != nil {
+		fs.mu.Unlock()
+		return nil, err
+	}
+	if fs.isDirLocked(name) {
+		fs.mu.Unlock()
+		return nil, &os.PathError{Op: "open", Path: filename, Err: errors.New("is a directory")}
+	}
+	mode, exists := fs.modeLocked(name)
+	overlay := fs.overlay[name]
+	fs.mu.Unlock()
+
+	if !exists && flag&os.O_CREATE == 0 {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+	}
+	if exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrExist}
+	}
+	if !exists {
+		mode = storage.GitFileModeRegular
+		if perm&0o111 != 0 {
+			mode = storage.GitFileModeExecutable
+		}
+	}
+
+	var data []byte
+	if exists && flag&os.O_TRUNC == 0 {
+		if overlay != nil && !overlay.deleted {
+			data = append([]byte(nil), overlay.data...)
+		} else {
+			contents, err := fs.fetch(name)
+			if err != nil {
+				return nil, err
+			}
+			data = contents
+		}
+	}
+
+	return &file{
+		fs:       fs,
+		name:     name,
+		data:     data,
+		mode:     mode,
+		writable: writable,
+		append:   flag&os.O_APPEND != 0,
+		dirty:    writable && (!exists || flag&os.O_TRUNC != 0),
+	}, nil
+}
+
+// Stat returns file info, following a symlink one level when its target
+// exists in the filesystem.
+func (fs *Filesystem) Stat(filename string) (os.FileInfo, error) {
+	info, err := fs.Lstat(filename)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return info, err
+	}
+	target, err := fs.Readlink(filename)
+	if err != nil {
+		return info, nil
+	}
+	if !path.IsAbs(target) {
+		target = path.Join(path.Dir(cleanPath(filename)), target)
+	}
+	if resolved, err := fs.Lstat(target); err == nil {
+		return renamedInfo{FileInfo: resolved, name: info.Name()}, nil
+	}
+	return info, nil
+}
+
+// Lstat returns file info without following symlinks.
+func (fs *Filesystem) Lstat(filename string) (os.FileInfo, error) {
+	name := cleanPath(filename)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.loadLocked(); err != nil {
+		return nil, err
+	}
+	if fs.isDirLocked(name) {
+		return fileInfo{name: path.Base("/" + name), mode: os.ModeDir | 0o755}, nil
+	}
+	mode, exists := fs.modeLocked(name)
+	if !exists {
+		return nil, &os.PathError{Op: "stat", Path: filename, Err: os.ErrNotExist}
+	}
+	size := int64(0)
+	if entry, ok := fs.overlay[name]; ok {
+		size = int64(len(entry.data))
+	} else {
+		size = fs.base[name].Size
+	}
+	return fileInfo{name: path.Base(name), size: size, mode: fileMode(mode)}, nil
+}
+
+// Rename moves a file or directory.
+func (fs *Filesystem) Rename(oldpath, newpath string) error {
+	if fs.options.Commit == nil {
+		return billy.ErrReadOnly
+	}
+	from := cleanPath(oldpath)
+	to := cleanPath(newpath)
+
+	fs.mu.Lock()
+	if err := fs.loadLocked(); err != nil {
+		fs.mu.Unlock()
+		return err
+	}
+	var sources []string
+	if fs.isDirLocked(from) {
+		sources = fs.filesUnderLocked(from)
+	} else if _, ok := fs.modeLocked(from); ok {
+		sources = []string{from}
+	}
+	fs.mu.Unlock()
+	if len(sources) == 0 {
+		return &os.PathError{Op: "rename", Path: oldpath, Err: os.ErrNotExist}
+	}
+
+	for _, source := range sources {
+		target := to + strings.TrimPrefix(source, from)
+		data, mode, err := fs.read(source)
+		if err != nil {
+			return err
+		}
+		fs.mu.Lock()
+		fs.overlay[target] = &overlayEntry{data: data, mode: mode}
+		fs.overlay[source] = &overlayEntry{deleted: true}
+		fs.mu.Unlock()
+	}
+	return nil
+}
+
+// Remove deletes a file or an empty directory.
+func (fs *Filesystem) Remove(filename string) error {
+	if fs.options.Commit == nil {
+		return billy.ErrReadOnly
+	}
+	name := cleanPath(filename)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.loadLocked(); err != nil {
+		return err
+	}
+	if fs.isDirLocked(name) {
+		if len(fs.filesUnderLocked(name)) > 0 {
+			return &os.PathError{Op: "remove", Path: filename, Err: errors.New("directory not empty")}
+		}
+		delete(fs.dirs, name)
+		return nil
+	}
+	if _, ok := fs.modeLocked(name); !ok {
+		return &os.PathError{Op: "remove", Path: filename, Err: os.ErrNotExist}
+	}
+	fs.overlay[name] = &overlayEntry{deleted: true}
+	return nil
+}
+
+// Join joins path elements.
+func (fs *Filesystem) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// TempFile creates a new file in dir with a unique name starting with prefix.
+func (fs *Filesystem) TempFile(dir, prefix string) (billy.File, error) {
+	for i := 0; ; i++ {
+		name := path.Join(dir, prefix+strconv.FormatInt(time.Now().UnixNano(), 36)+strconv.Itoa(i))
+		f, err := fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		return f, err
+	}
+}
+
+// ReadDir lists the entries of a directory.
+func (fs *Filesystem) ReadDir(dirname string) ([]os.FileInfo, error) {
+	dir := cleanPath(dirname)
+	fs.mu.Lock()
+	if err := fs.loadLocked(); err != nil {
+		fs.mu.Unlock()
+		return nil, err
+	}
+	if dir != "" && !fs.isDirLocked(dir) {
+		fs.mu.Unlock()
+		return nil, &os.PathError{Op: "readdir", Path: dirname, Err: os.ErrNotExist}
+	}
+	children := make(map[string]bool)
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	for _, name := range fs.pathsLocked() {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(name, prefix)
+		child, _, isDir := strings.Cut(rest, "/")
+		children[child] = children[child] || isDir
+	}
+	fs.mu.Unlock()
+
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]os.FileInfo, 0, len(names))
+	for _, name := range names {
+		info, err := fs.Lstat(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// MkdirAll records a directory. Git does not track empty directories, so
+// directories without files are not committed.
+func (fs *Filesystem) MkdirAll(filename string, perm os.FileMode) error {
+	if fs.options.Commit == nil {
+		return billy.ErrReadOnly
+	}
+	name := cleanPath(filename)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for name != "" && name != "." {
+		fs.dirs[name] = true
+		name = path.Dir(name)
+		if name == "." {
+			break
+		}
+	}
+	return nil
+}
+
+// Symlink creates a symbolic link.
+func (fs *Filesystem) Symlink(target, link string) error {
+	if fs.options.Commit == nil {
+		return billy.ErrReadOnly
+	}
+	name := cleanPath(link)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.loadLocked(); err != nil {
+		return err
+	}
+	if _, ok := fs.modeLocked(name); ok {
+		return &os.PathError{Op: "symlink", Path: link, Err: os.ErrExist}
+	}
+	fs.overlay[name] = &overlayEntry{data: []byte(target), mode: storage.GitFileModeSymlink}
+	return nil
+}
+
+// Readlink returns the target of a symbolic link.
+func (fs *Filesystem) Readlink(link string) (string, error) {
+	name := cleanPath(link)
+	data, mode, err := fs.read(name)
+	if err != nil {
+		return "", err
+	}
+	if mode != storage.GitFileModeSymlink {
+		return "", &os.PathError{Op: "readlink", Path: link, Err: errors.New("not a symlink")}
+	}
+	return string(data), nil
+}
+
+// Chroot returns a filesystem rooted at the given directory.
+func (fs *Filesystem) Chroot(dir string) (billy.Filesystem, error) {
+	return chroot.New(fs, dir), nil
+}
+
+// Root returns the filesystem root.
+func (fs *Filesystem) Root() string {
+	return "/"
+}
+
+// Flush commits buffered changes through a CommitBuilder and resets the
+// overlay. It returns a zero CommitResult when there is nothing to commit.
+// Writes made while the commit is being sent stay buffered for the next
+// Flush, and when Commit.ExpectedHeadSHA is set each later Flush expects
+// the commit made by the previous one.
+func (fs *Filesystem) Flush(ctx context.Context) (storage.CommitResult, error) {
+	if fs.options.Commit == nil {
+		return storage.CommitResult{}, billy.ErrReadOnly
+	}
+
+	fs.flushMu.Lock()
+	defer fs.flushMu.Unlock()
+
+	commit := *fs.options.Commit
+	if commit.ExpectedHeadSHA != "" && fs.headSHA != "" {
+		commit.ExpectedHeadSHA = fs.headSHA
+	}
+
+	fs.mu.Lock()
+	snapshot := make(map[string]*overlayEntry, len(fs.overlay))
+	names := make([]string, 0, len(fs.overlay))
+	deletes := make(map[string]bool)
+	for name, entry := range fs.overlay {
+		snapshot[name] = entry
+		names = append(names, name)
+		if _, ok := fs.base[name]; ok && entry.deleted {
+			deletes[name] = true
+		}
+	}
+	fs.mu.Unlock()
+	if len(names) == 0 {
+		return storage.CommitResult{}, nil
+	}
+
+	builder, err := fs.repo.CreateCommit(commit)
+	if err != nil {
+		return storage.CommitResult{}, err
+	}
+	sort.Strings(names)
+	changes := 0
+	for _, name := range names {
+		entry := snapshot[name]
+		if entry.deleted {
+			if deletes[name] {
+				builder = builder.DeletePath(name)
+				changes++
+			}
+			continue
+		}
+		builder = builder.AddFileFromBytes(name, entry.data, &storage.CommitFileOptions{Mode: entry.mode})
+		changes++
+	}
+	if changes == 0 {
+		fs.clearFlushed(snapshot, false)
+		return storage.CommitResult{}, nil
+	}
+
+	result, err := builder.Send(ctx)
+	if err != nil {
+		return storage.CommitResult{}, err
+	}
+	fs.headSHA = result.CommitSHA
+	fs.clearFlushed(snapshot, true)
+	return result, nil
+}
+
+// clearFlushed drops the overlay entries that were flushed, keeping any
+// staged again since the snapshot was taken.
+func (fs *Filesystem) clearFlushed(snapshot map[string]*overlayEntry, committed bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for name, entry := range snapshot {
+		if fs.overlay[name] == entry {
+			delete(fs.overlay, name)
+		}
+	}
+	if committed {
+		fs.loaded = false
+	}
+}
+
+func (fs *Filesystem) loadLocked() error {
+	if fs.loaded {
+		return nil
+	}
+	result, err := fs.repo.ListFilesWithMetadata(fs.ctx, storage.ListFilesWithMetadataOptions{
+		InvocationOptions: fs.options.InvocationOptions,
+		Ref:               fs.options.Ref,
+		Ephemeral:         fs.options.Ephemeral,
+	})
+	if err != nil {
+		return err
+	}
+	fs.base = make(map[string]storage.FileWithMetadata, len(result.Files))
+	for _, entry := range result.Files {
+		fs.base[cleanPath(entry.Path)] = entry
+	}
+	fs.loaded = true
+	return nil
+}
+
+func (fs *Filesystem) modeLocked(name string) (storage.GitFileMode, bool) {
+	if entry, ok := fs.overlay[name]; ok {
+		if entry.deleted {
+			return "", false
+		}
+		return entry.mode, true
+	}
+	if entry, ok := fs.base[name]; ok {
+		return storage.GitFileMode(entry.Mode), true
+	}
+	return "", false
+}
+
+func (fs *Filesystem) pathsLocked() []string {
+	paths := make([]string, 0, len(fs.base)+len(fs.overlay)+len(fs.dirs))
+	for name := range fs.base {
+		if entry, ok := fs.overlay[name]; ok && entry.deleted {
+			continue
+		}
+		paths = append(paths, name)
+	}
+	for name, entry := range fs.overlay {
+		if _, ok := fs.base[name]; ok || entry.deleted {
+			continue
+		}
+		paths = append(paths, name)
+	}
+	for name := range fs.dirs {
+		paths = append(paths, name+"/")
+	}
+	return paths
+}
+
+func (fs *Filesystem) isDirLocked(name string) bool {
+	if name == "" || fs.dirs[name] {
+		return true
+	}
+	prefix := name + "/"
+	for _, candidate := range fs.pathsLocked() {
+		if strings.HasPrefix(candidate, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs *Filesystem) filesUnderLocked(dir string) []string {
+	prefix := dir + "/"
+	var files []string
+	for _, candidate := range fs.pathsLocked() {
+		if strings.HasPrefix(candidate, prefix) && !strings.HasSuffix(candidate, "/") {
+			files = append(files, candidate)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (fs *Filesystem) read(name string) ([]byte, storage.GitFileMode, error) {
+	fs.mu.Lock()
+	if err := fs.loadLocked(); err != nil {
+		fs.mu.Unlock()
+		return nil, "", err
+	}
+	mode, exists := fs.modeLocked(name)
+	entry := fs.overlay[name]
+	fs.mu.Unlock()
+	if !exists {
+		return nil, "", &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if entry != nil {
+		return append([]byte(nil), entry.data...), mode, nil
+	}
+	data, err := fs.fetch(name)
+	return data, mode, err
+}
+
+func (fs *Filesystem) fetch(name string) ([]byte, error) {
+	follow := false
+	resp, err := fs.repo.FileStream(fs.ctx, storage.GetFileOptions{
+		InvocationOptions: fs.options.InvocationOptions,
+		Path:              name,
+		Ref:               fs.options.Ref,
+		Ephemeral:         fs.options.Ephemeral,
+		FollowSymlinks:    &follow,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (fs *Filesystem) stage(name string, data []byte, mode storage.GitFileMode) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.overlay[name] = &overlayEntry{data: data, mode: mode}
+}
+
+func cleanPath(name string) string {
+	cleaned := path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	return strings.TrimPrefix(cleaned, "/")
+}
+
+func fileMode(mode storage.GitFileMode) os.FileMode {
+	switch mode {
+	case storage.GitFileModeExecutable:
+		return 0o755
+	case storage.GitFileModeSymlink:
+		return os.ModeSymlink | 0o777
+	case storage.GitFileModeSubmodule:
+		return os.ModeIrregular
+	default:
+		return 0o644
+	}
+}
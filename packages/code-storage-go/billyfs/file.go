@@ -0,0 +1,164 @@
+package billyfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	storage "github.com/pierrecomputer/sdk/packages/code-storage-go"
+)
+
+// file is an in-memory billy.File. Writable files are staged into the
+// filesystem overlay on Close.
+type file struct {
+	fs       *Filesystem
+	name     string
+	data     []byte
+	pos      int64
+	mode     storage.GitFileMode
+	writable bool
+	append   bool
+	dirty    bool
+	closed   bool
+}
+
+var errFileClosed = errors.New("file already closed")
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	if f.pos >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[f.pos:])
+	f.pos += int64(n)
+	return n, nil
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = f.pos + offset
+	case io.SeekEnd:
+		next = int64(len(f.data)) + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.pos = next
+	return next, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	if !f.writable {
+		return 0, os.ErrPermission
+	}
+	if f.append {
+		f.pos = int64(len(f.data))
+	}
+	end := f.pos + int64(len(p))
+	if end > int64(len(f.data)) {
+		grown := make([]byte, end)
+		copy(grown, f.data)
+		f.data = grown
+	}
+	copy(f.data[f.pos:], p)
+	f.pos = end
+	f.dirty = true
+	return len(p), nil
+}
+
+func (f *file) Truncate(size int64) error {
+	if f.closed {
+		return errFileClosed
+	}
+	if !f.writable {
+		return os.ErrPermission
+	}
+	if size < 0 {
+		return errors.New("negative size")
+	}
+	if size <= int64(len(f.data)) {
+		f.data = f.data[:size]
+	} else {
+		grown := make([]byte, size)
+		copy(grown, f.data)
+		f.data = grown
+	}
+	f.dirty = true
+	return nil
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return errFileClosed
+	}
+	f.closed = true
+	if f.writable && f.dirty {
+		f.fs.stage(f.name, f.data, f.mode)
+	}
+	return nil
+}
+
+func (f *file) Lock() error {
+	return nil
+}
+
+func (f *file) Unlock() error {
+	return nil
+}
+
+type fileInfo struct {
+	name string
+	size int64
+	mode os.FileMode
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) Mode() os.FileMode  { return i.mode }
+func (i fileInfo) ModTime() time.Time { return time.Time{} }
+func (i fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i fileInfo) Sys() interface{}   { return nil }
+
+type renamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i renamedInfo) Name() string { return path.Base(i.name) }
@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlobCache stores blob contents keyed by immutable blob SHA. It is used by
+// GetBlob only: FileStream and other path-based reads do not know the blob
+// SHA before the request and always go to the API.
+type BlobCache interface {
+	// Open returns the cached contents for sha, or false on a miss.
+	Open(sha string) (io.ReadCloser, bool)
+	// Put stores the contents read from r under sha. Implementations must
+	// discard the entry if r returns an error before io.EOF.
+	Put(sha string, r io.Reader) error
+}
+
+// DiskBlobCacheOptions configures a DiskBlobCache.
+type DiskBlobCacheOptions struct {
+	Dir      string
+	MaxBytes int64
+}
+
+// DiskBlobCache is a persistent BlobCache with size-bounded LRU eviction.
+// Entries survive process restarts; recency is tracked via file mtimes.
+type DiskBlobCache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+}
+
+type diskBlobEntry struct {
+	sha  string
+	size int64
+}
+
+// NewDiskBlobCache opens (or creates) a blob cache rooted at options.Dir.
+func NewDiskBlobCache(options DiskBlobCacheOptions) (*DiskBlobCache, error) {
+	dir := strings.TrimSpace(options.Dir)
+	if dir == "" {
+		return nil, errors.New("disk blob cache dir is required")
+	}
+	if options.MaxBytes <= 0 {
+		return nil, errors.New("disk blob cache maxBytes must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	cache := &DiskBlobCache{
+		dir:      dir,
+		maxBytes: options.MaxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+	if err := cache.load(); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func (c *DiskBlobCache) load() error {
+	type found struct {
+		sha     string
+		size    int64
+		modTime time.Time
+	}
+	var existing []found
+	err := filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if strings.HasPrefix(name, ".tmp-") {
+			_ = os.Remove(path)
+			return nil
+		}
+		if !isHexObjectID(name) {
+			return nil
+		}
+		existing = append(existing, found{sha: name, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(existing, func(i, j int) bool {
+		return existing[i].modTime.After(existing[j].modTime)
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range existing {
+		c.entries[entry.sha] = c.lru.PushBack(&diskBlobEntry{sha: entry.sha, size: entry.size})
+		c.size += entry.size
+	}
+	c.evictLocked()
+	return nil
+}
+
+func (c *DiskBlobCache) path(sha string) string {
+	return filepath.Join(c.dir, sha[:2], sha)
+}
+
+// Open returns the cached blob contents for sha.
+func (c *DiskBlobCache) Open(sha string) (io.ReadCloser, bool) {
+	sha = strings.ToLower(strings.TrimSpace(sha))
+	if !isHexObjectID(sha) {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	elem, ok := c.entries[sha]
+	if ok {
+		c.lru.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	path := c.path(sha)
+	file, err := os.Open(path)
+	if err != nil {
+		c.remove(sha)
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return file, true
+}
+
+// Put stores the blob contents for sha, evicting least recently used
+// entries to stay within MaxBytes.
+func (c *DiskBlobCache) Put(sha string, r io.Reader) error {
+	sha = strings.ToLower(strings.TrimSpace(sha))
+	if !isHexObjectID(sha) {
+		return errors.New("disk blob cache requires a hex object sha")
+	}
+
+	dir := filepath.Dir(c.path(sha))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	// Read at most one byte past the limit so an oversized blob is
+	// abandoned as soon as it is known not to fit.
+	size, err := io.Copy(tmp, io.LimitReader(r, c.maxBytes+1))
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && size > c.maxBytes {
+		err = errors.New("blob exceeds disk blob cache size")
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path(sha)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[sha]; ok {
+		entry := elem.Value.(*diskBlobEntry)
+		c.size -= entry.size
+		entry.size = size
+		c.lru.MoveToFront(elem)
+	} else {
+		c.entries[sha] = c.lru.PushFront(&diskBlobEntry{sha: sha, size: size})
+	}
+	c.size += size
+	c.evictLocked()
+	return nil
+}
+
+// Size returns the total bytes currently held by the cache.
+func (c *DiskBlobCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *DiskBlobCache) remove(sha string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[sha]; ok {
+		c.size -= elem.Value.(*diskBlobEntry).size
+		c.lru.Remove(elem)
+		delete(c.entries, sha)
+	}
+}
+
+func (c *DiskBlobCache) evictLocked() {
+	for c.size > c.maxBytes {
+		elem := c.lru.Back()
+		if elem == nil {
+			return
+		}
+		entry := elem.Value.(*diskBlobEntry)
+		_ = os.Remove(c.path(entry.sha))
+		c.lru.Remove(elem)
+		delete(c.entries, entry.sha)
+		c.size -= entry.size
+	}
+}
+
+// cachedBlobResponse builds a synthetic response for a blob cache hit.
+func cachedBlobResponse(body io.ReadCloser) *http.Response {
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Header:        header,
+		Body:          body,
+		ContentLength: -1,
+	}
+}
+
+// blobCacheTee copies a response body into a BlobCache as it is read. The
+// entry is committed only once the body has been read to io.EOF.
+type blobCacheTee struct {
+	body     io.ReadCloser
+	writer   *io.PipeWriter
+	done     chan struct{}
+	finished bool
+}
+
+var errBlobCacheAborted = errors.New("blob read aborted before EOF")
+
+func newBlobCacheTee(cache BlobCache, sha string, body io.ReadCloser) *blobCacheTee {
+	pipeReader, pipeWriter := io.Pipe()
+	tee := &blobCacheTee{body: body, writer: pipeWriter, done: make(chan struct{})}
+	go func() {
+		defer close(tee.done)
+		err := cache.Put(sha, pipeReader)
+		if err == nil {
+			err = io.ErrClosedPipe
+		}
+		// Unblock the writer if the cache stopped reading early.
+		_ = pipeReader.CloseWithError(err)
+	}()
+	return tee
+}
+
+func (t *blobCacheTee) Read(p []byte) (int, error) {
+	n, err := t.body.Read(p)
+	if n > 0 && !t.finished {
+		if _, werr := t.writer.Write(p[:n]); werr != nil {
+			t.finished = true
+		}
+	}
+	if err == io.EOF && !t.finished {
+		_ = t.writer.Close()
+		<-t.done
+		t.finished = true
+	}
+	return n, err
+}
+
+func (t *blobCacheTee) Close() error {
+	if !t.finished {
+		_ = t.writer.CloseWithError(errBlobCacheAborted)
+		t.finished = true
+	}
+	return t.body.Close()
+}
+
+func isHexObjectID(value string) bool {
+	if len(value) != 40 && len(value) != 64 {
+		return false
+	}
+	for _, ch := range value {
+		if (ch < '0' || ch > '9') && (ch < 'a' || ch > 'f') {
+			return false
+		}
+	}
+	return true
+}
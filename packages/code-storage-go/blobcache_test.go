@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testBlobSHA1 = "1111111111111111111111111111111111111111"
+	testBlobSHA2 = "2222222222222222222222222222222222222222"
+	testBlobSHA3 = "3333333333333333333333333333333333333333"
+)
+
+func readCachedBlob(t *testing.T, cache *DiskBlobCache, sha string) (string, bool) {
+	t.Helper()
+	body, ok := cache.Open(sha)
+	if !ok {
+		return "", false
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read cached blob: %v", err)
+	}
+	return string(data), true
+}
+
+func TestDiskBlobCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewDiskBlobCache(DiskBlobCacheOptions{Dir: dir, MaxBytes: 10})
+	if err != nil {
+		t.Fatalf("cache error: %v", err)
+	}
+
+	if err := cache.Put(testBlobSHA1, strings.NewReader("aaaa")); err != nil {
+		t.Fatalf("put error: %v", err)
+	}
+	if err := cache.Put(testBlobSHA2, strings.NewReader("bbbb")); err != nil {
+		t.Fatalf("put error: %v", err)
+	}
+	if _, ok := readCachedBlob(t, cache, testBlobSHA1); !ok {
+		t.Fatalf("expected cache hit")
+	}
+	if err := cache.Put(testBlobSHA3, strings.NewReader("cccc")); err != nil {
+		t.Fatalf("put error: %v", err)
+	}
+
+	if _, ok := readCachedBlob(t, cache, testBlobSHA2); ok {
+		t.Fatalf("expected least recently used blob to be evicted")
+	}
+	if got, ok := readCachedBlob(t, cache, testBlobSHA1); !ok || got != "aaaa" {
+		t.Fatalf("expected recently used blob to remain, got %q", got)
+	}
+	if cache.Size() != 8 {
+		t.Fatalf("unexpected cache size: %d", cache.Size())
+	}
+
+	reopened, err := NewDiskBlobCache(DiskBlobCacheOptions{Dir: dir, MaxBytes: 10})
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	if got, ok := readCachedBlob(t, reopened, testBlobSHA3); !ok || got != "cccc" {
+		t.Fatalf("expected blob to persist across instances, got %q", got)
+	}
+}
+
+func TestDiskBlobCacheRejectsInvalidSHA(t *testing.T) {
+	cache, err := NewDiskBlobCache(DiskBlobCacheOptions{Dir: t.TempDir(), MaxBytes: 10})
+	if err != nil {
+		t.Fatalf("cache error: %v", err)
+	}
+	if err := cache.Put("../escape", strings.NewReader("x")); err == nil {
+		t.Fatalf("expected invalid sha error")
+	}
+	if err := cache.Put(testBlobSHA1, strings.NewReader("this blob is too large")); err == nil {
+		t.Fatalf("expected oversized blob error")
+	}
+	if _, ok := cache.Open(testBlobSHA1); ok {
+		t.Fatalf("expected oversized blob to be discarded")
+	}
+
+	// An oversized stream is abandoned just past the limit, not drained.
+	stream := &countingReader{reader: endlessReader{}}
+	if err := cache.Put(testBlobSHA2, stream); err == nil {
+		t.Fatalf("expected oversized stream error")
+	}
+	if stream.n > 11+32*1024 {
+		t.Fatalf("read %d bytes of an oversized stream", stream.n)
+	}
+}
+
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func TestGetBlobUsesBlobCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte("blob contents"))
+	}))
+	defer server.Close()
+
+	cache, err := NewDiskBlobCache(DiskBlobCacheOptions{Dir: t.TempDir(), MaxBytes: 1024})
+	if err != nil {
+		t.Fatalf("cache error: %v", err)
+	}
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL, BlobCache: cache})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	for i := 0; i < 2; i++ {
+		resp, err := repo.GetBlob(nil, GetBlobOptions{SHA: testBlobSHA1})
+		if err != nil {
+			t.Fatalf("get blob error: %v", err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if string(data) != "blob contents" {
+			t.Fatalf("unexpected blob body: %s", data)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("expected one request, got %d", requests)
+	}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetChurnStatsOptions configures GetChurnStats. Since and Until bound the
+// commit dates considered; zero values leave that side open.
+type GetChurnStatsOptions struct {
+	InvocationOptions
+	// Ref is where the history walk starts. Defaults to the default branch.
+	Ref        string
+	Since      time.Time
+	Until      time.Time
+	PathPrefix string
+	// Limit caps the number of files and of directories returned, keeping
+	// the highest-churn entries. Zero uses the server default.
+	Limit int
+}
+
+// GetChurnStatsResult reports change activity per file and per directory,
+// each ordered by commit count, highest first.
+type GetChurnStatsResult struct {
+	Ref         string
+	Files       []ChurnStats
+	Directories []ChurnStats
+}
+
+// ChurnStats summarizes how often a path changed in the window. Directory
+// entries aggregate every file beneath them.
+type ChurnStats struct {
+	Path          string
+	Commits       int
+	Authors       int
+	Additions     int
+	Deletions     int
+	LastChangedAt time.Time
+}
+
+// GetChurnStats returns change frequency, author counts and line churn for
+// the files and directories under PathPrefix, computed server-side so
+// hotspot dashboards do not need to replay history through GetCommitDiff.
+func (r *Repo) GetChurnStats(ctx context.Context, options GetChurnStatsOptions) (GetChurnStatsResult, error) {
+	if !options.Since.IsZero() && !options.Until.IsZero() && options.Until.Before(options.Since) {
+		return GetChurnStatsResult{}, errors.New("getChurnStats until must not be before since")
+	}
+	if options.Limit < 0 {
+		return GetChurnStatsResult{}, errors.New("getChurnStats limit must be non-negative")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return GetChurnStatsResult{}, err
+	}
+
+	params := url.Values{}
+	if ref := strings.TrimSpace(options.Ref); ref != "" {
+		params.Set("ref", ref)
+	}
+	if !options.Since.IsZero() {
+		params.Set("since", options.Since.UTC().Format(time.RFC3339))
+	}
+	if !options.Until.IsZero() {
+		params.Set("until", options.Until.UTC().Format(time.RFC3339))
+	}
+	if prefix := strings.Trim(strings.TrimSpace(options.PathPrefix), "/"); prefix != "" {
+		params.Set("path_prefix", prefix)
+	}
+	if options.Limit > 0 {
+		params.Set("limit", strconv.Itoa(options.Limit))
+	}
+	if len(params) == 0 {
+		params = nil
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/churn", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return GetChurnStatsResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload churnStatsResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return GetChurnStatsResult{}, err
+	}
+
+	return GetChurnStatsResult{
+		Ref:         payload.Ref,
+		Files:       transformChurnStats(payload.Files),
+		Directories: transformChurnStats(payload.Directories),
+	}, nil
+}
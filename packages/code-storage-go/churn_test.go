@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetChurnStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/churn" || r.Method != http.MethodGet {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("since") != "2024-01-01T00:00:00Z" || q.Get("until") != "2024-02-01T00:00:00Z" || q.Get("path_prefix") != "src" || q.Get("ref") != "" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ref":"main","files":[{"path":"src/repo.go","commits":12,"authors":3,"additions":340,"deletions":120,"last_changed_at":"2024-01-30T09:00:00Z"}],"directories":[{"path":"src","commits":15,"authors":4,"additions":400,"deletions":150,"last_changed_at":"2024-01-30T09:00:00Z"}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	result, err := repo.GetChurnStats(nil, GetChurnStatsOptions{Since: since, Until: since.AddDate(0, 1, 0), PathPrefix: "src/"})
+	if err != nil {
+		t.Fatalf("get churn stats error: %v", err)
+	}
+	if result.Ref != "main" || len(result.Files) != 1 || len(result.Directories) != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	file := result.Files[0]
+	if file.Path != "src/repo.go" || file.Commits != 12 || file.Authors != 3 || file.Additions != 340 || file.Deletions != 120 || file.LastChangedAt.IsZero() {
+		t.Fatalf("unexpected file stats: %+v", file)
+	}
+
+	if _, err := repo.GetChurnStats(nil, GetChurnStatsOptions{Since: since, Until: since.Add(-time.Hour)}); err == nil {
+		t.Fatalf("expected window validation error")
+	}
+}
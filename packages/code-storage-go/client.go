@@ -6,6 +6,8 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -35,6 +37,16 @@ func NewClient(options Options) (*Client, error) {
 	if storageBaseURL == "" {
 		storageBaseURL = DefaultStorageBaseURL(options.Name)
 	}
+	storageScheme := strings.ToLower(strings.TrimSpace(options.StorageScheme))
+	if storageScheme == "" {
+		storageScheme = "https"
+	}
+	if storageScheme != "https" && storageScheme != "http" {
+		return nil, errors.New("git storage scheme must be http or https")
+	}
+	if options.StoragePort < 0 || options.StoragePort > 65535 {
+		return nil, errors.New("git storage port must be a valid TCP port")
+	}
 	version := options.APIVersion
 	if version == 0 {
 		version = DefaultAPIVersion
@@ -47,17 +59,27 @@ func NewClient(options Options) (*Client, error) {
 
 	client := &Client{
 		options: Options{
-			Name:           options.Name,
-			Key:            options.Key,
-			APIBaseURL:     apiBaseURL,
-			StorageBaseURL: storageBaseURL,
-			APIVersion:     version,
-			DefaultTTL:     options.DefaultTTL,
-			HTTPClient:     options.HTTPClient,
+			Name:             options.Name,
+			Key:              options.Key,
+			APIBaseURL:       apiBaseURL,
+			StorageBaseURL:   storageBaseURL,
+			StorageScheme:    storageScheme,
+			StoragePort:      options.StoragePort,
+			APIVersion:       version,
+			DefaultTTL:       options.DefaultTTL,
+			HTTPClient:       options.HTTPClient,
+			RateLimiter:      options.RateLimiter,
+			BlobCache:        options.BlobCache,
+			Endpoints:        options.Endpoints,
+			FailoverWrites:   options.FailoverWrites,
+			FailoverCooldown: options.FailoverCooldown,
+			DeduplicateReads: options.DeduplicateReads,
+			DryRun:           options.DryRun,
+			OnDryRun:         options.OnDryRun,
 		},
 		privateKey: privateKey,
 	}
-	client.api = newAPIFetcher(apiBaseURL, version, options.HTTPClient)
+	client.api = newAPIFetcher(client.options)
 	return client, nil
 }
 
@@ -83,7 +105,7 @@ func (c *Client) CreateRepo(ctx context.Context, options CreateRepoOptions) (*Re
 		repoID = uuid.NewString()
 	}
 
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := c.generateJWT(repoID, RemoteURLOptions{Permissions: []Permission{PermissionRepoWrite}, TTL: ttl})
 	if err != nil {
 		return nil, err
@@ -139,20 +161,21 @@ func (c *Client) CreateRepo(ctx context.Context, options CreateRepoOptions) (*Re
 		}
 	}
 
+	branchFallback := false
 	if resolvedDefaultBranch == "" {
 		if strings.TrimSpace(options.DefaultBranch) != "" {
 			resolvedDefaultBranch = options.DefaultBranch
 		} else if !isFork {
 			resolvedDefaultBranch = "main"
+			branchFallback = !options.SkipOrgDefaults
 		}
 	}
 
-	var body interface{}
-	if baseRepo != nil || resolvedDefaultBranch != "" {
-		body = &createRepoRequest{
-			BaseRepo:      baseRepo,
-			DefaultBranch: resolvedDefaultBranch,
-		}
+	body := &createRepoRequest{
+		BaseRepo:              baseRepo,
+		DefaultBranch:         resolvedDefaultBranch,
+		DefaultBranchFallback: branchFallback,
+		SkipOrgDefaults:       options.SkipOrgDefaults,
 	}
 
 	resp, err := c.api.post(ctx, "repos", nil, body, jwtToken, &requestOptions{allowedStatus: map[int]bool{409: true}})
@@ -164,19 +187,45 @@ func (c *Client) CreateRepo(ctx context.Context, options CreateRepoOptions) (*Re
 		return nil, errors.New("repository already exists")
 	}
 
+	// The server reports the branch it created, which may be the org
+	// default or, for forks, the base repository's default branch.
+	var payload struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := decodeJSON(resp, &payload); err == nil && strings.TrimSpace(payload.DefaultBranch) != "" {
+		resolvedDefaultBranch = strings.TrimSpace(payload.DefaultBranch)
+	}
+
+	waitBranch := resolvedDefaultBranch
 	if resolvedDefaultBranch == "" {
 		resolvedDefaultBranch = "main"
 	}
-	return c.Repo(RepoOptions{
+	repo, err := c.Repo(RepoOptions{
 		ID:            repoID,
 		DefaultBranch: resolvedDefaultBranch,
 		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
 	})
+	if err != nil {
+		return nil, err
+	}
+	if options.WaitReady != nil && baseRepo != nil {
+		if err := repo.waitReady(ctx, waitBranch, *options.WaitReady); err != nil {
+			return nil, err
+		}
+	}
+	return repo, nil
 }
 
 // ListRepos lists repositories for the org.
 func (c *Client) ListRepos(ctx context.Context, options ListReposOptions) (ListReposResult, error) {
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	if token := options.Resume; token != nil {
+		if err := checkResumeToken(token, ResumeTokenRepos, "", options.Cursor); err != nil {
+			return ListReposResult{}, err
+		}
+		options.Cursor = token.Cursor
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := c.generateJWT("org", RemoteURLOptions{Permissions: []Permission{PermissionOrgRead}, TTL: ttl})
 	if err != nil {
 		return ListReposResult{}, err
@@ -193,7 +242,7 @@ func (c *Client) ListRepos(ctx context.Context, options ListReposOptions) (ListR
 		params = nil
 	}
 
-	resp, err := c.api.get(ctx, "repos", params, jwtToken, nil)
+	resp, err := c.api.get(ctx, "repos", params, jwtToken, readRequestOptions(options.InvocationOptions))
 	if err != nil {
 		return ListReposResult{}, err
 	}
@@ -214,6 +263,7 @@ func (c *Client) ListRepos(ctx context.Context, options ListReposOptions) (ListR
 			URL:           repo.URL,
 			DefaultBranch: repo.DefaultBranch,
 			CreatedAt:     repo.CreatedAt,
+			LegalHold:     repo.LegalHold.hold(),
 		}
 		if repo.BaseRepo != nil {
 			entry.BaseRepo = &RepoBaseInfo{
@@ -224,6 +274,9 @@ func (c *Client) ListRepos(ctx context.Context, options ListReposOptions) (ListR
 		}
 		result.Repos = append(result.Repos, entry)
 	}
+	if result.HasMore && result.NextCursor != "" {
+		result.ResumeToken = &ResumeToken{Kind: ResumeTokenRepos, Cursor: result.NextCursor}
+	}
 
 	return result, nil
 }
@@ -289,13 +342,13 @@ func (c *Client) DeleteRepo(ctx context.Context, options DeleteRepoOptions) (Del
 	if strings.TrimSpace(options.ID) == "" {
 		return DeleteRepoResult{}, errors.New("deleteRepo id is required")
 	}
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := c.generateJWT(options.ID, RemoteURLOptions{Permissions: []Permission{PermissionRepoWrite}, TTL: ttl})
 	if err != nil {
 		return DeleteRepoResult{}, err
 	}
 
-	resp, err := c.api.delete(ctx, "repos/delete", nil, nil, jwtToken, &requestOptions{allowedStatus: map[int]bool{404: true, 409: true}})
+	resp, err := c.api.delete(ctx, "repos/delete", nil, nil, jwtToken, &requestOptions{allowedStatus: map[int]bool{404: true, 409: true, 423: true}})
 	if err != nil {
 		return DeleteRepoResult{}, err
 	}
@@ -307,6 +360,9 @@ func (c *Client) DeleteRepo(ctx context.Context, options DeleteRepoOptions) (Del
 	if resp.StatusCode == 409 {
 		return DeleteRepoResult{}, errors.New("repository already deleted")
 	}
+	if resp.StatusCode == 423 {
+		return DeleteRepoResult{}, errors.New("repository is under legal hold")
+	}
 
 	var payload struct {
 		RepoID  string `json:"repo_id"`
@@ -319,13 +375,29 @@ func (c *Client) DeleteRepo(ctx context.Context, options DeleteRepoOptions) (Del
 	return DeleteRepoResult{RepoID: payload.RepoID, Message: payload.Message}, nil
 }
 
+// WithActor returns a client whose tokens name actor, such as an end user
+// or agent ID, as their subject, so server logs and audit trails attribute
+// its operations to that actor. Repos opened from the returned client
+// inherit the actor. The receiver is not modified.
+func (c *Client) WithActor(actor string) *Client {
+	clone := *c
+	clone.actor = strings.TrimSpace(actor)
+	return &clone
+}
+
 func (c *Client) generateJWT(repoID string, options RemoteURLOptions) (string, error) {
-	permissions := options.Permissions
+	token, _, err := c.signJWT(repoID, options.Permissions, options.TTL, options.Actor, nil)
+	return token, err
+}
+
+// signJWT signs a token for repoID. The subject is actor, falling back to
+// the client's actor and then "@pierre/storage". extra claims are added
+// as-is and must not collide with the standard ones.
+func (c *Client) signJWT(repoID string, permissions []Permission, ttl time.Duration, actor string, extra map[string]interface{}) (string, time.Time, error) {
 	if len(permissions) == 0 {
 		permissions = []Permission{PermissionGitWrite, PermissionGitRead}
 	}
 
-	ttl := options.TTL
 	if ttl <= 0 {
 		if c.options.DefaultTTL > 0 {
 			ttl = c.options.DefaultTTL
@@ -334,18 +406,33 @@ func (c *Client) generateJWT(repoID string, options RemoteURLOptions) (string, e
 		}
 	}
 
+	subject := strings.TrimSpace(actor)
+	if subject == "" {
+		subject = c.actor
+	}
+	if subject == "" {
+		subject = "@pierre/storage"
+	}
+
 	issuedAt := time.Now()
+	expiresAt := issuedAt.Add(ttl)
 	claims := jwt.MapClaims{
 		"iss":    c.options.Name,
-		"sub":    "@pierre/storage",
+		"sub":    subject,
 		"repo":   repoID,
 		"scopes": permissions,
 		"iat":    issuedAt.Unix(),
-		"exp":    issuedAt.Add(ttl).Unix(),
+		"exp":    expiresAt.Unix(),
+	}
+	for key, value := range extra {
+		claims[key] = value
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
-	return token.SignedString(c.privateKey)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(c.privateKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, time.Unix(expiresAt.Unix(), 0), nil
 }
 
 func parseECPrivateKey(pemBytes []byte) (*ecdsa.PrivateKey, error) {
@@ -368,9 +455,90 @@ func parseECPrivateKey(pemBytes []byte) (*ecdsa.PrivateKey, error) {
 	return nil, errors.New("unsupported private key format")
 }
 
-func resolveInvocationTTL(options InvocationOptions, defaultTTL time.Duration) time.Duration {
+// contextTTLMargin is added to a context-derived TTL so the token outlives
+// the final request of the operation.
+const contextTTLMargin = 30 * time.Second
+
+func resolveInvocationTTL(ctx context.Context, options InvocationOptions, defaultTTL time.Duration) time.Duration {
 	if options.TTL > 0 {
 		return options.TTL
 	}
-	return defaultTTL
+	if !options.TTLFromContext {
+		return defaultTTL
+	}
+	maxTTL := options.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = defaultTTL
+	}
+	if ctx == nil {
+		return maxTTL
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return maxTTL
+	}
+	ttl := time.Until(deadline) + contextTTLMargin
+	if ttl < contextTTLMargin {
+		ttl = contextTTLMargin
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return ttl
+}
+
+const (
+	defaultWaitReadyTimeout      = 60 * time.Second
+	defaultWaitReadyPollInterval = 500 * time.Millisecond
+)
+
+// waitReady polls until branch (or the server default when empty) resolves.
+func (r *Repo) waitReady(ctx context.Context, branch string, options WaitReadyOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = defaultWaitReadyTimeout
+	}
+	interval := options.PollInterval
+	if interval <= 0 {
+		interval = defaultWaitReadyPollInterval
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lastErr error
+	for {
+		_, err := r.ListCommits(ctx, ListCommitsOptions{Branch: branch, Limit: 1})
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() == nil && !isNotReadyError(err) {
+			return err
+		}
+		if lastErr == nil || ctx.Err() == nil {
+			lastErr = err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("createRepo timed out waiting for repository to become ready: %w", lastErr)
+		case <-timer.C:
+		}
+	}
+}
+
+func isNotReadyError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Status {
+	case http.StatusNotFound, http.StatusConflict, http.StatusLocked, http.StatusTooEarly, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
 }
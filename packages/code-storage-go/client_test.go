@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -63,6 +64,44 @@ func TestCreateRepoDefaultBranch(t *testing.T) {
 	}
 }
 
+func TestCreateRepoDefaultBranchFallback(t *testing.T) {
+	var receivedBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody = nil
+		_ = json.NewDecoder(r.Body).Decode(&receivedBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"repo_id":"repo","url":"https://repo.git"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+
+	repo, err := client.CreateRepo(nil, CreateRepoOptions{})
+	if err != nil {
+		t.Fatalf("create repo error: %v", err)
+	}
+	if receivedBody["default_branch_fallback"] != true || repo.DefaultBranch != "main" {
+		t.Fatalf("expected main as a replaceable fallback, got %#v (%s)", receivedBody, repo.DefaultBranch)
+	}
+
+	if _, err := client.CreateRepo(nil, CreateRepoOptions{SkipOrgDefaults: true}); err != nil {
+		t.Fatalf("create repo error: %v", err)
+	}
+	if _, ok := receivedBody["default_branch_fallback"]; ok || receivedBody["default_branch"] != "main" || receivedBody["skip_org_defaults"] != true {
+		t.Fatalf("expected a fixed main branch without org defaults, got %#v", receivedBody)
+	}
+
+	if _, err := client.CreateRepo(nil, CreateRepoOptions{DefaultBranch: "develop"}); err != nil {
+		t.Fatalf("create repo error: %v", err)
+	}
+	if _, ok := receivedBody["default_branch_fallback"]; ok || receivedBody["default_branch"] != "develop" {
+		t.Fatalf("expected an explicit branch not to be replaceable, got %#v", receivedBody)
+	}
+}
+
 func TestCreateRepoForkBaseRepo(t *testing.T) {
 	var receivedBody map[string]interface{}
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -542,6 +581,59 @@ func TestDeleteRepoTTL(t *testing.T) {
 	}
 }
 
+func TestTTLFromContext(t *testing.T) {
+	var ttls []int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := parseJWTFromToken(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		ttls = append(ttls, int64(claims["exp"].(float64))-int64(claims["iat"].(float64)))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"repo_id":"repo","message":"ok"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	invocation := InvocationOptions{TTLFromContext: true}
+	if _, err := client.DeleteRepo(ctx, DeleteRepoOptions{ID: "repo", InvocationOptions: invocation}); err != nil {
+		t.Fatalf("delete repo error: %v", err)
+	}
+	capped := InvocationOptions{TTLFromContext: true, MaxTTL: time.Minute}
+	if _, err := client.DeleteRepo(ctx, DeleteRepoOptions{ID: "repo", InvocationOptions: capped}); err != nil {
+		t.Fatalf("delete repo error: %v", err)
+	}
+	if _, err := client.DeleteRepo(nil, DeleteRepoOptions{ID: "repo", InvocationOptions: invocation}); err != nil {
+		t.Fatalf("delete repo error: %v", err)
+	}
+
+	if len(ttls) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(ttls))
+	}
+	// Two minutes plus the margin, allowing for clock rounding.
+	if ttls[0] < 145 || ttls[0] > 151 {
+		t.Fatalf("expected ttl near the deadline, got %d", ttls[0])
+	}
+	if ttls[1] != 60 {
+		t.Fatalf("expected ttl capped at 60, got %d", ttls[1])
+	}
+	if ttls[2] != int64(defaultTokenTTL/time.Second) {
+		t.Fatalf("expected default ttl without a deadline, got %d", ttls[2])
+	}
+
+	if ttl := resolveInvocationTTL(ctx, InvocationOptions{TTL: time.Hour, TTLFromContext: true}, defaultTokenTTL); ttl != time.Hour {
+		t.Fatalf("expected explicit ttl to win, got %s", ttl)
+	}
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Minute))
+	defer cancelExpired()
+	if ttl := resolveInvocationTTL(expired, invocation, defaultTokenTTL); ttl != contextTTLMargin {
+		t.Fatalf("expected margin for a past deadline, got %s", ttl)
+	}
+}
+
 func TestDeleteRepoNotFound(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -637,3 +729,55 @@ func TestCreateRepoUserAgentHeader(t *testing.T) {
 		t.Fatalf("missing Code-Storage-Agent header")
 	}
 }
+
+func TestCreateRepoWaitReady(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/repos":
+			_, _ = w.Write([]byte(`{"repo_id":"repo","url":"https://repo.git"}`))
+		case "/api/v1/repos/commits":
+			polls++
+			if r.URL.Query().Get("branch") != "trunk" {
+				t.Errorf("unexpected branch: %s", r.URL.Query().Get("branch"))
+			}
+			if polls < 3 {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"branch not found"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"commits":[],"has_more":false}`))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+
+	_, err = client.CreateRepo(nil, CreateRepoOptions{
+		BaseRepo:      ForkBaseRepo{ID: "template"},
+		DefaultBranch: "trunk",
+		WaitReady:     &WaitReadyOptions{PollInterval: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("create repo error: %v", err)
+	}
+	if polls != 3 {
+		t.Fatalf("expected 3 readiness polls, got %d", polls)
+	}
+
+	polls = -100
+	_, err = client.CreateRepo(nil, CreateRepoOptions{
+		BaseRepo:      ForkBaseRepo{ID: "template"},
+		DefaultBranch: "trunk",
+		WaitReady:     &WaitReadyOptions{Timeout: 20 * time.Millisecond, PollInterval: time.Millisecond},
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out waiting") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// codeOwnersLocations are searched in order, as GitHub does.
+var codeOwnersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// GetCodeOwnersOptions configures GetCodeOwners.
+type GetCodeOwnersOptions struct {
+	InvocationOptions
+	// Ref is the branch, tag, or SHA to read CODEOWNERS from. Empty uses
+	// the default branch.
+	Ref       string
+	Ephemeral *bool
+}
+
+// CodeOwners is a parsed CODEOWNERS file. Source is the path it was read
+// from, or empty when the ref has none, in which case nothing is owned.
+type CodeOwners struct {
+	Source string
+	Rules  []CodeOwnersRule
+}
+
+// CodeOwnersRule is one CODEOWNERS line. A rule without owners marks paths
+// as unowned, overriding earlier rules.
+type CodeOwnersRule struct {
+	Pattern string
+	Owners  []string
+	Line    int
+	regex   *regexp.Regexp
+	dirOnly bool
+	// nested is false for patterns ending in "/*", which match the files
+	// of a directory but not those of its subdirectories.
+	nested bool
+}
+
+// GetCodeOwners reads the CODEOWNERS file at a ref from .github/, the
+// repository root, or docs/, whichever exists first.
+func (r *Repo) GetCodeOwners(ctx context.Context, options GetCodeOwnersOptions) (*CodeOwners, error) {
+	for _, location := range codeOwnersLocations {
+		resp, err := r.FileStream(ctx, GetFileOptions{InvocationOptions: options.InvocationOptions, Path: location, Ref: options.Ref, Ephemeral: options.Ephemeral})
+		if err != nil {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+				continue
+			}
+			return nil, fmt.Errorf("getCodeOwners read %s: %w", location, err)
+		}
+		owners, err := ParseCodeOwners(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("getCodeOwners read %s: %w", location, err)
+		}
+		owners.Source = location
+		return owners, nil
+	}
+	return &CodeOwners{}, nil
+}
+
+// ParseCodeOwners parses CODEOWNERS content. Lines whose pattern cannot be
+// used, such as gitignore negations, are skipped as GitHub skips them.
+func ParseCodeOwners(r io.Reader) (*CodeOwners, error) {
+	owners := &CodeOwners{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if rule, ok := parseCodeOwnersLine(scanner.Text()); ok {
+			rule.Line = line
+			owners.Rules = append(owners.Rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return owners, nil
+}
+
+// Match returns the rule that decides a path's owners. The last matching
+// rule wins.
+func (c *CodeOwners) Match(p string) (CodeOwnersRule, bool) {
+	if c == nil {
+		return CodeOwnersRule{}, false
+	}
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	for i := len(c.Rules) - 1; i >= 0; i-- {
+		if c.Rules[i].matches(p) {
+			return c.Rules[i], true
+		}
+	}
+	return CodeOwnersRule{}, false
+}
+
+// OwnersFor maps each path to its owners. Unowned paths map to nil.
+func (c *CodeOwners) OwnersFor(paths []string) map[string][]string {
+	result := make(map[string][]string, len(paths))
+	for _, p := range paths {
+		rule, _ := c.Match(p)
+		result[p] = rule.Owners
+	}
+	return result
+}
+
+// AnnotateDiff sets FileDiff.Owners on each file, such as the Files of a
+// GetBranchDiffResult. Renamed files are owned by their new path.
+func (c *CodeOwners) AnnotateDiff(files []FileDiff) {
+	for i := range files {
+		rule, _ := c.Match(files[i].Path)
+		files[i].Owners = rule.Owners
+	}
+}
+
+func (rule CodeOwnersRule) matches(p string) bool {
+	if !rule.dirOnly && rule.regex.MatchString(p) {
+		return true
+	}
+	if !rule.nested {
+		return rule.dirOnly && rule.regex.MatchString(path.Dir(p))
+	}
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if rule.regex.MatchString(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCodeOwnersLine(line string) (CodeOwnersRule, bool) {
+	fields := strings.Fields(strings.TrimSpace(line))
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "!") {
+		return CodeOwnersRule{}, false
+	}
+	rule := CodeOwnersRule{Pattern: fields[0], nested: true}
+	for _, owner := range fields[1:] {
+		if strings.HasPrefix(owner, "#") {
+			break
+		}
+		rule.Owners = append(rule.Owners, owner)
+	}
+
+	pattern := strings.TrimPrefix(rule.Pattern, "\\")
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		rule.nested = false
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		// "/" or "*" style catch-alls own the whole repository.
+		pattern = "**"
+	}
+	expr := gitignoreRegexp(pattern)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	regex, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return CodeOwnersRule{}, false
+	}
+	rule.regex = regex
+	return rule, true
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodeOwners(t *testing.T) {
+	content := strings.Join([]string{
+		"# Default owners",
+		"*                 @acme/core",
+		"*.go              @acme/go-team # Go sources",
+		"/docs/*           @acme/docs",
+		"apps/             @acme/apps @alice",
+		"/build/logs/      @acme/infra",
+		"apps/generated/",
+		"!ignored.txt      @nobody",
+	}, "\n")
+
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/file" || r.URL.Query().Get("ref") != "release" {
+			t.Errorf("unexpected request: %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		requested = append(requested, r.URL.Query().Get("path"))
+		if r.URL.Query().Get("path") != "CODEOWNERS" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	owners, err := repo.GetCodeOwners(nil, GetCodeOwnersOptions{Ref: "release"})
+	if err != nil {
+		t.Fatalf("get code owners error: %v", err)
+	}
+	if owners.Source != "CODEOWNERS" || len(owners.Rules) != 6 || !reflect.DeepEqual(requested, []string{".github/CODEOWNERS", "CODEOWNERS"}) {
+		t.Fatalf("unexpected code owners from %v: %+v", requested, owners)
+	}
+
+	got := owners.OwnersFor([]string{
+		"README.md",
+		"cmd/main.go",
+		"docs/guide.md",
+		"docs/api/index.md",
+		"apps/web/app.go",
+		"services/apps/main.ts",
+		"build/logs/today/run.log",
+		"apps/generated/schema.ts",
+	})
+	want := map[string][]string{
+		"README.md":                {"@acme/core"},
+		"cmd/main.go":              {"@acme/go-team"},
+		"docs/guide.md":            {"@acme/docs"},
+		"docs/api/index.md":        {"@acme/core"},
+		"apps/web/app.go":          {"@acme/apps", "@alice"},
+		"services/apps/main.ts":    {"@acme/apps", "@alice"},
+		"build/logs/today/run.log": {"@acme/infra"},
+		"apps/generated/schema.ts": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected owners:\n got %v\nwant %v", got, want)
+	}
+	if rule, ok := owners.Match("cmd/main.go"); !ok || rule.Line != 3 || rule.Pattern != "*.go" {
+		t.Fatalf("unexpected rule: %+v", rule)
+	}
+
+	files := []FileDiff{{Path: "docs/guide.md"}, {Path: "apps/generated/schema.ts"}}
+	owners.AnnotateDiff(files)
+	if !reflect.DeepEqual(files[0].Owners, []string{"@acme/docs"}) || files[1].Owners != nil {
+		t.Fatalf("unexpected annotated files: %+v", files)
+	}
+
+	var none *CodeOwners
+	if _, ok := none.Match("README.md"); ok {
+		t.Fatalf("expected nil code owners to own nothing")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -17,11 +18,15 @@ import (
 const maxChunkBytes = 4 * 1024 * 1024
 
 type commitOperation struct {
-	Path      string
-	ContentID string
-	Mode      GitFileMode
-	Operation string
-	Source    io.Reader
+	Path            string
+	ContentID       string
+	Mode            GitFileMode
+	Operation       string
+	Source          io.Reader
+	Attributes      map[string]string
+	FailIfExists    bool
+	FailIfMissing   bool
+	ExpectedBlobSHA string
 }
 
 func (b *CommitBuilder) normalize() error {
@@ -64,6 +69,50 @@ func (b *CommitBuilder) normalize() error {
 	if b.options.EphemeralBase && b.options.BaseBranch == "" {
 		return errors.New("createCommit ephemeralBase requires baseBranch")
 	}
+	if b.options.EphemeralTTL < 0 {
+		return errors.New("createCommit ephemeralTTL must not be negative")
+	}
+	if b.options.EphemeralTTL > 0 && !b.options.Ephemeral {
+		return errors.New("createCommit ephemeralTTL requires ephemeral")
+	}
+	if b.options.LargeFileThreshold < 0 {
+		return errors.New("createCommit largeFileThreshold must not be negative")
+	}
+	switch b.options.CheckGitignore {
+	case "", GitignoreCheckWarn, GitignoreCheckError:
+	default:
+		return errors.New("createCommit checkGitignore must be warn or error")
+	}
+
+	if len(b.options.Parents) > 0 {
+		parents := make([]string, 0, len(b.options.Parents))
+		seen := make(map[string]bool, len(b.options.Parents))
+		headFound := false
+		for _, parent := range b.options.Parents {
+			parent = strings.ToLower(strings.TrimSpace(parent))
+			if !isHexObjectID(parent) {
+				return errors.New("createCommit parents must be full commit SHAs")
+			}
+			if seen[parent] {
+				return errors.New("createCommit parents must not contain duplicates")
+			}
+			seen[parent] = true
+			if parent == strings.ToLower(b.options.ExpectedHeadSHA) {
+				headFound = true
+			}
+			parents = append(parents, parent)
+		}
+		if len(parents) < 2 {
+			return errors.New("createCommit parents must list at least two commits")
+		}
+		if b.options.ExpectedHeadSHA == "" {
+			return errors.New("createCommit parents requires expectedHeadSHA")
+		}
+		if !headFound {
+			return errors.New("createCommit expectedHeadSHA must be one of parents")
+		}
+		b.options.Parents = parents
+	}
 
 	if b.options.Committer != nil {
 		if strings.TrimSpace(b.options.Committer.Name) == "" || strings.TrimSpace(b.options.Committer.Email) == "" {
@@ -99,17 +148,58 @@ func (b *CommitBuilder) AddFile(path string, source io.Reader, options *CommitFi
 	if options != nil && options.Mode != "" {
 		mode = options.Mode
 	}
+	if options != nil && options.FailIfExists && options.FailIfMissing {
+		b.err = errors.New("createCommit failIfExists and failIfMissing are mutually exclusive")
+		return b
+	}
+	expectedBlobSHA := ""
+	if options != nil {
+		if expectedBlobSHA, err = normalizeExpectedBlobSHA(options.ExpectedBlobSHA); err != nil {
+			b.err = err
+			return b
+		}
+		if expectedBlobSHA != "" && options.FailIfExists {
+			b.err = errors.New("createCommit expectedBlobSHA cannot be combined with failIfExists")
+			return b
+		}
+	}
+	var attributes map[string]string
+	if options != nil && len(options.Attributes) > 0 {
+		attributes = make(map[string]string, len(options.Attributes))
+		for key, value := range options.Attributes {
+			if strings.TrimSpace(key) == "" {
+				b.err = errors.New("createCommit file attribute keys must not be empty")
+				return b
+			}
+			attributes[key] = value
+		}
+	}
 
-	b.ops = append(b.ops, commitOperation{
-		Path:      normalizedPath,
-		ContentID: uuid.NewString(),
-		Mode:      mode,
-		Operation: "upsert",
-		Source:    source,
-	})
+	op := commitOperation{
+		Path:       normalizedPath,
+		ContentID:  uuid.NewString(),
+		Mode:       mode,
+		Operation:  "upsert",
+		Source:     source,
+		Attributes: attributes,
+	}
+	if options != nil {
+		op.FailIfExists = options.FailIfExists
+		op.FailIfMissing = options.FailIfMissing
+		op.ExpectedBlobSHA = expectedBlobSHA
+	}
+	b.ops = append(b.ops, op)
 	return b
 }
 
+func normalizeExpectedBlobSHA(value string) (string, error) {
+	sha := strings.ToLower(strings.TrimSpace(value))
+	if sha != "" && !isHexObjectID(sha) {
+		return "", errors.New("createCommit expectedBlobSHA must be a full blob SHA")
+	}
+	return sha, nil
+}
+
 // AddFileFromBytes adds a binary file.
 func (b *CommitBuilder) AddFileFromBytes(path string, contents []byte, options *CommitFileOptions) *CommitBuilder {
 	if b.err != nil {
@@ -140,6 +230,11 @@ func (b *CommitBuilder) AddFileFromString(path string, contents string, options
 
 // DeletePath removes a file or directory.
 func (b *CommitBuilder) DeletePath(path string) *CommitBuilder {
+	return b.DeletePathWithOptions(path, nil)
+}
+
+// DeletePathWithOptions removes a file or directory with preconditions.
+func (b *CommitBuilder) DeletePathWithOptions(path string, options *DeletePathOptions) *CommitBuilder {
 	if b.err != nil {
 		return b
 	}
@@ -152,11 +247,19 @@ func (b *CommitBuilder) DeletePath(path string) *CommitBuilder {
 		b.err = err
 		return b
 	}
-	b.ops = append(b.ops, commitOperation{
+	op := commitOperation{
 		Path:      normalizedPath,
 		ContentID: uuid.NewString(),
 		Operation: "delete",
-	})
+	}
+	if options != nil {
+		op.FailIfMissing = options.FailIfMissing
+		if op.ExpectedBlobSHA, err = normalizeExpectedBlobSHA(options.ExpectedBlobSHA); err != nil {
+			b.err = err
+			return b
+		}
+	}
+	b.ops = append(b.ops, op)
 	return b
 }
 
@@ -182,12 +285,27 @@ func (b *CommitBuilder) Send(ctx context.Context) (CommitResult, error) {
 		return CommitResult{}, errors.New("createCommit client is required")
 	}
 
-	ttl := resolveCommitTTL(b.options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveCommitTTL(ctx, b.options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := b.client.generateJWT(b.repoID, RemoteURLOptions{Permissions: []Permission{PermissionGitWrite}, TTL: ttl})
 	if err != nil {
 		return CommitResult{}, err
 	}
 
+	var ignored []IgnoredPath
+	if b.options.CheckGitignore != "" {
+		if ignored, err = b.checkGitignore(ctx); err != nil {
+			return CommitResult{}, err
+		}
+		if len(ignored) > 0 && b.options.CheckGitignore == GitignoreCheckError {
+			return CommitResult{}, &IgnoredPathsError{Paths: ignored}
+		}
+	}
+
+	lfsFiles, err := b.convertLargeFiles(ctx, jwtToken)
+	if err != nil {
+		return CommitResult{}, err
+	}
+
 	metadata := buildCommitMetadata(b.options, b.ops)
 
 	pipeReader, pipeWriter := io.Pipe()
@@ -212,8 +330,10 @@ func (b *CommitBuilder) Send(ctx context.Context) (CommitResult, error) {
 		}
 	}()
 
-	url := b.client.api.basePath() + "/repos/commit-pack"
-	resp, err := doStreamingRequest(ctx, b.client.api.httpClient, http.MethodPost, url, jwtToken, pipeReader)
+	if err := b.client.api.wait(ctx); err != nil {
+		return CommitResult{}, err
+	}
+	resp, err := b.client.api.stream(ctx, http.MethodPost, "repos/commit-pack", jwtToken, "application/x-ndjson", pipeReader)
 	if err != nil {
 		return CommitResult{}, err
 	}
@@ -221,11 +341,11 @@ func (b *CommitBuilder) Send(ctx context.Context) (CommitResult, error) {
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		fallback := "createCommit request failed (" + itoa(resp.StatusCode) + " " + resp.Status + ")"
-		statusMessage, statusLabel, refUpdate, err := parseCommitPackError(resp, fallback)
+		statusMessage, statusLabel, refUpdate, conflicts, err := parseCommitPackError(resp, fallback)
 		if err != nil {
 			return CommitResult{}, err
 		}
-		return CommitResult{}, newRefUpdateError(statusMessage, statusLabel, refUpdate)
+		return CommitResult{}, newCommitError(statusMessage, statusLabel, refUpdate, conflicts)
 	}
 
 	var ack commitPackAck
@@ -233,7 +353,61 @@ func (b *CommitBuilder) Send(ctx context.Context) (CommitResult, error) {
 		return CommitResult{}, err
 	}
 
-	return buildCommitResult(ack)
+	result, err := buildCommitResult(ack)
+	if err != nil {
+		return CommitResult{}, err
+	}
+	result.LFSFiles = lfsFiles
+	result.IgnoredPaths = ignored
+	return result, nil
+}
+
+// convertLargeFiles uploads upserts above LargeFileThreshold as LFS objects
+// and swaps their sources for pointer files.
+func (b *CommitBuilder) convertLargeFiles(ctx context.Context, jwtToken string) ([]CommitLFSFile, error) {
+	threshold := b.options.LargeFileThreshold
+	// Under DryRun large files stay inline: each upload would be a write of
+	// its own and end Send before the commit itself is reported.
+	if threshold <= 0 || b.client.api.dryRun {
+		return nil, nil
+	}
+	var converted []CommitLFSFile
+	for i := range b.ops {
+		op := &b.ops[i]
+		if op.Operation != "upsert" || op.Mode == GitFileModeSymlink {
+			continue
+		}
+		source, large, err := measureSource(op.Source, threshold)
+		if err != nil {
+			return nil, err
+		}
+		op.Source = source
+		if !large {
+			continue
+		}
+		pointer, err := b.client.uploadLFSObject(ctx, jwtToken, source)
+		if err != nil {
+			return nil, fmt.Errorf("createCommit upload %s: %w", op.Path, err)
+		}
+		op.Source = strings.NewReader(formatLFSPointer(pointer.OID, pointer.Size))
+		converted = append(converted, CommitLFSFile{Path: op.Path, Pointer: pointer})
+	}
+	return converted, nil
+}
+
+// measureSource reports whether source holds more than threshold bytes. It
+// returns a reader yielding the full content, buffering at most threshold+1
+// bytes when the source cannot report its length.
+func measureSource(source io.Reader, threshold int64) (io.Reader, bool, error) {
+	if sized, ok := source.(interface{ Len() int }); ok {
+		return source, int64(sized.Len()) > threshold, nil
+	}
+	var head bytes.Buffer
+	n, err := io.Copy(&head, io.LimitReader(source, threshold+1))
+	if err != nil {
+		return nil, false, err
+	}
+	return io.MultiReader(&head, source), n > threshold, nil
 }
 
 func (b *CommitBuilder) ensureNotSent() error {
@@ -247,9 +421,13 @@ func buildCommitMetadata(options CommitOptions, ops []commitOperation) *commitMe
 	files := make([]fileEntryPayload, 0, len(ops))
 	for _, op := range ops {
 		entry := fileEntryPayload{
-			Path:      op.Path,
-			ContentID: op.ContentID,
-			Operation: op.Operation,
+			Path:            op.Path,
+			ContentID:       op.ContentID,
+			Operation:       op.Operation,
+			Attributes:      op.Attributes,
+			FailIfExists:    op.FailIfExists,
+			FailIfMissing:   op.FailIfMissing,
+			ExpectedBlobSHA: op.ExpectedBlobSHA,
 		}
 		if op.Operation == "upsert" && op.Mode != "" {
 			entry.Mode = string(op.Mode)
@@ -285,6 +463,12 @@ func buildCommitMetadata(options CommitOptions, ops []commitOperation) *commitMe
 	if options.EphemeralBase {
 		metadata.EphemeralBase = true
 	}
+	if options.EphemeralTTL > 0 {
+		metadata.EphemeralTTL = durationSecondsCeil(options.EphemeralTTL)
+	}
+	if len(options.Parents) > 0 {
+		metadata.Parents = options.Parents
+	}
 
 	return metadata
 }
@@ -376,27 +560,29 @@ func normalizeLegacyTargetRef(ref string) (string, error) {
 	return branch, nil
 }
 
-func resolveCommitTTL(options InvocationOptions, defaultValue time.Duration) time.Duration {
-	if options.TTL > 0 {
-		return options.TTL
-	}
-	return defaultValue
+func resolveCommitTTL(ctx context.Context, options InvocationOptions, defaultValue time.Duration) time.Duration {
+	return resolveInvocationTTL(ctx, options, defaultValue)
 }
 
-func doStreamingRequest(ctx context.Context, client *http.Client, method string, url string, jwtToken string, body io.Reader) (*http.Response, error) {
+// stream sends an NDJSON request body to path without buffering it.
+func (f *apiFetcher) stream(ctx context.Context, method string, path string, jwtToken string, contentType string, body io.Reader) (*http.Response, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	if f.dryRun {
+		return nil, f.dryRunStream(method, path, jwtToken, body)
+	}
+	client := f.httpClient
 	if client == nil {
 		client = http.DefaultClient
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, f.basePath()+"/"+path, body)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+jwtToken)
-	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Code-Storage-Agent", userAgent())
 
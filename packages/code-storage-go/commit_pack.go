@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type commitPackAck struct {
@@ -18,13 +19,35 @@ type commitPackAck struct {
 		BlobCount    int    `json:"blob_count"`
 	} `json:"commit"`
 	Result struct {
-		Branch  string `json:"branch"`
-		OldSHA  string `json:"old_sha"`
-		NewSHA  string `json:"new_sha"`
-		Success bool   `json:"success"`
-		Status  string `json:"status"`
-		Message string `json:"message,omitempty"`
+		Branch    string            `json:"branch"`
+		OldSHA    string            `json:"old_sha"`
+		NewSHA    string            `json:"new_sha"`
+		Success   bool              `json:"success"`
+		Status    string            `json:"status"`
+		Message   string            `json:"message,omitempty"`
+		Conflicts []fileConflictRaw `json:"conflicts,omitempty"`
 	} `json:"result"`
+	Timing *commitTimingRaw `json:"timing,omitempty"`
+}
+
+type commitTimingRaw struct {
+	QueueMS            float64 `json:"queue_ms"`
+	PackVerificationMS float64 `json:"pack_verification_ms"`
+	ProcessingMS       float64 `json:"processing_ms"`
+}
+
+func (raw *commitTimingRaw) timing() *CommitTiming {
+	if raw == nil {
+		return nil
+	}
+	millis := func(value float64) time.Duration {
+		return time.Duration(value * float64(time.Millisecond))
+	}
+	return &CommitTiming{
+		Queue:            millis(raw.QueueMS),
+		PackVerification: millis(raw.PackVerificationMS),
+		Processing:       millis(raw.ProcessingMS),
+	}
 }
 
 type commitPackResponse struct {
@@ -36,15 +59,22 @@ type commitPackResponse struct {
 		BlobCount    int    `json:"blob_count"`
 	} `json:"commit,omitempty"`
 	Result struct {
-		Branch  string `json:"branch"`
-		OldSHA  string `json:"old_sha"`
-		NewSHA  string `json:"new_sha"`
-		Success *bool  `json:"success"`
-		Status  string `json:"status"`
-		Message string `json:"message"`
+		Branch    string            `json:"branch"`
+		OldSHA    string            `json:"old_sha"`
+		NewSHA    string            `json:"new_sha"`
+		Success   *bool             `json:"success"`
+		Status    string            `json:"status"`
+		Message   string            `json:"message"`
+		Conflicts []fileConflictRaw `json:"conflicts"`
 	} `json:"result"`
 }
 
+type fileConflictRaw struct {
+	Path            string `json:"path"`
+	ExpectedBlobSHA string `json:"expected_blob_sha"`
+	ActualBlobSHA   string `json:"actual_blob_sha"`
+}
+
 type errorEnvelope struct {
 	Error string `json:"error"`
 }
@@ -61,7 +91,7 @@ func buildCommitResult(ack commitPackAck) (CommitResult, error) {
 		if strings.TrimSpace(message) == "" {
 			message = "commit failed with status " + ack.Result.Status
 		}
-		return CommitResult{}, newRefUpdateError(message, ack.Result.Status, &refUpdate)
+		return CommitResult{}, newCommitError(message, ack.Result.Status, &refUpdate, ack.Result.Conflicts)
 	}
 
 	return CommitResult{
@@ -71,17 +101,19 @@ func buildCommitResult(ack commitPackAck) (CommitResult, error) {
 		PackBytes:    ack.Commit.PackBytes,
 		BlobCount:    ack.Commit.BlobCount,
 		RefUpdate:    refUpdate,
+		Timing:       ack.Timing.timing(),
 	}, nil
 }
 
-func parseCommitPackError(resp *http.Response, fallbackMessage string) (string, string, *RefUpdate, error) {
+func parseCommitPackError(resp *http.Response, fallbackMessage string) (string, string, *RefUpdate, []fileConflictRaw, error) {
 	body, err := readAll(resp)
 	if err != nil {
-		return "", "", nil, err
+		return "", "", nil, nil, err
 	}
 
 	statusLabel := defaultStatusLabel(resp.StatusCode)
 	var refUpdate *RefUpdate
+	var conflicts []fileConflictRaw
 	message := ""
 
 	var parsed commitPackResponse
@@ -93,6 +125,7 @@ func parseCommitPackError(resp *http.Response, fallbackMessage string) (string,
 			message = strings.TrimSpace(parsed.Result.Message)
 		}
 		refUpdate = partialRefUpdate(parsed.Result.Branch, parsed.Result.OldSHA, parsed.Result.NewSHA)
+		conflicts = parsed.Result.Conflicts
 	}
 
 	if message == "" {
@@ -116,7 +149,7 @@ func parseCommitPackError(resp *http.Response, fallbackMessage string) (string,
 		}
 	}
 
-	return message, statusLabel, refUpdate, nil
+	return message, statusLabel, refUpdate, conflicts, nil
 }
 
 func defaultStatusLabel(statusCode int) string {
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CommitStatusState is the state of a commit status check.
+type CommitStatusState string
+
+const (
+	CommitStatusPending CommitStatusState = "pending"
+	CommitStatusSuccess CommitStatusState = "success"
+	CommitStatusFailure CommitStatusState = "failure"
+	CommitStatusError   CommitStatusState = "error"
+)
+
+// CreateCommitStatusOptions configures CreateCommitStatus.
+type CreateCommitStatusOptions struct {
+	InvocationOptions
+	SHA   string
+	State CommitStatusState
+	// Context names the check, such as "ci/build". A new status for the
+	// same SHA and Context replaces the previous one.
+	Context     string
+	TargetURL   string
+	Description string
+}
+
+// ListCommitStatusesOptions configures ListCommitStatuses.
+type ListCommitStatusesOptions struct {
+	InvocationOptions
+	SHA    string
+	Cursor string
+	Limit  int
+}
+
+// CommitStatus is the latest status reported for one check on a commit.
+type CommitStatus struct {
+	ID           string
+	SHA          string
+	State        CommitStatusState
+	Context      string
+	TargetURL    string
+	Description  string
+	CreatedAt    time.Time
+	RawCreatedAt string
+	UpdatedAt    time.Time
+	RawUpdatedAt string
+}
+
+// ListCommitStatusesResult lists the statuses of a commit. State combines
+// every check: failure if any failed or errored, pending if any are still
+// pending, and success when all succeeded.
+type ListCommitStatusesResult struct {
+	SHA        string
+	State      CommitStatusState
+	Statuses   []CommitStatus
+	NextCursor string
+	HasMore    bool
+}
+
+// CreateCommitStatus reports a check result, such as a CI build, against a
+// commit.
+func (r *Repo) CreateCommitStatus(ctx context.Context, options CreateCommitStatusOptions) (CommitStatus, error) {
+	sha := strings.TrimSpace(options.SHA)
+	if sha == "" {
+		return CommitStatus{}, errors.New("createCommitStatus sha is required")
+	}
+	switch options.State {
+	case CommitStatusPending, CommitStatusSuccess, CommitStatusFailure, CommitStatusError:
+	default:
+		return CommitStatus{}, errors.New("createCommitStatus state must be pending, success, failure, or error")
+	}
+	statusContext := strings.TrimSpace(options.Context)
+	if statusContext == "" {
+		return CommitStatus{}, errors.New("createCommitStatus context is required")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitWrite}, TTL: ttl})
+	if err != nil {
+		return CommitStatus{}, err
+	}
+
+	body := &createCommitStatusRequest{
+		SHA:         sha,
+		State:       string(options.State),
+		Context:     statusContext,
+		TargetURL:   strings.TrimSpace(options.TargetURL),
+		Description: strings.TrimSpace(options.Description),
+	}
+	resp, err := r.client.api.post(ctx, "repos/commits/statuses", nil, body, jwtToken, nil)
+	if err != nil {
+		return CommitStatus{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload commitStatusRaw
+	if err := decodeJSON(resp, &payload); err != nil {
+		return CommitStatus{}, err
+	}
+	return transformCommitStatus(payload), nil
+}
+
+// ListCommitStatuses lists the latest status of each check on a commit,
+// with their combined state for merge gating.
+func (r *Repo) ListCommitStatuses(ctx context.Context, options ListCommitStatusesOptions) (ListCommitStatusesResult, error) {
+	sha := strings.TrimSpace(options.SHA)
+	if sha == "" {
+		return ListCommitStatusesResult{}, errors.New("listCommitStatuses sha is required")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return ListCommitStatusesResult{}, err
+	}
+
+	params := url.Values{}
+	params.Set("sha", sha)
+	if options.Cursor != "" {
+		params.Set("cursor", options.Cursor)
+	}
+	if options.Limit > 0 {
+		params.Set("limit", itoa(options.Limit))
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/commits/statuses", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return ListCommitStatusesResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload listCommitStatusesResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return ListCommitStatusesResult{}, err
+	}
+
+	result := ListCommitStatusesResult{
+		SHA:        payload.SHA,
+		State:      CommitStatusState(payload.State),
+		NextCursor: payload.NextCursor,
+		HasMore:    payload.HasMore,
+	}
+	for _, status := range payload.Statuses {
+		result.Statuses = append(result.Statuses, transformCommitStatus(status))
+	}
+	return result, nil
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCommitStatuses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/commits/statuses" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		claims := parseJWTFromToken(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		scopes, _ := claims["scopes"].([]interface{})
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodPost:
+			if len(scopes) != 1 || scopes[0] != "git:write" {
+				t.Errorf("unexpected scopes: %v", scopes)
+			}
+			var body createCommitStatusRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decode body: %v", err)
+			}
+			if body.SHA != "abc123" || body.State != "success" || body.Context != "ci/build" || body.TargetURL != "https://ci.example.com/1" {
+				t.Errorf("unexpected body: %+v", body)
+			}
+			_, _ = w.Write([]byte(`{"id":"st_1","sha":"abc123","state":"success","context":"ci/build","target_url":"https://ci.example.com/1","created_at":"2024-01-20T10:30:00Z","updated_at":"2024-01-20T10:35:00Z"}`))
+		case http.MethodGet:
+			if r.URL.Query().Get("sha") != "abc123" || r.URL.Query().Get("limit") != "10" {
+				t.Errorf("unexpected query: %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`{"sha":"abc123","state":"pending","statuses":[{"id":"st_1","sha":"abc123","state":"success","context":"ci/build"},{"id":"st_2","sha":"abc123","state":"pending","context":"ci/lint"}],"next_cursor":"c2","has_more":true}`))
+		default:
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	status, err := repo.CreateCommitStatus(nil, CreateCommitStatusOptions{SHA: "abc123", State: CommitStatusSuccess, Context: "ci/build", TargetURL: "https://ci.example.com/1"})
+	if err != nil {
+		t.Fatalf("create commit status error: %v", err)
+	}
+	if status.ID != "st_1" || status.State != CommitStatusSuccess || status.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	list, err := repo.ListCommitStatuses(nil, ListCommitStatusesOptions{SHA: "abc123", Limit: 10})
+	if err != nil {
+		t.Fatalf("list commit statuses error: %v", err)
+	}
+	if list.State != CommitStatusPending || len(list.Statuses) != 2 || list.Statuses[1].Context != "ci/lint" || !list.HasMore || list.NextCursor != "c2" {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+
+	if _, err := repo.CreateCommitStatus(nil, CreateCommitStatusOptions{SHA: "abc123", State: "done", Context: "ci/build"}); err == nil {
+		t.Fatalf("expected state error")
+	}
+	if _, err := repo.CreateCommitStatus(nil, CreateCommitStatusOptions{SHA: "abc123", State: CommitStatusFailure}); err == nil {
+		t.Fatalf("expected context error")
+	}
+}
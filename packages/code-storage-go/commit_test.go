@@ -2,11 +2,17 @@ package storage
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCommitPackRequest(t *testing.T) {
@@ -89,7 +95,7 @@ func TestCommitFromDiffRequest(t *testing.T) {
 	}
 	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
 
-	_, err = repo.CreateCommitFromDiff(nil, CommitFromDiffOptions{
+	result, err := repo.CreateCommitFromDiff(nil, CommitFromDiffOptions{
 		TargetBranch:  "main",
 		CommitMessage: "test",
 		Author:        CommitSignature{Name: "Tester", Email: "test@example.com"},
@@ -101,4 +107,605 @@ func TestCommitFromDiffRequest(t *testing.T) {
 	if requestPath != "/api/v1/repos/diff-commit" {
 		t.Fatalf("unexpected path: %s", requestPath)
 	}
+	if result.Timing != nil {
+		t.Fatalf("expected nil timing when the server omits it")
+	}
+}
+
+func TestCommitServerTiming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"commit":{"commit_sha":"abc","tree_sha":"def","target_branch":"main","pack_bytes":10,"blob_count":1},"result":{"branch":"main","old_sha":"old","new_sha":"new","success":true,"status":"ok"},"timing":{"queue_ms":12,"pack_verification_ms":3.5,"processing_ms":40}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	builder, err := repo.CreateCommit(CommitOptions{TargetBranch: "main", CommitMessage: "timed", Author: CommitSignature{Name: "Tester", Email: "test@example.com"}})
+	if err != nil {
+		t.Fatalf("builder error: %v", err)
+	}
+	result, err := builder.AddFileFromString("a.txt", "a", nil).Send(nil)
+	if err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if result.Timing == nil {
+		t.Fatalf("expected timing details")
+	}
+	if result.Timing.Queue != 12*time.Millisecond || result.Timing.PackVerification != 3500*time.Microsecond || result.Timing.Processing != 40*time.Millisecond {
+		t.Fatalf("unexpected timing: %+v", result.Timing)
+	}
+}
+
+func TestCommitFileAttributes(t *testing.T) {
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"commit":{"commit_sha":"abc","tree_sha":"def","target_branch":"main","pack_bytes":10,"blob_count":1},"result":{"branch":"main","old_sha":"old","new_sha":"new","success":true,"status":"ok"}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	builder, err := repo.CreateCommit(CommitOptions{
+		TargetBranch:  "main",
+		CommitMessage: "generated",
+		Author:        CommitSignature{Name: "Tester", Email: "test@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("builder error: %v", err)
+	}
+	attributes := map[string]string{"generator": "protoc", "review-exempt": "true"}
+	builder = builder.AddFileFromBytes("gen/api.pb.go", []byte("package api"), &CommitFileOptions{Attributes: attributes})
+	attributes["generator"] = "mutated"
+	builder = builder.DeletePath("old.txt")
+	if _, err := builder.Send(nil); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+
+	var first struct {
+		Metadata struct {
+			Files []struct {
+				Path       string            `json:"path"`
+				Attributes map[string]string `json:"attributes"`
+			} `json:"files"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("decode first line: %v", err)
+	}
+	files := first.Metadata.Files
+	if len(files) != 2 {
+		t.Fatalf("unexpected files: %+v", files)
+	}
+	if files[0].Attributes["generator"] != "protoc" || files[0].Attributes["review-exempt"] != "true" {
+		t.Fatalf("unexpected attributes: %+v", files[0].Attributes)
+	}
+	if files[1].Attributes != nil {
+		t.Fatalf("expected no attributes on delete: %+v", files[1].Attributes)
+	}
+	if strings.Contains(lines[0], `"attributes":{}`) {
+		t.Fatalf("expected empty attributes to be omitted")
+	}
+
+	builder, _ = repo.CreateCommit(CommitOptions{
+		TargetBranch:  "main",
+		CommitMessage: "bad",
+		Author:        CommitSignature{Name: "Tester", Email: "test@example.com"},
+	})
+	builder = builder.AddFileFromString("a.txt", "a", &CommitTextFileOptions{CommitFileOptions: CommitFileOptions{Attributes: map[string]string{" ": "x"}}})
+	if builder.Err() == nil {
+		t.Fatalf("expected empty attribute key error")
+	}
+}
+
+func TestCommitMergeParents(t *testing.T) {
+	head := strings.Repeat("a", 40)
+	other := strings.Repeat("b", 40)
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lines = readNDJSONLines(t, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"commit":{"commit_sha":"abc","tree_sha":"def","target_branch":"main","pack_bytes":10,"blob_count":1},"result":{"branch":"main","old_sha":"old","new_sha":"new","success":true,"status":"ok"}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	builder, err := repo.CreateCommit(CommitOptions{
+		TargetBranch:    "main",
+		CommitMessage:   "merge feature",
+		ExpectedHeadSHA: head,
+		Parents:         []string{head, strings.ToUpper(other)},
+		Author:          CommitSignature{Name: "Tester", Email: "test@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("builder error: %v", err)
+	}
+	builder = builder.AddFileFromString("merged.txt", "resolved", nil)
+	if _, err := builder.Send(nil); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+
+	var envelope struct {
+		Metadata struct {
+			Parents []string `json:"parents"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &envelope); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	if len(envelope.Metadata.Parents) != 2 || envelope.Metadata.Parents[0] != head || envelope.Metadata.Parents[1] != other {
+		t.Fatalf("unexpected parents: %#v", envelope.Metadata.Parents)
+	}
+}
+
+func TestCommitMergeParentsValidation(t *testing.T) {
+	client, err := NewClient(Options{Name: "acme", Key: testKey})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+	head := strings.Repeat("a", 40)
+	other := strings.Repeat("b", 40)
+
+	cases := map[string]CommitOptions{
+		"single parent":  {ExpectedHeadSHA: head, Parents: []string{head}},
+		"short sha":      {ExpectedHeadSHA: head, Parents: []string{head, "abc123"}},
+		"duplicate":      {ExpectedHeadSHA: head, Parents: []string{head, head}},
+		"missing head":   {Parents: []string{head, other}},
+		"head not found": {ExpectedHeadSHA: strings.Repeat("c", 40), Parents: []string{head, other}},
+	}
+	for name, options := range cases {
+		options.TargetBranch = "main"
+		options.CommitMessage = "merge"
+		options.Author = CommitSignature{Name: "Tester", Email: "test@example.com"}
+		if _, err := repo.CreateCommit(options); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestCommitEphemeralTTL(t *testing.T) {
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lines = readNDJSONLines(t, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"commit":{"commit_sha":"abc","tree_sha":"def","target_branch":"checkpoint","pack_bytes":10,"blob_count":1},"result":{"branch":"checkpoint","old_sha":"old","new_sha":"new","success":true,"status":"ok"}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	builder, err := repo.CreateCommit(CommitOptions{
+		TargetBranch:  "checkpoint",
+		CommitMessage: "checkpoint",
+		Ephemeral:     true,
+		EphemeralTTL:  90*time.Minute + 500*time.Millisecond,
+		Author:        CommitSignature{Name: "Tester", Email: "test@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("builder error: %v", err)
+	}
+	builder = builder.AddFileFromString("state.json", "{}", nil)
+	if _, err := builder.Send(nil); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+
+	var envelope struct {
+		Metadata struct {
+			EphemeralTTL int64 `json:"ephemeral_ttl_seconds"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &envelope); err != nil {
+		t.Fatalf("decode metadata: %v", err)
+	}
+	if envelope.Metadata.EphemeralTTL != 5401 {
+		t.Fatalf("unexpected ephemeral_ttl_seconds: %d", envelope.Metadata.EphemeralTTL)
+	}
+
+	_, err = repo.CreateCommit(CommitOptions{
+		TargetBranch:  "checkpoint",
+		CommitMessage: "checkpoint",
+		EphemeralTTL:  time.Hour,
+		Author:        CommitSignature{Name: "Tester", Email: "test@example.com"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "requires ephemeral") {
+		t.Fatalf("expected ephemeral validation error, got %v", err)
+	}
+	_, err = repo.CreateCommitFromDiff(nil, CommitFromDiffOptions{
+		TargetBranch:  "checkpoint",
+		CommitMessage: "checkpoint",
+		EphemeralTTL:  time.Hour,
+		Diff:          strings.NewReader("diff"),
+		Author:        CommitSignature{Name: "Tester", Email: "test@example.com"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "requires ephemeral") {
+		t.Fatalf("expected diff ephemeral validation error, got %v", err)
+	}
+}
+
+func TestCommitFilePreconditions(t *testing.T) {
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lines = readNDJSONLines(t, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"commit":{"commit_sha":"abc","tree_sha":"def","target_branch":"main","pack_bytes":10,"blob_count":1},"result":{"branch":"main","old_sha":"old","new_sha":"new","success":true,"status":"ok"}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	builder, err := repo.CreateCommit(CommitOptions{
+		TargetBranch:  "main",
+		CommitMessage: "guarded",
+		Author:        CommitSignature{Name: "Tester", Email: "test@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("builder error: %v", err)
+	}
+	builder = builder.
+		AddFileFromBytes("new.txt", []byte("new"), &CommitFileOptions{FailIfExists: true}).
+		AddFileFromBytes("existing.txt", []byte("updated"), &CommitFileOptions{FailIfMissing: true}).
+		DeletePathWithOptions("gone.txt", &DeletePathOptions{FailIfMissing: true}).
+		DeletePath("maybe.txt")
+	if _, err := builder.Send(nil); err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+
+	var first struct {
+		Metadata struct {
+			Files []struct {
+				Path          string `json:"path"`
+				FailIfExists  bool   `json:"fail_if_exists"`
+				FailIfMissing bool   `json:"fail_if_missing"`
+			} `json:"files"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("decode first line: %v", err)
+	}
+	files := first.Metadata.Files
+	if len(files) != 4 {
+		t.Fatalf("unexpected files: %+v", files)
+	}
+	if !files[0].FailIfExists || files[0].FailIfMissing {
+		t.Fatalf("unexpected create-only flags: %+v", files[0])
+	}
+	if files[1].FailIfExists || !files[1].FailIfMissing || !files[2].FailIfMissing {
+		t.Fatalf("unexpected must-exist flags: %+v", files[1:3])
+	}
+	if files[3].FailIfExists || files[3].FailIfMissing {
+		t.Fatalf("expected no preconditions: %+v", files[3])
+	}
+
+	builder, _ = repo.CreateCommit(CommitOptions{
+		TargetBranch:  "main",
+		CommitMessage: "bad",
+		Author:        CommitSignature{Name: "Tester", Email: "test@example.com"},
+	})
+	builder = builder.AddFileFromString("a.txt", "a", &CommitTextFileOptions{CommitFileOptions: CommitFileOptions{FailIfExists: true, FailIfMissing: true}})
+	if builder.Err() == nil {
+		t.Fatalf("expected conflicting precondition error")
+	}
+}
+
+func TestCommitExpectedBlobSHAConflict(t *testing.T) {
+	expected := strings.Repeat("a", 40)
+	actual := strings.Repeat("b", 40)
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lines = readNDJSONLines(t, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"result":{"branch":"main","old_sha":"old","new_sha":"","success":false,"status":"conflict","message":"file changed","conflicts":[{"path":"src/app.go","expected_blob_sha":"` + expected + `","actual_blob_sha":"` + actual + `"}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	builder, err := repo.CreateCommit(CommitOptions{
+		TargetBranch:  "main",
+		CommitMessage: "edit",
+		Author:        CommitSignature{Name: "Tester", Email: "test@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("builder error: %v", err)
+	}
+	builder = builder.
+		AddFileFromString("src/app.go", "package app", &CommitTextFileOptions{CommitFileOptions: CommitFileOptions{ExpectedBlobSHA: strings.ToUpper(expected)}}).
+		DeletePathWithOptions("src/old.go", &DeletePathOptions{ExpectedBlobSHA: actual})
+	_, err = builder.Send(nil)
+
+	var conflictErr *FileConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("expected FileConflictError, got %T %v", err, err)
+	}
+	if len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0] != (FileConflict{Path: "src/app.go", ExpectedBlobSHA: expected, ActualBlobSHA: actual}) {
+		t.Fatalf("unexpected conflicts: %+v", conflictErr.Conflicts)
+	}
+	var refErr *RefUpdateError
+	if !errors.As(err, &refErr) || refErr.Reason != RefUpdateReasonConflict {
+		t.Fatalf("expected wrapped RefUpdateError, got %v", err)
+	}
+
+	var first struct {
+		Metadata struct {
+			Files []struct {
+				ExpectedBlobSHA string `json:"expected_blob_sha"`
+			} `json:"files"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("decode first line: %v", err)
+	}
+	if len(first.Metadata.Files) != 2 || first.Metadata.Files[0].ExpectedBlobSHA != expected || first.Metadata.Files[1].ExpectedBlobSHA != actual {
+		t.Fatalf("unexpected expected_blob_sha: %+v", first.Metadata.Files)
+	}
+
+	builder, _ = repo.CreateCommit(CommitOptions{
+		TargetBranch:  "main",
+		CommitMessage: "bad",
+		Author:        CommitSignature{Name: "Tester", Email: "test@example.com"},
+	})
+	if builder.AddFileFromBytes("a.txt", nil, &CommitFileOptions{ExpectedBlobSHA: "abc"}).Err() == nil {
+		t.Fatalf("expected short blob sha error")
+	}
+}
+
+func TestCommitLargeFileThreshold(t *testing.T) {
+	large := strings.Repeat("x", 64)
+	var uploads []string
+	chunks := map[string]string{}
+	var files []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/repos/lfs/objects":
+			if ct := r.Header.Get("Content-Type"); ct != "application/octet-stream" {
+				t.Errorf("unexpected LFS upload content type: %s", ct)
+			}
+			body, _ := io.ReadAll(r.Body)
+			uploads = append(uploads, string(body))
+			sum := sha256.Sum256(body)
+			_, _ = w.Write([]byte(`{"oid":"` + hex.EncodeToString(sum[:]) + `","size":` + strconv.Itoa(len(body)) + `}`))
+		case "/api/v1/repos/commit-pack":
+			for i, line := range readNDJSONLines(t, r.Body) {
+				var payload map[string]map[string]interface{}
+				if err := json.Unmarshal([]byte(line), &payload); err != nil {
+					t.Errorf("decode line: %v", err)
+					continue
+				}
+				if i == 0 {
+					files = payload["metadata"]["files"].([]interface{})
+					continue
+				}
+				chunk := payload["blob_chunk"]
+				chunks[chunk["content_id"].(string)] += string(decodeBase64(t, chunk["data"].(string)))
+			}
+			_, _ = w.Write([]byte(`{"commit":{"commit_sha":"abc","tree_sha":"def","target_branch":"main","pack_bytes":10,"blob_count":2},"result":{"branch":"main","old_sha":"old","new_sha":"new","success":true,"status":"ok"}}`))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	builder, err := repo.CreateCommit(CommitOptions{TargetBranch: "main", CommitMessage: "assets", Author: CommitSignature{Name: "Tester", Email: "test@example.com"}, LargeFileThreshold: 32})
+	if err != nil {
+		t.Fatalf("builder error: %v", err)
+	}
+	result, err := builder.
+		AddFileFromString("small.txt", "small", nil).
+		AddFile("big.bin", bufio.NewReader(strings.NewReader(large)), nil).
+		Send(nil)
+	if err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+
+	if len(uploads) != 1 || uploads[0] != large {
+		t.Fatalf("expected the large file to be uploaded once, got %d uploads", len(uploads))
+	}
+	sum := sha256.Sum256([]byte(large))
+	oid := hex.EncodeToString(sum[:])
+	if len(result.LFSFiles) != 1 || result.LFSFiles[0].Path != "big.bin" || result.LFSFiles[0].Pointer.OID != oid || result.LFSFiles[0].Pointer.Size != 64 {
+		t.Fatalf("unexpected LFS files: %+v", result.LFSFiles)
+	}
+
+	contents := map[string]string{}
+	for _, file := range files {
+		entry := file.(map[string]interface{})
+		contents[entry["path"].(string)] = chunks[entry["content_id"].(string)]
+	}
+	if contents["small.txt"] != "small" {
+		t.Fatalf("expected small file inline, got %q", contents["small.txt"])
+	}
+	pointer, ok := ParseLFSPointer([]byte(contents["big.bin"]))
+	if !ok || pointer.OID != oid || pointer.Size != 64 {
+		t.Fatalf("expected pointer file for big.bin, got %q", contents["big.bin"])
+	}
+
+	if _, err := repo.CreateCommit(CommitOptions{TargetBranch: "main", CommitMessage: "m", Author: CommitSignature{Name: "a", Email: "b"}, LargeFileThreshold: -1}); err == nil {
+		t.Fatalf("expected negative threshold error")
+	}
+}
+
+func TestCommitLargeFileThresholdDryRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run sent %s %s", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL, DryRun: true})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	builder, err := repo.CreateCommit(CommitOptions{TargetBranch: "main", CommitMessage: "assets", Author: CommitSignature{Name: "Tester", Email: "test@example.com"}, LargeFileThreshold: 4})
+	if err != nil {
+		t.Fatalf("builder error: %v", err)
+	}
+	_, err = builder.AddFileFromString("big.bin", "too large", nil).Send(nil)
+	result, ok := AsDryRun(err)
+	if !ok || result.Path != "repos/commit-pack" {
+		t.Fatalf("expected the commit itself to be reported, got %v", err)
+	}
+}
+
+func TestCommitLargeFileUploadError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/lfs/objects" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		_, _ = w.Write([]byte(`{"error":"object exceeds quota"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	builder, err := repo.CreateCommit(CommitOptions{TargetBranch: "main", CommitMessage: "assets", Author: CommitSignature{Name: "Tester", Email: "test@example.com"}, LargeFileThreshold: 4})
+	if err != nil {
+		t.Fatalf("builder error: %v", err)
+	}
+	_, err = builder.AddFileFromString("big.bin", "too large", nil).Send(nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusRequestEntityTooLarge || apiErr.Message != "object exceeds quota" || apiErr.Method != http.MethodPost {
+		t.Fatalf("expected API error with the decoded body, got %v", err)
+	}
+}
+
+func TestCommitCheckGitignore(t *testing.T) {
+	var requested []string
+	commits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/file":
+			path := r.URL.Query().Get("path")
+			requested = append(requested, path)
+			if ref := r.URL.Query().Get("ref"); ref != "dev" {
+				t.Errorf("expected base ref, got %q", ref)
+			}
+			switch path {
+			case ".gitignore":
+				_, _ = w.Write([]byte("# build output\nnode_modules/\n*.log\n!keep.log\n/dist\n"))
+			case "web/.gitignore":
+				_, _ = w.Write([]byte(".cache\n"))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case "/api/v1/repos/commit-pack":
+			commits++
+			_, _ = io.Copy(io.Discard, r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"commit":{"commit_sha":"abc","tree_sha":"def","target_branch":"feature","pack_bytes":10,"blob_count":1},"result":{"branch":"feature","old_sha":"old","new_sha":"new","success":true,"status":"ok"}}`))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	stage := func(check GitignoreCheck) *CommitBuilder {
+		builder, err := repo.CreateCommit(CommitOptions{TargetBranch: "feature", BaseBranch: "dev", CommitMessage: "build", Author: CommitSignature{Name: "Tester", Email: "test@example.com"}, CheckGitignore: check})
+		if err != nil {
+			t.Fatalf("builder error: %v", err)
+		}
+		return builder.
+			AddFileFromString("src/main.go", "package main", nil).
+			AddFileFromString("node_modules/left-pad/index.js", "module.exports = 1", nil).
+			AddFileFromString("logs/debug.log", "trace", nil).
+			AddFileFromString("logs/keep.log", "kept", nil).
+			AddFileFromString("dist/app.js", "bundle", nil).
+			AddFileFromString("web/dist/app.js", "bundle", nil).
+			AddFileFromString("web/.cache/entry", "cached", nil)
+	}
+
+	_, err = stage(GitignoreCheckError).Send(nil)
+	var ignoredErr *IgnoredPathsError
+	if !errors.As(err, &ignoredErr) {
+		t.Fatalf("expected IgnoredPathsError, got %v", err)
+	}
+	if commits != 0 {
+		t.Fatalf("expected no commit to be sent")
+	}
+	got := map[string]string{}
+	for _, ignored := range ignoredErr.Paths {
+		got[ignored.Path] = ignored.Source + ":" + ignored.Pattern
+	}
+	want := map[string]string{
+		"node_modules/left-pad/index.js": ".gitignore:node_modules/",
+		"logs/debug.log":                 ".gitignore:*.log",
+		"dist/app.js":                    ".gitignore:/dist",
+		"web/.cache/entry":               "web/.gitignore:.cache",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected ignored paths: %+v", ignoredErr.Paths)
+	}
+	for path, rule := range want {
+		if got[path] != rule {
+			t.Fatalf("expected %s ignored by %s, got %q", path, rule, got[path])
+		}
+	}
+	for _, path := range requested {
+		if strings.HasPrefix(path, "node_modules/") {
+			t.Fatalf("did not expect a lookup inside an ignored directory: %s", path)
+		}
+	}
+
+	result, err := stage(GitignoreCheckWarn).Send(nil)
+	if err != nil {
+		t.Fatalf("send error: %v", err)
+	}
+	if commits != 1 || len(result.IgnoredPaths) != len(want) {
+		t.Fatalf("expected commit with warnings, got %d commits and %+v", commits, result.IgnoredPaths)
+	}
+
+	if _, err := repo.CreateCommit(CommitOptions{TargetBranch: "main", CommitMessage: "m", Author: CommitSignature{Name: "a", Email: "b"}, CheckGitignore: "strict"}); err == nil {
+		t.Fatalf("expected checkGitignore validation error")
+	}
 }
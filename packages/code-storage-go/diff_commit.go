@@ -48,6 +48,12 @@ func (d *diffCommitExecutor) normalize() (CommitFromDiffOptions, error) {
 	if options.EphemeralBase && options.BaseBranch == "" {
 		return options, errors.New("createCommitFromDiff ephemeralBase requires baseBranch")
 	}
+	if options.EphemeralTTL < 0 {
+		return options, errors.New("createCommitFromDiff ephemeralTTL must not be negative")
+	}
+	if options.EphemeralTTL > 0 && !options.Ephemeral {
+		return options, errors.New("createCommitFromDiff ephemeralTTL requires ephemeral")
+	}
 
 	if options.Committer != nil {
 		if strings.TrimSpace(options.Committer.Name) == "" || strings.TrimSpace(options.Committer.Email) == "" {
@@ -66,7 +72,7 @@ func (d *diffCommitExecutor) send(ctx context.Context, repoID string) (CommitRes
 		return CommitResult{}, err
 	}
 
-	ttl := resolveCommitTTL(options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveCommitTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := d.client.generateJWT(repoID, RemoteURLOptions{Permissions: []Permission{PermissionGitWrite}, TTL: ttl})
 	if err != nil {
 		return CommitResult{}, err
@@ -95,8 +101,10 @@ func (d *diffCommitExecutor) send(ctx context.Context, repoID string) (CommitRes
 		}
 	}()
 
-	url := d.client.api.basePath() + "/repos/diff-commit"
-	resp, err := doStreamingRequest(ctx, d.client.api.httpClient, http.MethodPost, url, jwtToken, pipeReader)
+	if err := d.client.api.wait(ctx); err != nil {
+		return CommitResult{}, err
+	}
+	resp, err := d.client.api.stream(ctx, http.MethodPost, "repos/diff-commit", jwtToken, "application/x-ndjson", pipeReader)
 	if err != nil {
 		return CommitResult{}, err
 	}
@@ -104,11 +112,11 @@ func (d *diffCommitExecutor) send(ctx context.Context, repoID string) (CommitRes
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		fallback := "createCommitFromDiff request failed (" + itoa(resp.StatusCode) + " " + resp.Status + ")"
-		statusMessage, statusLabel, refUpdate, err := parseCommitPackError(resp, fallback)
+		statusMessage, statusLabel, refUpdate, conflicts, err := parseCommitPackError(resp, fallback)
 		if err != nil {
 			return CommitResult{}, err
 		}
-		return CommitResult{}, newRefUpdateError(statusMessage, statusLabel, refUpdate)
+		return CommitResult{}, newCommitError(statusMessage, statusLabel, refUpdate, conflicts)
 	}
 
 	var ack commitPackAck
@@ -141,6 +149,9 @@ func buildDiffCommitMetadata(options CommitFromDiffOptions) *commitMetadataPaylo
 	if options.EphemeralBase {
 		metadata.EphemeralBase = true
 	}
+	if options.EphemeralTTL > 0 {
+		metadata.EphemeralTTL = durationSecondsCeil(options.EphemeralTTL)
+	}
 	if options.Committer != nil {
 		metadata.Committer = &authorInfo{
 			Name:  options.Committer.Name,
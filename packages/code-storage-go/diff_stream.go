@@ -0,0 +1,147 @@
+package storage
+
+import "context"
+
+const defaultDiffStreamPageSize = 100
+
+type diffPage struct {
+	stats      DiffStats
+	files      []FileDiff
+	filtered   []FilteredFile
+	nextCursor string
+	hasMore    bool
+}
+
+type diffPageFetcher func(ctx context.Context, cursor string) (diffPage, error)
+
+// DiffStream iterates over the files of a diff one page at a time, so only
+// a single page of patches is held in memory. Use it like bufio.Scanner:
+//
+//	for stream.Next() {
+//		file := stream.File()
+//	}
+//	if err := stream.Err(); err != nil { ... }
+type DiffStream struct {
+	ctx      context.Context
+	fetch    diffPageFetcher
+	stats    DiffStats
+	page     []FileDiff
+	filtered []FilteredFile
+	current  FileDiff
+	cursor   string
+	hasMore  bool
+	err      error
+}
+
+// BranchDiffStream streams a branch diff. options.Limit sets the page size
+// and defaults to 100 files.
+func (r *Repo) BranchDiffStream(ctx context.Context, options GetBranchDiffOptions) (*DiffStream, error) {
+	if options.Limit <= 0 {
+		options.Limit = defaultDiffStreamPageSize
+	}
+	return newDiffStream(ctx, options.Cursor, func(ctx context.Context, cursor string) (diffPage, error) {
+		options.Cursor = cursor
+		result, err := r.GetBranchDiff(ctx, options)
+		if err != nil {
+			return diffPage{}, err
+		}
+		return diffPage{stats: result.Stats, files: result.Files, filtered: result.FilteredFiles, nextCursor: result.NextCursor, hasMore: result.HasMore}, nil
+	})
+}
+
+// CommitDiffStream streams a commit diff. options.Limit sets the page size
+// and defaults to 100 files.
+func (r *Repo) CommitDiffStream(ctx context.Context, options GetCommitDiffOptions) (*DiffStream, error) {
+	if options.Limit <= 0 {
+		options.Limit = defaultDiffStreamPageSize
+	}
+	return newDiffStream(ctx, options.Cursor, func(ctx context.Context, cursor string) (diffPage, error) {
+		options.Cursor = cursor
+		result, err := r.GetCommitDiff(ctx, options)
+		if err != nil {
+			return diffPage{}, err
+		}
+		return diffPage{stats: result.Stats, files: result.Files, filtered: result.FilteredFiles, nextCursor: result.NextCursor, hasMore: result.HasMore}, nil
+	})
+}
+
+// CompareDiffStream streams a diff between two refs. options.Limit sets the
+// page size and defaults to 100 files.
+func (r *Repo) CompareDiffStream(ctx context.Context, options CompareDiffOptions) (*DiffStream, error) {
+	if options.Limit <= 0 {
+		options.Limit = defaultDiffStreamPageSize
+	}
+	return newDiffStream(ctx, options.Cursor, func(ctx context.Context, cursor string) (diffPage, error) {
+		options.Cursor = cursor
+		result, err := r.CompareDiff(ctx, options)
+		if err != nil {
+			return diffPage{}, err
+		}
+		return diffPage{stats: result.Stats, files: result.Files, filtered: result.FilteredFiles, nextCursor: result.NextCursor, hasMore: result.HasMore}, nil
+	})
+}
+
+func newDiffStream(ctx context.Context, cursor string, fetch diffPageFetcher) (*DiffStream, error) {
+	s := &DiffStream{ctx: ctx, fetch: fetch}
+	page, err := fetch(ctx, cursor)
+	if err != nil {
+		return nil, err
+	}
+	s.stats = page.stats
+	s.load(page)
+	return s, nil
+}
+
+func (s *DiffStream) load(page diffPage) {
+	s.page = page.files
+	s.filtered = append(s.filtered, page.filtered...)
+	s.cursor = page.nextCursor
+	s.hasMore = page.hasMore && page.nextCursor != ""
+}
+
+// Next advances to the next file, fetching the next page when needed. It
+// returns false when the diff is exhausted or a request fails.
+func (s *DiffStream) Next() bool {
+	for len(s.page) == 0 {
+		if s.err != nil || !s.hasMore {
+			return false
+		}
+		page, err := s.fetch(s.ctx, s.cursor)
+		if err != nil {
+			s.err = err
+			return false
+		}
+		s.load(page)
+	}
+	s.current = s.page[0]
+	s.page[0] = FileDiff{}
+	s.page = s.page[1:]
+	return true
+}
+
+// File returns the current file.
+func (s *DiffStream) File() FileDiff {
+	return s.current
+}
+
+// Err returns the first error encountered while fetching pages.
+func (s *DiffStream) Err() error {
+	return s.err
+}
+
+// Stats returns the statistics for the whole diff.
+func (s *DiffStream) Stats() DiffStats {
+	return s.stats
+}
+
+// FilteredFiles returns the files omitted from patches on the pages read
+// so far.
+func (s *DiffStream) FilteredFiles() []FilteredFile {
+	return s.filtered
+}
+
+// Cursor returns the cursor of the next page to fetch. Files still pending
+// on the current page are not covered by it.
+func (s *DiffStream) Cursor() string {
+	return s.cursor
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBranchDiffStreamPages(t *testing.T) {
+	var cursors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/branches/diff" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("limit") != "2" {
+			t.Errorf("unexpected limit: %s", r.URL.Query().Get("limit"))
+		}
+		cursor := r.URL.Query().Get("cursor")
+		cursors = append(cursors, cursor)
+		w.Header().Set("Content-Type", "application/json")
+		switch cursor {
+		case "":
+			_, _ = w.Write([]byte(`{"branch":"feature","base":"main","stats":{"files":3,"additions":3,"deletions":0,"changes":3},"files":[{"path":"a.txt","state":"added","raw":"@@ -0,0 +1 @@\n+a\n"},{"path":"b.txt","state":"added","raw":"@@ -0,0 +1 @@\n+b\n"}],"filtered_files":[{"path":"big.bin","state":"added"}],"next_cursor":"p2","has_more":true}`))
+		case "p2":
+			_, _ = w.Write([]byte(`{"branch":"feature","base":"main","stats":{"files":3,"additions":3,"deletions":0,"changes":3},"files":[{"path":"c.txt","state":"added","raw":"@@ -0,0 +1 @@\n+c\n"}],"filtered_files":[],"has_more":false}`))
+		default:
+			t.Errorf("unexpected cursor: %s", cursor)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	stream, err := repo.BranchDiffStream(nil, GetBranchDiffOptions{Branch: "feature", Limit: 2})
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	var paths []string
+	for stream.Next() {
+		paths = append(paths, stream.File().Path)
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream iteration error: %v", err)
+	}
+	if len(paths) != 3 || paths[0] != "a.txt" || paths[2] != "c.txt" {
+		t.Fatalf("unexpected paths: %v", paths)
+	}
+	if len(cursors) != 2 || cursors[1] != "p2" {
+		t.Fatalf("unexpected cursors: %v", cursors)
+	}
+	if stream.Stats().Files != 3 || len(stream.FilteredFiles()) != 1 {
+		t.Fatalf("unexpected stats or filtered files: %+v %+v", stream.Stats(), stream.FilteredFiles())
+	}
+}
+
+func TestCommitDiffStreamReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("limit") != "100" {
+			t.Errorf("expected default page size, got %s", r.URL.Query().Get("limit"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("cursor") == "" {
+			_, _ = w.Write([]byte(`{"sha":"abc","stats":{"files":2},"files":[{"path":"a.txt","state":"modified","raw":""}],"next_cursor":"p2","has_more":true}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":"boom"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	stream, err := repo.CommitDiffStream(nil, GetCommitDiffOptions{SHA: "abc"})
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	count := 0
+	for stream.Next() {
+		count++
+	}
+	if count != 1 || stream.Err() == nil {
+		t.Fatalf("expected one file then an error, got %d files and %v", count, stream.Err())
+	}
+
+	if _, err := repo.CommitDiffStream(nil, GetCommitDiffOptions{}); err == nil {
+		t.Fatalf("expected sha validation error")
+	}
+}
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ConfigSeverity grades a configuration issue.
+type ConfigSeverity string
+
+const (
+	// ConfigSeverityError marks settings that make requests fail.
+	ConfigSeverityError ConfigSeverity = "error"
+	// ConfigSeverityWarning marks settings that work but are likely mistakes.
+	ConfigSeverityWarning ConfigSeverity = "warning"
+)
+
+// ConfigIssue describes one misconfiguration found by ValidateOptions or
+// Client.Doctor.
+type ConfigIssue struct {
+	// Field names the Options field at fault, e.g. "StorageBaseURL".
+	Field    string
+	Severity ConfigSeverity
+	Message  string
+	// Hint suggests how to fix the issue.
+	Hint string
+}
+
+func (i ConfigIssue) String() string {
+	text := string(i.Severity) + ": " + i.Field + ": " + i.Message
+	if i.Hint != "" {
+		text += " (" + i.Hint + ")"
+	}
+	return text
+}
+
+const (
+	doctorDialTimeout = 5 * time.Second
+	minRecommendedTTL = time.Minute
+)
+
+// ValidateOptions checks options without making network requests and
+// returns every issue found. An empty result means NewClient will accept the
+// options and the URLs are well formed.
+func ValidateOptions(options Options) []ConfigIssue {
+	var issues []ConfigIssue
+	add := func(field string, severity ConfigSeverity, message string, hint string) {
+		issues = append(issues, ConfigIssue{Field: field, Severity: severity, Message: message, Hint: hint})
+	}
+
+	name := strings.TrimSpace(options.Name)
+	if name == "" {
+		add("Name", ConfigSeverityError, "name is required", "set Name to your organization name")
+	} else if strings.ContainsAny(name, " /:@") {
+		add("Name", ConfigSeverityError, fmt.Sprintf("name %q is not a valid organization name", options.Name), "use the bare organization slug, e.g. \"acme\"")
+	}
+
+	if strings.TrimSpace(options.Key) == "" {
+		add("Key", ConfigSeverityError, "key is required", "set Key to the PEM-encoded ECDSA private key issued for your organization")
+	} else if key, err := parseECPrivateKey([]byte(options.Key)); err != nil {
+		hint := "provide a PEM-encoded ECDSA P-256 key in PKCS#8 or SEC 1 form"
+		if strings.Contains(options.Key, `\n`) && !strings.Contains(options.Key, "\n") {
+			hint = "the key contains literal \\n sequences; replace them with real newlines"
+		}
+		add("Key", ConfigSeverityError, err.Error(), hint)
+	} else if key.Curve != elliptic.P256() {
+		add("Key", ConfigSeverityError, "private key must use the P-256 curve", "tokens are signed with ES256, which requires a P-256 key")
+	}
+
+	if options.APIBaseURL != "" {
+		issues = append(issues, validateAPIBaseURL("APIBaseURL", options.APIBaseURL)...)
+	}
+	for i, endpoint := range options.Endpoints {
+		field := fmt.Sprintf("Endpoints[%d].APIBaseURL", i)
+		if strings.TrimSpace(endpoint.APIBaseURL) == "" {
+			add(field, ConfigSeverityWarning, "endpoint has no URL and is ignored", "remove the entry or set its APIBaseURL")
+			continue
+		}
+		issues = append(issues, validateAPIBaseURL(field, endpoint.APIBaseURL)...)
+	}
+	if options.FailoverWrites && len(options.Endpoints) == 0 {
+		add("FailoverWrites", ConfigSeverityWarning, "failover writes are enabled without secondary endpoints", "configure Endpoints or leave FailoverWrites unset")
+	}
+	if options.FailoverCooldown < 0 {
+		add("FailoverCooldown", ConfigSeverityError, "failover cooldown must not be negative", "use zero for the default cooldown")
+	}
+
+	if storage := options.StorageBaseURL; storage != "" {
+		if strings.Contains(storage, "://") {
+			add("StorageBaseURL", ConfigSeverityError, fmt.Sprintf("storage base URL %q includes a scheme", storage), "use a bare host such as \"acme.code.storage\" and set StorageScheme for http")
+		} else if parsed, err := url.Parse("//" + storage); err != nil || parsed.Hostname() == "" {
+			add("StorageBaseURL", ConfigSeverityError, fmt.Sprintf("storage base URL %q is not a valid host", storage), "use a bare host such as \"acme.code.storage\"")
+		} else if parsed.Path != "" || parsed.RawQuery != "" {
+			add("StorageBaseURL", ConfigSeverityError, fmt.Sprintf("storage base URL %q must not include a path", storage), "remove everything after the host")
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(options.StorageScheme)) {
+	case "", "https":
+	case "http":
+		add("StorageScheme", ConfigSeverityWarning, "git remotes use plain http", "only use http for local emulators; tokens are sent in the URL")
+	default:
+		add("StorageScheme", ConfigSeverityError, fmt.Sprintf("unsupported storage scheme %q", options.StorageScheme), "use http or https")
+	}
+	if options.StoragePort < 0 || options.StoragePort > 65535 {
+		add("StoragePort", ConfigSeverityError, fmt.Sprintf("port %d is not a valid TCP port", options.StoragePort), "use a port between 1 and 65535, or zero for the default")
+	}
+
+	if options.APIVersion < 0 {
+		add("APIVersion", ConfigSeverityError, "API version must not be negative", "use zero for the default version")
+	} else if options.APIVersion > DefaultAPIVersion {
+		add("APIVersion", ConfigSeverityWarning, fmt.Sprintf("API version %d is newer than this SDK supports (%d)", options.APIVersion, DefaultAPIVersion), "upgrade the SDK or use zero for the default version")
+	}
+
+	switch {
+	case options.DefaultTTL < 0:
+		add("DefaultTTL", ConfigSeverityError, "default TTL must not be negative", "use zero for the default of one year")
+	case options.DefaultTTL > 0 && options.DefaultTTL < minRecommendedTTL:
+		add("DefaultTTL", ConfigSeverityWarning, fmt.Sprintf("default TTL %s is shorter than a minute", options.DefaultTTL), "long clones and pushes may outlive their token; use at least a few minutes")
+	case options.DefaultTTL > defaultJWTTTL:
+		add("DefaultTTL", ConfigSeverityWarning, fmt.Sprintf("default TTL %s exceeds one year", options.DefaultTTL), "prefer short-lived tokens and mint new remote URLs as needed")
+	}
+
+	return issues
+}
+
+func validateAPIBaseURL(field string, raw string) []ConfigIssue {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return []ConfigIssue{{Field: field, Severity: ConfigSeverityError, Message: fmt.Sprintf("invalid URL %q: %v", raw, err), Hint: "use an absolute URL such as \"https://api.acme.code.storage\""}}
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return []ConfigIssue{{Field: field, Severity: ConfigSeverityError, Message: fmt.Sprintf("URL %q must use http or https", raw), Hint: "use an absolute URL such as \"https://api.acme.code.storage\""}}
+	}
+	if parsed.Host == "" {
+		return []ConfigIssue{{Field: field, Severity: ConfigSeverityError, Message: fmt.Sprintf("URL %q has no host", raw), Hint: "use an absolute URL such as \"https://api.acme.code.storage\""}}
+	}
+	var issues []ConfigIssue
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		issues = append(issues, ConfigIssue{Field: field, Severity: ConfigSeverityError, Message: fmt.Sprintf("URL %q must not include a query or fragment", raw), Hint: "remove everything after the host"})
+	}
+	if strings.Contains(parsed.Path, "/api/v") {
+		issues = append(issues, ConfigIssue{Field: field, Severity: ConfigSeverityError, Message: fmt.Sprintf("URL %q already includes the API version path", raw), Hint: "drop the /api/vN suffix; it is added per request"})
+	}
+	if parsed.Scheme == "http" && !isLoopbackHost(parsed.Hostname()) {
+		issues = append(issues, ConfigIssue{Field: field, Severity: ConfigSeverityWarning, Message: fmt.Sprintf("URL %q uses plain http", raw), Hint: "use https outside local development"})
+	}
+	return issues
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Doctor validates the client's resolved options, then checks that every
+// API endpoint accepts the client's credentials and that the git storage
+// host accepts connections. Connectivity problems are reported as issues
+// rather than errors.
+func (c *Client) Doctor(ctx context.Context) []ConfigIssue {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	issues := ValidateOptions(c.options)
+
+	jwtToken, err := c.generateJWT("org", RemoteURLOptions{Permissions: []Permission{PermissionOrgRead}, TTL: minRecommendedTTL})
+	if err != nil {
+		return append(issues, ConfigIssue{Field: "Key", Severity: ConfigSeverityError, Message: "could not sign a token: " + err.Error(), Hint: "check that Key is the private key issued for your organization"})
+	}
+	for index, base := range c.api.endpoints.urls {
+		field := "APIBaseURL"
+		if index > 0 {
+			field = fmt.Sprintf("Endpoints[%d].APIBaseURL", index-1)
+		}
+		if issue, ok := c.checkAPIEndpoint(ctx, field, base, jwtToken); !ok {
+			issues = append(issues, issue)
+		}
+	}
+
+	if issue, ok := c.checkStorageHost(ctx); !ok {
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+func (c *Client) checkAPIEndpoint(ctx context.Context, field string, base string, jwtToken string) (ConfigIssue, bool) {
+	target := base + "/api/v" + itoa(c.options.APIVersion) + "/repos?limit=1"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return ConfigIssue{Field: field, Severity: ConfigSeverityError, Message: err.Error(), Hint: "fix the URL"}, false
+	}
+	req.Header.Set("Authorization", "Bearer "+jwtToken)
+	req.Header.Set("Code-Storage-Agent", userAgent())
+
+	resp, err := c.api.httpClient.Do(req)
+	if err != nil {
+		return ConfigIssue{Field: field, Severity: ConfigSeverityError, Message: fmt.Sprintf("cannot reach %s: %v", base, err), Hint: "check DNS, proxies, and firewalls, and that the URL points at the Code Storage API"}, false
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ConfigIssue{Field: "Key", Severity: ConfigSeverityError, Message: fmt.Sprintf("%s rejected the client's credentials with status %d", base, resp.StatusCode), Hint: "check that Name matches the organization the Key was issued for"}, false
+	case resp.StatusCode == http.StatusNotFound:
+		return ConfigIssue{Field: field, Severity: ConfigSeverityError, Message: fmt.Sprintf("%s returned 404 for the API", base), Hint: "check the URL and APIVersion"}, false
+	case resp.StatusCode >= 500:
+		return ConfigIssue{Field: field, Severity: ConfigSeverityWarning, Message: fmt.Sprintf("%s is unhealthy (status %d)", base, resp.StatusCode), Hint: "retry later or configure failover Endpoints"}, false
+	case resp.StatusCode >= 400:
+		return ConfigIssue{Field: field, Severity: ConfigSeverityWarning, Message: fmt.Sprintf("%s returned status %d", base, resp.StatusCode), Hint: "check the URL and APIVersion"}, false
+	}
+	return ConfigIssue{}, true
+}
+
+func (c *Client) checkStorageHost(ctx context.Context) (ConfigIssue, bool) {
+	repo := &Repo{client: c}
+	parsed, err := url.Parse(repo.remoteURL("doctor.git", ""))
+	if err != nil || parsed.Hostname() == "" {
+		return ConfigIssue{}, true // reported by ValidateOptions
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "443"
+		if parsed.Scheme == "http" {
+			port = "80"
+		}
+	}
+	address := net.JoinHostPort(parsed.Hostname(), port)
+
+	dialer := net.Dialer{Timeout: doctorDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		var dnsErr *net.DNSError
+		hint := "check firewalls and that StorageBaseURL and StoragePort point at your git storage host"
+		if errors.As(err, &dnsErr) {
+			hint = "the host does not resolve; check StorageBaseURL"
+		}
+		return ConfigIssue{Field: "StorageBaseURL", Severity: ConfigSeverityError, Message: fmt.Sprintf("cannot connect to git storage at %s: %v", address, err), Hint: hint}, false
+	}
+	conn.Close()
+	return ConfigIssue{}, true
+}
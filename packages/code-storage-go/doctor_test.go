@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hasIssue(issues []ConfigIssue, field string, severity ConfigSeverity) bool {
+	for _, issue := range issues {
+		if issue.Field == field && issue.Severity == severity {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateOptions(t *testing.T) {
+	if issues := ValidateOptions(Options{Name: "acme", Key: testKey}); len(issues) != 0 {
+		t.Fatalf("expected no issues, got %v", issues)
+	}
+
+	issues := ValidateOptions(Options{
+		Name:           "acme",
+		Key:            strings.ReplaceAll(testKey, "\n", `\n`),
+		APIBaseURL:     "https://api.acme.code.storage/api/v1?x=1",
+		StorageBaseURL: "https://acme.code.storage",
+		StorageScheme:  "ssh",
+		StoragePort:    70000,
+		DefaultTTL:     time.Second,
+		Endpoints:      []EndpointConfig{{APIBaseURL: "http://api.backup.example"}},
+	})
+	expected := []struct {
+		field    string
+		severity ConfigSeverity
+	}{
+		{"Key", ConfigSeverityError},
+		{"APIBaseURL", ConfigSeverityError},
+		{"StorageBaseURL", ConfigSeverityError},
+		{"StorageScheme", ConfigSeverityError},
+		{"StoragePort", ConfigSeverityError},
+		{"DefaultTTL", ConfigSeverityWarning},
+		{"Endpoints[0].APIBaseURL", ConfigSeverityWarning},
+	}
+	for _, want := range expected {
+		if !hasIssue(issues, want.field, want.severity) {
+			t.Fatalf("expected %s %s issue, got %v", want.severity, want.field, issues)
+		}
+	}
+	for _, issue := range issues {
+		if issue.Hint == "" {
+			t.Fatalf("expected a remediation hint: %v", issue)
+		}
+		if issue.Field == "Key" && !strings.Contains(issue.Hint, `\n`) {
+			t.Fatalf("expected escaped newline hint, got %q", issue.Hint)
+		}
+	}
+
+	if issues := ValidateOptions(Options{}); !hasIssue(issues, "Name", ConfigSeverityError) || !hasIssue(issues, "Key", ConfigSeverityError) {
+		t.Fatalf("expected missing name and key issues, got %v", issues)
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos" || r.URL.Query().Get("limit") != "1" {
+			t.Errorf("unexpected request: %s", r.URL.String())
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			t.Errorf("missing authorization header")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"repos":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL, StorageBaseURL: host, StorageScheme: "http"})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	issues := client.Doctor(nil)
+	if len(issues) != 1 || issues[0].Field != "StorageScheme" {
+		t.Fatalf("expected only the http storage warning, got %v", issues)
+	}
+
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer rejecting.Close()
+	client, err = NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: rejecting.URL, StorageBaseURL: "127.0.0.1:1", StorageScheme: "http"})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	issues = client.Doctor(nil)
+	if !hasIssue(issues, "Key", ConfigSeverityError) {
+		t.Fatalf("expected rejected credentials issue, got %v", issues)
+	}
+	if !hasIssue(issues, "StorageBaseURL", ConfigSeverityError) {
+		t.Fatalf("expected storage connectivity issue, got %v", issues)
+	}
+}
@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	blobSHAHeader   = "Code-Storage-Blob-Sha"
+	commitSHAHeader = "Code-Storage-Commit-Sha"
+
+	defaultDownloadMaxRetries     = 3
+	defaultDownloadInitialBackoff = 250 * time.Millisecond
+	defaultDownloadMaxBackoff     = 5 * time.Second
+)
+
+// ErrDownloadChanged is returned when a download cannot resume because the
+// content changed on the server since the first response.
+var ErrDownloadChanged = errors.New("download content changed during resume")
+
+var errDownloadClosed = errors.New("download already closed")
+
+type downloadOpener func(ctx context.Context, header http.Header) (*http.Response, error)
+
+// Download is a response body that transparently resumes with Range
+// requests when the connection drops mid-stream. Resumption is only
+// attempted when the first response carries a strong ETag or blob SHA, so
+// the resumed bytes are guaranteed to belong to the same content.
+type Download struct {
+	downloadRetrier
+	open      downloadOpener
+	progress  DownloadProgressFunc
+	resp      *http.Response
+	total     int64
+	validator string
+	offset    int64
+	err       error
+	closed    bool
+	// digest hashes archive downloads for comparison with checksum.
+	digest   hash.Hash
+	checksum string
+}
+
+// downloadRetrier tracks the retry budget shared by one download.
+type downloadRetrier struct {
+	ctx     context.Context
+	policy  DownloadRetryPolicy
+	retries int
+}
+
+// DownloadFile streams a file like FileStream, resuming interrupted reads.
+func (r *Repo) DownloadFile(ctx context.Context, options DownloadFileOptions) (*Download, error) {
+	if strings.TrimSpace(options.Path) == "" {
+		return nil, errors.New("downloadFile path is required")
+	}
+	return newDownload(ctx, options.Retry, options.OnProgress, func(ctx context.Context, header http.Header) (*http.Response, error) {
+		return r.fileStream(ctx, options.GetFileOptions, header)
+	})
+}
+
+// DownloadArchive streams an archive like ArchiveStream, resuming
+// interrupted reads.
+func (r *Repo) DownloadArchive(ctx context.Context, options DownloadArchiveOptions) (*Download, error) {
+	archive, err := r.expandArchivePrefix(ctx, options.ArchiveOptions)
+	if err != nil {
+		return nil, err
+	}
+	options.ArchiveOptions = archive
+	download, err := newDownload(ctx, options.Retry, options.OnProgress, func(ctx context.Context, header http.Header) (*http.Response, error) {
+		return r.archiveStream(ctx, options.ArchiveOptions, header)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if download.resp.StatusCode == http.StatusOK {
+		download.digest = sha256.New()
+		download.checksum = ArchiveStreamSHA256(download.resp)
+	}
+	return download, nil
+}
+
+func newDownload(ctx context.Context, policy DownloadRetryPolicy, progress DownloadProgressFunc, open downloadOpener) (*Download, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	d := &Download{downloadRetrier: downloadRetrier{ctx: ctx, policy: policy}, open: open, progress: progress}
+	for {
+		resp, err := open(ctx, nil)
+		if err == nil {
+			d.resp = resp
+			d.total = resp.ContentLength
+			d.validator = downloadValidator(resp)
+			return d, nil
+		}
+		if !d.canRetry(err) {
+			return nil, err
+		}
+		if err := d.backoff(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Header returns the headers of the first response.
+func (d *Download) Header() http.Header {
+	return d.resp.Header
+}
+
+// StatusCode returns the status of the first response, e.g. 304 when a
+// conditional archive download matched.
+func (d *Download) StatusCode() int {
+	return d.resp.StatusCode
+}
+
+// SHA256 returns the server-provided SHA-256 of an archive download, or ""
+// when there is none. A digest sent as a trailer is only known once the
+// body has been read to EOF, at which point it has also been verified.
+func (d *Download) SHA256() string {
+	if d.checksum == "" && d.digest != nil {
+		return ArchiveStreamSHA256(d.resp)
+	}
+	return d.checksum
+}
+
+// BytesRead returns the number of body bytes delivered so far.
+func (d *Download) BytesRead() int64 {
+	return d.offset
+}
+
+// Size returns the Content-Length of the download, or -1 when unknown.
+func (d *Download) Size() int64 {
+	return d.total
+}
+
+// Read reads from the response body, resuming from the current offset if
+// the underlying stream fails.
+func (d *Download) Read(p []byte) (int, error) {
+	for {
+		if d.closed {
+			return 0, errDownloadClosed
+		}
+		if d.err != nil {
+			return 0, d.err
+		}
+
+		n, err := d.resp.Body.Read(p)
+		d.offset += int64(n)
+		if d.digest != nil {
+			d.digest.Write(p[:n])
+		}
+		if n > 0 && d.progress != nil {
+			d.progress(d.offset, d.total)
+		}
+		if err == io.EOF && d.digest != nil {
+			if verifyErr := verifyArchiveChecksum(d.SHA256(), d.digest); verifyErr != nil {
+				d.err = verifyErr
+				return n, verifyErr
+			}
+		}
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		if resumeErr := d.resume(err); resumeErr != nil {
+			if n > 0 {
+				d.err = resumeErr
+				return n, nil
+			}
+			return 0, resumeErr
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Close releases the current response body.
+func (d *Download) Close() error {
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	return d.resp.Body.Close()
+}
+
+func (d *Download) resume(cause error) error {
+	if d.validator == "" {
+		return cause
+	}
+	for d.canRetry(cause) {
+		if err := d.backoff(); err != nil {
+			return err
+		}
+
+		header := http.Header{}
+		header.Set("Range", "bytes="+strconv.FormatInt(d.offset, 10)+"-")
+		header.Set("If-Range", d.validator)
+		resp, err := d.open(d.ctx, header)
+		if err != nil {
+			cause = err
+			continue
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return ErrDownloadChanged
+		}
+		if validator := downloadValidator(resp); validator != "" && validator != d.validator {
+			resp.Body.Close()
+			return ErrDownloadChanged
+		}
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != d.offset {
+			resp.Body.Close()
+			return fmt.Errorf("download resume returned unexpected range %q", resp.Header.Get("Content-Range"))
+		}
+
+		_ = d.resp.Body.Close()
+		d.resp = resp
+		return nil
+	}
+	return cause
+}
+
+func (d *downloadRetrier) canRetry(err error) bool {
+	if d.retries >= d.policy.maxRetries() || d.ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status == http.StatusTooManyRequests || apiErr.Status >= 500
+	}
+	return true
+}
+
+func (d *downloadRetrier) backoff() error {
+	delay := d.policy.initialBackoff() << d.retries
+	if limit := d.policy.maxBackoff(); delay > limit || delay <= 0 {
+		delay = limit
+	}
+	d.retries++
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-d.ctx.Done():
+		return d.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (p DownloadRetryPolicy) maxRetries() int {
+	if p.MaxRetries > 0 {
+		return p.MaxRetries
+	}
+	return defaultDownloadMaxRetries
+}
+
+func (p DownloadRetryPolicy) initialBackoff() time.Duration {
+	if p.InitialBackoff > 0 {
+		return p.InitialBackoff
+	}
+	return defaultDownloadInitialBackoff
+}
+
+func (p DownloadRetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff > 0 {
+		return p.MaxBackoff
+	}
+	return defaultDownloadMaxBackoff
+}
+
+// downloadValidator returns an If-Range validator for resp: a strong ETag,
+// or the blob SHA as an entity tag.
+func downloadValidator(resp *http.Response) string {
+	if etag := strings.TrimSpace(resp.Header.Get("ETag")); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	if sha := strings.TrimSpace(resp.Header.Get(blobSHAHeader)); sha != "" {
+		return `"` + sha + `"`
+	}
+	return ""
+}
+
+func contentRangeStart(value string) (int64, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, false
+	}
+	rangeSpec, _, _ := strings.Cut(strings.TrimPrefix(value, "bytes "), "/")
+	startText, _, ok := strings.Cut(rangeSpec, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(startText), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const (
+	defaultParallelSegments       = 4
+	defaultParallelMinSegmentSize = 8 << 20
+)
+
+// DownloadArchiveParallel writes an archive into dst using concurrent range
+// requests when the server advertises byte ranges and a strong validator.
+// Otherwise the archive is streamed sequentially. Pass an *os.File to
+// download straight to disk; dst must not be read until the call returns.
+func (r *Repo) DownloadArchiveParallel(ctx context.Context, options ParallelArchiveDownloadOptions, dst io.WriterAt) (ParallelDownloadResult, error) {
+	if dst == nil {
+		return ParallelDownloadResult{}, errors.New("downloadArchiveParallel destination is required")
+	}
+	if options.Segments < 0 || options.MinSegmentSize < 0 {
+		return ParallelDownloadResult{}, errors.New("downloadArchiveParallel segments and minSegmentSize must not be negative")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	archive, err := r.expandArchivePrefix(ctx, options.ArchiveOptions)
+	if err != nil {
+		return ParallelDownloadResult{}, err
+	}
+	options.ArchiveOptions = archive
+
+	p := &parallelDownload{
+		ctx:      ctx,
+		dst:      dst,
+		policy:   options.Retry,
+		progress: options.OnProgress,
+		open: func(ctx context.Context, header http.Header) (*http.Response, error) {
+			return r.archiveStream(ctx, options.ArchiveOptions, header)
+		},
+	}
+
+	probeHeader := http.Header{}
+	probeHeader.Set("Range", "bytes=0-0")
+	resp, err := p.openWithRetry(probeHeader)
+	if err != nil {
+		return ParallelDownloadResult{}, err
+	}
+	result := ParallelDownloadResult{Header: resp.Header, Segments: 1}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		resp.Body.Close()
+		result.NotModified = true
+		return result, nil
+	case resp.StatusCode != http.StatusPartialContent:
+		defer resp.Body.Close()
+		p.total = resp.ContentLength
+		return p.copyVerified(resp, result)
+	}
+	resp.Body.Close()
+
+	total, ok := contentRangeSize(resp.Header.Get("Content-Range"))
+	p.validator = downloadValidator(resp)
+	if !ok || p.validator == "" {
+		resp, err = p.openWithRetry(nil)
+		if err != nil {
+			return ParallelDownloadResult{}, err
+		}
+		defer resp.Body.Close()
+		result.Header = resp.Header
+		p.total = resp.ContentLength
+		return p.copyVerified(resp, result)
+	}
+	result.SHA256 = ArchiveStreamSHA256(resp)
+	p.total = total
+
+	segments := options.Segments
+	if segments == 0 {
+		segments = defaultParallelSegments
+	}
+	minSize := options.MinSegmentSize
+	if minSize == 0 {
+		minSize = defaultParallelMinSegmentSize
+	}
+	if limit := (total + minSize - 1) / minSize; int64(segments) > limit {
+		segments = int(limit)
+	}
+	if segments < 1 {
+		segments = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p.ctx = ctx
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	size := (total + int64(segments) - 1) / int64(segments)
+	for start := int64(0); start < total; start += size {
+		end := min(start+size, total) - 1
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := p.fetchSegment(start, end); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return ParallelDownloadResult{}, firstErr
+	}
+
+	result.Size = total
+	result.Segments = segments
+	if reader, ok := dst.(io.ReaderAt); ok && result.SHA256 != "" {
+		digest := sha256.New()
+		if _, err := io.Copy(digest, io.NewSectionReader(reader, 0, total)); err != nil {
+			return ParallelDownloadResult{}, err
+		}
+		if err := verifyArchiveChecksum(result.SHA256, digest); err != nil {
+			return ParallelDownloadResult{}, err
+		}
+	}
+	return result, nil
+}
+
+// copyVerified streams a full archive response to dst and checks it against
+// the server digest.
+func (p *parallelDownload) copyVerified(resp *http.Response, result ParallelDownloadResult) (ParallelDownloadResult, error) {
+	digest := sha256.New()
+	size, err := p.copyAt(io.TeeReader(resp.Body, digest), 0)
+	result.Size = size
+	if err != nil {
+		return result, err
+	}
+	result.SHA256 = ArchiveStreamSHA256(resp)
+	if err := verifyArchiveChecksum(result.SHA256, digest); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+type parallelDownload struct {
+	ctx       context.Context
+	open      downloadOpener
+	dst       io.WriterAt
+	policy    DownloadRetryPolicy
+	progress  DownloadProgressFunc
+	validator string
+	total     int64
+
+	mu       sync.Mutex
+	received int64
+}
+
+func (p *parallelDownload) openWithRetry(header http.Header) (*http.Response, error) {
+	retrier := downloadRetrier{ctx: p.ctx, policy: p.policy}
+	for {
+		resp, err := p.open(p.ctx, header)
+		if err == nil {
+			return resp, nil
+		}
+		if !retrier.canRetry(err) {
+			return nil, err
+		}
+		if err := retrier.backoff(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// fetchSegment downloads the inclusive range [start, end], resuming from
+// the last written byte after a dropped connection.
+func (p *parallelDownload) fetchSegment(start, end int64) error {
+	retrier := downloadRetrier{ctx: p.ctx, policy: p.policy}
+	offset := start
+	for offset <= end {
+		retryable, err := p.fetchRange(&offset, end)
+		if err == nil {
+			continue
+		}
+		if !retryable || !retrier.canRetry(err) {
+			return err
+		}
+		if err := retrier.backoff(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parallelDownload) fetchRange(offset *int64, end int64) (bool, error) {
+	header := http.Header{}
+	header.Set("Range", "bytes="+strconv.FormatInt(*offset, 10)+"-"+strconv.FormatInt(end, 10))
+	header.Set("If-Range", p.validator)
+	resp, err := p.open(p.ctx, header)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return false, ErrDownloadChanged
+	}
+	if validator := downloadValidator(resp); validator != "" && validator != p.validator {
+		return false, ErrDownloadChanged
+	}
+	if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != *offset {
+		return false, fmt.Errorf("downloadArchiveParallel unexpected range %q", resp.Header.Get("Content-Range"))
+	}
+
+	n, err := p.copyAt(io.LimitReader(resp.Body, end-*offset+1), *offset)
+	*offset += n
+	if err != nil {
+		return true, err
+	}
+	if *offset <= end {
+		return true, io.ErrUnexpectedEOF
+	}
+	return false, nil
+}
+
+// copyAt copies src into dst at offset, reporting progress as it goes.
+func (p *parallelDownload) copyAt(src io.Reader, offset int64) (int64, error) {
+	return io.Copy(&progressWriter{w: io.NewOffsetWriter(p.dst, offset), p: p}, src)
+}
+
+func (p *parallelDownload) addProgress(n int64) {
+	if p.progress == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.received += n
+	p.progress(p.received, p.total)
+}
+
+type progressWriter struct {
+	w io.Writer
+	p *parallelDownload
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	if n > 0 {
+		w.p.addProgress(int64(n))
+	}
+	return n, err
+}
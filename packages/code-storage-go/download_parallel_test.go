@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadArchiveParallelSegments(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	var (
+		mu     sync.Mutex
+		ranges []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/archive" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		w.Header().Set("ETag", `"tree-1"`)
+		http.ServeContent(w, r, "archive.tar.gz", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "archive.tar.gz"))
+	if err != nil {
+		t.Fatalf("create error: %v", err)
+	}
+	defer file.Close()
+
+	var lastReceived int64
+	result, err := repo.DownloadArchiveParallel(nil, ParallelArchiveDownloadOptions{
+		ArchiveOptions: ArchiveOptions{Ref: "main"},
+		Segments:       4,
+		MinSegmentSize: 1,
+		OnProgress: func(received int64, total int64) {
+			if total != int64(len(content)) {
+				t.Errorf("unexpected total: %d", total)
+			}
+			lastReceived = received
+		},
+	}, file)
+	if err != nil {
+		t.Fatalf("download error: %v", err)
+	}
+	if result.Size != int64(len(content)) || result.Segments != 4 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if lastReceived != int64(len(content)) {
+		t.Fatalf("unexpected progress: %d", lastReceived)
+	}
+	written, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if string(written) != content {
+		t.Fatalf("unexpected content: %q", written)
+	}
+
+	expected := map[string]bool{"bytes=0-0": true, "bytes=0-24": true, "bytes=25-49": true, "bytes=50-74": true, "bytes=75-99": true}
+	if len(ranges) != len(expected) {
+		t.Fatalf("unexpected ranges: %v", ranges)
+	}
+	for _, value := range ranges {
+		if !expected[value] {
+			t.Fatalf("unexpected range %q", value)
+		}
+	}
+}
+
+func TestDownloadArchiveParallelFallsBackWithoutRanges(t *testing.T) {
+	content := "sequential archive"
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	var dst writerAtBuffer
+	result, err := repo.DownloadArchiveParallel(nil, ParallelArchiveDownloadOptions{MinSegmentSize: 1}, &dst)
+	if err != nil {
+		t.Fatalf("download error: %v", err)
+	}
+	if result.Segments != 1 || result.Size != int64(len(content)) || requests != 1 {
+		t.Fatalf("unexpected result: %+v after %d requests", result, requests)
+	}
+	if dst.String() != content {
+		t.Fatalf("unexpected content: %q", dst.String())
+	}
+}
+
+func TestDownloadArchiveParallelDetectsChangedContent(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		first := requests == 1
+		mu.Unlock()
+		if first {
+			w.Header().Set("ETag", `"tree-1"`)
+		} else {
+			w.Header().Set("ETag", `"tree-2"`)
+		}
+		http.ServeContent(w, r, "archive.tar.gz", time.Time{}, strings.NewReader(strings.Repeat("x", 64)))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	var dst writerAtBuffer
+	_, err = repo.DownloadArchiveParallel(nil, ParallelArchiveDownloadOptions{Segments: 2, MinSegmentSize: 1}, &dst)
+	if !errors.Is(err, ErrDownloadChanged) {
+		t.Fatalf("expected ErrDownloadChanged, got %v", err)
+	}
+}
+
+type writerAtBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *writerAtBuffer) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if end := int(off) + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+	copy(w.buf[off:], p)
+	return len(p), nil
+}
+
+func (w *writerAtBuffer) String() string {
+	return string(bytes.Clone(w.buf))
+}
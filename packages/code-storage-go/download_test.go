@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newDroppingServer(t *testing.T, content string, resumeETag string, ranges *[]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/file" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		rangeHeader := r.Header.Get("Range")
+		*ranges = append(*ranges, rangeHeader)
+		if rangeHeader == "" {
+			hijacker, ok := w.(http.Hijacker)
+			if !ok {
+				t.Errorf("server does not support hijacking")
+				return
+			}
+			conn, buf, err := hijacker.Hijack()
+			if err != nil {
+				t.Errorf("hijack error: %v", err)
+				return
+			}
+			_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nETag: \"v1\"\r\nContent-Length: " + strconv.Itoa(len(content)) + "\r\n\r\n" + content[:4])
+			_ = buf.Flush()
+			_ = conn.Close()
+			return
+		}
+		if r.Header.Get("If-Range") != `"v1"` {
+			t.Errorf("unexpected If-Range: %s", r.Header.Get("If-Range"))
+		}
+		if resumeETag != `"v1"` {
+			w.Header().Set("ETag", resumeETag)
+			_, _ = w.Write([]byte("different"))
+			return
+		}
+		w.Header().Set("ETag", resumeETag)
+		w.Header().Set("Content-Range", "bytes 4-"+strconv.Itoa(len(content)-1)+"/"+strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write([]byte(content[4:]))
+	}))
+}
+
+func TestDownloadFileResumesWithRange(t *testing.T) {
+	var ranges []string
+	server := newDroppingServer(t, "hello world", `"v1"`, &ranges)
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	download, err := repo.DownloadFile(nil, DownloadFileOptions{
+		GetFileOptions: GetFileOptions{Path: "big.bin"},
+		Retry:          DownloadRetryPolicy{InitialBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("download error: %v", err)
+	}
+	defer download.Close()
+
+	data, err := io.ReadAll(download)
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if string(data) != "hello world" {
+		t.Fatalf("unexpected content: %q", data)
+	}
+	if download.BytesRead() != int64(len("hello world")) {
+		t.Fatalf("unexpected bytes read: %d", download.BytesRead())
+	}
+	if len(ranges) != 2 || ranges[1] != "bytes=4-" {
+		t.Fatalf("unexpected range requests: %v", ranges)
+	}
+}
+
+func TestDownloadFileDetectsChangedContent(t *testing.T) {
+	var ranges []string
+	server := newDroppingServer(t, "hello world", `"v2"`, &ranges)
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	download, err := repo.DownloadFile(nil, DownloadFileOptions{
+		GetFileOptions: GetFileOptions{Path: "big.bin"},
+		Retry:          DownloadRetryPolicy{InitialBackoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("download error: %v", err)
+	}
+	defer download.Close()
+
+	_, err = io.ReadAll(download)
+	if !errors.Is(err, ErrDownloadChanged) {
+		t.Fatalf("expected ErrDownloadChanged, got %v", err)
+	}
+}
+
+func TestContentRangeStart(t *testing.T) {
+	if start, ok := contentRangeStart("bytes 100-199/200"); !ok || start != 100 {
+		t.Fatalf("unexpected start: %d %v", start, ok)
+	}
+	if _, ok := contentRangeStart("bytes */200"); ok {
+		t.Fatalf("expected unsatisfied range to be rejected")
+	}
+}
+
+func TestDownloadFileProgress(t *testing.T) {
+	var ranges []string
+	server := newDroppingServer(t, "hello world", `"v1"`, &ranges)
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	var received []int64
+	download, err := repo.DownloadFile(nil, DownloadFileOptions{
+		GetFileOptions: GetFileOptions{Path: "big.bin"},
+		Retry:          DownloadRetryPolicy{InitialBackoff: time.Millisecond},
+		OnProgress: func(bytes int64, total int64) {
+			if total != 11 {
+				t.Errorf("unexpected total: %d", total)
+			}
+			received = append(received, bytes)
+		},
+	})
+	if err != nil {
+		t.Fatalf("download error: %v", err)
+	}
+	defer download.Close()
+
+	if _, err := io.ReadAll(download); err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if len(received) < 2 || received[len(received)-1] != 11 {
+		t.Fatalf("unexpected progress: %v", received)
+	}
+	for i := 1; i < len(received); i++ {
+		if received[i] <= received[i-1] {
+			t.Fatalf("progress is not increasing: %v", received)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const dryRunRedacted = "[REDACTED]"
+
+// DryRunResult describes a mutating request that was not sent because
+// Options.DryRun is set. Mutating calls return it as their error; use
+// AsDryRun to recover it. Credentials and secrets are redacted, so results
+// are safe to log or attach to a review.
+type DryRunResult struct {
+	Method string
+	// Path is relative to the versioned API base, e.g. "repos/delete".
+	Path  string
+	Query url.Values
+	// Repo and Scopes come from the token the request would have used.
+	Repo   string
+	Scopes []string
+	// Body is the decoded JSON body. For streamed NDJSON bodies, such as
+	// commits, it is the first record, which holds the request metadata.
+	Body      interface{}
+	BodyBytes int64
+}
+
+func (r *DryRunResult) Error() string {
+	return "dry run: " + r.String()
+}
+
+// String summarizes the request on one line.
+func (r *DryRunResult) String() string {
+	summary := r.Method + " " + r.Path
+	if len(r.Query) > 0 {
+		summary += "?" + r.Query.Encode()
+	}
+	if r.Repo != "" {
+		summary += " repo=" + r.Repo
+	}
+	if len(r.Scopes) > 0 {
+		summary += " scopes=" + strings.Join(r.Scopes, ",")
+	}
+	if r.Body != nil {
+		if encoded, err := json.Marshal(r.Body); err == nil {
+			summary += " body=" + string(encoded)
+		}
+	}
+	return summary
+}
+
+// AsDryRun reports whether err is a DryRunResult and returns it.
+func AsDryRun(err error) (*DryRunResult, bool) {
+	var result *DryRunResult
+	if errors.As(err, &result) {
+		return result, true
+	}
+	return nil, false
+}
+
+func newDryRunResult(method string, path string, params url.Values, jwt string) *DryRunResult {
+	result := &DryRunResult{Method: method, Path: path}
+	if claims, ok := decodeTokenClaims(jwt); ok {
+		result.Repo = claims.Repo
+		result.Scopes = claims.Scopes
+	}
+	if len(params) > 0 {
+		result.Query = url.Values{}
+		for name, values := range params {
+			if isSensitiveField(name) {
+				values = []string{dryRunRedacted}
+			}
+			result.Query[name] = append([]string(nil), values...)
+		}
+	}
+	return result
+}
+
+// dryRunJSON reports a JSON API request instead of sending it.
+func (f *apiFetcher) dryRunJSON(method string, path string, params url.Values, body interface{}, jwt string) error {
+	result := newDryRunResult(method, path, params, jwt)
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		result.BodyBytes = int64(len(encoded))
+		var decoded interface{}
+		if err := json.Unmarshal(encoded, &decoded); err == nil {
+			result.Body = redactSensitive(decoded)
+		}
+	}
+	return f.reportDryRun(result)
+}
+
+// dryRunStream drains a streamed request body so its producer can finish,
+// keeping the first NDJSON record as the body summary.
+func (f *apiFetcher) dryRunStream(method string, path string, jwt string, body io.Reader) error {
+	result := newDryRunResult(method, path, nil, jwt)
+	reader := bufio.NewReader(body)
+	first, err := reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	rest, err := io.Copy(io.Discard, reader)
+	if err != nil {
+		return err
+	}
+	result.BodyBytes = int64(len(first)) + rest
+	var decoded interface{}
+	if err := json.Unmarshal(first, &decoded); err == nil {
+		result.Body = redactSensitive(decoded)
+	}
+	return f.reportDryRun(result)
+}
+
+func (f *apiFetcher) reportDryRun(result *DryRunResult) error {
+	if f.onDryRun != nil {
+		f.onDryRun(*result)
+	}
+	return result
+}
+
+func redactSensitive(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if isSensitiveField(key) {
+				v[key] = dryRunRedacted
+			} else {
+				v[key] = redactSensitive(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactSensitive(child)
+		}
+	}
+	return value
+}
+
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	switch name {
+	case "auth", "authorization", "key", "private_key":
+		return true
+	}
+	return strings.Contains(name, "token") || strings.Contains(name, "secret") || strings.Contains(name, "password")
+}
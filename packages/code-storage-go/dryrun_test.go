@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost && r.URL.Path == "/api/v1/repos/grep" {
+			_, _ = w.Write([]byte(`{"query":{"pattern":"TODO"},"repo":{"ref":"main","commit":"abc"},"matches":[]}`))
+			return
+		}
+		if r.Method != http.MethodGet {
+			t.Errorf("dry run sent %s %s", r.Method, r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"paths":["README.md"],"ref":"main"}`))
+	}))
+	defer server.Close()
+
+	var logged []DryRunResult
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL, DryRun: true, OnDryRun: func(result DryRunResult) {
+		logged = append(logged, result)
+	}})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+
+	_, err = client.CreateRepo(nil, CreateRepoOptions{ID: "fork", BaseRepo: ForkBaseRepo{ID: "template"}})
+	result, ok := AsDryRun(err)
+	if !ok {
+		t.Fatalf("expected dry run result, got %v", err)
+	}
+	if result.Method != http.MethodPost || result.Path != "repos" || result.Repo != "fork" {
+		t.Fatalf("unexpected dry run result: %+v", result)
+	}
+	if strings.Contains(result.String(), "eyJ") || !strings.Contains(result.String(), dryRunRedacted) {
+		t.Fatalf("expected redacted summary, got %s", result.String())
+	}
+
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+	if _, err := repo.ListFiles(nil, ListFilesOptions{}); err != nil {
+		t.Fatalf("reads should still run: %v", err)
+	}
+	if _, err := repo.Grep(nil, GrepOptions{Query: GrepQuery{Pattern: "TODO"}}); err != nil {
+		t.Fatalf("read-only POSTs should still run: %v", err)
+	}
+
+	builder, err := repo.CreateCommit(CommitOptions{TargetBranch: "main", CommitMessage: "staged", Author: CommitSignature{Name: "Tester", Email: "test@example.com"}})
+	if err != nil {
+		t.Fatalf("builder error: %v", err)
+	}
+	_, err = builder.AddFileFromString("README.md", "hello", nil).Send(nil)
+	result, ok = AsDryRun(err)
+	if !ok || result.Path != "repos/commit-pack" || result.BodyBytes == 0 {
+		t.Fatalf("unexpected commit dry run: %v", err)
+	}
+	body, _ := result.Body.(map[string]interface{})
+	metadata, _ := body["metadata"].(map[string]interface{})
+	if metadata["commit_message"] != "staged" {
+		t.Fatalf("expected commit metadata in body, got %#v", result.Body)
+	}
+
+	if _, err := client.DeleteRepo(nil, DeleteRepoOptions{}); err == nil || strings.HasPrefix(err.Error(), "dry run") {
+		t.Fatalf("expected validation error before dry run, got %v", err)
+	}
+	if len(logged) != 2 {
+		t.Fatalf("expected 2 logged dry runs, got %d", len(logged))
+	}
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DiffEditKind is the kind of a structured diff edit.
+type DiffEditKind string
+
+const (
+	DiffEditInsert  DiffEditKind = "insert"
+	DiffEditDelete  DiffEditKind = "delete"
+	DiffEditReplace DiffEditKind = "replace"
+)
+
+// DiffEdit is a line-range edit against the old side of a file. OldStart is
+// the 1-based line where the edit begins; inserts place Lines before it.
+// OldLines holds the removed lines so callers can verify the buffer first.
+type DiffEdit struct {
+	Kind     DiffEditKind
+	OldStart int
+	OldCount int
+	NewStart int
+	OldLines []string
+	Lines    []string
+}
+
+// Edits converts the unified diff of a file into machine-applicable edit
+// operations ordered by position.
+func (f FileDiff) Edits() ([]DiffEdit, error) {
+	return DiffEdits(f.Raw)
+}
+
+// DiffEdits converts a single-file unified diff into edit operations.
+func DiffEdits(raw string) ([]DiffEdit, error) {
+	hunks, err := parseSingleFileDiff(raw, "diff edits")
+	if err != nil {
+		return nil, err
+	}
+
+	var edits []DiffEdit
+	for _, hunk := range hunks {
+		oldLine, newLine := hunk.oldStart, hunk.newStart
+		if hunk.oldCount == 0 {
+			oldLine++
+		}
+		if hunk.newCount == 0 {
+			newLine++
+		}
+		var current *DiffEdit
+		flush := func() {
+			if current == nil {
+				return
+			}
+			switch {
+			case len(current.OldLines) > 0 && len(current.Lines) > 0:
+				current.Kind = DiffEditReplace
+			case len(current.OldLines) > 0:
+				current.Kind = DiffEditDelete
+			default:
+				current.Kind = DiffEditInsert
+			}
+			current.OldCount = len(current.OldLines)
+			edits = append(edits, *current)
+			current = nil
+		}
+		for _, line := range hunk.lines {
+			switch {
+			case strings.HasPrefix(line, "\\"):
+			case strings.HasPrefix(line, "-"):
+				if current == nil {
+					current = &DiffEdit{OldStart: oldLine, NewStart: newLine}
+				}
+				current.OldLines = append(current.OldLines, line[1:])
+				oldLine++
+			case strings.HasPrefix(line, "+"):
+				if current == nil {
+					current = &DiffEdit{OldStart: oldLine, NewStart: newLine}
+				}
+				current.Lines = append(current.Lines, line[1:])
+				newLine++
+			default:
+				flush()
+				oldLine++
+				newLine++
+			}
+		}
+		flush()
+	}
+	return edits, nil
+}
+
+// parseSingleFileDiff parses raw into hunks, accepting diffs that start
+// directly at a hunk header.
+func parseSingleFileDiff(raw string, name string) ([]patchHunk, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(raw, "@@ ") {
+		raw = "--- a\n+++ b\n" + raw
+	}
+	files, err := parseUnifiedDiff(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 1 {
+		return nil, errors.New(name + " require a single-file diff")
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	return files[0].hunks, nil
+}
+
+// ApplyEdits applies edits produced by DiffEdits to content. Removed lines
+// are verified against content before any change is made.
+func ApplyEdits(content string, edits []DiffEdit) (string, error) {
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	prevEnd := 0
+	for i, edit := range edits {
+		start := edit.OldStart - 1
+		if start < prevEnd || start+edit.OldCount > len(lines) || start < 0 {
+			return "", fmt.Errorf("diff edit %d is out of range", i)
+		}
+		for j, expected := range edit.OldLines {
+			if lines[start+j] != expected {
+				return "", fmt.Errorf("diff edit %d does not match line %d", i, start+j+1)
+			}
+		}
+		prevEnd = start + edit.OldCount
+	}
+
+	for i := len(edits) - 1; i >= 0; i-- {
+		edit := edits[i]
+		start := edit.OldStart - 1
+		updated := make([]string, 0, len(lines)-edit.OldCount+len(edit.Lines))
+		updated = append(updated, lines[:start]...)
+		updated = append(updated, edit.Lines...)
+		updated = append(updated, lines[start+edit.OldCount:]...)
+		lines = updated
+	}
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+	result := strings.Join(lines, "\n")
+	if trailingNewline || content == "" {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// DiffLineKind is the kind of a line within a diff hunk.
+type DiffLineKind string
+
+const (
+	DiffLineContext DiffLineKind = "context"
+	DiffLineAdded   DiffLineKind = "added"
+	DiffLineDeleted DiffLineKind = "deleted"
+)
+
+// DiffLine is one line of a hunk. OldLine and NewLine are 1-based line
+// numbers, or zero on the side where the line does not exist.
+type DiffLine struct {
+	Kind    DiffLineKind
+	Content string
+	OldLine int
+	NewLine int
+	// NoNewlineAtEOF is set when the line is the last in its file and has
+	// no trailing newline.
+	NoNewlineAtEOF bool
+	// Changes holds the byte ranges of Content that differ from the paired
+	// line on the other side. Only set by DiffWordHunks.
+	Changes []DiffRange
+}
+
+// Hunk is a parsed unified-diff hunk. Section is the optional text after
+// the closing @@ of the header, usually the enclosing function.
+type Hunk struct {
+	OldStart int
+	OldCount int
+	NewStart int
+	NewCount int
+	Section  string
+	Lines    []DiffLine
+}
+
+// Hunks parses the unified diff of a file into hunks and line records.
+func (f FileDiff) Hunks() ([]Hunk, error) {
+	return DiffHunks(f.Raw)
+}
+
+// DiffHunks parses a single-file unified diff into hunks.
+func DiffHunks(raw string) ([]Hunk, error) {
+	parsed, err := parseSingleFileDiff(raw, "diff hunks")
+	if err != nil {
+		return nil, err
+	}
+
+	hunks := make([]Hunk, 0, len(parsed))
+	for _, ph := range parsed {
+		hunk := Hunk{
+			OldStart: ph.oldStart,
+			OldCount: ph.oldCount,
+			NewStart: ph.newStart,
+			NewCount: ph.newCount,
+			Section:  strings.TrimSpace(ph.section),
+		}
+		oldLine, newLine := ph.oldStart, ph.newStart
+		for _, line := range ph.lines {
+			switch {
+			case strings.HasPrefix(line, "\\"):
+				if n := len(hunk.Lines); n > 0 {
+					hunk.Lines[n-1].NoNewlineAtEOF = true
+				}
+			case strings.HasPrefix(line, "-"):
+				hunk.Lines = append(hunk.Lines, DiffLine{Kind: DiffLineDeleted, Content: line[1:], OldLine: oldLine})
+				oldLine++
+			case strings.HasPrefix(line, "+"):
+				hunk.Lines = append(hunk.Lines, DiffLine{Kind: DiffLineAdded, Content: line[1:], NewLine: newLine})
+				newLine++
+			default:
+				content := line
+				if content != "" {
+					content = content[1:]
+				}
+				hunk.Lines = append(hunk.Lines, DiffLine{Kind: DiffLineContext, Content: content, OldLine: oldLine, NewLine: newLine})
+				oldLine++
+				newLine++
+			}
+		}
+		hunks = append(hunks, hunk)
+	}
+	return hunks, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffEditsRoundTrip(t *testing.T) {
+	original := "one\ntwo\nthree\nfour\nfive\nsix\nseven\n"
+	raw := "diff --git a/f.txt b/f.txt\n--- a/f.txt\n+++ b/f.txt\n" +
+		"@@ -1,3 +1,4 @@\n+zero\n one\n two\n three\n" +
+		"@@ -4,4 +5,2 @@\n-four\n-five\n+FIVE\n six\n-seven\n"
+
+	edits, err := FileDiff{Raw: raw}.Edits()
+	if err != nil {
+		t.Fatalf("edits error: %v", err)
+	}
+	if len(edits) != 3 {
+		t.Fatalf("unexpected edits: %+v", edits)
+	}
+	if edits[0].Kind != DiffEditInsert || edits[0].OldStart != 1 || edits[0].Lines[0] != "zero" {
+		t.Fatalf("unexpected insert: %+v", edits[0])
+	}
+	if edits[1].Kind != DiffEditReplace || edits[1].OldStart != 4 || edits[1].OldCount != 2 || edits[1].NewStart != 5 {
+		t.Fatalf("unexpected replace: %+v", edits[1])
+	}
+	if edits[2].Kind != DiffEditDelete || edits[2].OldStart != 7 || edits[2].OldCount != 1 {
+		t.Fatalf("unexpected delete: %+v", edits[2])
+	}
+
+	updated, err := ApplyEdits(original, edits)
+	if err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if updated != "zero\none\ntwo\nthree\nFIVE\nsix\n" {
+		t.Fatalf("unexpected result: %q", updated)
+	}
+
+	if _, err := ApplyEdits("something else\n", edits); err == nil {
+		t.Fatalf("expected mismatch error")
+	}
+}
+
+func TestDiffEditsNewFile(t *testing.T) {
+	edits, err := DiffEdits("@@ -0,0 +1,2 @@\n+hello\n+world\n")
+	if err != nil {
+		t.Fatalf("edits error: %v", err)
+	}
+	if len(edits) != 1 || edits[0].Kind != DiffEditInsert || edits[0].OldStart != 1 {
+		t.Fatalf("unexpected edits: %+v", edits)
+	}
+	updated, err := ApplyEdits("", edits)
+	if err != nil || updated != "hello\nworld\n" {
+		t.Fatalf("unexpected result: %q %v", updated, err)
+	}
+}
+
+func TestDiffHunks(t *testing.T) {
+	raw := "diff --git a/f.go b/f.go\n--- a/f.go\n+++ b/f.go\n" +
+		"@@ -2,3 +2,3 @@ func main() {\n ctx := context()\n-run(ctx)\n+runAll(ctx)\n done()\n" +
+		"@@ -9,1 +9,2 @@\n last\n+tail\n\\ No newline at end of file\n"
+
+	hunks, err := FileDiff{Raw: raw}.Hunks()
+	if err != nil {
+		t.Fatalf("hunks error: %v", err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("unexpected hunks: %+v", hunks)
+	}
+	first := hunks[0]
+	if first.OldStart != 2 || first.OldCount != 3 || first.NewStart != 2 || first.NewCount != 3 || first.Section != "func main() {" {
+		t.Fatalf("unexpected first hunk header: %+v", first)
+	}
+	expected := []DiffLine{
+		{Kind: DiffLineContext, Content: "ctx := context()", OldLine: 2, NewLine: 2},
+		{Kind: DiffLineDeleted, Content: "run(ctx)", OldLine: 3},
+		{Kind: DiffLineAdded, Content: "runAll(ctx)", NewLine: 3},
+		{Kind: DiffLineContext, Content: "done()", OldLine: 4, NewLine: 4},
+	}
+	if len(first.Lines) != len(expected) {
+		t.Fatalf("unexpected lines: %+v", first.Lines)
+	}
+	for i, line := range expected {
+		if !reflect.DeepEqual(first.Lines[i], line) {
+			t.Fatalf("line %d: expected %+v, got %+v", i, line, first.Lines[i])
+		}
+	}
+
+	second := hunks[1]
+	if len(second.Lines) != 2 || !second.Lines[1].NoNewlineAtEOF || second.Lines[1].NewLine != 10 {
+		t.Fatalf("unexpected second hunk: %+v", second)
+	}
+
+	if _, err := DiffHunks("--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n+b\n--- a/y\n+++ b/y\n@@ -1 +1 @@\n-a\n+b\n"); err == nil {
+		t.Fatalf("expected single-file error")
+	}
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultFailoverCooldown = 30 * time.Second
+
+// EndpointConfig describes a secondary API endpoint used for failover.
+type EndpointConfig struct {
+	APIBaseURL string
+	Region     string
+}
+
+// endpointPool tracks endpoint health. Endpoints that fail at the transport
+// level are skipped until their cooldown expires, so traffic sticks to the
+// healthy secondary and returns to the primary once it recovers.
+type endpointPool struct {
+	urls     []string
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	downUntil []time.Time
+}
+
+func newEndpointPool(primary string, secondaries []EndpointConfig, cooldown time.Duration) *endpointPool {
+	urls := []string{strings.TrimRight(primary, "/")}
+	for _, endpoint := range secondaries {
+		base := strings.TrimRight(strings.TrimSpace(endpoint.APIBaseURL), "/")
+		if base == "" || base == urls[0] {
+			continue
+		}
+		urls = append(urls, base)
+	}
+	if cooldown <= 0 {
+		cooldown = defaultFailoverCooldown
+	}
+	return &endpointPool{urls: urls, cooldown: cooldown, downUntil: make([]time.Time, len(urls))}
+}
+
+func (p *endpointPool) baseURL(index int) string {
+	return p.urls[index]
+}
+
+// preferred returns the first endpoint that is not cooling down.
+func (p *endpointPool) preferred() int {
+	return p.order()[0]
+}
+
+// order returns healthy endpoints in configured order followed by those
+// still cooling down, which are tried only as a last resort.
+func (p *endpointPool) order() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	healthy := make([]int, 0, len(p.urls))
+	var cooling []int
+	for i := range p.urls {
+		if now.Before(p.downUntil[i]) {
+			cooling = append(cooling, i)
+			continue
+		}
+		healthy = append(healthy, i)
+	}
+	return append(healthy, cooling...)
+}
+
+func (p *endpointPool) markDown(index int) {
+	if len(p.urls) == 1 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.downUntil[index] = time.Now().Add(p.cooldown)
+}
+
+func (p *endpointPool) markUp(index int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.downUntil[index] = time.Time{}
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type regionTransport struct {
+	down     map[string]bool
+	attempts map[string]int
+}
+
+func (t *regionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.attempts[req.URL.Host]++
+	if t.down[req.URL.Host] {
+		return nil, errors.New("connection refused")
+	}
+	body := `{"paths":["README.md"],"ref":"main"}`
+	if req.Method != http.MethodGet {
+		body = `{"repo_id":"repo","message":"ok"}`
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func newFailoverClient(t *testing.T, transport *regionTransport, failoverWrites bool) *Client {
+	t.Helper()
+	client, err := NewClient(Options{
+		Name:             "acme",
+		Key:              testKey,
+		APIBaseURL:       "https://primary.example",
+		Endpoints:        []EndpointConfig{{APIBaseURL: "https://secondary.example", Region: "eu"}},
+		FailoverWrites:   failoverWrites,
+		FailoverCooldown: time.Hour,
+		HTTPClient:       &http.Client{Transport: transport},
+	})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	return client
+}
+
+func TestEndpointFailoverForReads(t *testing.T) {
+	transport := &regionTransport{down: map[string]bool{"primary.example": true}, attempts: map[string]int{}}
+	client := newFailoverClient(t, transport, false)
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	for i := 0; i < 2; i++ {
+		if _, err := repo.ListFiles(nil, ListFilesOptions{}); err != nil {
+			t.Fatalf("list files error: %v", err)
+		}
+	}
+	if transport.attempts["primary.example"] != 1 {
+		t.Fatalf("expected sticky failover to skip primary, got %d attempts", transport.attempts["primary.example"])
+	}
+	if transport.attempts["secondary.example"] != 2 {
+		t.Fatalf("expected secondary to serve reads, got %d attempts", transport.attempts["secondary.example"])
+	}
+}
+
+func TestEndpointFailoverForReadPosts(t *testing.T) {
+	transport := &regionTransport{down: map[string]bool{"primary.example": true}, attempts: map[string]int{}}
+	client := newFailoverClient(t, transport, false)
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	if _, err := repo.Grep(nil, GrepOptions{Query: GrepQuery{Pattern: "TODO"}}); err != nil {
+		t.Fatalf("grep error: %v", err)
+	}
+	if transport.attempts["secondary.example"] != 1 {
+		t.Fatalf("expected read POST to fail over, got %d secondary attempts", transport.attempts["secondary.example"])
+	}
+}
+
+func TestEndpointFailoverWrites(t *testing.T) {
+	transport := &regionTransport{down: map[string]bool{"primary.example": true}, attempts: map[string]int{}}
+	client := newFailoverClient(t, transport, false)
+	if _, err := client.DeleteRepo(nil, DeleteRepoOptions{ID: "repo"}); err == nil {
+		t.Fatalf("expected write to fail without FailoverWrites")
+	}
+	if transport.attempts["secondary.example"] != 0 {
+		t.Fatalf("expected write to stay on primary")
+	}
+
+	transport = &regionTransport{down: map[string]bool{"primary.example": true}, attempts: map[string]int{}}
+	client = newFailoverClient(t, transport, true)
+	if _, err := client.DeleteRepo(nil, DeleteRepoOptions{ID: "repo"}); err != nil {
+		t.Fatalf("expected write failover, got %v", err)
+	}
+}
+
+func TestEndpointPoolRecovers(t *testing.T) {
+	pool := newEndpointPool("https://primary.example", []EndpointConfig{{APIBaseURL: "https://secondary.example"}}, time.Millisecond)
+	pool.markDown(0)
+	if pool.preferred() != 1 {
+		t.Fatalf("expected secondary while primary cools down")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if pool.preferred() != 0 {
+		t.Fatalf("expected primary after cooldown")
+	}
+}
+
+func TestEndpointWritesStayOnPrimaryAfterReadFailover(t *testing.T) {
+	transport := &regionTransport{down: map[string]bool{"primary.example": true}, attempts: map[string]int{}}
+	client := newFailoverClient(t, transport, false)
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	if _, err := repo.ListFiles(nil, ListFilesOptions{}); err != nil {
+		t.Fatalf("list files error: %v", err)
+	}
+	transport.down["primary.example"] = false
+
+	if _, err := client.DeleteRepo(nil, DeleteRepoOptions{ID: "repo"}); err != nil {
+		t.Fatalf("delete repo error: %v", err)
+	}
+	if transport.attempts["secondary.example"] != 1 {
+		t.Fatalf("expected only the read on secondary, got %d attempts", transport.attempts["secondary.example"])
+	}
+	if got := client.api.basePath(); got != "https://primary.example/api/v1" {
+		t.Fatalf("expected streamed writes to target primary, got %q", got)
+	}
+}
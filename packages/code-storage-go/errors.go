@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"strings"
 )
 
@@ -32,6 +33,7 @@ const (
 	RefUpdateReasonUnavailable        RefUpdateReason = "unavailable"
 	RefUpdateReasonInternal           RefUpdateReason = "internal"
 	RefUpdateReasonFailed             RefUpdateReason = "failed"
+	RefUpdateReasonLegalHold          RefUpdateReason = "legal_hold"
 	RefUpdateReasonUnknown            RefUpdateReason = "unknown"
 )
 
@@ -73,6 +75,8 @@ func inferRefUpdateReason(status string) RefUpdateReason {
 		return RefUpdateReasonInternal
 	case "failed":
 		return RefUpdateReasonFailed
+	case "legal_hold":
+		return RefUpdateReasonLegalHold
 	case "ok":
 		return RefUpdateReasonUnknown
 	default:
@@ -88,3 +92,59 @@ func newRefUpdateError(message string, status string, refUpdate *RefUpdate) *Ref
 		RefUpdate: refUpdate,
 	}
 }
+
+// ErrTagUnsigned is returned by TagDetails.VerifySignature for tags that
+// carry no signature.
+var ErrTagUnsigned = errors.New("tag is not signed")
+
+// ErrTagPayloadMismatch is returned by TagDetails.VerifySignature when the
+// signed payload does not belong to the tag it was returned with.
+var ErrTagPayloadMismatch = errors.New("tag signed payload does not match tag")
+
+// TagExistsError is returned by CreateTag when the tag name is taken.
+// TargetSHA is the object the existing tag points at, so callers can treat
+// a retry that finds the same target as success.
+type TagExistsError struct {
+	Name      string
+	TargetSHA string
+}
+
+func (e *TagExistsError) Error() string {
+	return "tag " + e.Name + " already exists"
+}
+
+// FileConflict describes a file whose current blob SHA did not match the
+// ExpectedBlobSHA of a commit operation. ActualBlobSHA is empty when the
+// file no longer exists.
+type FileConflict struct {
+	Path            string
+	ExpectedBlobSHA string
+	ActualBlobSHA   string
+}
+
+// FileConflictError describes a commit rejected by per-file blob SHA
+// preconditions. It unwraps to the underlying *RefUpdateError.
+type FileConflictError struct {
+	*RefUpdateError
+	Conflicts []FileConflict
+}
+
+func (e *FileConflictError) Unwrap() error {
+	return e.RefUpdateError
+}
+
+func newCommitError(message string, status string, refUpdate *RefUpdate, conflicts []fileConflictRaw) error {
+	refErr := newRefUpdateError(message, status, refUpdate)
+	if len(conflicts) == 0 {
+		return refErr
+	}
+	result := &FileConflictError{RefUpdateError: refErr}
+	for _, raw := range conflicts {
+		result.Conflicts = append(result.Conflicts, FileConflict{
+			Path:            raw.Path,
+			ExpectedBlobSHA: raw.ExpectedBlobSHA,
+			ActualBlobSHA:   raw.ActualBlobSHA,
+		})
+	}
+	return result
+}
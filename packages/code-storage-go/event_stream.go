@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultEventStreamInitialBackoff = time.Second
+	defaultEventStreamMaxBackoff     = 30 * time.Second
+)
+
+// Repo event types delivered by StreamEvents.
+const (
+	RepoEventRefUpdated    = "ref.updated"
+	RepoEventCommitCreated = "commit.created"
+)
+
+// StreamEventsOptions configures StreamEvents.
+type StreamEventsOptions struct {
+	InvocationOptions
+	// Types limits the stream to these event types. Empty streams all.
+	Types []string
+	// ResumeToken continues after an event from a previous stream, usually
+	// EventStream.ResumeToken saved before shutting down.
+	ResumeToken string
+	// InitialBackoff is the delay before the first reconnect. Zero uses
+	// one second, or the server's retry hint when it sends one.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponential reconnect delay. Zero uses 30s.
+	MaxBackoff time.Duration
+	// MaxReconnects bounds the reconnects made without receiving an event
+	// before the stream gives up. Zero retries until the context ends.
+	MaxReconnects int
+}
+
+// RepoEvent is one event from StreamEvents. Exactly one of RefUpdate,
+// Commit, or Unknown is set.
+type RepoEvent struct {
+	// ID is the server's event ID, usable as a resume token.
+	ID        string
+	Type      string
+	RefUpdate *RepoRefUpdateEvent
+	Commit    *RepoCommitEvent
+	Unknown   *RepoUnknownEvent
+}
+
+// RepoRefUpdateEvent reports a ref moving, being created (Before is all
+// zeros), or being deleted (After is all zeros).
+type RepoRefUpdateEvent struct {
+	Ref          string
+	Before       string
+	After        string
+	UpdatedAt    time.Time
+	RawUpdatedAt string
+}
+
+// RepoCommitEvent reports a commit that became reachable from Ref.
+type RepoCommitEvent struct {
+	Ref    string
+	Commit CommitInfo
+}
+
+// RepoUnknownEvent is a fallback for event types this SDK does not model.
+type RepoUnknownEvent struct {
+	Type string
+	Raw  []byte
+}
+
+// EventStream delivers repository events as they happen over server-sent
+// events. It reconnects after network failures and resumes from the last
+// delivered event, so events are neither skipped nor repeated across
+// reconnects. Use it like bufio.Scanner:
+//
+//	for stream.Next() {
+//		event := stream.Event()
+//	}
+//	if err := stream.Err(); err != nil { ... }
+type EventStream struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	repo       *Repo
+	options    StreamEventsOptions
+	mu         sync.Mutex
+	body       io.ReadCloser
+	reader     *bufio.Reader
+	current    RepoEvent
+	lastID     string
+	retry      time.Duration
+	reconnects int
+	err        error
+}
+
+// StreamEvents opens a stream of ref-update and commit events for the
+// repository. The first connection is made before returning, so
+// authentication and permission errors surface here. Close the stream when
+// done.
+func (r *Repo) StreamEvents(ctx context.Context, options StreamEventsOptions) (*EventStream, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if options.InitialBackoff < 0 || options.MaxBackoff < 0 || options.MaxReconnects < 0 {
+		return nil, errors.New("streamEvents backoff and maxReconnects must not be negative")
+	}
+	if options.MaxBackoff == 0 {
+		options.MaxBackoff = defaultEventStreamMaxBackoff
+	}
+	streamCtx, cancel := context.WithCancel(ctx)
+	s := &EventStream{
+		ctx:     streamCtx,
+		cancel:  cancel,
+		repo:    r,
+		options: options,
+		lastID:  strings.TrimSpace(options.ResumeToken),
+		retry:   options.InitialBackoff,
+	}
+	if err := s.connect(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Next blocks until the next event arrives. It returns false when the
+// stream is closed, its context ends, or reconnecting fails.
+func (s *EventStream) Next() bool {
+	for s.err == nil {
+		if s.ctx.Err() != nil {
+			s.fail(s.ctx.Err())
+			return false
+		}
+		if s.reader == nil {
+			if err := s.reconnect(); err != nil {
+				s.fail(err)
+				return false
+			}
+		}
+		event, ok, err := s.readEvent()
+		if err != nil {
+			s.closeBody()
+			s.reader = nil
+			if s.ctx.Err() != nil {
+				s.fail(s.ctx.Err())
+				return false
+			}
+			continue
+		}
+		if ok {
+			s.current = event
+			return true
+		}
+	}
+	return false
+}
+
+// Event returns the event read by the last call to Next.
+func (s *EventStream) Event() RepoEvent {
+	return s.current
+}
+
+// ResumeToken identifies the last delivered event. Pass it as
+// StreamEventsOptions.ResumeToken to continue after a restart.
+func (s *EventStream) ResumeToken() string {
+	return s.lastID
+}
+
+// Err returns the error that stopped the stream. Closing the stream or
+// cancelling its context is not reported as an error.
+func (s *EventStream) Err() error {
+	if errors.Is(s.err, context.Canceled) {
+		return nil
+	}
+	return s.err
+}
+
+// Close stops the stream and releases its connection. It may be called
+// from another goroutine to unblock Next.
+func (s *EventStream) Close() error {
+	s.cancel()
+	return s.closeBody()
+}
+
+func (s *EventStream) closeBody() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.body == nil {
+		return nil
+	}
+	err := s.body.Close()
+	s.body = nil
+	return err
+}
+
+func (s *EventStream) fail(err error) {
+	s.err = err
+	s.closeBody()
+}
+
+func (s *EventStream) connect() error {
+	// A fresh token per connection keeps long-lived streams from outliving
+	// their JWT.
+	ttl := resolveInvocationTTL(s.ctx, s.options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := s.repo.client.generateJWT(s.repo.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return err
+	}
+
+	var params url.Values
+	if len(s.options.Types) > 0 {
+		params = url.Values{"types": {strings.Join(s.options.Types, ",")}}
+	}
+	header := http.Header{"Accept": {"text/event-stream"}}
+	if s.lastID != "" {
+		header.Set("Last-Event-ID", s.lastID)
+	}
+	opts := withRequestHeader(streamRequestOptions(s.options.InvocationOptions), header)
+
+	resp, err := s.repo.client.api.get(s.ctx, "repos/events", params, jwtToken, opts)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil {
+		resp.Body.Close()
+		return s.ctx.Err()
+	}
+	s.body = resp.Body
+	s.reader = bufio.NewReader(resp.Body)
+	return nil
+}
+
+func (s *EventStream) reconnect() error {
+	for {
+		if s.options.MaxReconnects > 0 && s.reconnects >= s.options.MaxReconnects {
+			return errors.New("streamEvents gave up after " + itoa(s.reconnects) + " reconnects")
+		}
+		delay := s.retry
+		if delay <= 0 {
+			delay = defaultEventStreamInitialBackoff
+		}
+		delay <<= s.reconnects
+		if delay > s.options.MaxBackoff || delay <= 0 {
+			delay = s.options.MaxBackoff
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			return s.ctx.Err()
+		}
+
+		s.reconnects++
+		err := s.connect()
+		if err == nil {
+			return nil
+		}
+		var apiErr *APIError
+		if s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+		if errors.As(err, &apiErr) && apiErr.Status < 500 && apiErr.Status != http.StatusTooManyRequests && apiErr.Status != http.StatusRequestTimeout {
+			return err
+		}
+	}
+}
+
+// readEvent reads one server-sent event. Comments, retry hints, and
+// events without data are consumed without producing an event.
+func (s *EventStream) readEvent() (RepoEvent, bool, error) {
+	var id, eventType string
+	var data []string
+	hasID := false
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return RepoEvent{}, false, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id, hasID = value, true
+		case "event":
+			eventType = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				s.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if hasID {
+		s.lastID = id
+	}
+	if len(data) == 0 {
+		return RepoEvent{}, false, nil
+	}
+	// A delivered event means the connection is healthy again.
+	s.reconnects = 0
+	if eventType == "" {
+		eventType = "message"
+	}
+	return convertRepoEvent(id, eventType, []byte(strings.Join(data, "\n"))), true, nil
+}
+
+type rawRepoRefUpdateEvent struct {
+	Ref       string `json:"ref"`
+	Before    string `json:"before"`
+	After     string `json:"after"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type rawRepoCommitEvent struct {
+	Ref    string        `json:"ref"`
+	Commit commitInfoRaw `json:"commit"`
+}
+
+func convertRepoEvent(id string, eventType string, data []byte) RepoEvent {
+	event := RepoEvent{ID: id, Type: eventType}
+	switch eventType {
+	case RepoEventRefUpdated:
+		var raw rawRepoRefUpdateEvent
+		if err := json.Unmarshal(data, &raw); err == nil && raw.Ref != "" {
+			event.RefUpdate = &RepoRefUpdateEvent{Ref: raw.Ref, Before: raw.Before, After: raw.After, UpdatedAt: parseTime(raw.UpdatedAt), RawUpdatedAt: raw.UpdatedAt}
+			return event
+		}
+	case RepoEventCommitCreated:
+		var raw rawRepoCommitEvent
+		if err := json.Unmarshal(data, &raw); err == nil && raw.Commit.SHA != "" {
+			event.Commit = &RepoCommitEvent{Ref: raw.Ref, Commit: transformCommitInfo(raw.Commit)}
+			return event
+		}
+	}
+	event.Unknown = &RepoUnknownEvent{Type: eventType, Raw: data}
+	return event
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStreamEvents(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/events" || r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("unexpected request: %s %v", r.URL.Path, r.Header)
+		}
+		if r.URL.Query().Get("types") != "ref.updated,commit.created,branch.protected" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch atomic.AddInt32(&connections, 1) {
+		case 1:
+			if r.Header.Get("Last-Event-ID") != "evt_0" {
+				t.Errorf("expected resume token on first connect, got %q", r.Header.Get("Last-Event-ID"))
+			}
+			fmt.Fprint(w, "retry: 1\n\n")
+			fmt.Fprint(w, "id: evt_1\nevent: ref.updated\ndata: {\"ref\":\"refs/heads/main\",\"before\":\"abc\",\"after\":\"def\",\"updated_at\":\"2024-01-20T10:30:00Z\"}\n\n")
+			fmt.Fprint(w, ": heartbeat\n\n")
+			fmt.Fprint(w, "id: evt_2\nevent: commit.created\ndata: {\"ref\":\"refs/heads/main\",\n")
+			fmt.Fprint(w, "data: \"commit\":{\"sha\":\"def\",\"message\":\"agent edit\",\"date\":\"2024-01-20T10:29:00Z\"}}\n\n")
+			// Drop the connection to force a reconnect.
+		case 2:
+			if r.Header.Get("Last-Event-ID") != "evt_2" {
+				t.Errorf("expected reconnect to resume after evt_2, got %q", r.Header.Get("Last-Event-ID"))
+			}
+			fmt.Fprint(w, "id: evt_3\nevent: branch.protected\ndata: {\"branch\":\"main\"}\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	stream, err := repo.StreamEvents(nil, StreamEventsOptions{
+		Types:       []string{RepoEventRefUpdated, RepoEventCommitCreated, "branch.protected"},
+		ResumeToken: "evt_0",
+		MaxBackoff:  10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("stream events error: %v", err)
+	}
+
+	var events []RepoEvent
+	for len(events) < 3 && stream.Next() {
+		events = append(events, stream.Event())
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if ref := events[0].RefUpdate; ref == nil || ref.Ref != "refs/heads/main" || ref.After != "def" || ref.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected ref update: %+v", events[0])
+	}
+	if commit := events[1].Commit; commit == nil || commit.Commit.SHA != "def" || commit.Commit.Message != "agent edit" {
+		t.Fatalf("unexpected commit event: %+v", events[1])
+	}
+	if unknown := events[2].Unknown; unknown == nil || unknown.Type != "branch.protected" || string(unknown.Raw) != `{"branch":"main"}` {
+		t.Fatalf("unexpected unknown event: %+v", events[2])
+	}
+	if stream.ResumeToken() != "evt_3" {
+		t.Fatalf("unexpected resume token: %s", stream.ResumeToken())
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if stream.Next() || stream.Err() != nil {
+		t.Fatalf("expected closed stream to stop without error, got %v", stream.Err())
+	}
+
+	if _, err := repo.StreamEvents(nil, StreamEventsOptions{Types: []string{RepoEventRefUpdated, RepoEventCommitCreated, "branch.protected"}}); err == nil {
+		t.Fatalf("expected initial connection error")
+	}
+}
+
+func TestStreamEventsCloseUnblocksNext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+	stream, err := repo.StreamEvents(nil, StreamEventsOptions{})
+	if err != nil {
+		t.Fatalf("stream events error: %v", err)
+	}
+
+	time.AfterFunc(20*time.Millisecond, func() { _ = stream.Close() })
+	if stream.Next() {
+		t.Fatalf("expected no events")
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("expected close not to be reported as an error, got %v", err)
+	}
+}
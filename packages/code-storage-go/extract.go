@@ -0,0 +1,363 @@
+package storage
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SymlinkPolicy controls how DownloadAndExtract handles symlink entries.
+type SymlinkPolicy string
+
+const (
+	// SymlinkPolicySkip ignores symlink entries.
+	SymlinkPolicySkip SymlinkPolicy = "skip"
+	// SymlinkPolicyWithinDest creates symlinks whose target resolves inside
+	// the destination directory and fails on any other.
+	SymlinkPolicyWithinDest SymlinkPolicy = "within_dest"
+	// SymlinkPolicyError fails on any symlink entry.
+	SymlinkPolicyError SymlinkPolicy = "error"
+)
+
+// ExtractArchiveOptions configures DownloadAndExtract.
+type ExtractArchiveOptions struct {
+	ArchiveOptions
+	// Symlinks defaults to SymlinkPolicySkip.
+	Symlinks SymlinkPolicy
+}
+
+// ExtractedFile describes one entry written by DownloadAndExtract.
+type ExtractedFile struct {
+	// Path is slash-separated and relative to the destination directory.
+	Path       string
+	Mode       os.FileMode
+	Size       int64
+	LinkTarget string
+}
+
+// DownloadAndExtract streams an archive into destDir. Entries that would
+// escape destDir are rejected, file modes are preserved, and symlinks follow
+// options.Symlinks. Zip archives are spooled to a temporary file first.
+func (r *Repo) DownloadAndExtract(ctx context.Context, options ExtractArchiveOptions, destDir string) ([]ExtractedFile, error) {
+	if strings.TrimSpace(destDir) == "" {
+		return nil, errors.New("downloadAndExtract destDir is required")
+	}
+	if options.Compression == ArchiveCompressionZstd {
+		return nil, errors.New("downloadAndExtract supports gzip tarballs and zip archives only")
+	}
+	switch options.Symlinks {
+	case "":
+		options.Symlinks = SymlinkPolicySkip
+	case SymlinkPolicySkip, SymlinkPolicyWithinDest, SymlinkPolicyError:
+	default:
+		return nil, errors.New("downloadAndExtract symlinks must be skip, within_dest, or error")
+	}
+
+	root, err := filepath.Abs(destDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.ArchiveStream(ctx, options.ArchiveOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	extractor := &archiveExtractor{root: root, realRoot: realRoot, symlinks: options.Symlinks}
+	if options.Format == ArchiveFormatZip {
+		err = extractor.extractZip(resp.Body)
+	} else {
+		err = extractor.extractTarGz(resp.Body)
+	}
+	if err == nil {
+		// Drain any padding after the last entry so the archive checksum is
+		// verified.
+		_, err = io.Copy(io.Discard, resp.Body)
+	}
+	return extractor.files, err
+}
+
+type archiveExtractor struct {
+	root     string
+	realRoot string
+	symlinks SymlinkPolicy
+	files    []ExtractedFile
+}
+
+func (e *archiveExtractor) extractTarGz(body io.Reader) error {
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return fmt.Errorf("downloadAndExtract gzip: %w", err)
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("downloadAndExtract tar: %w", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := e.mkdir(header.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := e.writeFile(header.Name, os.FileMode(header.Mode), reader); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := e.symlink(header.Name, header.Linkname); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (e *archiveExtractor) extractZip(body io.Reader) error {
+	spool, err := os.CreateTemp("", "code-storage-archive-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, body)
+	if err != nil {
+		return err
+	}
+	reader, err := zip.NewReader(spool, size)
+	if err != nil {
+		return fmt.Errorf("downloadAndExtract zip: %w", err)
+	}
+
+	for _, file := range reader.File {
+		mode := file.Mode()
+		switch {
+		case mode.IsDir():
+			if err := e.mkdir(file.Name); err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			target, err := readZipEntry(file)
+			if err != nil {
+				return err
+			}
+			if err := e.symlink(file.Name, string(target)); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := file.Open()
+			if err != nil {
+				return err
+			}
+			err = e.writeFile(file.Name, mode, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readZipEntry(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// resolve maps an archive entry name to a path inside root.
+func (e *archiveExtractor) resolve(name string) (string, string, error) {
+	cleaned := path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	rel := strings.TrimPrefix(cleaned, "/")
+	if rel == "" || strings.Contains(name, "\x00") {
+		return "", "", fmt.Errorf("downloadAndExtract invalid entry name %q", name)
+	}
+	if path.IsAbs(name) || strings.HasPrefix(path.Clean(name), "../") || path.Clean(name) == ".." {
+		return "", "", fmt.Errorf("downloadAndExtract entry %q escapes destination", name)
+	}
+	return rel, filepath.Join(e.root, filepath.FromSlash(rel)), nil
+}
+
+func (e *archiveExtractor) mkdir(name string) error {
+	rel, target, err := e.resolve(name)
+	if err != nil {
+		return err
+	}
+	if err := e.checkParents(target); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return err
+	}
+	e.files = append(e.files, ExtractedFile{Path: rel, Mode: os.ModeDir | 0o755})
+	return nil
+}
+
+func (e *archiveExtractor) writeFile(name string, mode os.FileMode, contents io.Reader) error {
+	rel, target, err := e.resolve(name)
+	if err != nil {
+		return err
+	}
+	if err := e.checkParents(target); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	perm := os.FileMode(0o644)
+	if mode&0o111 != 0 {
+		perm = 0o755
+	}
+	_ = os.Remove(target)
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	size, err := io.Copy(file, contents)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	e.files = append(e.files, ExtractedFile{Path: rel, Mode: perm, Size: size})
+	return nil
+}
+
+func (e *archiveExtractor) symlink(name string, linkTarget string) error {
+	switch e.symlinks {
+	case SymlinkPolicySkip:
+		return nil
+	case SymlinkPolicyError:
+		return fmt.Errorf("downloadAndExtract symlink entry %q is not allowed", name)
+	}
+
+	rel, target, err := e.resolve(name)
+	if err != nil {
+		return err
+	}
+	if filepath.IsAbs(linkTarget) || path.IsAbs(linkTarget) {
+		return fmt.Errorf("downloadAndExtract symlink %q has an absolute target", name)
+	}
+	if !e.linkWithin(filepath.Dir(target), linkTarget) {
+		return fmt.Errorf("downloadAndExtract symlink %q escapes destination", name)
+	}
+	if err := e.checkParents(target); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	_ = os.Remove(target)
+	if err := os.Symlink(linkTarget, target); err != nil {
+		return err
+	}
+	e.files = append(e.files, ExtractedFile{Path: rel, Mode: os.ModeSymlink | 0o777, LinkTarget: linkTarget})
+	return nil
+}
+
+// checkParents rejects writes through previously extracted symlinks that
+// resolve outside root, including through directories that do not exist
+// yet but would be created under a symlinked ancestor.
+func (e *archiveExtractor) checkParents(target string) error {
+	resolved, err := realPath(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if !e.within(resolved) {
+		return fmt.Errorf("downloadAndExtract entry %q escapes destination", target)
+	}
+	return nil
+}
+
+// linkWithin reports whether linkTarget, relative to dir, stays inside
+// root. The target is walked one component at a time against the real
+// filesystem, so ".." applies to where a symlinked component points rather
+// than to its lexical parent. A ".." after a component that does not exist
+// yet cannot be checked and is rejected.
+func (e *archiveExtractor) linkWithin(dir string, linkTarget string) bool {
+	current, err := realPath(dir)
+	if err != nil {
+		return false
+	}
+	missing := false
+	for _, part := range strings.Split(filepath.ToSlash(linkTarget), "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if missing {
+				return false
+			}
+			current = filepath.Dir(current)
+		default:
+			current = filepath.Join(current, part)
+			if missing {
+				continue
+			}
+			resolved, err := filepath.EvalSymlinks(current)
+			if err != nil {
+				missing = true
+				continue
+			}
+			current = resolved
+		}
+	}
+	return e.within(current)
+}
+
+// realPath resolves the symlinks in the longest existing prefix of p and
+// appends the remaining components unchanged.
+func realPath(p string) (string, error) {
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(p))
+		p = parent
+	}
+}
+
+// within reports whether resolved, a path whose symlinks have already been
+// resolved, is inside root.
+func (e *archiveExtractor) within(resolved string) bool {
+	rel, err := filepath.Rel(e.realRoot, resolved)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
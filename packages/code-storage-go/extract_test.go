@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	name     string
+	body     string
+	mode     int64
+	typeflag byte
+	link     string
+}
+
+func buildTarGz(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: entry.mode, Typeflag: entry.typeflag, Linkname: entry.link, Size: int64(len(entry.body))}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(entry.body)); err != nil {
+			t.Fatalf("tar write: %v", err)
+		}
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+	return buf.Bytes()
+}
+
+func newArchiveRepo(t *testing.T, archive []byte) (*Repo, func()) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write(archive)
+	}))
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	return &Repo{ID: "repo", DefaultBranch: "main", client: client}, server.Close
+}
+
+func TestDownloadAndExtract(t *testing.T) {
+	archive := buildTarGz(t, []tarEntry{
+		{name: "repo/", typeflag: tar.TypeDir, mode: 0o755},
+		{name: "repo/README.md", body: "hello", typeflag: tar.TypeReg, mode: 0o644},
+		{name: "repo/bin/run.sh", body: "#!/bin/sh", typeflag: tar.TypeReg, mode: 0o755},
+		{name: "repo/docs", typeflag: tar.TypeSymlink, link: "README.md"},
+	})
+	repo, closeServer := newArchiveRepo(t, archive)
+	defer closeServer()
+
+	dest := t.TempDir()
+	files, err := repo.DownloadAndExtract(nil, ExtractArchiveOptions{Symlinks: SymlinkPolicyWithinDest}, dest)
+	if err != nil {
+		t.Fatalf("extract error: %v", err)
+	}
+	if len(files) != 4 || files[1].Path != "repo/README.md" || files[1].Size != 5 {
+		t.Fatalf("unexpected files: %+v", files)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "repo", "README.md"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected README: %q %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dest, "repo", "bin", "run.sh"))
+	if err != nil || info.Mode().Perm()&0o111 == 0 {
+		t.Fatalf("expected executable mode: %v %v", info, err)
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "repo", "docs")); err != nil || target != "README.md" {
+		t.Fatalf("unexpected symlink: %q %v", target, err)
+	}
+}
+
+func TestDownloadAndExtractRejectsEscapes(t *testing.T) {
+	cases := map[string][]tarEntry{
+		"traversal": {{name: "../evil.txt", body: "x", typeflag: tar.TypeReg, mode: 0o644}},
+		"symlink":   {{name: "link", typeflag: tar.TypeSymlink, link: "../../etc"}},
+		"absolute":  {{name: "abs", typeflag: tar.TypeSymlink, link: "/etc/passwd"}},
+	}
+	for name, entries := range cases {
+		repo, closeServer := newArchiveRepo(t, buildTarGz(t, entries))
+		dest := t.TempDir()
+		_, err := repo.DownloadAndExtract(nil, ExtractArchiveOptions{Symlinks: SymlinkPolicyWithinDest}, dest)
+		closeServer()
+		if err == nil || !strings.Contains(err.Error(), "downloadAndExtract") {
+			t.Fatalf("%s: expected escape error, got %v", name, err)
+		}
+	}
+
+	// d/.. looks like the root lexically but is the parent of dest once d
+	// resolves to dest itself.
+	chained := buildTarGz(t, []tarEntry{
+		{name: "d", typeflag: tar.TypeSymlink, link: "."},
+		{name: "e", typeflag: tar.TypeSymlink, link: "d/.."},
+		{name: "e/escaped/", typeflag: tar.TypeDir, mode: 0o755},
+	})
+	parent := t.TempDir()
+	repo, closeServer := newArchiveRepo(t, chained)
+	_, err := repo.DownloadAndExtract(nil, ExtractArchiveOptions{Symlinks: SymlinkPolicyWithinDest}, filepath.Join(parent, "dest"))
+	closeServer()
+	if err == nil || !strings.Contains(err.Error(), "escapes destination") {
+		t.Fatalf("expected chained symlink escape error, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(parent, "escaped")); !os.IsNotExist(err) {
+		t.Fatalf("extraction wrote outside the destination")
+	}
+
+	relative := buildTarGz(t, []tarEntry{
+		{name: "README.md", body: "hello", typeflag: tar.TypeReg, mode: 0o644},
+		{name: "docs/", typeflag: tar.TypeDir, mode: 0o755},
+		{name: "docs/readme", typeflag: tar.TypeSymlink, link: "../README.md"},
+	})
+	repo, closeServer = newArchiveRepo(t, relative)
+	_, err = repo.DownloadAndExtract(nil, ExtractArchiveOptions{Symlinks: SymlinkPolicyWithinDest}, t.TempDir())
+	closeServer()
+	if err != nil {
+		t.Fatalf("expected parent-relative link inside dest to extract, got %v", err)
+	}
+
+	repo, closeServer = newArchiveRepo(t, buildTarGz(t, []tarEntry{{name: "link", typeflag: tar.TypeSymlink, link: "target"}}))
+	defer closeServer()
+	dest := t.TempDir()
+	files, err := repo.DownloadAndExtract(nil, ExtractArchiveOptions{}, dest)
+	if err != nil || len(files) != 0 {
+		t.Fatalf("expected symlink to be skipped: %+v %v", files, err)
+	}
+	if _, err := repo.DownloadAndExtract(nil, ExtractArchiveOptions{Symlinks: SymlinkPolicyError}, dest); err == nil {
+		t.Fatalf("expected symlink policy error")
+	}
+}
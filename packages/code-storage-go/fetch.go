@@ -11,62 +11,181 @@ import (
 )
 
 type apiFetcher struct {
-	baseURL    string
-	version    int
-	httpClient *http.Client
+	endpoints      *endpointPool
+	version        int
+	httpClient     *http.Client
+	limiter        RateLimiter
+	failoverWrites bool
+	reads          *readFlightGroup
+	dryRun         bool
+	onDryRun       func(DryRunResult)
 }
 
-func newAPIFetcher(baseURL string, version int, client *http.Client) *apiFetcher {
+func newAPIFetcher(options Options) *apiFetcher {
+	client := options.HTTPClient
 	if client == nil {
 		client = http.DefaultClient
 	}
-	return &apiFetcher{baseURL: strings.TrimRight(baseURL, "/"), version: version, httpClient: client}
+	fetcher := &apiFetcher{
+		endpoints:      newEndpointPool(options.APIBaseURL, options.Endpoints, options.FailoverCooldown),
+		version:        options.APIVersion,
+		httpClient:     client,
+		limiter:        options.RateLimiter,
+		failoverWrites: options.FailoverWrites,
+		dryRun:         options.DryRun,
+		onDryRun:       options.OnDryRun,
+	}
+	if options.DeduplicateReads {
+		fetcher.reads = newReadFlightGroup()
+	}
+	return fetcher
 }
 
+// basePath returns the base URL for writes. Writes stay on the primary
+// endpoint unless FailoverWrites allows them to follow read failover.
 func (f *apiFetcher) basePath() string {
-	return f.baseURL + "/api/v" + itoa(f.version)
+	return f.basePathFor(f.writeEndpoint())
+}
+
+func (f *apiFetcher) writeEndpoint() int {
+	if f.failoverWrites {
+		return f.endpoints.preferred()
+	}
+	return 0
+}
+
+func (f *apiFetcher) basePathFor(index int) string {
+	return f.endpoints.baseURL(index) + "/api/v" + itoa(f.version)
 }
 
-func (f *apiFetcher) buildURL(path string, params url.Values) string {
+func (f *apiFetcher) buildURL(index int, path string, params url.Values) string {
 	if params == nil || len(params) == 0 {
-		return f.basePath() + "/" + path
+		return f.basePathFor(index) + "/" + path
 	}
-	return f.basePath() + "/" + path + "?" + params.Encode()
+	return f.basePathFor(index) + "/" + path + "?" + params.Encode()
 }
 
+const readPreferenceHeader = "Code-Storage-Read-Preference"
+
 type requestOptions struct {
-	allowedStatus map[int]bool
+	allowedStatus  map[int]bool
+	readPreference ReadPreference
+	header         http.Header
+	// stream skips read deduplication, which buffers whole bodies.
+	stream bool
+	// read marks a request that does not mutate state, even when it is
+	// sent as a POST, so dry runs let it through.
+	read bool
+}
+
+func readRequestOptions(invocation InvocationOptions) *requestOptions {
+	return &requestOptions{readPreference: invocation.ReadPreference, read: true}
+}
+
+// streamRequestOptions is readRequestOptions for reads whose body is handed
+// to the caller unread, so deduplication never buffers it.
+func streamRequestOptions(invocation InvocationOptions) *requestOptions {
+	opts := readRequestOptions(invocation)
+	opts.stream = true
+	return opts
+}
+
+func (opts *requestOptions) isRead(method string) bool {
+	if opts != nil && opts.read {
+		return true
+	}
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func withRequestHeader(opts *requestOptions, header http.Header) *requestOptions {
+	if len(header) == 0 {
+		return opts
+	}
+	if opts == nil {
+		opts = &requestOptions{}
+	}
+	opts.header = header
+	return opts
 }
 
 func (f *apiFetcher) request(ctx context.Context, method string, path string, params url.Values, body interface{}, jwt string, opts *requestOptions) (*http.Response, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	if f.dryRun && !opts.isRead(method) {
+		return nil, f.dryRunJSON(method, path, params, body, jwt)
+	}
+	if f.reads != nil && method == http.MethodGet && (opts == nil || !opts.stream) {
+		return f.reads.do(ctx, readFlightKey(path, params, jwt, opts), func(ctx context.Context) (*http.Response, error) {
+			return f.do(ctx, method, path, params, body, jwt, opts)
+		})
+	}
+	return f.do(ctx, method, path, params, body, jwt, opts)
+}
 
-	urlStr := f.buildURL(path, params)
-	var bodyReader io.Reader
+func (f *apiFetcher) do(ctx context.Context, method string, path string, params url.Values, body interface{}, jwt string, opts *requestOptions) (*http.Response, error) {
+	var payload []byte
 	if body != nil {
-		payload, err := json.Marshal(body)
+		encoded, err := json.Marshal(body)
 		if err != nil {
 			return nil, err
 		}
-		bodyReader = bytes.NewReader(payload)
+		payload = encoded
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, urlStr, bodyReader)
-	if err != nil {
+	if err := f.wait(ctx); err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+jwt)
-	req.Header.Set("Code-Storage-Agent", userAgent())
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
+	candidates := []int{0}
+	if opts.isRead(method) || f.failoverWrites {
+		candidates = f.endpoints.order()
 	}
 
-	resp, err := f.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	var resp *http.Response
+	var urlStr string
+	var lastErr error
+	for _, index := range candidates {
+		urlStr = f.buildURL(index, path, params)
+		var bodyReader io.Reader
+		if body != nil {
+			bodyReader = bytes.NewReader(payload)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, urlStr, bodyReader)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+jwt)
+		req.Header.Set("Code-Storage-Agent", userAgent())
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if opts != nil {
+			for key, values := range opts.header {
+				req.Header[key] = values
+			}
+			if opts.readPreference != "" {
+				req.Header.Set(readPreferenceHeader, string(opts.readPreference))
+			}
+		}
+
+		resp, err = f.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			f.endpoints.markDown(index)
+			lastErr = err
+			continue
+		}
+		f.endpoints.markUp(index)
+		lastErr = nil
+		break
+	}
+	if lastErr != nil {
+		return nil, lastErr
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
@@ -75,41 +194,47 @@ func (f *apiFetcher) request(ctx context.Context, method string, path string, pa
 		}
 
 		defer resp.Body.Close()
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		var parsed interface{}
-		message := ""
-		contentType := resp.Header.Get("content-type")
-		if strings.Contains(contentType, "application/json") {
-			var payload map[string]interface{}
-			if err := json.Unmarshal(bodyBytes, &payload); err == nil {
-				parsed = payload
-				if errVal, ok := payload["error"].(string); ok && strings.TrimSpace(errVal) != "" {
-					message = strings.TrimSpace(errVal)
-				}
-			}
-		}
-		if message == "" && len(bodyBytes) > 0 {
-			message = strings.TrimSpace(string(bodyBytes))
-			if message != "" {
-				parsed = message
+		return nil, newAPIError(resp, method, urlStr)
+	}
+
+	return resp, nil
+}
+
+// newAPIError reads a non-2xx response into an *APIError, using the JSON
+// "error" field or the plain-text body as the message.
+func newAPIError(resp *http.Response, method string, urlStr string) *APIError {
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	var parsed interface{}
+	message := ""
+	contentType := resp.Header.Get("content-type")
+	if strings.Contains(contentType, "application/json") {
+		var payload map[string]interface{}
+		if err := json.Unmarshal(bodyBytes, &payload); err == nil {
+			parsed = payload
+			if errVal, ok := payload["error"].(string); ok && strings.TrimSpace(errVal) != "" {
+				message = strings.TrimSpace(errVal)
 			}
 		}
-
-		if message == "" {
-			message = "request " + method + " " + urlStr + " failed with status " + itoa(resp.StatusCode) + " " + resp.Status
+	}
+	if message == "" && len(bodyBytes) > 0 {
+		message = strings.TrimSpace(string(bodyBytes))
+		if message != "" {
+			parsed = message
 		}
+	}
 
-		return nil, &APIError{
-			Message:    message,
-			Status:     resp.StatusCode,
-			StatusText: resp.Status,
-			Method:     method,
-			URL:        urlStr,
-			Body:       parsed,
-		}
+	if message == "" {
+		message = "request " + method + " " + urlStr + " failed with status " + itoa(resp.StatusCode) + " " + resp.Status
 	}
 
-	return resp, nil
+	return &APIError{
+		Message:    message,
+		Status:     resp.StatusCode,
+		StatusText: resp.Status,
+		Method:     method,
+		URL:        urlStr,
+		Body:       parsed,
+	}
 }
 
 func (f *apiFetcher) get(ctx context.Context, path string, params url.Values, jwt string, opts *requestOptions) (*http.Response, error) {
@@ -127,3 +252,13 @@ func (f *apiFetcher) put(ctx context.Context, path string, params url.Values, bo
 func (f *apiFetcher) delete(ctx context.Context, path string, params url.Values, body interface{}, jwt string, opts *requestOptions) (*http.Response, error) {
 	return f.request(ctx, http.MethodDelete, path, params, body, jwt, opts)
 }
+
+func (f *apiFetcher) wait(ctx context.Context) error {
+	if f.limiter == nil {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return f.limiter.Wait(ctx)
+}
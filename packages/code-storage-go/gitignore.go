@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GitignoreCheck controls whether CommitBuilder compares staged paths with
+// the .gitignore files at the base ref before sending.
+type GitignoreCheck string
+
+const (
+	// GitignoreCheckWarn commits as usual and lists ignored paths in
+	// CommitResult.IgnoredPaths.
+	GitignoreCheckWarn GitignoreCheck = "warn"
+	// GitignoreCheckError fails Send with an *IgnoredPathsError before
+	// anything is uploaded.
+	GitignoreCheckError GitignoreCheck = "error"
+)
+
+// IgnoredPath is a staged path that git would normally ignore. Pattern is
+// the matching .gitignore line and Source the .gitignore file holding it.
+type IgnoredPath struct {
+	Path    string
+	Pattern string
+	Source  string
+}
+
+// IgnoredPathsError is returned by Send under GitignoreCheckError when any
+// staged path matches the base ref's .gitignore rules.
+type IgnoredPathsError struct {
+	Paths []IgnoredPath
+}
+
+func (e *IgnoredPathsError) Error() string {
+	names := make([]string, 0, len(e.Paths))
+	for _, ignored := range e.Paths {
+		names = append(names, ignored.Path)
+	}
+	const shown = 5
+	summary := strings.Join(names, ", ")
+	if len(names) > shown {
+		summary = strings.Join(names[:shown], ", ") + fmt.Sprintf(" and %d more", len(names)-shown)
+	}
+	return fmt.Sprintf("createCommit %d staged paths match .gitignore: %s", len(names), summary)
+}
+
+// checkGitignore reports the staged upserts matched by .gitignore files at
+// the base ref. Only the directories holding staged paths are consulted,
+// and directories already ignored by a parent are not descended into, as
+// git does. Upserts with FailIfMissing or ExpectedBlobSHA are skipped
+// because they update tracked files, which ignore rules do not affect.
+func (b *CommitBuilder) checkGitignore(ctx context.Context) ([]IgnoredPath, error) {
+	var paths []string
+	dirs := map[string]bool{"": true}
+	for _, op := range b.ops {
+		if op.Operation != "upsert" || op.FailIfMissing || op.ExpectedBlobSHA != "" {
+			continue
+		}
+		paths = append(paths, op.Path)
+		for dir := path.Dir(op.Path); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	ordered := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		ordered = append(ordered, dir)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		di, dj := strings.Count(ordered[i], "/"), strings.Count(ordered[j], "/")
+		if ordered[i] == "" || ordered[j] == "" {
+			return ordered[i] == ""
+		}
+		if di != dj {
+			return di < dj
+		}
+		return ordered[i] < ordered[j]
+	})
+
+	ref, ephemeral := b.options.TargetBranch, b.options.Ephemeral
+	if b.options.BaseBranch != "" {
+		ref, ephemeral = b.options.BaseBranch, b.options.EphemeralBase
+	}
+	repo := &Repo{ID: b.repoID, client: b.client}
+
+	var matcher gitignoreMatcher
+	for _, dir := range ordered {
+		if dir != "" {
+			if _, ignored := matcher.matchPath(dir, true); ignored {
+				continue
+			}
+		}
+		source := path.Join(dir, ".gitignore")
+		options := GetFileOptions{InvocationOptions: b.options.InvocationOptions, Path: source, Ref: ref}
+		if ephemeral {
+			options.Ephemeral = &ephemeral
+		}
+		resp, err := repo.FileStream(ctx, options)
+		if err != nil {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+				continue
+			}
+			return nil, fmt.Errorf("createCommit read %s: %w", source, err)
+		}
+		err = matcher.parse(resp.Body, dir, source)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("createCommit read %s: %w", source, err)
+		}
+	}
+
+	var ignored []IgnoredPath
+	for _, p := range paths {
+		if rule, ok := matcher.matchPath(p, false); ok {
+			ignored = append(ignored, IgnoredPath{Path: p, Pattern: rule.pattern, Source: rule.source})
+		}
+	}
+	return ignored, nil
+}
+
+type gitignoreRule struct {
+	pattern string
+	source  string
+	base    string
+	negate  bool
+	dirOnly bool
+	regex   *regexp.Regexp
+}
+
+// gitignoreMatcher applies rules in file order, parents before children,
+// so the last matching rule wins as in git.
+type gitignoreMatcher struct {
+	rules []gitignoreRule
+}
+
+func (m *gitignoreMatcher) parse(r io.Reader, base string, source string) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if rule, ok := parseGitignoreLine(line); ok {
+			rule.base, rule.source = base, source
+			m.rules = append(m.rules, rule)
+		}
+	}
+	return scanner.Err()
+}
+
+// matchPath reports the rule ignoring a path, checking its parent
+// directories first since nothing below an ignored directory can be
+// re-included.
+func (m *gitignoreMatcher) matchPath(p string, isDir bool) (gitignoreRule, bool) {
+	parts := strings.Split(p, "/")
+	for i := 1; i < len(parts); i++ {
+		if rule, ignored := m.match(strings.Join(parts[:i], "/"), true); ignored {
+			return rule, true
+		}
+	}
+	return m.match(p, isDir)
+}
+
+func (m *gitignoreMatcher) match(p string, isDir bool) (gitignoreRule, bool) {
+	var matched gitignoreRule
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel := p
+		if rule.base != "" {
+			if !strings.HasPrefix(p, rule.base+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(p, rule.base+"/")
+		}
+		if rule.regex.MatchString(rel) {
+			matched, ignored = rule, !rule.negate
+		}
+	}
+	return matched, ignored
+}
+
+func parseGitignoreLine(line string) (gitignoreRule, bool) {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimSuffix(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return gitignoreRule{}, false
+	}
+	rule := gitignoreRule{pattern: line}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return gitignoreRule{}, false
+	}
+
+	expr := gitignoreRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	regex, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return gitignoreRule{}, false
+	}
+	rule.regex = regex
+	return rule, true
+}
+
+// gitignoreRegexp translates a gitignore glob into a regular expression.
+func gitignoreRegexp(glob string) string {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**") {
+				switch {
+				case strings.HasPrefix(glob[i:], "**/"):
+					expr.WriteString("(?:.*/)?")
+					i += 2
+				case i+2 == len(glob):
+					expr.WriteString(".*")
+					i++
+				default:
+					expr.WriteString("[^/]*")
+					i++
+				}
+				continue
+			}
+			expr.WriteString("[^/]*")
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expr.String()
+}
@@ -3,6 +3,7 @@ module github.com/pierrecomputer/sdk/packages/code-storage-go
 go 1.24.3
 
 require (
+	github.com/go-git/go-billy/v5 v5.6.2
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/google/uuid v1.6.0
 )
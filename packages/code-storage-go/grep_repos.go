@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const defaultGrepReposConcurrency = 8
+
+// GrepReposOptions configures GrepRepos. Grep is applied to every
+// repository; its Pagination.Limit is the page size per repository and its
+// Pagination.Cursor is ignored in favour of Cursor.
+type GrepReposOptions struct {
+	RepoIDs []string
+	Grep    GrepOptions
+	// Concurrency bounds the number of repositories searched at once.
+	// Zero uses 8.
+	Concurrency int
+	// Cursor continues a previous search from GrepReposResult.NextCursor.
+	// Only the repositories that had more results are searched, and RepoIDs
+	// may be left empty. A repository whose continuation fails stays in
+	// NextCursor at the same page, so the next call retries it.
+	Cursor string
+}
+
+// GrepReposResult merges grep results from several repositories. Matches
+// are grouped by repository in RepoIDs order.
+type GrepReposResult struct {
+	Matches []RepoGrepMatch
+	// Repos reports the outcome for each searched repository, in RepoIDs
+	// order. A failed repository does not fail the whole search.
+	Repos      []RepoGrepStatus
+	NextCursor string
+	HasMore    bool
+}
+
+// RepoGrepMatch is a grep match tagged with its repository.
+type RepoGrepMatch struct {
+	RepoID string
+	GrepFileMatch
+}
+
+// RepoGrepStatus describes the search of one repository. Err is set when
+// the search failed, in which case Repo and HasMore are zero.
+type RepoGrepStatus struct {
+	RepoID  string
+	Repo    GrepRepo
+	HasMore bool
+	Err     error
+}
+
+// Failed returns the statuses of repositories whose search failed.
+func (r GrepReposResult) Failed() []RepoGrepStatus {
+	var failed []RepoGrepStatus
+	for _, status := range r.Repos {
+		if status.Err != nil {
+			failed = append(failed, status)
+		}
+	}
+	return failed
+}
+
+// GrepRepos runs the same grep across many repositories with bounded
+// concurrency. Per-repository failures are reported in Repos rather than
+// returned; the error is reserved for invalid options and cancellation.
+func (c *Client) GrepRepos(ctx context.Context, options GrepReposOptions) (GrepReposResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if options.Concurrency < 0 {
+		return GrepReposResult{}, errors.New("grepRepos concurrency must not be negative")
+	}
+	if strings.TrimSpace(options.Grep.Query.Pattern) == "" {
+		return GrepReposResult{}, errors.New("grep query.pattern is required")
+	}
+
+	var repoIDs []string
+	var cursors map[string]string
+	if strings.TrimSpace(options.Cursor) != "" {
+		var err error
+		if cursors, err = decodeGrepReposCursor(options.Cursor); err != nil {
+			return GrepReposResult{}, err
+		}
+		for _, id := range options.RepoIDs {
+			if _, ok := cursors[strings.TrimSpace(id)]; ok {
+				repoIDs = append(repoIDs, strings.TrimSpace(id))
+			}
+		}
+		if len(repoIDs) == 0 {
+			for id := range cursors {
+				repoIDs = append(repoIDs, id)
+			}
+			sort.Strings(repoIDs)
+		}
+	} else {
+		seen := make(map[string]bool, len(options.RepoIDs))
+		for _, id := range options.RepoIDs {
+			id = strings.TrimSpace(id)
+			if id == "" {
+				return GrepReposResult{}, errors.New("grepRepos repoIDs must not contain empty entries")
+			}
+			if !seen[id] {
+				seen[id] = true
+				repoIDs = append(repoIDs, id)
+			}
+		}
+	}
+	if len(repoIDs) == 0 {
+		return GrepReposResult{}, errors.New("grepRepos repoIDs is required")
+	}
+
+	concurrency := options.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultGrepReposConcurrency
+	}
+
+	results := make([]GrepResult, len(repoIDs))
+	errs := make([]error, len(repoIDs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, id := range repoIDs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return GrepReposResult{}, ctx.Err()
+		}
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			grep := options.Grep
+			if grep.Pagination != nil || cursors[id] != "" {
+				pagination := GrepPagination{Cursor: cursors[id]}
+				if grep.Pagination != nil {
+					pagination.Limit = grep.Pagination.Limit
+				}
+				grep.Pagination = &pagination
+			}
+			repo := &Repo{ID: id, client: c}
+			results[i], errs[i] = repo.Grep(ctx, grep)
+		}(i, id)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return GrepReposResult{}, ctx.Err()
+	}
+
+	var result GrepReposResult
+	next := map[string]string{}
+	for i, id := range repoIDs {
+		status := RepoGrepStatus{RepoID: id, Err: errs[i]}
+		if cursor, ok := cursors[id]; ok && errs[i] != nil {
+			// Keep a failed continuation in the cursor so the next call
+			// retries the same page instead of dropping the repository.
+			next[id] = cursor
+		}
+		if errs[i] == nil {
+			status.Repo = results[i].Repo
+			status.HasMore = results[i].HasMore
+			for _, match := range results[i].Matches {
+				result.Matches = append(result.Matches, RepoGrepMatch{RepoID: id, GrepFileMatch: match})
+			}
+			if results[i].HasMore && results[i].NextCursor != "" {
+				next[id] = results[i].NextCursor
+			}
+		}
+		result.Repos = append(result.Repos, status)
+	}
+	if len(next) > 0 {
+		result.HasMore = true
+		result.NextCursor = encodeGrepReposCursor(next)
+	}
+	return result, nil
+}
+
+func encodeGrepReposCursor(cursors map[string]string) string {
+	data, _ := json.Marshal(cursors)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeGrepReposCursor(value string) (map[string]string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("grepRepos invalid cursor: %w", err)
+	}
+	var cursors map[string]string
+	if err := json.Unmarshal(data, &cursors); err != nil {
+		return nil, fmt.Errorf("grepRepos invalid cursor: %w", err)
+	}
+	return cursors, nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGrepRepos(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		cursors  = map[string]string{}
+		inFlight int32
+		peak     int32
+		flaky    int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			seen := atomic.LoadInt32(&peak)
+			if current <= seen || atomic.CompareAndSwapInt32(&peak, seen, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		claims := parseJWTFromToken(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		repoID, _ := claims["repo"].(string)
+		var body grepRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		cursor := ""
+		if body.Pagination != nil {
+			cursor = body.Pagination.Cursor
+		}
+		mu.Lock()
+		cursors[repoID] = cursor
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case repoID == "broken" || (repoID == "big" && cursor == "page2" && atomic.AddInt32(&flaky, -1) >= 0):
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error":"boom"}`))
+		case repoID == "big" && cursor == "":
+			_, _ = w.Write([]byte(`{"query":{"pattern":"TODO"},"repo":{"ref":"main","commit":"b1"},"matches":[{"path":"a.go","lines":[{"line_number":1,"text":"TODO","type":"match"}]}],"next_cursor":"page2","has_more":true}`))
+		default:
+			_, _ = w.Write([]byte(`{"query":{"pattern":"TODO"},"repo":{"ref":"main","commit":"c1"},"matches":[{"path":"b.go","lines":[{"line_number":2,"text":"TODO","type":"match"}]}],"has_more":false}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+
+	options := GrepReposOptions{RepoIDs: []string{"big", "small", "broken", "other"}, Grep: GrepOptions{Query: GrepQuery{Pattern: "TODO"}}, Concurrency: 2}
+	result, err := client.GrepRepos(nil, options)
+	if err != nil {
+		t.Fatalf("grep repos error: %v", err)
+	}
+	if peak := atomic.LoadInt32(&peak); peak > 2 {
+		t.Fatalf("expected at most 2 concurrent requests, got %d", peak)
+	}
+	if len(result.Matches) != 3 || result.Matches[0].RepoID != "big" || result.Matches[0].Path != "a.go" || result.Matches[1].RepoID != "small" || result.Matches[2].RepoID != "other" {
+		t.Fatalf("unexpected matches: %+v", result.Matches)
+	}
+	if failed := result.Failed(); len(failed) != 1 || failed[0].RepoID != "broken" {
+		t.Fatalf("unexpected failures: %+v", failed)
+	}
+	if !result.HasMore || result.NextCursor == "" {
+		t.Fatalf("expected an aggregated cursor")
+	}
+
+	// A failed continuation page is carried forward and retried.
+	atomic.StoreInt32(&flaky, 1)
+	options.Cursor = result.NextCursor
+	page, err := client.GrepRepos(nil, options)
+	if err != nil {
+		t.Fatalf("grep repos page error: %v", err)
+	}
+	if len(page.Failed()) != 1 || !page.HasMore || page.NextCursor != result.NextCursor {
+		t.Fatalf("expected the failed page to stay in the cursor, got %+v", page)
+	}
+
+	page, err = client.GrepRepos(nil, options)
+	if err != nil {
+		t.Fatalf("grep repos page error: %v", err)
+	}
+	if len(page.Repos) != 1 || page.Repos[0].RepoID != "big" || cursors["big"] != "page2" {
+		t.Fatalf("expected only big to be resumed, got %+v (cursor %q)", page.Repos, cursors["big"])
+	}
+	if page.HasMore || page.NextCursor != "" {
+		t.Fatalf("expected the search to be complete")
+	}
+
+	if _, err := client.GrepRepos(nil, GrepReposOptions{Grep: GrepOptions{Query: GrepQuery{Pattern: "TODO"}}}); err == nil {
+		t.Fatalf("expected repoIDs validation error")
+	}
+}
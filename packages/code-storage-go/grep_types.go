@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// grepTypeGlobs maps GrepFileFilters.Types names to include globs, following
+// ripgrep's --type definitions where one exists.
+var grepTypeGlobs = map[string][]string{
+	"c":         {"*.c", "*.h"},
+	"cpp":       {"*.cc", "*.cpp", "*.cxx", "*.hh", "*.hpp", "*.hxx", "*.inl"},
+	"csharp":    {"*.cs"},
+	"css":       {"*.css", "*.scss", "*.sass", "*.less"},
+	"docker":    {"Dockerfile", "*.dockerfile", "Dockerfile.*"},
+	"docs":      {"*.md", "*.markdown", "*.mdx", "*.rst", "*.adoc", "*.txt"},
+	"go":        {"*.go"},
+	"html":      {"*.htm", "*.html"},
+	"java":      {"*.java"},
+	"js":        {"*.js", "*.jsx", "*.mjs", "*.cjs"},
+	"json":      {"*.json", "*.jsonc"},
+	"kotlin":    {"*.kt", "*.kts"},
+	"make":      {"Makefile", "makefile", "GNUmakefile", "*.mk", "*.mak"},
+	"markdown":  {"*.md", "*.markdown", "*.mdx"},
+	"proto":     {"*.proto"},
+	"py":        {"*.py", "*.pyi"},
+	"ruby":      {"*.rb", "Gemfile", "Rakefile", "*.gemspec"},
+	"rust":      {"*.rs"},
+	"sh":        {"*.sh", "*.bash", "*.zsh"},
+	"sql":       {"*.sql"},
+	"swift":     {"*.swift"},
+	"terraform": {"*.tf", "*.tfvars"},
+	"toml":      {"*.toml"},
+	"ts":        {"*.ts", "*.tsx", "*.mts", "*.cts"},
+	"yaml":      {"*.yaml", "*.yml"},
+}
+
+// GrepFileTypes returns the names accepted by GrepFileFilters.Types, sorted.
+func GrepFileTypes() []string {
+	names := make([]string, 0, len(grepTypeGlobs))
+	for name := range grepTypeGlobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandGrepTypes returns a copy of globs with the include globs of each
+// named type appended, skipping duplicates. The caller's slice is never
+// written to.
+func expandGrepTypes(globs []string, types []string) ([]string, error) {
+	globs = append([]string(nil), globs...)
+	seen := make(map[string]bool, len(globs))
+	for _, glob := range globs {
+		seen[glob] = true
+	}
+	for _, name := range types {
+		expanded, ok := grepTypeGlobs[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, errors.New("grep fileFilters.types contains unknown type " + strconv.Quote(name))
+		}
+		for _, glob := range expanded {
+			if !seen[glob] {
+				seen[glob] = true
+				globs = append(globs, glob)
+			}
+		}
+	}
+	return globs, nil
+}
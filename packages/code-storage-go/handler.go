@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const defaultIndexFile = "index.html"
+
+// RepoHandlerOptions configures NewRepoHandler.
+type RepoHandlerOptions struct {
+	// InvocationOptions.TTL sets the lifetime of the read token minted for
+	// each request.
+	InvocationOptions
+	Ref string
+	// IndexFile is served for directory requests. Defaults to index.html.
+	IndexFile string
+	// CacheControl, when set, is sent on every successful response.
+	CacheControl string
+}
+
+type repoHandler struct {
+	repo    *Repo
+	options RepoHandlerOptions
+}
+
+// NewRepoHandler returns an http.Handler that serves files from repo at
+// options.Ref. Responses carry an ETag derived from the blob SHA and honor
+// If-None-Match, which is forwarded upstream so unchanged files are not
+// transferred; directory paths serve their index file.
+func NewRepoHandler(repo *Repo, options RepoHandlerOptions) http.Handler {
+	if strings.TrimSpace(options.IndexFile) == "" {
+		options.IndexFile = defaultIndexFile
+	}
+	return &repoHandler{repo: repo, options: options}
+}
+
+func (h *repoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestPath := path.Clean("/" + r.URL.Path)
+	isDir := strings.HasSuffix(r.URL.Path, "/") || requestPath == "/"
+	filePath := strings.TrimPrefix(requestPath, "/")
+	if isDir {
+		filePath = strings.TrimPrefix(path.Join(requestPath, h.options.IndexFile), "/")
+	}
+
+	// Forward the validator so an unchanged file is answered upstream
+	// without transferring its body.
+	var conditional http.Header
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		conditional = http.Header{"If-None-Match": []string{ifNoneMatch}}
+	}
+	resp, err := h.open(r, filePath, conditional)
+	if isNotFound(err) && !isDir {
+		// Redirect to the directory form when the path has an index file.
+		indexResp, indexErr := h.open(r, path.Join(filePath, h.options.IndexFile), nil)
+		if indexErr == nil {
+			indexResp.Body.Close()
+			localRedirect(w, r, path.Base(requestPath)+"/")
+			return
+		}
+	}
+	if err != nil {
+		if isNotFound(err) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	header := w.Header()
+	etag := downloadValidator(resp)
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+	if resp.StatusCode == http.StatusNotModified || (etag != "" && etagMatches(r.Header.Get("If-None-Match"), etag)) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Type", contentTypeFor(filePath, resp.Header.Get("Content-Type")))
+	if resp.ContentLength >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
+	if h.options.CacheControl != "" {
+		header.Set("Cache-Control", h.options.CacheControl)
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func (h *repoHandler) open(r *http.Request, filePath string, header http.Header) (*http.Response, error) {
+	follow := true
+	return h.repo.fileStream(r.Context(), GetFileOptions{
+		InvocationOptions: h.options.InvocationOptions,
+		Path:              filePath,
+		Ref:               h.options.Ref,
+		FollowSymlinks:    &follow,
+	}, header)
+}
+
+// localRedirect redirects relative to the request path, like
+// http.FileServer, so the handler keeps working under http.StripPrefix.
+func localRedirect(w http.ResponseWriter, r *http.Request, newPath string) {
+	if r.URL.RawQuery != "" {
+		newPath += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Location", newPath)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+func isNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func contentTypeFor(filePath string, upstream string) string {
+	if upstream != "" && !strings.HasPrefix(upstream, "application/octet-stream") {
+		return upstream
+	}
+	if byExt := mime.TypeByExtension(path.Ext(filePath)); byExt != "" {
+		return byExt
+	}
+	if upstream != "" {
+		return upstream
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRepoHandler(t *testing.T) {
+	files := map[string]string{
+		"index.html":      "<h1>home</h1>",
+		"docs/index.html": "<h1>docs</h1>",
+		"app.js":          "console.log(1)",
+	}
+	var ttls []time.Duration
+	conditional := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/file" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("ref") != "preview" {
+			t.Errorf("unexpected ref: %s", r.URL.Query().Get("ref"))
+		}
+		claims := parseJWTFromToken(t, r.Header.Get("Authorization")[len("Bearer "):])
+		exp := int64(claims["exp"].(float64))
+		iat := int64(claims["iat"].(float64))
+		ttls = append(ttls, time.Duration(exp-iat)*time.Second)
+
+		contents, ok := files[r.URL.Query().Get("path")]
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+			return
+		}
+		etag := `"sha-` + r.URL.Query().Get("path") + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			conditional++
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set(blobSHAHeader, "sha-"+r.URL.Query().Get("path"))
+		_, _ = w.Write([]byte(contents))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+	handler := NewRepoHandler(repo, RepoHandlerOptions{
+		InvocationOptions: InvocationOptions{TTL: 2 * time.Minute},
+		Ref:               "preview",
+		CacheControl:      "no-cache",
+	})
+	site := httptest.NewServer(handler)
+	defer site.Close()
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	resp, err := noRedirect.Get(site.URL + "/")
+	if err != nil {
+		t.Fatalf("get error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "<h1>home</h1>" {
+		t.Fatalf("unexpected index response: %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("ETag") != `"sha-index.html"` || resp.Header.Get("Cache-Control") != "no-cache" {
+		t.Fatalf("unexpected headers: %v", resp.Header)
+	}
+	if resp.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected content type: %s", resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = noRedirect.Get(site.URL + "/docs")
+	if err != nil {
+		t.Fatalf("get error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "docs/" {
+		t.Fatalf("expected redirect to docs/, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// Mounted under a prefix, the redirect stays relative to it.
+	mounted := httptest.NewServer(http.StripPrefix("/site", handler))
+	defer mounted.Close()
+	resp, err = noRedirect.Get(mounted.URL + "/site/docs")
+	if err != nil {
+		t.Fatalf("get error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "docs/" {
+		t.Fatalf("expected relative redirect under prefix, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, site.URL+"/app.js", nil)
+	req.Header.Set("If-None-Match", `"sha-app.js"`)
+	resp, err = noRedirect.Do(req)
+	if err != nil {
+		t.Fatalf("get error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified || resp.Header.Get("ETag") != `"sha-app.js"` || conditional != 1 {
+		t.Fatalf("expected 304 answered upstream, got %d (%d conditional)", resp.StatusCode, conditional)
+	}
+
+	resp, err = noRedirect.Get(site.URL + "/missing.txt")
+	if err != nil {
+		t.Fatalf("get error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+
+	resp, err = noRedirect.Post(site.URL+"/app.js", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("post error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", resp.StatusCode)
+	}
+
+	for _, ttl := range ttls {
+		if ttl != 2*time.Minute {
+			t.Fatalf("unexpected token ttl: %s", ttl)
+		}
+	}
+}
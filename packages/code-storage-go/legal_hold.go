@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// LegalHold describes an active legal hold. While it is in place the
+// repository cannot be deleted and branches cannot be force-pushed or
+// otherwise rewritten; fast-forward commits are still accepted.
+type LegalHold struct {
+	Reason string
+	// Reference is a caller-defined case or ticket identifier.
+	Reference   string
+	PlacedAt    time.Time
+	RawPlacedAt string
+}
+
+// PlaceLegalHoldOptions configures PlaceLegalHold.
+type PlaceLegalHoldOptions struct {
+	InvocationOptions
+	RepoID    string
+	Reason    string
+	Reference string
+}
+
+// RemoveLegalHoldOptions configures RemoveLegalHold. Reason is recorded in
+// the audit log.
+type RemoveLegalHoldOptions struct {
+	InvocationOptions
+	RepoID string
+	Reason string
+}
+
+// PlaceLegalHold puts a repository under legal hold. Placing a hold on a
+// repository that already has one replaces its reason and reference.
+func (c *Client) PlaceLegalHold(ctx context.Context, options PlaceLegalHoldOptions) (LegalHold, error) {
+	repoID := strings.TrimSpace(options.RepoID)
+	if repoID == "" {
+		return LegalHold{}, errors.New("placeLegalHold repoID is required")
+	}
+	reason := strings.TrimSpace(options.Reason)
+	if reason == "" {
+		return LegalHold{}, errors.New("placeLegalHold reason is required")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := c.generateJWT(repoID, RemoteURLOptions{Permissions: []Permission{PermissionRepoWrite}, TTL: ttl})
+	if err != nil {
+		return LegalHold{}, err
+	}
+
+	body := &legalHoldRequest{Reason: reason, Reference: strings.TrimSpace(options.Reference)}
+	resp, err := c.api.post(ctx, "repos/legal-hold", nil, body, jwtToken, nil)
+	if err != nil {
+		return LegalHold{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload legalHoldRaw
+	if err := decodeJSON(resp, &payload); err != nil {
+		return LegalHold{}, err
+	}
+	return *payload.hold(), nil
+}
+
+// RemoveLegalHold lifts the legal hold on a repository. Removing a hold
+// that does not exist is not an error.
+func (c *Client) RemoveLegalHold(ctx context.Context, options RemoveLegalHoldOptions) error {
+	repoID := strings.TrimSpace(options.RepoID)
+	if repoID == "" {
+		return errors.New("removeLegalHold repoID is required")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := c.generateJWT(repoID, RemoteURLOptions{Permissions: []Permission{PermissionRepoWrite}, TTL: ttl})
+	if err != nil {
+		return err
+	}
+
+	var body interface{}
+	if reason := strings.TrimSpace(options.Reason); reason != "" {
+		body = &legalHoldRequest{Reason: reason}
+	}
+	resp, err := c.api.delete(ctx, "repos/legal-hold", nil, body, jwtToken, &requestOptions{allowedStatus: map[int]bool{404: true}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
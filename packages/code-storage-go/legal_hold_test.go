@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLegalHold(t *testing.T) {
+	held := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := parseJWTFromToken(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		switch {
+		case r.URL.Path == "/api/v1/repos/legal-hold" && r.Method == http.MethodPost:
+			if claims["repo"] != "repo-1" {
+				t.Errorf("unexpected repo claim: %v", claims["repo"])
+			}
+			var body legalHoldRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decode body: %v", err)
+			}
+			if body.Reason != "litigation" || body.Reference != "CASE-7" {
+				t.Errorf("unexpected body: %+v", body)
+			}
+			held = true
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"reason":"litigation","reference":"CASE-7","placed_at":"2024-01-20T10:30:00Z"}`))
+		case r.URL.Path == "/api/v1/repos/legal-hold" && r.Method == http.MethodDelete:
+			held = false
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/api/v1/repos/delete":
+			if held {
+				w.WriteHeader(http.StatusLocked)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"repo_id":"repo-1","message":"deleted"}`))
+		case r.URL.Path == "/api/v1/repos":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"repos":[{"repo_id":"repo-1","legal_hold":{"reason":"litigation","placed_at":"2024-01-20T10:30:00Z"}},{"repo_id":"repo-2"}],"has_more":false}`))
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+
+	hold, err := client.PlaceLegalHold(nil, PlaceLegalHoldOptions{RepoID: "repo-1", Reason: "litigation", Reference: "CASE-7"})
+	if err != nil {
+		t.Fatalf("place hold error: %v", err)
+	}
+	if hold.Reference != "CASE-7" || hold.PlacedAt.IsZero() {
+		t.Fatalf("unexpected hold: %+v", hold)
+	}
+
+	if _, err := client.DeleteRepo(nil, DeleteRepoOptions{ID: "repo-1"}); err == nil || !strings.Contains(err.Error(), "legal hold") {
+		t.Fatalf("expected legal hold error, got %v", err)
+	}
+
+	repos, err := client.ListRepos(nil, ListReposOptions{})
+	if err != nil {
+		t.Fatalf("list repos error: %v", err)
+	}
+	if repos.Repos[0].LegalHold == nil || repos.Repos[0].LegalHold.Reason != "litigation" || repos.Repos[1].LegalHold != nil {
+		t.Fatalf("unexpected hold status: %+v", repos.Repos)
+	}
+
+	if err := client.RemoveLegalHold(nil, RemoveLegalHoldOptions{RepoID: "repo-1", Reason: "case closed"}); err != nil {
+		t.Fatalf("remove hold error: %v", err)
+	}
+	if _, err := client.DeleteRepo(nil, DeleteRepoOptions{ID: "repo-1"}); err != nil {
+		t.Fatalf("delete after release error: %v", err)
+	}
+
+	if _, err := client.PlaceLegalHold(nil, PlaceLegalHoldOptions{RepoID: "repo-1"}); err == nil {
+		t.Fatalf("expected reason validation error")
+	}
+	if reason := inferRefUpdateReason("legal_hold"); reason != RefUpdateReasonLegalHold {
+		t.Fatalf("unexpected ref update reason: %s", reason)
+	}
+}
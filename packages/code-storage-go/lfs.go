@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	lfsPointerMaxBytes    = 1024
+	lfsPointerSpecPrefix  = "https://git-lfs.github.com/spec/"
+	lfsPointerLegacySpec  = "https://hawser.github.com/spec/v1"
+	lfsPointerOIDSHA256   = "sha256:"
+	lfsPointerVersionLine = "version "
+)
+
+// ParseLFSPointer parses Git LFS pointer file contents. It returns false when
+// data is not a valid pointer.
+func ParseLFSPointer(data []byte) (*LFSPointer, bool) {
+	if len(data) == 0 || len(data) > lfsPointerMaxBytes {
+		return nil, false
+	}
+	text := strings.TrimRight(string(data), "\n")
+	lines := strings.Split(text, "\n")
+	if !strings.HasPrefix(lines[0], lfsPointerVersionLine) {
+		return nil, false
+	}
+	version := strings.TrimPrefix(lines[0], lfsPointerVersionLine)
+	if !strings.HasPrefix(version, lfsPointerSpecPrefix) && version != lfsPointerLegacySpec {
+		return nil, false
+	}
+
+	pointer := &LFSPointer{Version: version, Size: -1}
+	for _, line := range lines[1:] {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, false
+		}
+		switch key {
+		case "oid":
+			if !strings.HasPrefix(value, lfsPointerOIDSHA256) {
+				return nil, false
+			}
+			oid := strings.TrimPrefix(value, lfsPointerOIDSHA256)
+			if !isHexObjectID(oid) || len(oid) != 64 {
+				return nil, false
+			}
+			pointer.OID = oid
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return nil, false
+			}
+			pointer.Size = size
+		}
+	}
+	if pointer.OID == "" || pointer.Size < 0 {
+		return nil, false
+	}
+	return pointer, true
+}
+
+// lfsPointerFromPatch detects an LFS pointer on the new side of a unified
+// diff for a single file.
+func lfsPointerFromPatch(raw string) *LFSPointer {
+	if !strings.Contains(raw, lfsPointerVersionLine+lfsPointerSpecPrefix) && !strings.Contains(raw, lfsPointerLegacySpec) {
+		return nil
+	}
+	var content bytes.Buffer
+	inHunk := false
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			inHunk = true
+			continue
+		}
+		if !inHunk || line == "" {
+			continue
+		}
+		switch line[0] {
+		case '+', ' ':
+			content.WriteString(line[1:])
+			content.WriteByte('\n')
+		}
+	}
+	pointer, ok := ParseLFSPointer(content.Bytes())
+	if !ok {
+		return nil
+	}
+	return pointer
+}
+
+// GetLFSObject returns the raw response for streaming a Git LFS object.
+func (r *Repo) GetLFSObject(ctx context.Context, options GetLFSObjectOptions) (*http.Response, error) {
+	oid := strings.ToLower(strings.TrimSpace(options.OID))
+	if oid == "" {
+		return nil, errors.New("getLFSObject oid is required")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("oid", oid)
+
+	resp, err := r.client.api.get(ctx, "repos/lfs/object", params, jwtToken, streamRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// ResolveLFSFile streams a file and, when its contents are an LFS pointer,
+// transparently streams the referenced LFS object instead. The pointer is
+// returned when resolution happened.
+func (r *Repo) ResolveLFSFile(ctx context.Context, options GetFileOptions) (*http.Response, *LFSPointer, error) {
+	resp, err := r.FileStream(ctx, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := bufio.NewReaderSize(resp.Body, lfsPointerMaxBytes+1)
+	head, err := reader.Peek(lfsPointerMaxBytes + 1)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		_ = resp.Body.Close()
+		return nil, nil, err
+	}
+	pointer, ok := ParseLFSPointer(head)
+	if !ok {
+		resp.Body = &readCloser{Reader: reader, Closer: resp.Body}
+		return resp, nil, nil
+	}
+	_ = resp.Body.Close()
+
+	objectResp, err := r.GetLFSObject(ctx, GetLFSObjectOptions{InvocationOptions: options.InvocationOptions, OID: pointer.OID})
+	if err != nil {
+		return nil, pointer, err
+	}
+	return objectResp, pointer, nil
+}
+
+// formatLFSPointer renders the canonical pointer file for an object.
+func formatLFSPointer(oid string, size int64) string {
+	return lfsPointerVersionLine + lfsPointerSpecPrefix + "v1\n" +
+		"oid " + lfsPointerOIDSHA256 + oid + "\n" +
+		"size " + strconv.FormatInt(size, 10) + "\n"
+}
+
+// uploadLFSObject streams source to the LFS object store and verifies the
+// server-computed OID against a local digest.
+func (c *Client) uploadLFSObject(ctx context.Context, jwtToken string, source io.Reader) (LFSPointer, error) {
+	hasher := sha256.New()
+	counter := &countingReader{reader: io.TeeReader(source, hasher)}
+
+	if err := c.api.wait(ctx); err != nil {
+		return LFSPointer{}, err
+	}
+	resp, err := c.api.stream(ctx, http.MethodPost, "repos/lfs/objects", jwtToken, "application/octet-stream", counter)
+	if err != nil {
+		return LFSPointer{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return LFSPointer{}, newAPIError(resp, http.MethodPost, resp.Request.URL.String())
+	}
+
+	var payload struct {
+		OID  string `json:"oid"`
+		Size int64  `json:"size"`
+	}
+	if err := decodeJSON(resp, &payload); err != nil {
+		return LFSPointer{}, err
+	}
+	oid := hex.EncodeToString(hasher.Sum(nil))
+	if strings.ToLower(payload.OID) != oid || payload.Size != counter.n {
+		return LFSPointer{}, errors.New("upload LFS object: server digest does not match uploaded content")
+	}
+	return LFSPointer{Version: lfsPointerSpecPrefix + "v1", OID: oid, Size: counter.n}, nil
+}
+
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
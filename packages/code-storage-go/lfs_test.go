@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testLFSPointer = "version https://git-lfs.github.com/spec/v1\noid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12345\n"
+
+func TestParseLFSPointer(t *testing.T) {
+	pointer, ok := ParseLFSPointer([]byte(testLFSPointer))
+	if !ok {
+		t.Fatalf("expected lfs pointer")
+	}
+	if pointer.OID != "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393" || pointer.Size != 12345 {
+		t.Fatalf("unexpected pointer: %+v", pointer)
+	}
+
+	if _, ok := ParseLFSPointer([]byte("hello world\n")); ok {
+		t.Fatalf("expected regular file to not be a pointer")
+	}
+	if _, ok := ParseLFSPointer([]byte("version https://git-lfs.github.com/spec/v1\noid sha256:abc\nsize 1\n")); ok {
+		t.Fatalf("expected invalid oid to be rejected")
+	}
+}
+
+func TestDiffDetectsLFSPointer(t *testing.T) {
+	raw := "diff --git a/model.bin b/model.bin\nnew file mode 100644\n--- /dev/null\n+++ b/model.bin\n@@ -0,0 +1,3 @@\n+version https://git-lfs.github.com/spec/v1\n+oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\n+size 12345\n"
+	result := transformCommitDiff(commitDiffResponse{
+		SHA:   "abc",
+		Files: []fileDiffRaw{{Path: "model.bin", State: "A", Raw: raw}, {Path: "main.go", State: "M", Raw: "@@ -1 +1 @@\n-a\n+b\n"}},
+	})
+	if result.Files[0].LFSPointer == nil || result.Files[0].LFSPointer.Size != 12345 {
+		t.Fatalf("expected lfs pointer on diff file")
+	}
+	if result.Files[1].LFSPointer != nil {
+		t.Fatalf("expected no lfs pointer on regular file")
+	}
+}
+
+func TestResolveLFSFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/file":
+			if r.URL.Query().Get("path") == "model.bin" {
+				_, _ = w.Write([]byte(testLFSPointer))
+				return
+			}
+			_, _ = w.Write([]byte("plain contents"))
+		case "/api/v1/repos/lfs/object":
+			if r.URL.Query().Get("oid") != "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393" {
+				t.Fatalf("unexpected oid: %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte("large object"))
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, pointer, err := repo.ResolveLFSFile(nil, GetFileOptions{Path: "model.bin"})
+	if err != nil {
+		t.Fatalf("resolve lfs error: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if pointer == nil || string(data) != "large object" {
+		t.Fatalf("expected lfs object contents, got %q", data)
+	}
+
+	resp, pointer, err = repo.ResolveLFSFile(nil, GetFileOptions{Path: "README.md"})
+	if err != nil {
+		t.Fatalf("resolve lfs error: %v", err)
+	}
+	data, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if pointer != nil || string(data) != "plain contents" {
+		t.Fatalf("expected plain contents, got %q", data)
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// NoteConflictError is returned by SetNoteJSON and UpdateNoteJSON when the
+// notes ref moved after the note was read, so the write was rejected to
+// avoid losing a concurrent update. Read the note again and retry.
+type NoteConflictError struct {
+	*RefUpdateError
+	SHA            string
+	ExpectedRefSHA string
+}
+
+func (e *NoteConflictError) Unwrap() error {
+	return e.RefUpdateError
+}
+
+// SetNoteJSONOptions configures SetNoteJSON.
+type SetNoteJSONOptions struct {
+	InvocationOptions
+	SHA        string
+	ObjectType NoteObjectType
+	// Value is marshalled with encoding/json and replaces any existing note.
+	Value interface{}
+	// ExpectedRefSHA is the notes ref SHA the caller last read, usually
+	// GetNoteResult.RefSHA. Empty writes unconditionally.
+	ExpectedRefSHA string
+	Author         *NoteAuthor
+}
+
+// UpdateNoteJSONOptions configures UpdateNoteJSON.
+type UpdateNoteJSONOptions struct {
+	InvocationOptions
+	SHA        string
+	ObjectType NoteObjectType
+	Author     *NoteAuthor
+}
+
+// GetNoteJSON reads a note and unmarshals it into v. The returned RefSHA
+// can be passed to SetNoteJSON as ExpectedRefSHA.
+func (r *Repo) GetNoteJSON(ctx context.Context, options GetNoteOptions, v interface{}) (GetNoteResult, error) {
+	result, err := r.GetNote(ctx, options)
+	if err != nil {
+		return GetNoteResult{}, err
+	}
+	if err := json.Unmarshal([]byte(result.Note), v); err != nil {
+		return GetNoteResult{}, fmt.Errorf("getNoteJSON decode note for %s: %w", result.SHA, err)
+	}
+	return result, nil
+}
+
+// SetNoteJSON stores value as the JSON note of an object, replacing any
+// existing note. A CAS failure against ExpectedRefSHA is returned as a
+// *NoteConflictError.
+func (r *Repo) SetNoteJSON(ctx context.Context, options SetNoteJSONOptions) (NoteWriteResult, error) {
+	return r.writeNoteJSON(ctx, options.InvocationOptions, "overwrite", options.SHA, options.ObjectType, options.Value, options.ExpectedRefSHA, options.Author)
+}
+
+// UpdateNoteJSON performs a read-modify-write of a JSON note. It decodes
+// the current note into v, calls update with whether a note existed, and
+// writes v back guarded by the notes ref SHA it read. If another writer
+// got there first the result is a *NoteConflictError and nothing is
+// written. Returning an error from update aborts without writing.
+func (r *Repo) UpdateNoteJSON(ctx context.Context, options UpdateNoteJSONOptions, v interface{}, update func(found bool) error) (NoteWriteResult, error) {
+	if update == nil {
+		return NoteWriteResult{}, errors.New("updateNoteJSON update is required")
+	}
+
+	action := "overwrite"
+	current, err := r.GetNoteJSON(ctx, GetNoteOptions{InvocationOptions: options.InvocationOptions, SHA: options.SHA, ObjectType: options.ObjectType}, v)
+	var apiErr *APIError
+	switch {
+	case err == nil:
+	case errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound:
+		// Adding fails if another writer creates the note first, which
+		// guards the write without a ref SHA to compare against.
+		action = "add"
+	default:
+		return NoteWriteResult{}, err
+	}
+
+	if err := update(action == "overwrite"); err != nil {
+		return NoteWriteResult{}, err
+	}
+	return r.writeNoteJSON(ctx, options.InvocationOptions, action, options.SHA, options.ObjectType, v, current.RefSHA, options.Author)
+}
+
+func (r *Repo) writeNoteJSON(ctx context.Context, invocation InvocationOptions, action string, sha string, objectType NoteObjectType, value interface{}, expectedRefSHA string, author *NoteAuthor) (NoteWriteResult, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return NoteWriteResult{}, fmt.Errorf("setNoteJSON encode note: %w", err)
+	}
+	result, err := r.writeNote(ctx, invocation, action, sha, objectType, string(data), expectedRefSHA, author)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.Status == http.StatusConflict || apiErr.Status == http.StatusPreconditionFailed) {
+		err = newRefUpdateError(apiErr.Message, "conflict", nil)
+	}
+	var refErr *RefUpdateError
+	if errors.As(err, &refErr) && isNoteConflict(refErr, action) {
+		return NoteWriteResult{}, &NoteConflictError{
+			RefUpdateError: refErr,
+			SHA:            strings.TrimSpace(sha),
+			ExpectedRefSHA: expectedRefSHA,
+		}
+	}
+	return result, err
+}
+
+func isNoteConflict(err *RefUpdateError, action string) bool {
+	switch err.Reason {
+	case RefUpdateReasonPreconditionFailed, RefUpdateReasonConflict:
+		return true
+	}
+	// A competing "add" surfaces as the note already existing.
+	return action == "add" && strings.EqualFold(strings.TrimSpace(err.Status), "already_exists")
+}
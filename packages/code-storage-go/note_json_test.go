@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type reviewNote struct {
+	Approvals []string `json:"approvals"`
+}
+
+func TestUpdateNoteJSON(t *testing.T) {
+	note := `{"approvals":["alice"]}`
+	refSHA := "ref1"
+	var writes []noteWriteRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/notes" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			if note == "" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"note not found"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"sha":"abc","note":` + quoteJSON(note) + `,"ref_sha":"` + refSHA + `"}`))
+		case http.MethodPost:
+			var body noteWriteRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decode body: %v", err)
+			}
+			writes = append(writes, body)
+			if body.ExpectedRefSHA != "" && body.ExpectedRefSHA != refSHA {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"sha":"abc","target_ref":"refs/notes/commits","result":{"success":false,"status":"precondition_failed","message":"notes ref moved"}}`))
+				return
+			}
+			note, refSHA = body.Note, refSHA+"'"
+			_, _ = w.Write([]byte(`{"sha":"abc","target_ref":"refs/notes/commits","new_ref_sha":"` + refSHA + `","result":{"success":true,"status":"ok"}}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	var review reviewNote
+	if _, err := repo.UpdateNoteJSON(nil, UpdateNoteJSONOptions{SHA: "abc"}, &review, func(found bool) error {
+		if !found {
+			t.Errorf("expected the existing note to be found")
+		}
+		review.Approvals = append(review.Approvals, "bob")
+		return nil
+	}); err != nil {
+		t.Fatalf("update note error: %v", err)
+	}
+	if len(writes) != 1 || writes[0].Action != "overwrite" || writes[0].ExpectedRefSHA != "ref1" || writes[0].Note != `{"approvals":["alice","bob"]}` {
+		t.Fatalf("unexpected write: %+v", writes)
+	}
+
+	var stored reviewNote
+	current, err := repo.GetNoteJSON(nil, GetNoteOptions{SHA: "abc"}, &stored)
+	if err != nil {
+		t.Fatalf("get note json error: %v", err)
+	}
+	if len(stored.Approvals) != 2 || current.RefSHA != refSHA {
+		t.Fatalf("unexpected stored note: %+v (%s)", stored, current.RefSHA)
+	}
+
+	_, err = repo.SetNoteJSON(nil, SetNoteJSONOptions{SHA: "abc", Value: reviewNote{}, ExpectedRefSHA: "stale"})
+	var conflict *NoteConflictError
+	if !errors.As(err, &conflict) || conflict.ExpectedRefSHA != "stale" || conflict.Reason != RefUpdateReasonPreconditionFailed {
+		t.Fatalf("expected NoteConflictError, got %v", err)
+	}
+
+	note = ""
+	var fresh reviewNote
+	if _, err := repo.UpdateNoteJSON(nil, UpdateNoteJSONOptions{SHA: "abc"}, &fresh, func(found bool) error {
+		if found {
+			t.Errorf("expected no existing note")
+		}
+		fresh.Approvals = []string{"carol"}
+		return nil
+	}); err != nil {
+		t.Fatalf("create note error: %v", err)
+	}
+	if last := writes[len(writes)-1]; last.Action != "add" || last.ExpectedRefSHA != "" {
+		t.Fatalf("unexpected create write: %+v", last)
+	}
+}
+
+func quoteJSON(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
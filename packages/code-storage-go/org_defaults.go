@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// OrgRepoDefaults holds organization-wide settings that CreateRepo applies
+// to every new repository unless CreateRepoOptions overrides them or sets
+// SkipOrgDefaults.
+type OrgRepoDefaults struct {
+	// DefaultBranch is used when CreateRepo is called without one. Empty
+	// keeps the service default of "main".
+	DefaultBranch    string
+	BranchProtection []BranchProtectionRule
+	Webhooks         []WebhookEndpoint
+	Retention        *RetentionPolicy
+	// UpdatedAt is set by the server and ignored by SetOrgRepoDefaults.
+	UpdatedAt    time.Time
+	RawUpdatedAt string
+}
+
+// BranchProtectionRule restricts updates to branches whose names match
+// Pattern, a glob such as "main" or "release/*".
+type BranchProtectionRule struct {
+	Pattern              string
+	BlockForcePush       bool
+	BlockDeletion        bool
+	RequireLinearHistory bool
+}
+
+// WebhookEndpoint subscribes a URL to repository events. Secret is
+// write-only and is never returned by GetOrgRepoDefaults.
+type WebhookEndpoint struct {
+	// ID is assigned by the server and identifies the endpoint for
+	// SendWebhookPing. It is ignored by SetOrgRepoDefaults.
+	ID     string
+	URL    string
+	Events []string
+	Secret string
+}
+
+// RetentionPolicy bounds how long transient data is kept.
+type RetentionPolicy struct {
+	// EphemeralBranchTTL expires ephemeral branches that set no TTL.
+	EphemeralBranchTTL time.Duration
+	// DeletedRepoRetention is how long deleted repositories can be
+	// recovered before they are purged.
+	DeletedRepoRetention time.Duration
+}
+
+// GetOrgRepoDefaultsOptions configures GetOrgRepoDefaults.
+type GetOrgRepoDefaultsOptions struct {
+	InvocationOptions
+}
+
+// SetOrgRepoDefaultsOptions configures SetOrgRepoDefaults. Defaults replaces
+// the stored template as a whole.
+type SetOrgRepoDefaultsOptions struct {
+	InvocationOptions
+	Defaults OrgRepoDefaults
+}
+
+// GetOrgRepoDefaults returns the organization's repository defaults.
+func (c *Client) GetOrgRepoDefaults(ctx context.Context, options GetOrgRepoDefaultsOptions) (OrgRepoDefaults, error) {
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := c.generateJWT("org", RemoteURLOptions{Permissions: []Permission{PermissionOrgRead}, TTL: ttl})
+	if err != nil {
+		return OrgRepoDefaults{}, err
+	}
+
+	resp, err := c.api.get(ctx, "org/repo-defaults", nil, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return OrgRepoDefaults{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload orgRepoDefaultsPayload
+	if err := decodeJSON(resp, &payload); err != nil {
+		return OrgRepoDefaults{}, err
+	}
+	return payload.defaults(), nil
+}
+
+// SetOrgRepoDefaults replaces the organization's repository defaults and
+// returns the stored result. Existing repositories are not changed.
+func (c *Client) SetOrgRepoDefaults(ctx context.Context, options SetOrgRepoDefaultsOptions) (OrgRepoDefaults, error) {
+	defaults := options.Defaults
+	for _, rule := range defaults.BranchProtection {
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return OrgRepoDefaults{}, errors.New("setOrgRepoDefaults branch protection pattern is required")
+		}
+	}
+	for _, hook := range defaults.Webhooks {
+		if !strings.HasPrefix(hook.URL, "https://") && !strings.HasPrefix(hook.URL, "http://") {
+			return OrgRepoDefaults{}, errors.New("setOrgRepoDefaults webhook url must be http or https")
+		}
+	}
+	if retention := defaults.Retention; retention != nil && (retention.EphemeralBranchTTL < 0 || retention.DeletedRepoRetention < 0) {
+		return OrgRepoDefaults{}, errors.New("setOrgRepoDefaults retention must be non-negative")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := c.generateJWT("org", RemoteURLOptions{Permissions: []Permission{PermissionOrgWrite}, TTL: ttl})
+	if err != nil {
+		return OrgRepoDefaults{}, err
+	}
+
+	resp, err := c.api.put(ctx, "org/repo-defaults", nil, newOrgRepoDefaultsPayload(defaults), jwtToken, nil)
+	if err != nil {
+		return OrgRepoDefaults{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload orgRepoDefaultsPayload
+	if err := decodeJSON(resp, &payload); err != nil {
+		return OrgRepoDefaults{}, err
+	}
+	return payload.defaults(), nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrgRepoDefaults(t *testing.T) {
+	var stored orgRepoDefaultsPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/org/repo-defaults" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		claims := parseJWTFromToken(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		scopes, _ := claims["scopes"].([]interface{})
+		switch r.Method {
+		case http.MethodPut:
+			if len(scopes) != 1 || scopes[0] != string(PermissionOrgWrite) {
+				t.Errorf("unexpected scopes: %v", scopes)
+			}
+			if err := json.NewDecoder(r.Body).Decode(&stored); err != nil {
+				t.Errorf("decode body: %v", err)
+			}
+			stored.UpdatedAt = "2024-01-20T10:30:00Z"
+		case http.MethodGet:
+			if len(scopes) != 1 || scopes[0] != string(PermissionOrgRead) {
+				t.Errorf("unexpected scopes: %v", scopes)
+			}
+		}
+		response := stored
+		response.Webhooks = nil
+		for _, hook := range stored.Webhooks {
+			hook.Secret = ""
+			response.Webhooks = append(response.Webhooks, hook)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+
+	_, err = client.SetOrgRepoDefaults(nil, SetOrgRepoDefaultsOptions{Defaults: OrgRepoDefaults{
+		DefaultBranch:    "trunk",
+		BranchProtection: []BranchProtectionRule{{Pattern: "trunk", BlockForcePush: true, BlockDeletion: true}},
+		Webhooks:         []WebhookEndpoint{{URL: "https://hooks.example.com/push", Events: []string{"push"}, Secret: "s3cret"}},
+		Retention:        &RetentionPolicy{EphemeralBranchTTL: 72 * time.Hour},
+	}})
+	if err != nil {
+		t.Fatalf("set defaults error: %v", err)
+	}
+	if stored.DefaultBranch != "trunk" || stored.Webhooks[0].Secret != "s3cret" || stored.Retention.EphemeralBranchTTLSeconds != 259200 {
+		t.Fatalf("unexpected stored payload: %+v", stored)
+	}
+
+	defaults, err := client.GetOrgRepoDefaults(nil, GetOrgRepoDefaultsOptions{})
+	if err != nil {
+		t.Fatalf("get defaults error: %v", err)
+	}
+	if defaults.DefaultBranch != "trunk" || len(defaults.BranchProtection) != 1 || !defaults.BranchProtection[0].BlockForcePush {
+		t.Fatalf("unexpected defaults: %+v", defaults)
+	}
+	if len(defaults.Webhooks) != 1 || defaults.Webhooks[0].Secret != "" || defaults.Retention.EphemeralBranchTTL != 72*time.Hour {
+		t.Fatalf("unexpected defaults: %+v", defaults)
+	}
+	if defaults.UpdatedAt.IsZero() {
+		t.Fatalf("expected updated at")
+	}
+
+	if _, err := client.SetOrgRepoDefaults(nil, SetOrgRepoDefaultsOptions{Defaults: OrgRepoDefaults{Webhooks: []WebhookEndpoint{{URL: "ftp://example.com"}}}}); err == nil {
+		t.Fatalf("expected webhook url error")
+	}
+}
+
+func TestCreateRepoUsesOrgDefaultBranch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"repo_id":"repo","url":"https://repo.git","default_branch":"trunk"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo, err := client.CreateRepo(nil, CreateRepoOptions{})
+	if err != nil {
+		t.Fatalf("create repo error: %v", err)
+	}
+	if repo.DefaultBranch != "trunk" {
+		t.Fatalf("expected org default branch, got %s", repo.DefaultBranch)
+	}
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HunkSelection selects hunks of one file in a unified diff. File and Hunks
+// are zero-based indexes; an empty Hunks keeps every hunk of the file.
+type HunkSelection struct {
+	File  int
+	Hunks []int
+}
+
+type patchFile struct {
+	header []string
+	hunks  []patchHunk
+}
+
+type patchHunk struct {
+	oldStart int
+	oldCount int
+	newStart int
+	newCount int
+	section  string
+	lines    []string
+}
+
+// SelectHunks reduces a unified diff to the selected files and hunks,
+// rewriting hunk headers so the result applies cleanly with
+// CreateCommitFromDiff.
+func SelectHunks(diff string, selections []HunkSelection) (string, error) {
+	files, err := parseUnifiedDiff(diff)
+	if err != nil {
+		return "", err
+	}
+
+	selected := make(map[int]map[int]bool, len(selections))
+	for _, selection := range selections {
+		if selection.File < 0 || selection.File >= len(files) {
+			return "", fmt.Errorf("hunk selection file index %d out of range", selection.File)
+		}
+		hunks := selected[selection.File]
+		if hunks == nil {
+			hunks = make(map[int]bool)
+			selected[selection.File] = hunks
+		}
+		if len(selection.Hunks) == 0 {
+			for i := range files[selection.File].hunks {
+				hunks[i] = true
+			}
+			if len(files[selection.File].hunks) == 0 {
+				hunks[-1] = true
+			}
+			continue
+		}
+		for _, index := range selection.Hunks {
+			if index < 0 || index >= len(files[selection.File].hunks) {
+				return "", fmt.Errorf("hunk selection index %d out of range for file %d", index, selection.File)
+			}
+			hunks[index] = true
+		}
+	}
+
+	var out strings.Builder
+	for fileIndex, file := range files {
+		hunks, ok := selected[fileIndex]
+		if !ok {
+			continue
+		}
+		if len(file.hunks) > 0 && isWholeFilePatch(file) && len(hunks) != len(file.hunks) {
+			return "", fmt.Errorf("file %d is created or deleted and cannot be partially applied", fileIndex)
+		}
+
+		for _, line := range file.header {
+			out.WriteString(line)
+			out.WriteByte('\n')
+		}
+		delta := 0
+		for hunkIndex, hunk := range file.hunks {
+			if !hunks[hunkIndex] {
+				continue
+			}
+			newStart := hunk.oldStart + delta
+			if hunk.newCount == 0 && hunk.oldCount > 0 {
+				newStart--
+			} else if hunk.oldCount == 0 {
+				newStart++
+			}
+			if newStart < 0 {
+				newStart = 0
+			}
+			out.WriteString(formatHunkHeader(hunk.oldStart, hunk.oldCount, newStart, hunk.newCount, hunk.section))
+			out.WriteByte('\n')
+			for _, line := range hunk.lines {
+				out.WriteString(line)
+				out.WriteByte('\n')
+			}
+			delta += hunk.newCount - hunk.oldCount
+		}
+	}
+
+	return out.String(), nil
+}
+
+func isWholeFilePatch(file patchFile) bool {
+	for _, line := range file.header {
+		if line == "--- /dev/null" || line == "+++ /dev/null" ||
+			strings.HasPrefix(line, "new file mode ") || strings.HasPrefix(line, "deleted file mode ") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseUnifiedDiff(diff string) ([]patchFile, error) {
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var files []patchFile
+	var current *patchFile
+	startFile := func() {
+		files = append(files, patchFile{})
+		current = &files[len(files)-1]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			startFile()
+			current.header = append(current.header, line)
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") &&
+			(current == nil || len(current.hunks) > 0):
+			startFile()
+			current.header = append(current.header, line)
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				return nil, errors.New("unified diff hunk found before file header")
+			}
+			hunk, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			oldRemaining, newRemaining := hunk.oldCount, hunk.newCount
+			for oldRemaining > 0 || newRemaining > 0 {
+				i++
+				if i >= len(lines) {
+					return nil, errors.New("unified diff hunk is truncated")
+				}
+				body := lines[i]
+				switch {
+				case strings.HasPrefix(body, "\\"):
+				case strings.HasPrefix(body, "+"):
+					newRemaining--
+				case strings.HasPrefix(body, "-"):
+					oldRemaining--
+				case strings.HasPrefix(body, " ") || body == "":
+					oldRemaining--
+					newRemaining--
+				default:
+					return nil, fmt.Errorf("unexpected line in unified diff hunk: %q", body)
+				}
+				hunk.lines = append(hunk.lines, body)
+			}
+			if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\\") {
+				i++
+				hunk.lines = append(hunk.lines, lines[i])
+			}
+			current.hunks = append(current.hunks, hunk)
+		default:
+			if current == nil {
+				continue
+			}
+			if len(current.hunks) > 0 {
+				return nil, fmt.Errorf("unexpected line after unified diff hunk: %q", line)
+			}
+			current.header = append(current.header, line)
+		}
+	}
+
+	return files, nil
+}
+
+func parseHunkHeader(line string) (patchHunk, error) {
+	rest := strings.TrimPrefix(line, "@@ ")
+	end := strings.Index(rest, " @@")
+	if end < 0 {
+		return patchHunk{}, fmt.Errorf("invalid hunk header: %q", line)
+	}
+	ranges := strings.Fields(rest[:end])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return patchHunk{}, fmt.Errorf("invalid hunk header: %q", line)
+	}
+	oldStart, oldCount, err := parseHunkRange(ranges[0][1:])
+	if err != nil {
+		return patchHunk{}, fmt.Errorf("invalid hunk header: %q", line)
+	}
+	newStart, newCount, err := parseHunkRange(ranges[1][1:])
+	if err != nil {
+		return patchHunk{}, fmt.Errorf("invalid hunk header: %q", line)
+	}
+	return patchHunk{
+		oldStart: oldStart,
+		oldCount: oldCount,
+		newStart: newStart,
+		newCount: newCount,
+		section:  strings.TrimPrefix(rest[end+3:], " "),
+	}, nil
+}
+
+func parseHunkRange(value string) (int, int, error) {
+	startText, countText, hasCount := strings.Cut(value, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, err
+	}
+	count := 1
+	if hasCount {
+		count, err = strconv.Atoi(countText)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
+
+func formatHunkHeader(oldStart, oldCount, newStart, newCount int, section string) string {
+	header := "@@ -" + formatHunkRange(oldStart, oldCount) + " +" + formatHunkRange(newStart, newCount) + " @@"
+	if section != "" {
+		header += " " + section
+	}
+	return header
+}
+
+func formatHunkRange(start, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return strconv.Itoa(start) + "," + strconv.Itoa(count)
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+const testMultiHunkDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@ package main
+ line1
++added1
+ line2
+ line3
+@@ -10,3 +11,2 @@ func a()
+ line10
+-line11
+ line12
+@@ -20,2 +20,3 @@ func b()
+ line20
++added2
+ line21
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++hello
++world
+`
+
+func TestSelectHunks(t *testing.T) {
+	reduced, err := SelectHunks(testMultiHunkDiff, []HunkSelection{{File: 0, Hunks: []int{0, 2}}})
+	if err != nil {
+		t.Fatalf("select hunks error: %v", err)
+	}
+	if strings.Contains(reduced, "-line11") {
+		t.Fatalf("expected unselected hunk to be dropped:\n%s", reduced)
+	}
+	if strings.Contains(reduced, "new.txt") {
+		t.Fatalf("expected unselected file to be dropped:\n%s", reduced)
+	}
+	if !strings.Contains(reduced, "@@ -1,3 +1,4 @@ package main\n") {
+		t.Fatalf("expected first hunk header to be preserved:\n%s", reduced)
+	}
+	if !strings.Contains(reduced, "@@ -20,2 +21,3 @@ func b()\n") {
+		t.Fatalf("expected last hunk header to be rebased:\n%s", reduced)
+	}
+	if !strings.HasPrefix(reduced, "diff --git a/main.go b/main.go\nindex 1111111..2222222 100644\n") {
+		t.Fatalf("expected file header to be preserved:\n%s", reduced)
+	}
+}
+
+func TestSelectHunksWholeFile(t *testing.T) {
+	reduced, err := SelectHunks(testMultiHunkDiff, []HunkSelection{{File: 1}})
+	if err != nil {
+		t.Fatalf("select hunks error: %v", err)
+	}
+	if !strings.HasPrefix(reduced, "diff --git a/new.txt b/new.txt\n") || !strings.Contains(reduced, "@@ -0,0 +1,2 @@\n+hello\n+world\n") {
+		t.Fatalf("unexpected reduced diff:\n%s", reduced)
+	}
+}
+
+func TestSelectHunksErrors(t *testing.T) {
+	if _, err := SelectHunks(testMultiHunkDiff, []HunkSelection{{File: 5}}); err == nil {
+		t.Fatalf("expected file index error")
+	}
+	if _, err := SelectHunks(testMultiHunkDiff, []HunkSelection{{File: 0, Hunks: []int{3}}}); err == nil {
+		t.Fatalf("expected hunk index error")
+	}
+	if _, err := SelectHunks("@@ -1 +1 @@\n-a\n+b\n", nil); err == nil {
+		t.Fatalf("expected missing file header error")
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+const (
+	defaultPushCommitsLimit = 250
+	pushCommitsPageSize     = 100
+)
+
+// ListPushCommitsOptions configures Client.ListPushCommits.
+type ListPushCommitsOptions struct {
+	InvocationOptions
+	Event WebhookPushEvent
+	// Limit caps the number of commits returned. Zero uses 250.
+	Limit int
+}
+
+// ListPushCommitsResult lists the commits a push introduced, newest first.
+type ListPushCommitsResult struct {
+	Commits []CommitInfo
+	// Truncated is set when more than Limit commits were new. This is
+	// expected for a new branch, where every ancestor is new to the ref.
+	Truncated bool
+}
+
+// ListPushCommits fetches the commits a push event added: those reachable
+// from After but not from Before, like git log Before..After. This
+// includes side-branch commits brought in by a merge and, for a force
+// push, only the commits Before did not already have. A branch deletion,
+// where After is all zeros, has no commits.
+func (c *Client) ListPushCommits(ctx context.Context, options ListPushCommitsOptions) (ListPushCommitsResult, error) {
+	event := options.Event
+	repoID := strings.TrimSpace(event.Repository.ID)
+	if repoID == "" {
+		return ListPushCommitsResult{}, errors.New("listPushCommits event repository id is required")
+	}
+	if strings.TrimSpace(event.After) == "" {
+		return ListPushCommitsResult{}, errors.New("listPushCommits event after is required")
+	}
+	if options.Limit < 0 {
+		return ListPushCommitsResult{}, errors.New("listPushCommits limit must not be negative")
+	}
+	if isZeroSHA(event.After) {
+		return ListPushCommitsResult{}, nil
+	}
+	limit := options.Limit
+	if limit == 0 {
+		limit = defaultPushCommitsLimit
+	}
+	var exclude []string
+	if before := strings.TrimSpace(event.Before); before != "" && !isZeroSHA(before) {
+		exclude = []string{before}
+	}
+
+	repo := &Repo{ID: repoID, client: c}
+	var result ListPushCommitsResult
+	cursor := ""
+	for {
+		pageSize := pushCommitsPageSize
+		if remaining := limit - len(result.Commits) + 1; remaining < pageSize {
+			// One extra commit tells a full page apart from truncation.
+			pageSize = remaining
+		}
+		page, err := repo.ListCommits(ctx, ListCommitsOptions{
+			InvocationOptions: options.InvocationOptions,
+			Branch:            strings.TrimSpace(event.After),
+			Exclude:           exclude,
+			Order:             CommitOrderTopo,
+			Cursor:            cursor,
+			Limit:             pageSize,
+		})
+		if err != nil {
+			return ListPushCommitsResult{}, err
+		}
+		for _, commit := range page.Commits {
+			if len(result.Commits) == limit {
+				result.Truncated = true
+				return result, nil
+			}
+			result.Commits = append(result.Commits, commit)
+		}
+		if !page.HasMore || page.NextCursor == "" {
+			return result, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func isZeroSHA(sha string) bool {
+	sha = strings.TrimSpace(sha)
+	return sha != "" && strings.Trim(sha, "0") == ""
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newCommitGraphServer serves repos/commits for a commit graph listed in
+// topological order, honoring exclude like git log --not.
+func newCommitGraphServer(t *testing.T, order []string, parents map[string][]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/commits" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("order") != "topo" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		reachable := func(heads []string) map[string]bool {
+			seen := map[string]bool{}
+			for len(heads) > 0 {
+				sha := heads[0]
+				heads = heads[1:]
+				if !seen[sha] {
+					seen[sha] = true
+					heads = append(heads, parents[sha]...)
+				}
+			}
+			return seen
+		}
+		included := reachable([]string{q.Get("branch")})
+		excluded := reachable(q["exclude"])
+		var listed []string
+		for _, sha := range order {
+			if included[sha] && !excluded[sha] {
+				listed = append(listed, sha)
+			}
+		}
+		start, _ := strconv.Atoi(q.Get("cursor"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		var commits []string
+		for i := start; i < start+limit && i < len(listed); i++ {
+			commits = append(commits, fmt.Sprintf(`{"sha":"%s","message":"commit %s","date":"2024-01-20T10:30:00Z"}`, listed[i], listed[i]))
+		}
+		next := start + limit
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"commits":[%s],"next_cursor":"%d","has_more":%t}`, strings.Join(commits, ","), next, next < len(listed))
+	}))
+}
+
+func TestListPushCommits(t *testing.T) {
+	// Linear history c5 -> c4 -> ... -> c0.
+	order := []string{"c5", "c4", "c3", "c2", "c1", "c0"}
+	parents := map[string][]string{}
+	for i := 0; i < len(order)-1; i++ {
+		parents[order[i]] = []string{order[i+1]}
+	}
+	server := newCommitGraphServer(t, order, parents)
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	event := WebhookPushEvent{Repository: WebhookRepository{ID: "repo"}, Ref: "main", Before: "c2", After: "c5"}
+
+	result, err := client.ListPushCommits(nil, ListPushCommitsOptions{Event: event})
+	if err != nil {
+		t.Fatalf("list push commits error: %v", err)
+	}
+	if len(result.Commits) != 3 || result.Commits[0].SHA != "c5" || result.Commits[2].SHA != "c3" || result.Truncated {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	event.Before = "0000000000000000000000000000000000000000"
+	result, err = client.ListPushCommits(nil, ListPushCommitsOptions{Event: event, Limit: 2})
+	if err != nil {
+		t.Fatalf("list push commits error: %v", err)
+	}
+	if len(result.Commits) != 2 || result.Commits[1].SHA != "c4" || !result.Truncated {
+		t.Fatalf("expected truncated new-branch push, got %+v", result)
+	}
+
+	event.Before, event.After = "c5", "0000000000000000000000000000000000000000"
+	result, err = client.ListPushCommits(nil, ListPushCommitsOptions{Event: event})
+	if err != nil || len(result.Commits) != 0 {
+		t.Fatalf("expected no commits for a deleted branch, got %+v (%v)", result, err)
+	}
+}
+
+func TestListPushCommitsMerge(t *testing.T) {
+	// main was at m1; the push merges side branch s2 -> s1, forked from m0.
+	// Topological order lists m1 before the side branch, so walking until
+	// Before would stop early and miss s2 and s1.
+	order := []string{"merge", "m1", "s2", "s1", "m0"}
+	parents := map[string][]string{
+		"merge": {"m1", "s2"},
+		"m1":    {"m0"},
+		"s2":    {"s1"},
+		"s1":    {"m0"},
+	}
+	server := newCommitGraphServer(t, order, parents)
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	event := WebhookPushEvent{Repository: WebhookRepository{ID: "repo"}, Ref: "main", Before: "m1", After: "merge"}
+
+	result, err := client.ListPushCommits(nil, ListPushCommitsOptions{Event: event})
+	if err != nil {
+		t.Fatalf("list push commits error: %v", err)
+	}
+	var shas []string
+	for _, commit := range result.Commits {
+		shas = append(shas, commit.SHA)
+	}
+	if strings.Join(shas, ",") != "merge,s2,s1" || result.Truncated {
+		t.Fatalf("expected merge and side-branch commits, got %v (truncated %t)", shas, result.Truncated)
+	}
+}
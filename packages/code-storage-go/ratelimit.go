@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRedisRateLimitKey    = "code-storage:rate-limit"
+	defaultRedisRateLimitWindow = time.Second
+)
+
+// RateLimiter gates outgoing API requests. Wait blocks until a request may be
+// sent or the context is done.
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// RedisEvaler is the subset of a Redis client used by RedisRateLimiter.
+// go-redis clients can be adapted by wrapping rdb.Eval(...).Result() in a
+// RedisEvalFunc.
+type RedisEvaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// RedisEvalFunc adapts a function to the RedisEvaler interface.
+type RedisEvalFunc func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+
+// Eval calls f.
+func (f RedisEvalFunc) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return f(ctx, script, keys, args...)
+}
+
+// RedisRateLimiterOptions configures a Redis-backed rate limiter.
+type RedisRateLimiterOptions struct {
+	// Key is the Redis key shared by every process using the same org key.
+	Key string
+	// Limit is the number of requests allowed per Window across all processes.
+	Limit int
+	// Window is the fixed window length. Defaults to one second.
+	Window time.Duration
+}
+
+// RedisRateLimiter is a fixed-window limiter coordinated through Redis so
+// that many workers sharing one org key collectively respect server limits.
+type RedisRateLimiter struct {
+	redis  RedisEvaler
+	key    string
+	limit  int
+	window time.Duration
+}
+
+// redisRateLimitScript increments the window counter and returns the number
+// of milliseconds to wait before retrying, or 0 when the request may proceed.
+const redisRateLimitScript = `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+if count > tonumber(ARGV[1]) then
+  local ttl = redis.call('PTTL', KEYS[1])
+  if ttl < 0 then
+    redis.call('PEXPIRE', KEYS[1], ARGV[2])
+    ttl = tonumber(ARGV[2])
+  end
+  return ttl
+end
+return 0
+`
+
+// NewRedisRateLimiter creates a Redis-backed rate limiter.
+func NewRedisRateLimiter(redis RedisEvaler, options RedisRateLimiterOptions) (*RedisRateLimiter, error) {
+	if redis == nil {
+		return nil, errors.New("redis rate limiter requires a redis client")
+	}
+	if options.Limit <= 0 {
+		return nil, errors.New("redis rate limiter limit must be positive")
+	}
+	key := options.Key
+	if key == "" {
+		key = defaultRedisRateLimitKey
+	}
+	window := options.Window
+	if window <= 0 {
+		window = defaultRedisRateLimitWindow
+	}
+	return &RedisRateLimiter{redis: redis, key: key, limit: options.Limit, window: window}, nil
+}
+
+// Wait blocks until the shared window has capacity.
+func (l *RedisRateLimiter) Wait(ctx context.Context) error {
+	for {
+		result, err := l.redis.Eval(ctx, redisRateLimitScript, []string{l.key}, l.limit, l.window.Milliseconds())
+		if err != nil {
+			return err
+		}
+		delayMS, err := redisInt(result)
+		if err != nil {
+			return err
+		}
+		if delayMS <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(time.Duration(delayMS) * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func redisInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	default:
+		return 0, errors.New("unexpected redis rate limiter reply")
+	}
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type countingLimiter struct {
+	calls int
+	err   error
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.calls++
+	return l.err
+}
+
+func TestRateLimiterWaitsBeforeRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"paths":[],"ref":"main"}`))
+	}))
+	defer server.Close()
+
+	limiter := &countingLimiter{}
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL, RateLimiter: limiter})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	if _, err := repo.ListFiles(nil, ListFilesOptions{}); err != nil {
+		t.Fatalf("list files error: %v", err)
+	}
+	if limiter.calls != 1 {
+		t.Fatalf("expected limiter to be consulted once, got %d", limiter.calls)
+	}
+
+	limiter.err = errors.New("limited")
+	if _, err := repo.ListFiles(nil, ListFilesOptions{}); err == nil || err.Error() != "limited" {
+		t.Fatalf("expected limiter error, got %v", err)
+	}
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	var replies = []interface{}{int64(20), int64(0)}
+	var keys []string
+	evaler := RedisEvalFunc(func(ctx context.Context, script string, k []string, args ...interface{}) (interface{}, error) {
+		keys = append(keys, k...)
+		if args[0] != 5 || args[1] != int64(1000) {
+			t.Fatalf("unexpected args: %v", args)
+		}
+		reply := replies[0]
+		replies = replies[1:]
+		return reply, nil
+	})
+
+	limiter, err := NewRedisRateLimiter(evaler, RedisRateLimiterOptions{Key: "acme", Limit: 5})
+	if err != nil {
+		t.Fatalf("limiter error: %v", err)
+	}
+	start := time.Now()
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("wait error: %v", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("expected limiter to wait for window")
+	}
+	if len(keys) != 2 || keys[0] != "acme" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+}
+
+func TestRedisRateLimiterContextCancel(t *testing.T) {
+	evaler := RedisEvalFunc(func(ctx context.Context, script string, k []string, args ...interface{}) (interface{}, error) {
+		return int64(60000), nil
+	})
+	limiter, err := NewRedisRateLimiter(evaler, RedisRateLimiterOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("limiter error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	if _, err := NewRedisRateLimiter(evaler, RedisRateLimiterOptions{}); err == nil {
+		t.Fatalf("expected error for missing limit")
+	}
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FileReader provides random access to a repository file through HTTP range
+// requests. It implements io.ReaderAt and io.ReadSeeker, so it can back
+// archive/zip and similar readers without downloading the whole file.
+// ReadAt is safe for concurrent use; Read and Seek are not.
+type FileReader struct {
+	ctx       context.Context
+	repo      *Repo
+	options   GetFileOptions
+	size      int64
+	validator string
+	pos       int64
+}
+
+var (
+	_ io.ReaderAt   = (*FileReader)(nil)
+	_ io.ReadSeeker = (*FileReader)(nil)
+)
+
+// OpenFileReader probes the file size and returns a FileReader. Every range
+// request is pinned to the content seen by the probe; if the file changes,
+// reads fail with ErrDownloadChanged.
+func (r *Repo) OpenFileReader(ctx context.Context, options GetFileOptions) (*FileReader, error) {
+	if strings.TrimSpace(options.Path) == "" {
+		return nil, errors.New("openFileReader path is required")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	header := http.Header{}
+	header.Set("Range", "bytes=0-0")
+	resp, err := r.fileStream(ctx, options, header)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusRequestedRangeNotSatisfiable {
+			return &FileReader{ctx: ctx, repo: r, options: options}, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, errors.New("openFileReader requires range request support")
+	}
+	size, ok := contentRangeSize(resp.Header.Get("Content-Range"))
+	if !ok {
+		return nil, fmt.Errorf("openFileReader unexpected content range %q", resp.Header.Get("Content-Range"))
+	}
+
+	return &FileReader{
+		ctx:       ctx,
+		repo:      r,
+		options:   options,
+		size:      size,
+		validator: downloadValidator(resp),
+	}, nil
+}
+
+// Size returns the file size in bytes.
+func (f *FileReader) Size() int64 {
+	return f.size
+}
+
+// ReadAt reads len(p) bytes starting at off with a single range request.
+func (f *FileReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("fileReader negative offset")
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	end := off + int64(len(p)) - 1
+	if end >= f.size {
+		end = f.size - 1
+	}
+	header := http.Header{}
+	header.Set("Range", "bytes="+strconv.FormatInt(off, 10)+"-"+strconv.FormatInt(end, 10))
+	if f.validator != "" {
+		header.Set("If-Range", f.validator)
+	}
+	resp, err := f.repo.fileStream(f.ctx, f.options, header)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, ErrDownloadChanged
+	}
+	if validator := downloadValidator(resp); f.validator != "" && validator != "" && validator != f.validator {
+		return 0, ErrDownloadChanged
+	}
+	if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != off {
+		return 0, fmt.Errorf("fileReader unexpected content range %q", resp.Header.Get("Content-Range"))
+	}
+
+	n, err := io.ReadFull(resp.Body, p[:end-off+1])
+	if err != nil {
+		return n, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Read reads from the current position.
+func (f *FileReader) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.pos)
+	f.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek sets the position for the next Read.
+func (f *FileReader) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = f.pos + offset
+	case io.SeekEnd:
+		next = f.size + offset
+	default:
+		return 0, errors.New("fileReader invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("fileReader negative position")
+	}
+	f.pos = next
+	return next, nil
+}
+
+func contentRangeSize(value string) (int64, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, false
+	}
+	_, sizeText, ok := strings.Cut(value, "/")
+	if !ok || sizeText == "*" {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeText), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFileReaderRandomAccess(t *testing.T) {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	entry, err := writer.Create("data/report.txt")
+	if err != nil {
+		t.Fatalf("zip create error: %v", err)
+	}
+	_, _ = entry.Write([]byte("quarterly numbers"))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("zip close error: %v", err)
+	}
+	content := archive.Bytes()
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/file" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		ranges = append(ranges, r.Header.Get("Range"))
+		if len(ranges) > 1 && r.Header.Get("If-Range") != `"blob"` {
+			t.Errorf("unexpected If-Range: %s", r.Header.Get("If-Range"))
+		}
+		w.Header().Set("ETag", `"blob"`)
+		http.ServeContent(w, r, "artifact.zip", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	reader, err := repo.OpenFileReader(nil, GetFileOptions{Path: "artifact.zip"})
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	if reader.Size() != int64(len(content)) {
+		t.Fatalf("unexpected size: %d", reader.Size())
+	}
+
+	zipReader, err := zip.NewReader(reader, reader.Size())
+	if err != nil {
+		t.Fatalf("zip reader error: %v", err)
+	}
+	if len(zipReader.File) != 1 || zipReader.File[0].Name != "data/report.txt" {
+		t.Fatalf("unexpected zip entries: %+v", zipReader.File)
+	}
+	file, err := zipReader.File[0].Open()
+	if err != nil {
+		t.Fatalf("zip open error: %v", err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if string(data) != "quarterly numbers" {
+		t.Fatalf("unexpected zip contents: %q", data)
+	}
+	if ranges[0] != "bytes=0-0" || len(ranges) < 3 {
+		t.Fatalf("unexpected range requests: %v", ranges)
+	}
+
+	if _, err := reader.Seek(-4, io.SeekEnd); err != nil {
+		t.Fatalf("seek error: %v", err)
+	}
+	tail, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if !bytes.Equal(tail, content[len(content)-4:]) {
+		t.Fatalf("unexpected tail: %v", tail)
+	}
+}
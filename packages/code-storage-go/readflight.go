@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// readFlightGroup deduplicates identical concurrent GET requests. The first
+// caller's request runs detached from its context and is canceled only once
+// every caller waiting on it has given up. Results are not cached: a request
+// that starts after the shared one finishes goes to the server again.
+// Bodies are buffered whole, so streaming reads bypass the group.
+type readFlightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*readFlight
+}
+
+type readFlight struct {
+	done    chan struct{}
+	resp    *http.Response
+	body    []byte
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func newReadFlightGroup() *readFlightGroup {
+	return &readFlightGroup{calls: make(map[string]*readFlight)}
+}
+
+func (g *readFlightGroup) do(ctx context.Context, key string, fn func(context.Context) (*http.Response, error)) (*http.Response, error) {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if ok {
+		call.waiters++
+		g.mu.Unlock()
+		return g.wait(ctx, key, call)
+	}
+	flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	call = &readFlight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		resp, err := fn(flightCtx)
+		if err == nil {
+			call.body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		call.resp, call.err = resp, err
+
+		g.mu.Lock()
+		if g.calls[key] == call {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	return g.wait(ctx, key, call)
+}
+
+func (g *readFlightGroup) wait(ctx context.Context, key string, call *readFlight) (*http.Response, error) {
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		resp := *call.resp
+		resp.Header = call.resp.Header.Clone()
+		resp.Body = io.NopCloser(bytes.NewReader(call.body))
+		resp.ContentLength = int64(len(call.body))
+		return &resp, nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// readFlightKey identifies a request by everything that can change its
+// response: the URL, the issuer, subject, repository, and scopes of the
+// token (not its timestamps), and per-request headers.
+func readFlightKey(path string, params url.Values, jwt string, opts *requestOptions) string {
+	var key strings.Builder
+	key.WriteString(path)
+	key.WriteString("?")
+	key.WriteString(params.Encode())
+	key.WriteString("\x00")
+	key.WriteString(tokenScopeKey(jwt))
+	if opts != nil {
+		key.WriteString("\x00")
+		key.WriteString(string(opts.readPreference))
+		names := make([]string, 0, len(opts.header))
+		for name := range opts.header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			key.WriteString("\x00" + name + ":" + strings.Join(opts.header[name], ","))
+		}
+		statuses := make([]int, 0, len(opts.allowedStatus))
+		for status, allowed := range opts.allowedStatus {
+			if allowed {
+				statuses = append(statuses, status)
+			}
+		}
+		sort.Ints(statuses)
+		for _, status := range statuses {
+			key.WriteString("\x00" + itoa(status))
+		}
+	}
+	return key.String()
+}
+
+func tokenScopeKey(jwt string) string {
+	claims, ok := decodeTokenClaims(jwt)
+	if !ok {
+		return jwt
+	}
+	return claims.Issuer + "\x00" + claims.Subject + "\x00" + claims.Repo + "\x00" + strings.Join(claims.Scopes, ",") + "\x00" + strings.Join(claims.SubmoduleRepos, ",")
+}
+
+type tokenClaims struct {
+	Issuer  string   `json:"iss"`
+	Subject string   `json:"sub"`
+	Repo    string   `json:"repo"`
+	Scopes  []string `json:"scopes"`
+	// SubmoduleRepos widens a read token to the listed submodule
+	// repositories, so it is part of the token's scope.
+	SubmoduleRepos []string `json:"submodule_repos"`
+}
+
+// decodeTokenClaims reads the claims of a JWT minted by this client without
+// verifying its signature.
+func decodeTokenClaims(jwt string) (tokenClaims, bool) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return tokenClaims{}, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return tokenClaims{}, false
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return tokenClaims{}, false
+	}
+	return claims, true
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitForFlightWaiters(t *testing.T, group *readFlightGroup, waiters int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		group.mu.Lock()
+		total := 0
+		for _, call := range group.calls {
+			total += call.waiters
+		}
+		group.mu.Unlock()
+		if total == waiters {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d waiters", waiters)
+}
+
+func TestDeduplicateReads(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"sha":"abc","note":"shared","ref_sha":"n1"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL, DeduplicateReads: true})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	const callers = 5
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	results := make([]GetNoteResult, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		ctx := context.Background()
+		if i == 0 {
+			ctx = cancelCtx
+		}
+		wg.Add(1)
+		go func(i int, ctx context.Context) {
+			defer wg.Done()
+			results[i], errs[i] = repo.GetNote(ctx, GetNoteOptions{SHA: "abc"})
+		}(i, ctx)
+	}
+	waitForFlightWaiters(t, client.api.reads, callers)
+
+	// The caller that started the request gives up; the others still share it.
+	cancel()
+	waitForFlightWaiters(t, client.api.reads, callers-1)
+	close(release)
+	wg.Wait()
+
+	if hits.Load() != 1 {
+		t.Fatalf("expected one request, got %d", hits.Load())
+	}
+	for i := 1; i < callers; i++ {
+		if errs[i] != nil || results[i].Note != "shared" {
+			t.Fatalf("caller %d: unexpected result %+v, %v", i, results[i], errs[i])
+		}
+	}
+
+	// Finished requests are not cached.
+	if _, err := repo.GetNote(nil, GetNoteOptions{SHA: "abc"}); err != nil {
+		t.Fatalf("get note error: %v", err)
+	}
+	if hits.Load() != 2 {
+		t.Fatalf("expected a fresh request, got %d", hits.Load())
+	}
+}
+
+func TestDeduplicateReadsSkipsStreams(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("first chunk"))
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL, DeduplicateReads: true})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, err := repo.FileStream(nil, GetFileOptions{Path: "README.md"})
+	if err != nil {
+		t.Fatalf("file stream error: %v", err)
+	}
+	defer resp.Body.Close()
+	buf := make([]byte, len("first chunk"))
+	if _, err := io.ReadFull(resp.Body, buf); err != nil || string(buf) != "first chunk" {
+		t.Fatalf("expected the body to stream before the response finished, got %q (%v)", buf, err)
+	}
+}
+
+func TestReadFlightKeyScopesByRepo(t *testing.T) {
+	client, err := NewClient(Options{Name: "acme", Key: testKey})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	read := RemoteURLOptions{Permissions: []Permission{PermissionGitRead}}
+	first, _ := client.generateJWT("repo-a", read)
+	later, _ := client.generateJWT("repo-a", RemoteURLOptions{Permissions: read.Permissions, TTL: time.Minute})
+	other, _ := client.generateJWT("repo-b", read)
+
+	if readFlightKey("repos/file", nil, first, nil) != readFlightKey("repos/file", nil, later, nil) {
+		t.Fatalf("expected tokens for the same repo and scopes to share a key")
+	}
+	if readFlightKey("repos/file", nil, first, nil) == readFlightKey("repos/file", nil, other, nil) {
+		t.Fatalf("expected different repos to use different keys")
+	}
+}
+
+func TestReadFlightKeyScopesByActor(t *testing.T) {
+	client, err := NewClient(Options{Name: "acme", Key: testKey})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	read := RemoteURLOptions{Permissions: []Permission{PermissionGitRead}}
+	alice, _ := client.WithActor("user:alice").generateJWT("repo-a", read)
+	bob, _ := client.WithActor("user:bob").generateJWT("repo-a", read)
+
+	if readFlightKey("repos/file", nil, alice, nil) == readFlightKey("repos/file", nil, bob, nil) {
+		t.Fatalf("expected different actors to use different keys")
+	}
+}
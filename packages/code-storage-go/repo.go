@@ -2,12 +2,17 @@ package storage
 
 import (
 	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var restoreCommitAllowedStatus = map[int]bool{
@@ -51,13 +56,7 @@ func (r *Repo) RemoteURL(ctx context.Context, options RemoteURLOptions) (string,
 		return "", err
 	}
 
-	u := url.URL{
-		Scheme: "https",
-		Host:   r.client.options.StorageBaseURL,
-		Path:   "/" + r.ID + ".git",
-	}
-	u.User = url.UserPassword("t", jwtToken)
-	return u.String(), nil
+	return r.remoteURL(r.ID+".git", jwtToken), nil
 }
 
 // EphemeralRemoteURL returns the ephemeral remote URL.
@@ -67,23 +66,41 @@ func (r *Repo) EphemeralRemoteURL(ctx context.Context, options RemoteURLOptions)
 		return "", err
 	}
 
+	return r.remoteURL(r.ID+"+ephemeral.git", jwtToken), nil
+}
+
+func (r *Repo) remoteURL(path string, jwtToken string) string {
+	host := r.client.options.StorageBaseURL
+	if port := r.client.options.StoragePort; port > 0 {
+		if parsed, err := url.Parse("//" + host); err == nil && parsed.Port() != "" {
+			host = parsed.Hostname()
+		}
+		host = net.JoinHostPort(strings.Trim(host, "[]"), itoa(port))
+	}
 	u := url.URL{
-		Scheme: "https",
-		Host:   r.client.options.StorageBaseURL,
-		Path:   "/" + r.ID + "+ephemeral.git",
+		Scheme: r.client.options.StorageScheme,
+		Host:   host,
+		Path:   "/" + path,
 	}
 	u.User = url.UserPassword("t", jwtToken)
-	return u.String(), nil
+	return u.String()
 }
 
 // FileStream returns the raw response for streaming file contents.
 func (r *Repo) FileStream(ctx context.Context, options GetFileOptions) (*http.Response, error) {
+	return r.fileStream(ctx, options, nil)
+}
+
+func (r *Repo) fileStream(ctx context.Context, options GetFileOptions, header http.Header) (*http.Response, error) {
 	if strings.TrimSpace(options.Path) == "" {
 		return nil, errors.New("getFileStream path is required")
 	}
+	if options.ResolveSubmodules && len(options.SubmoduleRepos) == 0 {
+		return nil, errors.New("getFileStream submoduleRepos is required with resolveSubmodules")
+	}
 
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
-	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.submoduleReadJWT(ttl, options.SubmoduleRepos)
 	if err != nil {
 		return nil, fmt.Errorf("archive stream generate jwt: %w", err)
 	}
@@ -99,19 +116,248 @@ func (r *Repo) FileStream(ctx context.Context, options GetFileOptions) (*http.Re
 	if options.EphemeralBase != nil {
 		params.Set("ephemeral_base", strconv.FormatBool(*options.EphemeralBase))
 	}
+	if options.FollowSymlinks != nil {
+		params.Set("follow_symlinks", strconv.FormatBool(*options.FollowSymlinks))
+	}
+	if options.ResolveSubmodules {
+		params.Set("resolve_submodules", "true")
+	}
+
+	opts := withRequestHeader(streamRequestOptions(options.InvocationOptions), header)
+	if header.Get("If-None-Match") != "" {
+		opts.allowedStatus = map[int]bool{http.StatusNotModified: true}
+	}
+	resp, err := r.client.api.get(ctx, "repos/file", params, jwtToken, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// submoduleReadJWT mints the git:read token for a read that may follow
+// submodules. Each of repos is added to the token's submodule_repos claim,
+// which grants git:read on that repository for this request; submodules
+// pointing anywhere else are left unresolved.
+func (r *Repo) submoduleReadJWT(ttl time.Duration, repos []string) (string, error) {
+	var granted []string
+	for _, repo := range repos {
+		if repo = strings.TrimSpace(repo); repo != "" && repo != r.ID {
+			granted = append(granted, repo)
+		}
+	}
+	if len(granted) == 0 {
+		return r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	}
+	token, _, err := r.client.signJWT(r.ID, []Permission{PermissionGitRead}, ttl, "", map[string]interface{}{"submodule_repos": granted})
+	return token, err
+}
+
+// FileStreamEntryType reports the tree entry type of a FileStream response.
+func FileStreamEntryType(resp *http.Response) FileEntryType {
+	if resp == nil {
+		return FileEntryTypeUnknown
+	}
+	if mode := strings.TrimSpace(resp.Header.Get(fileModeHeader)); mode != "" {
+		return fileEntryTypeFromMode(mode)
+	}
+	switch FileEntryType(strings.ToLower(strings.TrimSpace(resp.Header.Get(fileEntryTypeHeader)))) {
+	case FileEntryTypeFile:
+		return FileEntryTypeFile
+	case FileEntryTypeSymlink:
+		return FileEntryTypeSymlink
+	case FileEntryTypeSubmodule:
+		return FileEntryTypeSubmodule
+	default:
+		return FileEntryTypeUnknown
+	}
+}
+
+// FileStreamSubmoduleRepo reports the ID of the repository that served a
+// FileStream response read through a resolved submodule, or "" when the
+// file belongs to the requested repo.
+func FileStreamSubmoduleRepo(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	return strings.TrimSpace(resp.Header.Get(submoduleRepoHeader))
+}
+
+// FileStreamBlobSHA reports the blob SHA of the file served by a FileStream
+// response, or "" when the server did not send one. It identifies the file
+// version, for example to attach a note with NoteObjectBlob.
+func FileStreamBlobSHA(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	return strings.TrimSpace(resp.Header.Get(blobSHAHeader))
+}
+
+// GetBlob returns the raw response for streaming a blob by its object SHA,
+// independent of any ref. When Options.BlobCache is set, hits are served
+// from the cache and misses are stored as the body is read.
+func (r *Repo) GetBlob(ctx context.Context, options GetBlobOptions) (*http.Response, error) {
+	sha := strings.TrimSpace(options.SHA)
+	if sha == "" {
+		return nil, errors.New("getBlob sha is required")
+	}
+
+	cache := r.client.options.BlobCache
+	if cache != nil {
+		if body, ok := cache.Open(sha); ok {
+			return cachedBlobResponse(body), nil
+		}
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := r.client.api.get(ctx, "repos/file", params, jwtToken, nil)
+	params := url.Values{}
+	params.Set("sha", sha)
+
+	resp, err := r.client.api.get(ctx, "repos/blob", params, jwtToken, streamRequestOptions(options.InvocationOptions))
 	if err != nil {
 		return nil, err
 	}
+	if cache != nil {
+		resp.Body = newBlobCacheTee(cache, sha, resp.Body)
+	}
 
 	return resp, nil
 }
 
+// GetCommitPatch returns the raw response for streaming a commit as
+// `git format-patch` mbox output, with authorship and message headers, so it
+// can be piped into `git am`.
+func (r *Repo) GetCommitPatch(ctx context.Context, options GetCommitPatchOptions) (*http.Response, error) {
+	sha := strings.TrimSpace(options.SHA)
+	if sha == "" {
+		return nil, errors.New("getCommitPatch sha is required")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("sha", sha)
+	if baseSHA := strings.TrimSpace(options.BaseSHA); baseSHA != "" {
+		params.Set("base_sha", baseSHA)
+	}
+
+	return r.client.api.get(ctx, "repos/patch", params, jwtToken, streamRequestOptions(options.InvocationOptions))
+}
+
 // ArchiveStream returns the raw response for streaming repository archives.
+// The response ETag can be passed back as ArchiveOptions.IfNoneMatch to
+// skip unchanged snapshots; use DownloadArchive to resume interrupted reads.
+// When the server sends an archive digest, reading the body to EOF returns
+// ErrArchiveChecksumMismatch if the received bytes do not match it.
 func (r *Repo) ArchiveStream(ctx context.Context, options ArchiveOptions) (*http.Response, error) {
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
-	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	options, err := r.expandArchivePrefix(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.archiveStream(ctx, options, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		resp.Body = newChecksumBody(resp)
+	}
+	return resp, nil
+}
+
+// expandArchivePrefix replaces {repo}, {ref}, {sha}, and {short_sha} in
+// ArchivePrefix. The commit SHA is looked up only when the prefix uses it
+// and Ref is not already a full SHA; the archive is then pinned to that
+// commit so its contents match the prefix. Slashes in {ref} become dashes.
+func (r *Repo) expandArchivePrefix(ctx context.Context, options ArchiveOptions) (ArchiveOptions, error) {
+	prefix := options.ArchivePrefix
+	if !strings.Contains(prefix, "{") {
+		return options, nil
+	}
+
+	needSHA := false
+	for rest := prefix; ; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return options, errors.New("archivePrefix has an unterminated placeholder")
+		}
+		switch name := rest[start+1 : start+end]; name {
+		case "repo", "ref":
+		case "sha", "short_sha":
+			needSHA = true
+		default:
+			return options, fmt.Errorf("archivePrefix has unknown placeholder {%s}", name)
+		}
+		rest = rest[start+end+1:]
+	}
+
+	ref := strings.TrimSpace(options.Ref)
+	if ref == "" {
+		ref = strings.TrimSpace(options.SHA)
+	}
+	if ref == "" {
+		ref = r.DefaultBranch
+	}
+	sha := ""
+	if needSHA {
+		sha = strings.ToLower(ref)
+		if !isHexObjectID(sha) {
+			result, err := r.ListCommits(ctx, ListCommitsOptions{InvocationOptions: options.InvocationOptions, Branch: ref, Limit: 1, Order: CommitOrderTopo})
+			if err != nil {
+				return options, fmt.Errorf("archivePrefix resolve sha: %w", err)
+			}
+			if len(result.Commits) == 0 {
+				return options, fmt.Errorf("archivePrefix could not resolve a commit for %q", ref)
+			}
+			sha = result.Commits[0].SHA
+			options.Ref = ""
+			options.SHA = sha
+		}
+	}
+	shortSHA := sha
+	if len(shortSHA) > 7 {
+		shortSHA = shortSHA[:7]
+	}
+
+	options.ArchivePrefix = strings.NewReplacer(
+		"{repo}", r.ID,
+		"{ref}", strings.ReplaceAll(ref, "/", "-"),
+		"{sha}", sha,
+		"{short_sha}", shortSHA,
+	).Replace(prefix)
+	return options, nil
+}
+
+func (r *Repo) archiveStream(ctx context.Context, options ArchiveOptions, header http.Header) (*http.Response, error) {
+	switch options.Format {
+	case "", ArchiveFormatTarGz, ArchiveFormatZip:
+	default:
+		return nil, errors.New("archiveStream format must be tar.gz or zip")
+	}
+	switch options.Compression {
+	case "", ArchiveCompressionGzip:
+	case ArchiveCompressionZstd:
+		if options.Format == ArchiveFormatZip {
+			return nil, errors.New("archiveStream compression is not supported for zip archives")
+		}
+	default:
+		return nil, errors.New("archiveStream compression must be gzip or zstd")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.submoduleReadJWT(ttl, options.SubmoduleRepos)
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +366,44 @@ func (r *Repo) ArchiveStream(ctx context.Context, options ArchiveOptions) (*http
 	if ref := strings.TrimSpace(options.Ref); ref != "" {
 		req.Ref = ref
 	}
+	if sha := strings.ToLower(strings.TrimSpace(options.SHA)); sha != "" {
+		if req.Ref != "" {
+			return nil, errors.New("archiveStream ref and sha are mutually exclusive")
+		}
+		if !isHexObjectID(sha) {
+			return nil, errors.New("archiveStream sha must be a full commit SHA")
+		}
+		req.SHA = sha
+	}
+	if path := strings.Trim(strings.TrimSpace(options.Path), "/"); path != "" {
+		for _, segment := range strings.Split(path, "/") {
+			if segment == ".." {
+				return nil, errors.New("archiveStream path must not contain .. segments")
+			}
+		}
+		req.Path = path
+	}
+	if len(options.Paths) > 0 {
+		if req.Path != "" {
+			return nil, errors.New("archiveStream path and paths are mutually exclusive")
+		}
+		seen := make(map[string]bool, len(options.Paths))
+		for _, entry := range options.Paths {
+			entry = strings.Trim(strings.TrimSpace(entry), "/")
+			if entry == "" {
+				return nil, errors.New("archiveStream paths must not contain empty entries")
+			}
+			for _, segment := range strings.Split(entry, "/") {
+				if segment == ".." {
+					return nil, errors.New("archiveStream paths must not contain .. segments")
+				}
+			}
+			if !seen[entry] {
+				seen[entry] = true
+				req.Paths = append(req.Paths, entry)
+			}
+		}
+	}
 	if len(options.IncludeGlobs) > 0 {
 		req.IncludeGlobs = options.IncludeGlobs
 	}
@@ -132,13 +416,66 @@ func (r *Repo) ArchiveStream(ctx context.Context, options ArchiveOptions) (*http
 	if prefix := strings.TrimSpace(options.ArchivePrefix); prefix != "" {
 		req.Archive = &archiveOptions{Prefix: prefix}
 	}
+	if options.Format != "" && options.Format != ArchiveFormatTarGz {
+		if req.Archive == nil {
+			req.Archive = &archiveOptions{}
+		}
+		req.Archive.Format = string(options.Format)
+	}
+	if options.IncludeSubmodules {
+		if req.Archive == nil {
+			req.Archive = &archiveOptions{}
+		}
+		req.Archive.IncludeSubmodules = true
+	}
+	if options.MaxVolumeBytes < 0 {
+		return nil, errors.New("archiveStream maxVolumeBytes must not be negative")
+	}
+	if options.Volume < 0 || (options.Volume > 0 && options.MaxVolumeBytes == 0) {
+		return nil, errors.New("archiveStream volume requires maxVolumeBytes and must not be negative")
+	}
+	if options.MaxVolumeBytes > 0 {
+		if req.Archive == nil {
+			req.Archive = &archiveOptions{}
+		}
+		volume := options.Volume
+		req.Archive.MaxVolumeBytes = options.MaxVolumeBytes
+		req.Archive.Volume = &volume
+	}
+	if options.Compression == ArchiveCompressionZstd {
+		if req.Archive == nil {
+			req.Archive = &archiveOptions{}
+		}
+		req.Archive.Compression = string(options.Compression)
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("Accept", "application/zstd, application/gzip;q=0.5")
+	}
 
 	var body interface{}
-	if req.Ref != "" || len(req.IncludeGlobs) > 0 || len(req.ExcludeGlobs) > 0 || req.MaxBlobSize != nil || req.Archive != nil {
+	if req.Ref != "" || req.SHA != "" || req.Path != "" || len(req.Paths) > 0 || len(req.IncludeGlobs) > 0 || len(req.ExcludeGlobs) > 0 || req.MaxBlobSize != nil || req.Archive != nil {
 		body = req
 	}
 
-	resp, err := r.client.api.post(ctx, "repos/archive", nil, body, jwtToken, nil)
+	if etag := strings.TrimSpace(options.IfNoneMatch); etag != "" {
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("If-None-Match", etag)
+	}
+	opts := withRequestHeader(readRequestOptions(options.InvocationOptions), header)
+	if header.Get("If-None-Match") != "" {
+		opts.allowedStatus = map[int]bool{http.StatusNotModified: true}
+	}
+
+	resp, err := r.client.api.post(ctx, "repos/archive", nil, body, jwtToken, opts)
+	if err != nil && options.Compression == ArchiveCompressionZstd && isUnsupportedCompressionError(err) {
+		fallback := options
+		fallback.Compression = ""
+		header.Del("Accept")
+		return r.archiveStream(ctx, fallback, header)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("archive stream request: %w", err)
 	}
@@ -146,10 +483,51 @@ func (r *Repo) ArchiveStream(ctx context.Context, options ArchiveOptions) (*http
 	return resp, nil
 }
 
+// ArchiveStreamCompression reports the compression codec of an
+// ArchiveStream response, or "" for zip archives.
+func ArchiveStreamCompression(resp *http.Response) ArchiveCompression {
+	if resp == nil {
+		return ""
+	}
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	switch {
+	case strings.Contains(contentType, "zstd"):
+		return ArchiveCompressionZstd
+	case strings.Contains(contentType, "zip") && !strings.Contains(contentType, "gzip"):
+		return ""
+	default:
+		return ArchiveCompressionGzip
+	}
+}
+
+// ArchiveStreamCommitSHA reports the commit an archive response was built
+// from, or "" when the server did not say.
+func ArchiveStreamCommitSHA(resp *http.Response) string {
+	if resp == nil {
+		return ""
+	}
+	return strings.TrimSpace(resp.Header.Get(commitSHAHeader))
+}
+
+func isUnsupportedCompressionError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Status {
+	case http.StatusBadRequest, http.StatusNotAcceptable, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
 // ListFiles lists file paths.
 func (r *Repo) ListFiles(ctx context.Context, options ListFilesOptions) (ListFilesResult, error) {
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
-	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if options.ResolveSubmodules && len(options.SubmoduleRepos) == 0 {
+		return ListFilesResult{}, errors.New("listFiles submoduleRepos is required with resolveSubmodules")
+	}
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.submoduleReadJWT(ttl, options.SubmoduleRepos)
 	if err != nil {
 		return ListFilesResult{}, err
 	}
@@ -161,11 +539,14 @@ func (r *Repo) ListFiles(ctx context.Context, options ListFilesOptions) (ListFil
 	if options.Ephemeral != nil {
 		params.Set("ephemeral", strconv.FormatBool(*options.Ephemeral))
 	}
+	if options.ResolveSubmodules {
+		params.Set("resolve_submodules", "true")
+	}
 	if len(params) == 0 {
 		params = nil
 	}
 
-	resp, err := r.client.api.get(ctx, "repos/files", params, jwtToken, nil)
+	resp, err := r.client.api.get(ctx, "repos/files", params, jwtToken, readRequestOptions(options.InvocationOptions))
 	if err != nil {
 		return ListFilesResult{}, err
 	}
@@ -176,13 +557,72 @@ func (r *Repo) ListFiles(ctx context.Context, options ListFilesOptions) (ListFil
 		return ListFilesResult{}, err
 	}
 
-	return ListFilesResult{Paths: payload.Paths, Ref: payload.Ref}, nil
+	return ListFilesResult{
+		Paths:     payload.Paths,
+		Ref:       payload.Ref,
+		TreeSHA:   payload.TreeSHA,
+		CommitSHA: payload.CommitSHA,
+	}, nil
+}
+
+// SearchFiles ranks the file paths at a ref by fuzzy match against a query,
+// for "open file by name" pickers. Matching runs server-side, so callers do
+// not need to fetch the full ListFiles result on every keystroke.
+func (r *Repo) SearchFiles(ctx context.Context, options SearchFilesOptions) (SearchFilesResult, error) {
+	query := strings.TrimSpace(options.Query)
+	if query == "" {
+		return SearchFilesResult{}, errors.New("searchFiles query is required")
+	}
+	if options.Limit < 0 {
+		return SearchFilesResult{}, errors.New("searchFiles limit must be non-negative")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return SearchFilesResult{}, err
+	}
+
+	params := url.Values{}
+	params.Set("query", query)
+	if options.Ref != "" {
+		params.Set("ref", options.Ref)
+	}
+	if options.Ephemeral != nil {
+		params.Set("ephemeral", strconv.FormatBool(*options.Ephemeral))
+	}
+	if prefix := strings.Trim(strings.TrimSpace(options.PathPrefix), "/"); prefix != "" {
+		params.Set("path_prefix", prefix)
+	}
+	if options.Limit > 0 {
+		params.Set("limit", strconv.Itoa(options.Limit))
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/files/search", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return SearchFilesResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload searchFilesResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return SearchFilesResult{}, err
+	}
+
+	result := SearchFilesResult{Ref: payload.Ref, CommitSHA: payload.CommitSHA}
+	for _, match := range payload.Matches {
+		result.Matches = append(result.Matches, FileSearchMatch{Path: match.Path, Score: match.Score, Positions: match.Positions})
+	}
+	return result, nil
 }
 
 // ListFilesWithMetadata lists files with mode/size and last commit metadata.
 func (r *Repo) ListFilesWithMetadata(ctx context.Context, options ListFilesWithMetadataOptions) (ListFilesWithMetadataResult, error) {
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
-	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if options.ResolveSubmodules && len(options.SubmoduleRepos) == 0 {
+		return ListFilesWithMetadataResult{}, errors.New("listFilesWithMetadata submoduleRepos is required with resolveSubmodules")
+	}
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.submoduleReadJWT(ttl, options.SubmoduleRepos)
 	if err != nil {
 		return ListFilesWithMetadataResult{}, err
 	}
@@ -194,11 +634,14 @@ func (r *Repo) ListFilesWithMetadata(ctx context.Context, options ListFilesWithM
 	if options.Ephemeral != nil {
 		params.Set("ephemeral", strconv.FormatBool(*options.Ephemeral))
 	}
+	if options.ResolveSubmodules {
+		params.Set("resolve_submodules", "true")
+	}
 	if len(params) == 0 {
 		params = nil
 	}
 
-	resp, err := r.client.api.get(ctx, "repos/files/metadata", params, jwtToken, nil)
+	resp, err := r.client.api.get(ctx, "repos/files/metadata", params, jwtToken, readRequestOptions(options.InvocationOptions))
 	if err != nil {
 		return ListFilesWithMetadataResult{}, err
 	}
@@ -210,15 +653,22 @@ func (r *Repo) ListFilesWithMetadata(ctx context.Context, options ListFilesWithM
 	}
 
 	result := ListFilesWithMetadataResult{
-		Ref:     payload.Ref,
-		Commits: make(map[string]CommitMetadata, len(payload.Commits)),
+		Ref:       payload.Ref,
+		TreeSHA:   payload.TreeSHA,
+		CommitSHA: payload.CommitSHA,
+		Commits:   make(map[string]CommitMetadata, len(payload.Commits)),
 	}
 	for _, file := range payload.Files {
 		result.Files = append(result.Files, FileWithMetadata{
 			Path:          file.Path,
 			Mode:          file.Mode,
+			Type:          fileEntryTypeFromMode(file.Mode),
 			Size:          file.Size,
 			LastCommitSHA: file.LastCommitSHA,
+			IsBinary:      file.IsBinary,
+			ContentType:   file.ContentType,
+			Attributes:    file.Attributes,
+			SubmoduleRepo: file.SubmoduleRepo,
 		})
 	}
 	for sha, commit := range payload.Commits {
@@ -233,9 +683,184 @@ func (r *Repo) ListFilesWithMetadata(ctx context.Context, options ListFilesWithM
 	return result, nil
 }
 
+// TreeStats returns aggregate file counts and byte sizes per directory.
+func (r *Repo) TreeStats(ctx context.Context, options TreeStatsOptions) (TreeStatsResult, error) {
+	if options.Depth < 0 {
+		return TreeStatsResult{}, errors.New("treeStats depth must be non-negative")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return TreeStatsResult{}, err
+	}
+
+	params := url.Values{}
+	if options.Ref != "" {
+		params.Set("ref", options.Ref)
+	}
+	if path := strings.Trim(strings.TrimSpace(options.Path), "/"); path != "" {
+		params.Set("path", path)
+	}
+	// Always sent: zero means Path alone, not the server default.
+	params.Set("depth", itoa(options.Depth))
+	if options.Ephemeral != nil {
+		params.Set("ephemeral", strconv.FormatBool(*options.Ephemeral))
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/tree/stats", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return TreeStatsResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload treeStatsResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return TreeStatsResult{}, err
+	}
+
+	result := TreeStatsResult{Ref: payload.Ref, CommitSHA: payload.CommitSHA}
+	for _, dir := range payload.Directories {
+		result.Directories = append(result.Directories, DirectoryStats{
+			Path:      dir.Path,
+			FileCount: dir.FileCount,
+			DirCount:  dir.DirCount,
+			Bytes:     dir.Bytes,
+			Depth:     dir.Depth,
+		})
+	}
+	return result, nil
+}
+
+// ListDirectories lists only the directories under Root with their child
+// counts, so tree views can expand folders lazily.
+func (r *Repo) ListDirectories(ctx context.Context, options ListDirectoriesOptions) (ListDirectoriesResult, error) {
+	if options.Depth < 0 {
+		return ListDirectoriesResult{}, errors.New("listDirectories depth must be non-negative")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return ListDirectoriesResult{}, err
+	}
+
+	params := url.Values{}
+	if options.Ref != "" {
+		params.Set("ref", options.Ref)
+	}
+	if root := strings.Trim(strings.TrimSpace(options.Root), "/"); root != "" {
+		params.Set("root", root)
+	}
+	if options.Depth > 0 {
+		params.Set("depth", itoa(options.Depth))
+	}
+	if options.Ephemeral != nil {
+		params.Set("ephemeral", strconv.FormatBool(*options.Ephemeral))
+	}
+	if len(params) == 0 {
+		params = nil
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/directories", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return ListDirectoriesResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload listDirectoriesResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return ListDirectoriesResult{}, err
+	}
+
+	result := ListDirectoriesResult{Ref: payload.Ref, CommitSHA: payload.CommitSHA}
+	for _, dir := range payload.Directories {
+		result.Directories = append(result.Directories, DirectoryEntry{
+			Path:      dir.Path,
+			Name:      dir.Name,
+			Depth:     dir.Depth,
+			FileCount: dir.FileCount,
+			DirCount:  dir.DirCount,
+		})
+	}
+	return result, nil
+}
+
+// Languages returns byte counts per language, classified server-side.
+func (r *Repo) Languages(ctx context.Context, options LanguagesOptions) (LanguagesResult, error) {
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return LanguagesResult{}, err
+	}
+
+	var params url.Values
+	if ref := strings.TrimSpace(options.Ref); ref != "" {
+		params = url.Values{}
+		params.Set("ref", ref)
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/languages", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return LanguagesResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload languagesResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return LanguagesResult{}, err
+	}
+	return transformLanguages(payload), nil
+}
+
+// ListSubmodules lists the gitlinks at a ref with their pinned SHAs, as a
+// manifest to ship alongside archives.
+func (r *Repo) ListSubmodules(ctx context.Context, options ListSubmodulesOptions) (ListSubmodulesResult, error) {
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return ListSubmodulesResult{}, err
+	}
+
+	var params url.Values
+	if ref := strings.TrimSpace(options.Ref); ref != "" {
+		params = url.Values{}
+		params.Set("ref", ref)
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/submodules", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return ListSubmodulesResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload listSubmodulesResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return ListSubmodulesResult{}, err
+	}
+
+	result := ListSubmodulesResult{Ref: payload.Ref, CommitSHA: payload.CommitSHA}
+	for _, raw := range payload.Submodules {
+		result.Submodules = append(result.Submodules, Submodule{
+			Path:     raw.Path,
+			SHA:      raw.SHA,
+			URL:      raw.URL,
+			Resolved: raw.Resolved,
+		})
+	}
+	return result, nil
+}
+
 // ListBranches lists branches.
 func (r *Repo) ListBranches(ctx context.Context, options ListBranchesOptions) (ListBranchesResult, error) {
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	if token := options.Resume; token != nil {
+		if err := checkResumeToken(token, ResumeTokenBranches, r.ID, options.Cursor); err != nil {
+			return ListBranchesResult{}, err
+		}
+		options.Cursor = token.Cursor
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
 	if err != nil {
 		return ListBranchesResult{}, err
@@ -252,7 +877,7 @@ func (r *Repo) ListBranches(ctx context.Context, options ListBranchesOptions) (L
 		params = nil
 	}
 
-	resp, err := r.client.api.get(ctx, "repos/branches", params, jwtToken, nil)
+	resp, err := r.client.api.get(ctx, "repos/branches", params, jwtToken, readRequestOptions(options.InvocationOptions))
 	if err != nil {
 		return ListBranchesResult{}, err
 	}
@@ -275,100 +900,337 @@ func (r *Repo) ListBranches(ctx context.Context, options ListBranchesOptions) (L
 			CreatedAt: branch.CreatedAt,
 		})
 	}
+	if result.HasMore && result.NextCursor != "" {
+		result.ResumeToken = &ResumeToken{Kind: ResumeTokenBranches, RepoID: r.ID, Cursor: result.NextCursor}
+	}
+	return result, nil
+}
+
+// ListTags lists tags.
+func (r *Repo) ListTags(ctx context.Context, options ListTagsOptions) (ListTagsResult, error) {
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return ListTagsResult{}, err
+	}
+
+	params := url.Values{}
+	if options.Cursor != "" {
+		params.Set("cursor", options.Cursor)
+	}
+	if options.Limit > 0 {
+		params.Set("limit", itoa(options.Limit))
+	}
+	if len(params) == 0 {
+		params = nil
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/tags", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return ListTagsResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload listTagsResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return ListTagsResult{}, err
+	}
+
+	result := ListTagsResult{NextCursor: payload.NextCursor, HasMore: payload.HasMore}
+	for _, tag := range payload.Tags {
+		info := TagInfo{
+			Cursor:    tag.Cursor,
+			Name:      tag.Name,
+			TargetSHA: tag.TargetSHA,
+			TagSHA:    tag.TagSHA,
+			Message:   tag.Message,
+			CreatedAt: tag.CreatedAt,
+		}
+		if tag.Tagger != nil {
+			info.Tagger = &CommitSignature{Name: tag.Tagger.Name, Email: tag.Tagger.Email}
+		}
+		result.Tags = append(result.Tags, info)
+	}
 	return result, nil
 }
 
 // ListCommits lists commits.
 func (r *Repo) ListCommits(ctx context.Context, options ListCommitsOptions) (ListCommitsResult, error) {
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	firstSHA := ""
+	if token := options.Resume; token != nil {
+		if err := checkResumeToken(token, ResumeTokenCommits, r.ID, options.Cursor); err != nil {
+			return ListCommitsResult{}, err
+		}
+		if (options.Branch != "" && options.Branch != token.Branch) || (options.Order != "" && options.Order != token.Order) {
+			return ListCommitsResult{}, errors.New("listCommits resume token was created for a different branch or order")
+		}
+		options.Branch, options.Order, options.Cursor = token.Branch, token.Order, token.Cursor
+		if token.FirstSHA != "" {
+			head, err := r.ListCommits(ctx, ListCommitsOptions{InvocationOptions: options.InvocationOptions, Branch: options.Branch, Order: options.Order, Limit: 1})
+			if err != nil {
+				return ListCommitsResult{}, err
+			}
+			if len(head.Commits) == 0 || head.Commits[0].SHA != token.FirstSHA {
+				return ListCommitsResult{}, ErrResumeRefMoved
+			}
+		}
+		firstSHA = token.FirstSHA
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
 	if err != nil {
 		return ListCommitsResult{}, err
 	}
 
-	params := url.Values{}
-	if options.Branch != "" {
-		params.Set("branch", options.Branch)
+	params := url.Values{}
+	if options.Branch != "" {
+		params.Set("branch", options.Branch)
+	}
+	for _, rev := range options.Exclude {
+		if rev = strings.TrimSpace(rev); rev != "" {
+			params.Add("exclude", rev)
+		}
+	}
+	if options.Cursor != "" {
+		params.Set("cursor", options.Cursor)
+	}
+	if options.Limit > 0 {
+		params.Set("limit", itoa(options.Limit))
+	}
+	switch options.Order {
+	case "":
+	case CommitOrderDate, CommitOrderTopo, CommitOrderAuthorDate:
+		params.Set("order", string(options.Order))
+	default:
+		return ListCommitsResult{}, errors.New("listCommits order must be date, topo, or author-date")
+	}
+	if len(params) == 0 {
+		params = nil
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/commits", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return ListCommitsResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload listCommitsResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return ListCommitsResult{}, err
+	}
+
+	result := ListCommitsResult{HasMore: payload.HasMore}
+	if payload.NextCursor != "" {
+		result.NextCursor = payload.NextCursor
+	}
+	for _, commit := range payload.Commits {
+		result.Commits = append(result.Commits, transformCommitInfo(commit))
+	}
+	if options.Resume == nil && options.Cursor == "" && len(result.Commits) > 0 {
+		firstSHA = result.Commits[0].SHA
+	}
+	if result.HasMore && result.NextCursor != "" {
+		result.ResumeToken = &ResumeToken{
+			Kind:     ResumeTokenCommits,
+			RepoID:   r.ID,
+			Cursor:   result.NextCursor,
+			Branch:   options.Branch,
+			Order:    options.Order,
+			FirstSHA: firstSHA,
+		}
+	}
+
+	return result, nil
+}
+
+// SearchHistory finds commits that add or remove occurrences of a pattern,
+// like git log -S, or with Regex set, whose changed lines match it, like
+// git log -G. Results are ordered newest first.
+func (r *Repo) SearchHistory(ctx context.Context, options SearchHistoryOptions) (SearchHistoryResult, error) {
+	if options.Pattern == "" {
+		return SearchHistoryResult{}, errors.New("searchHistory pattern is required")
+	}
+	if options.Limit < 0 {
+		return SearchHistoryResult{}, errors.New("searchHistory limit must be non-negative")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return SearchHistoryResult{}, err
+	}
+
+	body := &searchHistoryRequest{
+		Pattern: options.Pattern,
+		Regex:   options.Regex,
+		Ref:     strings.TrimSpace(options.Ref),
+		Limit:   options.Limit,
+		Cursor:  options.Cursor,
+	}
+	for _, path := range options.Paths {
+		if strings.TrimSpace(path) != "" {
+			body.Paths = append(body.Paths, strings.TrimSpace(path))
+		}
+	}
+
+	resp, err := r.client.api.post(ctx, "repos/history/search", nil, body, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return SearchHistoryResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload searchHistoryResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return SearchHistoryResult{}, err
+	}
+
+	result := SearchHistoryResult{Ref: payload.Ref, NextCursor: payload.NextCursor, HasMore: payload.HasMore}
+	for _, match := range payload.Matches {
+		result.Matches = append(result.Matches, HistoryMatch{Commit: transformCommitInfo(match.Commit), Paths: match.Paths})
+	}
+	return result, nil
+}
+
+// GetNote reads a git note.
+func (r *Repo) GetNote(ctx context.Context, options GetNoteOptions) (GetNoteResult, error) {
+	sha := strings.TrimSpace(options.SHA)
+	if sha == "" {
+		return GetNoteResult{}, errors.New("getNote sha is required")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return GetNoteResult{}, err
+	}
+
+	objectType, err := normalizeNoteObjectType(options.ObjectType, "getNote")
+	if err != nil {
+		return GetNoteResult{}, err
+	}
+
+	params := url.Values{}
+	params.Set("sha", sha)
+	if objectType != "" {
+		params.Set("object_type", objectType)
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/notes", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return GetNoteResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload noteReadResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return GetNoteResult{}, err
+	}
+
+	return GetNoteResult{SHA: payload.SHA, ObjectType: noteObjectType(payload.ObjectType), Note: payload.Note, RefSHA: payload.RefSHA}, nil
+}
+
+// GetNotes reads the notes of many commits in a single request.
+func (r *Repo) GetNotes(ctx context.Context, options GetNotesOptions) (GetNotesResult, error) {
+	base := strings.TrimSpace(options.Base)
+	head := strings.TrimSpace(options.Head)
+	body := &getNotesRequest{Base: base, Head: head}
+	seen := make(map[string]struct{}, len(options.SHAs))
+	for _, sha := range options.SHAs {
+		sha = strings.TrimSpace(sha)
+		if sha == "" {
+			return GetNotesResult{}, errors.New("getNotes shas must not contain empty values")
+		}
+		if _, ok := seen[sha]; ok {
+			continue
+		}
+		seen[sha] = struct{}{}
+		body.SHAs = append(body.SHAs, sha)
 	}
-	if options.Cursor != "" {
-		params.Set("cursor", options.Cursor)
+	if len(body.SHAs) > 0 && (base != "" || head != "") {
+		return GetNotesResult{}, errors.New("getNotes shas and base/head are mutually exclusive")
 	}
-	if options.Limit > 0 {
-		params.Set("limit", itoa(options.Limit))
+	if len(body.SHAs) == 0 && head == "" {
+		if base != "" {
+			return GetNotesResult{}, errors.New("getNotes head is required when base is set")
+		}
+		return GetNotesResult{}, errors.New("getNotes shas or head is required")
 	}
-	if len(params) == 0 {
-		params = nil
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return GetNotesResult{}, err
 	}
 
-	resp, err := r.client.api.get(ctx, "repos/commits", params, jwtToken, nil)
+	resp, err := r.client.api.post(ctx, "repos/notes/batch", nil, body, jwtToken, readRequestOptions(options.InvocationOptions))
 	if err != nil {
-		return ListCommitsResult{}, err
+		return GetNotesResult{}, err
 	}
 	defer resp.Body.Close()
 
-	var payload listCommitsResponse
+	var payload notesReadResponse
 	if err := decodeJSON(resp, &payload); err != nil {
-		return ListCommitsResult{}, err
+		return GetNotesResult{}, err
 	}
 
-	result := ListCommitsResult{HasMore: payload.HasMore}
-	if payload.NextCursor != "" {
-		result.NextCursor = payload.NextCursor
+	result := GetNotesResult{Notes: make(map[string]string, len(payload.Notes)), RefSHA: payload.RefSHA}
+	for _, entry := range payload.Notes {
+		result.Notes[entry.SHA] = entry.Note
 	}
-	for _, commit := range payload.Commits {
-		result.Commits = append(result.Commits, CommitInfo{
-			SHA:            commit.SHA,
-			Message:        commit.Message,
-			AuthorName:     commit.AuthorName,
-			AuthorEmail:    commit.AuthorEmail,
-			CommitterName:  commit.CommitterName,
-			CommitterEmail: commit.CommitterEmail,
-			Date:           parseTime(commit.Date),
-			RawDate:        commit.Date,
-		})
-	}
-
 	return result, nil
 }
 
-// GetNote reads a git note.
-func (r *Repo) GetNote(ctx context.Context, options GetNoteOptions) (GetNoteResult, error) {
-	sha := strings.TrimSpace(options.SHA)
-	if sha == "" {
-		return GetNoteResult{}, errors.New("getNote sha is required")
+// ListNotes enumerates every note under refs/notes/commits, for callers
+// that do not know the annotated commit SHAs up front.
+func (r *Repo) ListNotes(ctx context.Context, options ListNotesOptions) (ListNotesResult, error) {
+	if options.Limit < 0 {
+		return ListNotesResult{}, errors.New("listNotes limit must be non-negative")
 	}
 
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
 	if err != nil {
-		return GetNoteResult{}, err
+		return ListNotesResult{}, err
 	}
 
 	params := url.Values{}
-	params.Set("sha", sha)
+	if options.Cursor != "" {
+		params.Set("cursor", options.Cursor)
+	}
+	if options.Limit > 0 {
+		params.Set("limit", itoa(options.Limit))
+	}
+	if len(params) == 0 {
+		params = nil
+	}
 
-	resp, err := r.client.api.get(ctx, "repos/notes", params, jwtToken, nil)
+	resp, err := r.client.api.get(ctx, "repos/notes/list", params, jwtToken, readRequestOptions(options.InvocationOptions))
 	if err != nil {
-		return GetNoteResult{}, err
+		return ListNotesResult{}, err
 	}
 	defer resp.Body.Close()
 
-	var payload noteReadResponse
+	var payload listNotesResponse
 	if err := decodeJSON(resp, &payload); err != nil {
-		return GetNoteResult{}, err
+		return ListNotesResult{}, err
 	}
 
-	return GetNoteResult{SHA: payload.SHA, Note: payload.Note, RefSHA: payload.RefSHA}, nil
+	result := ListNotesResult{RefSHA: payload.RefSHA, NextCursor: payload.NextCursor, HasMore: payload.HasMore}
+	for _, entry := range payload.Notes {
+		result.Notes = append(result.Notes, NoteEntry{SHA: entry.SHA, ObjectType: noteObjectType(entry.ObjectType), Note: entry.Note})
+	}
+	return result, nil
 }
 
 // CreateNote adds a git note.
 func (r *Repo) CreateNote(ctx context.Context, options CreateNoteOptions) (NoteWriteResult, error) {
-	return r.writeNote(ctx, options.InvocationOptions, "add", options.SHA, options.Note, options.ExpectedRefSHA, options.Author)
+	return r.writeNote(ctx, options.InvocationOptions, "add", options.SHA, options.ObjectType, options.Note, options.ExpectedRefSHA, options.Author)
 }
 
 // AppendNote appends to a git note.
 func (r *Repo) AppendNote(ctx context.Context, options AppendNoteOptions) (NoteWriteResult, error) {
-	return r.writeNote(ctx, options.InvocationOptions, "append", options.SHA, options.Note, options.ExpectedRefSHA, options.Author)
+	return r.writeNote(ctx, options.InvocationOptions, "append", options.SHA, options.ObjectType, options.Note, options.ExpectedRefSHA, options.Author)
 }
 
 // DeleteNote deletes a git note.
@@ -377,14 +1239,18 @@ func (r *Repo) DeleteNote(ctx context.Context, options DeleteNoteOptions) (NoteW
 	if sha == "" {
 		return NoteWriteResult{}, errors.New("deleteNote sha is required")
 	}
+	objectType, err := normalizeNoteObjectType(options.ObjectType, "deleteNote")
+	if err != nil {
+		return NoteWriteResult{}, err
+	}
 
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitWrite}, TTL: ttl})
 	if err != nil {
 		return NoteWriteResult{}, err
 	}
 
-	body := &noteWriteRequest{SHA: sha}
+	body := &noteWriteRequest{SHA: sha, ObjectType: objectType}
 	if strings.TrimSpace(options.ExpectedRefSHA) != "" {
 		body.ExpectedRefSHA = options.ExpectedRefSHA
 	}
@@ -419,27 +1285,32 @@ func (r *Repo) DeleteNote(ctx context.Context, options DeleteNoteOptions) (NoteW
 	return result, nil
 }
 
-func (r *Repo) writeNote(ctx context.Context, invocation InvocationOptions, action string, sha string, note string, expectedRefSHA string, author *NoteAuthor) (NoteWriteResult, error) {
+func (r *Repo) writeNote(ctx context.Context, invocation InvocationOptions, action string, sha string, objectType NoteObjectType, note string, expectedRefSHA string, author *NoteAuthor) (NoteWriteResult, error) {
 	sha = strings.TrimSpace(sha)
 	if sha == "" {
 		return NoteWriteResult{}, errors.New("note sha is required")
 	}
+	normalizedType, err := normalizeNoteObjectType(objectType, "note")
+	if err != nil {
+		return NoteWriteResult{}, err
+	}
 
 	note = strings.TrimSpace(note)
 	if note == "" {
 		return NoteWriteResult{}, errors.New("note content is required")
 	}
 
-	ttl := resolveInvocationTTL(invocation, defaultTokenTTL)
+	ttl := resolveInvocationTTL(ctx, invocation, defaultTokenTTL)
 	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitWrite}, TTL: ttl})
 	if err != nil {
 		return NoteWriteResult{}, err
 	}
 
 	body := &noteWriteRequest{
-		SHA:    sha,
-		Action: action,
-		Note:   note,
+		SHA:        sha,
+		ObjectType: normalizedType,
+		Action:     action,
+		Note:       note,
 	}
 	if strings.TrimSpace(expectedRefSHA) != "" {
 		body.ExpectedRefSHA = expectedRefSHA
@@ -485,7 +1356,7 @@ func (r *Repo) GetBranchDiff(ctx context.Context, options GetBranchDiffOptions)
 		return GetBranchDiffResult{}, errors.New("getBranchDiff branch is required")
 	}
 
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
 	if err != nil {
 		return GetBranchDiffResult{}, err
@@ -507,8 +1378,26 @@ func (r *Repo) GetBranchDiff(ctx context.Context, options GetBranchDiffOptions)
 			params.Add("path", path)
 		}
 	}
+	if options.Cursor != "" {
+		params.Set("cursor", options.Cursor)
+	}
+	if options.Limit > 0 {
+		params.Set("limit", itoa(options.Limit))
+	}
+	if err := setContextLinesParam(params, options.ContextLines, "getBranchDiff"); err != nil {
+		return GetBranchDiffResult{}, err
+	}
+	if err := setDiffStatesParam(params, options.States, "getBranchDiff"); err != nil {
+		return GetBranchDiffResult{}, err
+	}
+	if err := setDiffComparisonParam(params, options.Comparison, "getBranchDiff"); err != nil {
+		return GetBranchDiffResult{}, err
+	}
+	if err := setRenameDetectionParams(params, options.RenameDetectionOptions, "getBranchDiff"); err != nil {
+		return GetBranchDiffResult{}, err
+	}
 
-	resp, err := r.client.api.get(ctx, "repos/branches/diff", params, jwtToken, nil)
+	resp, err := r.client.api.get(ctx, "repos/branches/diff", params, jwtToken, readRequestOptions(options.InvocationOptions))
 	if err != nil {
 		return GetBranchDiffResult{}, err
 	}
@@ -522,13 +1411,84 @@ func (r *Repo) GetBranchDiff(ctx context.Context, options GetBranchDiffOptions)
 	return transformBranchDiff(payload), nil
 }
 
+// CompareDiff returns the diff between any two refs or SHAs, including
+// ephemeral refs. The result's Branch field holds the resolved head.
+func (r *Repo) CompareDiff(ctx context.Context, options CompareDiffOptions) (GetBranchDiffResult, error) {
+	base := strings.TrimSpace(options.Base)
+	head := strings.TrimSpace(options.Head)
+	if base == "" || head == "" {
+		return GetBranchDiffResult{}, errors.New("compareDiff base and head are required")
+	}
+
+	params := url.Values{}
+	params.Set("base", base)
+	params.Set("head", head)
+	if options.EphemeralBase {
+		params.Set("ephemeral_base", "true")
+	}
+	if options.EphemeralHead {
+		params.Set("ephemeral_head", "true")
+	}
+	for _, path := range options.Paths {
+		if strings.TrimSpace(path) != "" {
+			params.Add("path", path)
+		}
+	}
+	if options.Cursor != "" {
+		params.Set("cursor", options.Cursor)
+	}
+	if options.Limit > 0 {
+		params.Set("limit", itoa(options.Limit))
+	}
+	if err := setContextLinesParam(params, options.ContextLines, "compareDiff"); err != nil {
+		return GetBranchDiffResult{}, err
+	}
+	if err := setDiffStatesParam(params, options.States, "compareDiff"); err != nil {
+		return GetBranchDiffResult{}, err
+	}
+	if err := setDiffComparisonParam(params, options.Comparison, "compareDiff"); err != nil {
+		return GetBranchDiffResult{}, err
+	}
+	if err := setRenameDetectionParams(params, options.RenameDetectionOptions, "compareDiff"); err != nil {
+		return GetBranchDiffResult{}, err
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return GetBranchDiffResult{}, err
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/compare/diff", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return GetBranchDiffResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload compareDiffResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return GetBranchDiffResult{}, err
+	}
+
+	if payload.Branch == "" {
+		payload.Branch = payload.Head
+	}
+	if payload.Branch == "" {
+		payload.Branch = head
+	}
+	if payload.Base == "" {
+		payload.Base = base
+	}
+	return transformBranchDiff(payload.branchDiffResponse), nil
+}
+
 // GetCommitDiff returns a diff for a commit.
 func (r *Repo) GetCommitDiff(ctx context.Context, options GetCommitDiffOptions) (GetCommitDiffResult, error) {
 	if strings.TrimSpace(options.SHA) == "" {
 		return GetCommitDiffResult{}, errors.New("getCommitDiff sha is required")
 	}
 
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
 	if err != nil {
 		return GetCommitDiffResult{}, err
@@ -544,8 +1504,23 @@ func (r *Repo) GetCommitDiff(ctx context.Context, options GetCommitDiffOptions)
 			params.Add("path", path)
 		}
 	}
+	if options.Cursor != "" {
+		params.Set("cursor", options.Cursor)
+	}
+	if options.Limit > 0 {
+		params.Set("limit", itoa(options.Limit))
+	}
+	if err := setContextLinesParam(params, options.ContextLines, "getCommitDiff"); err != nil {
+		return GetCommitDiffResult{}, err
+	}
+	if err := setDiffStatesParam(params, options.States, "getCommitDiff"); err != nil {
+		return GetCommitDiffResult{}, err
+	}
+	if err := setRenameDetectionParams(params, options.RenameDetectionOptions, "getCommitDiff"); err != nil {
+		return GetCommitDiffResult{}, err
+	}
 
-	resp, err := r.client.api.get(ctx, "repos/diff", params, jwtToken, nil)
+	resp, err := r.client.api.get(ctx, "repos/diff", params, jwtToken, readRequestOptions(options.InvocationOptions))
 	if err != nil {
 		return GetCommitDiffResult{}, err
 	}
@@ -565,8 +1540,13 @@ func (r *Repo) Grep(ctx context.Context, options GrepOptions) (GrepResult, error
 	if pattern == "" {
 		return GrepResult{}, errors.New("grep query.pattern is required")
 	}
+	switch options.Binary {
+	case "", GrepBinarySkip, GrepBinaryMatch, GrepBinaryText:
+	default:
+		return GrepResult{}, errors.New("grep binary must be skip, match, or text")
+	}
 
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
 	if err != nil {
 		return GrepResult{}, err
@@ -576,7 +1556,9 @@ func (r *Repo) Grep(ctx context.Context, options GrepOptions) (GrepResult, error
 		Query: grepQueryPayload{
 			Pattern:       pattern,
 			CaseSensitive: options.Query.CaseSensitive,
+			InvertMatch:   options.Query.InvertMatch,
 		},
+		FilesWithoutMatch: options.FilesWithoutMatch,
 	}
 	ref := strings.TrimSpace(options.Ref)
 	if ref == "" {
@@ -585,14 +1567,37 @@ func (r *Repo) Grep(ctx context.Context, options GrepOptions) (GrepResult, error
 	if ref != "" {
 		body.Ref = ref
 	}
+	if len(options.Refs) > 0 {
+		if body.Ref != "" {
+			return GrepResult{}, errors.New("grep ref and refs are mutually exclusive")
+		}
+		seen := make(map[grepRefPayload]bool, len(options.Refs))
+		for _, entry := range options.Refs {
+			target := grepRefPayload{Ref: strings.TrimSpace(entry.Ref), Ephemeral: entry.Ephemeral}
+			if target.Ref == "" {
+				return GrepResult{}, errors.New("grep refs must not contain empty entries")
+			}
+			if !seen[target] {
+				seen[target] = true
+				body.Refs = append(body.Refs, target)
+			}
+		}
+	}
 	if len(options.Paths) > 0 {
 		body.Paths = options.Paths
 	}
+	if options.Binary != "" {
+		body.Binary = string(options.Binary)
+	}
 	if options.FileFilters != nil {
 		filters := &grepFileFilterPayload{}
 		hasFilters := false
-		if len(options.FileFilters.IncludeGlobs) > 0 {
-			filters.IncludeGlobs = options.FileFilters.IncludeGlobs
+		includeGlobs, err := expandGrepTypes(options.FileFilters.IncludeGlobs, options.FileFilters.Types)
+		if err != nil {
+			return GrepResult{}, err
+		}
+		if len(includeGlobs) > 0 {
+			filters.IncludeGlobs = includeGlobs
 			hasFilters = true
 		}
 		if len(options.FileFilters.ExcludeGlobs) > 0 {
@@ -603,6 +1608,10 @@ func (r *Repo) Grep(ctx context.Context, options GrepOptions) (GrepResult, error
 			filters.ExtensionFilters = options.FileFilters.ExtensionFilters
 			hasFilters = true
 		}
+		if options.FileFilters.MaxFileBytes != nil {
+			filters.MaxFileBytes = options.FileFilters.MaxFileBytes
+			hasFilters = true
+		}
 		if hasFilters {
 			body.FileFilters = filters
 		}
@@ -653,7 +1662,7 @@ func (r *Repo) Grep(ctx context.Context, options GrepOptions) (GrepResult, error
 		}
 	}
 
-	resp, err := r.client.api.post(ctx, "repos/grep", nil, body, jwtToken, nil)
+	resp, err := r.client.api.post(ctx, "repos/grep", nil, body, jwtToken, readRequestOptions(options.InvocationOptions))
 	if err != nil {
 		return GrepResult{}, err
 	}
@@ -665,15 +1674,18 @@ func (r *Repo) Grep(ctx context.Context, options GrepOptions) (GrepResult, error
 	}
 
 	result := GrepResult{
-		Query:   GrepQuery{Pattern: payload.Query.Pattern, CaseSensitive: &payload.Query.CaseSensitive},
+		Query:   GrepQuery{Pattern: payload.Query.Pattern, CaseSensitive: &payload.Query.CaseSensitive, InvertMatch: payload.Query.InvertMatch},
 		Repo:    GrepRepo{Ref: payload.Repo.Ref, Commit: payload.Repo.Commit},
 		HasMore: payload.HasMore,
 	}
 	if payload.NextCursor != "" {
 		result.NextCursor = payload.NextCursor
 	}
+	for _, searched := range payload.Refs {
+		result.Refs = append(result.Refs, GrepRepo{Ref: searched.Ref, Commit: searched.Commit})
+	}
 	for _, match := range payload.Matches {
-		entry := GrepFileMatch{Path: match.Path}
+		entry := GrepFileMatch{Path: match.Path, IsBinary: match.IsBinary, Ref: match.Ref, Commit: match.Commit}
 		for _, line := range match.Lines {
 			entry.Lines = append(entry.Lines, GrepLine{LineNumber: line.LineNumber, Text: line.Text, Type: line.Type})
 		}
@@ -685,7 +1697,7 @@ func (r *Repo) Grep(ctx context.Context, options GrepOptions) (GrepResult, error
 
 // PullUpstream triggers a pull-upstream operation.
 func (r *Repo) PullUpstream(ctx context.Context, options PullUpstreamOptions) error {
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitWrite}, TTL: ttl})
 	if err != nil {
 		return err
@@ -718,8 +1730,13 @@ func (r *Repo) CreateBranch(ctx context.Context, options CreateBranchOptions) (C
 	if targetBranch == "" {
 		return CreateBranchResult{}, errors.New("createBranch targetBranch is required")
 	}
+	switch options.IfExists {
+	case "", BranchIfExistsError, BranchIfExistsReturnExisting, BranchIfExistsRequireSameHead:
+	default:
+		return CreateBranchResult{}, errors.New("createBranch ifExists must be error, return_existing, or require_same_head")
+	}
 
-	ttl := resolveInvocationTTL(options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitWrite}, TTL: ttl})
 	if err != nil {
 		return CreateBranchResult{}, err
@@ -731,6 +1748,9 @@ func (r *Repo) CreateBranch(ctx context.Context, options CreateBranchOptions) (C
 		BaseIsEphemeral:   options.BaseIsEphemeral,
 		TargetIsEphemeral: options.TargetIsEphemeral,
 	}
+	if options.IfExists != BranchIfExistsError {
+		body.IfExists = string(options.IfExists)
+	}
 
 	resp, err := r.client.api.post(ctx, "repos/branches/create", nil, body, jwtToken, nil)
 	if err != nil {
@@ -748,10 +1768,308 @@ func (r *Repo) CreateBranch(ctx context.Context, options CreateBranchOptions) (C
 		TargetBranch:      payload.TargetBranch,
 		TargetIsEphemeral: payload.TargetIsEphemeral,
 		CommitSHA:         payload.CommitSHA,
+		AlreadyExisted:    payload.AlreadyExisted,
+	}
+	return result, nil
+}
+
+// GetTag returns a tag with its annotation, the commit it resolves to, and
+// its raw signature when signed.
+func (r *Repo) GetTag(ctx context.Context, options GetTagOptions) (TagDetails, error) {
+	name := strings.TrimPrefix(strings.TrimSpace(options.Name), "refs/tags/")
+	if name == "" {
+		return TagDetails{}, errors.New("getTag name is required")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return TagDetails{}, err
+	}
+
+	params := url.Values{}
+	params.Set("name", name)
+	if options.Verify {
+		params.Set("verify", "true")
+	}
+
+	resp, err := r.client.api.get(ctx, "repos/tag", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return TagDetails{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload tagDetailsResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return TagDetails{}, err
+	}
+
+	details := TagDetails{
+		Name:          payload.Name,
+		TargetSHA:     payload.TargetSHA,
+		TargetType:    payload.TargetType,
+		CommitSHA:     payload.CommitSHA,
+		TagSHA:        payload.TagSHA,
+		Message:       payload.Message,
+		TaggedAt:      parseTime(payload.TaggedAt),
+		RawTaggedAt:   payload.TaggedAt,
+		Signature:     payload.Signature,
+		SignedPayload: payload.SignedPayload,
+	}
+	if payload.Tagger != nil {
+		details.Tagger = &CommitSignature{Name: payload.Tagger.Name, Email: payload.Tagger.Email}
+	}
+	if v := payload.Verification; v != nil {
+		details.Verification = &TagVerification{Verified: v.Verified, Reason: v.Reason, KeyID: v.KeyID, Signer: v.Signer}
+	}
+	return details, nil
+}
+
+// VerifySignature checks the tag signature with a caller-supplied verifier,
+// such as an OpenPGP or SSH signature check against trusted keys. It
+// returns ErrTagUnsigned when the tag has no signature, and
+// ErrTagPayloadMismatch without calling verify when SignedPayload is not
+// the payload of this tag: its object and tag lines must name TargetSHA
+// and Name, and the payload plus Signature must hash to TagSHA.
+func (t TagDetails) VerifySignature(verify func(payload []byte, signature []byte) error) error {
+	if verify == nil {
+		return errors.New("verifySignature verify is required")
+	}
+	if strings.TrimSpace(t.Signature) == "" {
+		return ErrTagUnsigned
+	}
+	if !t.signedPayloadMatches() {
+		return ErrTagPayloadMismatch
+	}
+	return verify([]byte(t.SignedPayload), []byte(t.Signature))
+}
+
+// signedPayloadMatches rebuilds the tag object git stores for a signed tag,
+// the signed payload with the signature appended, and checks it against
+// the tag's own fields.
+func (t TagDetails) signedPayloadMatches() bool {
+	header, _, _ := strings.Cut(t.SignedPayload, "\n\n")
+	var object, name string
+	for _, line := range strings.Split(header, "\n") {
+		if value, ok := strings.CutPrefix(line, "object "); ok {
+			object = value
+		} else if value, ok := strings.CutPrefix(line, "tag "); ok {
+			name = value
+		}
+	}
+	if object == "" || !strings.EqualFold(object, t.TargetSHA) || name != t.Name {
+		return false
+	}
+	content := t.SignedPayload + t.Signature
+	raw := []byte("tag " + strconv.Itoa(len(content)) + "\x00" + content)
+	var id string
+	switch len(t.TagSHA) {
+	case 2 * sha1.Size:
+		sum := sha1.Sum(raw)
+		id = hex.EncodeToString(sum[:])
+	case 2 * sha256.Size:
+		sum := sha256.Sum256(raw)
+		id = hex.EncodeToString(sum[:])
+	default:
+		return false
+	}
+	return strings.EqualFold(id, t.TagSHA)
+}
+
+// CreateTag creates a lightweight or annotated tag. Creation is atomic: if
+// the name is already taken, nothing is written and a *TagExistsError is
+// returned.
+func (r *Repo) CreateTag(ctx context.Context, options CreateTagOptions) (CreateTagResult, error) {
+	name := strings.TrimPrefix(strings.TrimSpace(options.Name), "refs/tags/")
+	if name == "" {
+		return CreateTagResult{}, errors.New("createTag name is required")
+	}
+	if strings.HasPrefix(name, "refs/") {
+		return CreateTagResult{}, errors.New("createTag name must be a tag name or refs/tags/ ref")
+	}
+	sha := strings.TrimSpace(options.SHA)
+	if sha == "" {
+		return CreateTagResult{}, errors.New("createTag sha is required")
+	}
+	message := strings.TrimSpace(options.Message)
+	if options.Annotated {
+		if message == "" {
+			return CreateTagResult{}, errors.New("createTag message is required for annotated tags")
+		}
+		if options.Tagger == nil || strings.TrimSpace(options.Tagger.Name) == "" || strings.TrimSpace(options.Tagger.Email) == "" {
+			return CreateTagResult{}, errors.New("createTag tagger name and email are required for annotated tags")
+		}
+	} else if message != "" || options.Tagger != nil {
+		return CreateTagResult{}, errors.New("createTag message and tagger require annotated")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitWrite}, TTL: ttl})
+	if err != nil {
+		return CreateTagResult{}, err
+	}
+
+	body := &createTagRequest{Name: name, SHA: sha, Annotated: options.Annotated, Message: message}
+	if options.Tagger != nil {
+		body.Tagger = &authorInfo{Name: strings.TrimSpace(options.Tagger.Name), Email: strings.TrimSpace(options.Tagger.Email)}
+	}
+
+	resp, err := r.client.api.post(ctx, "repos/tags/create", nil, body, jwtToken, &requestOptions{allowedStatus: map[int]bool{409: true}})
+	if err != nil {
+		return CreateTagResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload createTagResponse
+	if resp.StatusCode == 409 {
+		_ = decodeJSON(resp, &payload)
+		return CreateTagResult{}, &TagExistsError{Name: name, TargetSHA: payload.TargetSHA}
+	}
+	if err := decodeJSON(resp, &payload); err != nil {
+		return CreateTagResult{}, err
+	}
+
+	return CreateTagResult{
+		Name:      payload.Name,
+		TargetSHA: payload.TargetSHA,
+		TagSHA:    payload.TagSHA,
+		Annotated: payload.Annotated,
+	}, nil
+}
+
+// DeleteTag deletes a tag, so release automation can remove or move tags
+// without a local clone. A missing tag or an ExpectedSHA mismatch is
+// returned as a *RefUpdateError.
+func (r *Repo) DeleteTag(ctx context.Context, options DeleteTagOptions) (DeleteTagResult, error) {
+	name := strings.TrimPrefix(strings.TrimSpace(options.Name), "refs/tags/")
+	if name == "" {
+		return DeleteTagResult{}, errors.New("deleteTag name is required")
+	}
+	if strings.HasPrefix(name, "refs/") {
+		return DeleteTagResult{}, errors.New("deleteTag name must be a tag name or refs/tags/ ref")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitWrite}, TTL: ttl})
+	if err != nil {
+		return DeleteTagResult{}, err
+	}
+
+	body := &deleteTagRequest{Name: name, ExpectedSHA: strings.TrimSpace(options.ExpectedSHA)}
+	resp, err := r.client.api.delete(ctx, "repos/tags", nil, body, jwtToken, &requestOptions{allowedStatus: map[int]bool{404: true, 409: true, 412: true}})
+	if err != nil {
+		return DeleteTagResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload deleteTagResponse
+	decodeErr := decodeJSON(resp, &payload)
+	refUpdate := partialRefUpdate(payload.Result.Ref, payload.Result.OldSHA, payload.Result.NewSHA)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 && decodeErr != nil {
+		return DeleteTagResult{}, decodeErr
+	}
+	if !payload.Result.Success {
+		status := payload.Result.Status
+		if status == "" {
+			status = "precondition_failed"
+			if resp.StatusCode == 404 {
+				status = "not_found"
+			}
+		}
+		message := payload.Result.Message
+		if strings.TrimSpace(message) == "" {
+			message = "deleteTag failed with status " + status
+		}
+		return DeleteTagResult{}, newRefUpdateError(message, status, refUpdate)
+	}
+
+	result := DeleteTagResult{Name: name}
+	if refUpdate != nil {
+		result.RefUpdate = *refUpdate
 	}
 	return result, nil
 }
 
+// GetBranchMetadata reads the key/value metadata of a branch. A branch
+// without metadata returns empty Values.
+func (r *Repo) GetBranchMetadata(ctx context.Context, options GetBranchMetadataOptions) (BranchMetadata, error) {
+	branch := strings.TrimSpace(options.Branch)
+	if branch == "" {
+		return BranchMetadata{}, errors.New("getBranchMetadata branch is required")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitRead}, TTL: ttl})
+	if err != nil {
+		return BranchMetadata{}, err
+	}
+
+	params := url.Values{}
+	params.Set("branch", branch)
+
+	resp, err := r.client.api.get(ctx, "repos/branches/metadata", params, jwtToken, readRequestOptions(options.InvocationOptions))
+	if err != nil {
+		return BranchMetadata{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload branchMetadataResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return BranchMetadata{}, err
+	}
+	return transformBranchMetadata(payload), nil
+}
+
+// SetBranchMetadata updates the key/value metadata of a branch and returns
+// the metadata after the write.
+func (r *Repo) SetBranchMetadata(ctx context.Context, options SetBranchMetadataOptions) (BranchMetadata, error) {
+	branch := strings.TrimSpace(options.Branch)
+	if branch == "" {
+		return BranchMetadata{}, errors.New("setBranchMetadata branch is required")
+	}
+	if len(options.Values) == 0 && len(options.Delete) == 0 {
+		return BranchMetadata{}, errors.New("setBranchMetadata values or delete is required")
+	}
+	for key := range options.Values {
+		if strings.TrimSpace(key) == "" {
+			return BranchMetadata{}, errors.New("setBranchMetadata keys must not be empty")
+		}
+	}
+	for _, key := range options.Delete {
+		if strings.TrimSpace(key) == "" {
+			return BranchMetadata{}, errors.New("setBranchMetadata keys must not be empty")
+		}
+		if _, ok := options.Values[key]; ok {
+			return BranchMetadata{}, errors.New("setBranchMetadata key " + strconv.Quote(key) + " is both set and deleted")
+		}
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitWrite}, TTL: ttl})
+	if err != nil {
+		return BranchMetadata{}, err
+	}
+
+	body := &setBranchMetadataRequest{
+		Branch:          branch,
+		Set:             options.Values,
+		Delete:          options.Delete,
+		ExpectedVersion: strings.TrimSpace(options.ExpectedVersion),
+	}
+
+	resp, err := r.client.api.put(ctx, "repos/branches/metadata", nil, body, jwtToken, nil)
+	if err != nil {
+		return BranchMetadata{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload branchMetadataResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return BranchMetadata{}, err
+	}
+	return transformBranchMetadata(payload), nil
+}
+
 // RestoreCommit restores a commit into a branch.
 func (r *Repo) RestoreCommit(ctx context.Context, options RestoreCommitOptions) (RestoreCommitResult, error) {
 	targetBranch := strings.TrimSpace(options.TargetBranch)
@@ -771,7 +2089,7 @@ func (r *Repo) RestoreCommit(ctx context.Context, options RestoreCommitOptions)
 		return RestoreCommitResult{}, errors.New("restoreCommit author name and email are required")
 	}
 
-	ttl := resolveCommitTTL(options.InvocationOptions, defaultTokenTTL)
+	ttl := resolveCommitTTL(ctx, options.InvocationOptions, defaultTokenTTL)
 	jwtToken, err := r.client.generateJWT(r.ID, RemoteURLOptions{Permissions: []Permission{PermissionGitWrite}, TTL: ttl})
 	if err != nil {
 		return RestoreCommitResult{}, err
@@ -1,11 +1,15 @@
 package storage
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -57,7 +61,7 @@ func TestListFilesEphemeral(t *testing.T) {
 			t.Fatalf("unexpected query: %s", r.URL.RawQuery)
 		}
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{"paths":["docs/readme.md"],"ref":"refs/namespaces/ephemeral/refs/heads/feature/demo"}`))
+		_, _ = w.Write([]byte(`{"paths":["docs/readme.md"],"ref":"refs/namespaces/ephemeral/refs/heads/feature/demo","tree_sha":"tree123","commit_sha":"commit123"}`))
 	}))
 	defer server.Close()
 
@@ -75,6 +79,9 @@ func TestListFilesEphemeral(t *testing.T) {
 	if result.Ref == "" || len(result.Paths) != 1 {
 		t.Fatalf("unexpected result")
 	}
+	if result.TreeSHA != "tree123" || result.CommitSHA != "commit123" {
+		t.Fatalf("unexpected snapshot shas: %+v", result)
+	}
 }
 
 func TestListFilesWithMetadataEphemeral(t *testing.T) {
@@ -87,7 +94,7 @@ func TestListFilesWithMetadataEphemeral(t *testing.T) {
 			t.Fatalf("unexpected query: %s", r.URL.RawQuery)
 		}
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{"files":[{"path":"docs/readme.md","mode":"100644","size":12,"last_commit_sha":"deadbeef"}],"commits":{"deadbeef":{"author":"Test User","date":"2026-02-19T12:00:00Z","message":"initial commit"}},"ref":"refs/namespaces/ephemeral/refs/heads/feature/demo"}`))
+		_, _ = w.Write([]byte(`{"files":[{"path":"docs/readme.md","mode":"100644","size":12,"last_commit_sha":"deadbeef"}],"commits":{"deadbeef":{"author":"Test User","date":"2026-02-19T12:00:00Z","message":"initial commit"}},"ref":"refs/namespaces/ephemeral/refs/heads/feature/demo","tree_sha":"tree123","commit_sha":"deadbeef"}`))
 	}))
 	defer server.Close()
 
@@ -105,6 +112,12 @@ func TestListFilesWithMetadataEphemeral(t *testing.T) {
 	if result.Ref == "" || len(result.Files) != 1 {
 		t.Fatalf("unexpected result")
 	}
+	if result.TreeSHA != "tree123" || result.CommitSHA != "deadbeef" {
+		t.Fatalf("unexpected snapshot shas: %s %s", result.TreeSHA, result.CommitSHA)
+	}
+	if result.Files[0].Type != FileEntryTypeFile {
+		t.Fatalf("unexpected entry type: %s", result.Files[0].Type)
+	}
 	if result.Files[0].LastCommitSHA != "deadbeef" {
 		t.Fatalf("unexpected last commit sha: %s", result.Files[0].LastCommitSHA)
 	}
@@ -323,6 +336,206 @@ func TestCommitDiffQuery(t *testing.T) {
 	}
 }
 
+func TestCompareDiff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/compare/diff" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("base") != "v1.0.0" || q.Get("head") != "preview/123" || q.Get("ephemeral_head") != "true" || q.Get("ephemeral_base") != "" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		if q["path"][0] != "docs" {
+			t.Errorf("unexpected paths: %v", q["path"])
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"base":"v1.0.0","head":"preview/123","stats":{"files":1,"additions":1,"deletions":0,"changes":1},"files":[{"path":"docs/a.md","state":"A","raw":"@@ -0,0 +1 @@\n+a\n"}],"filtered_files":[]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.CompareDiff(nil, CompareDiffOptions{Base: "v1.0.0", Head: "preview/123", EphemeralHead: true, Paths: []string{"docs"}})
+	if err != nil {
+		t.Fatalf("compare diff error: %v", err)
+	}
+	if result.Branch != "preview/123" || result.Base != "v1.0.0" || len(result.Files) != 1 || result.Files[0].State != DiffStateAdded {
+		t.Fatalf("unexpected compare result: %+v", result)
+	}
+
+	if _, err := repo.CompareDiff(nil, CompareDiffOptions{Base: "main"}); err == nil {
+		t.Fatalf("expected head validation error")
+	}
+}
+
+func TestDiffContextLines(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.Query().Get("context_lines")+":"+strconv.FormatBool(r.URL.Query().Has("context_lines")))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"branch":"feature","base":"main","sha":"abc","stats":{},"files":[],"filtered_files":[]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	if _, err := repo.GetBranchDiff(nil, GetBranchDiffOptions{Branch: "feature", ContextLines: intPtr(0)}); err != nil {
+		t.Fatalf("branch diff error: %v", err)
+	}
+	if _, err := repo.GetCommitDiff(nil, GetCommitDiffOptions{SHA: "abc", ContextLines: intPtr(25)}); err != nil {
+		t.Fatalf("commit diff error: %v", err)
+	}
+	if _, err := repo.CompareDiff(nil, CompareDiffOptions{Base: "main", Head: "feature"}); err != nil {
+		t.Fatalf("compare diff error: %v", err)
+	}
+	expected := []string{
+		"/api/v1/repos/branches/diff?0:true",
+		"/api/v1/repos/diff?25:true",
+		"/api/v1/repos/compare/diff?:false",
+	}
+	if len(queries) != len(expected) {
+		t.Fatalf("unexpected requests: %v", queries)
+	}
+	for i := range expected {
+		if queries[i] != expected[i] {
+			t.Fatalf("request %d: expected %s, got %s", i, expected[i], queries[i])
+		}
+	}
+
+	if _, err := repo.GetCommitDiff(nil, GetCommitDiffOptions{SHA: "abc", ContextLines: intPtr(-1)}); err == nil {
+		t.Fatalf("expected negative context lines error")
+	}
+}
+
+func TestDiffStatesFilter(t *testing.T) {
+	var states [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		states = append(states, r.URL.Query()["state"])
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"branch":"feature","base":"main","sha":"abc","stats":{},"files":[{"path":"gone.txt","state":"D","raw":""}],"filtered_files":[]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.GetBranchDiff(nil, GetBranchDiffOptions{Branch: "feature", States: []DiffFileState{DiffStateDeleted, DiffStateAdded, DiffStateDeleted}})
+	if err != nil {
+		t.Fatalf("branch diff error: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].State != DiffStateDeleted {
+		t.Fatalf("unexpected files: %+v", result.Files)
+	}
+	if _, err := repo.GetCommitDiff(nil, GetCommitDiffOptions{SHA: "abc", States: []DiffFileState{DiffStateRenamed}}); err != nil {
+		t.Fatalf("commit diff error: %v", err)
+	}
+	if len(states) != 2 || strings.Join(states[0], ",") != "deleted,added" || strings.Join(states[1], ",") != "renamed" {
+		t.Fatalf("unexpected state params: %v", states)
+	}
+
+	if _, err := repo.CompareDiff(nil, CompareDiffOptions{Base: "main", Head: "feature", States: []DiffFileState{DiffStateUnknown}}); err == nil {
+		t.Fatalf("expected unsupported state error")
+	}
+}
+
+func TestDiffComparison(t *testing.T) {
+	var comparisons []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comparisons = append(comparisons, r.URL.Query().Get("comparison"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"branch":"feature","base":"main","merge_base_sha":"mb123","stats":{},"files":[],"filtered_files":[]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.GetBranchDiff(nil, GetBranchDiffOptions{Branch: "feature", Comparison: DiffComparisonMergeBase})
+	if err != nil {
+		t.Fatalf("branch diff error: %v", err)
+	}
+	if result.MergeBaseSHA != "mb123" {
+		t.Fatalf("unexpected merge base: %q", result.MergeBaseSHA)
+	}
+	if _, err := repo.CompareDiff(nil, CompareDiffOptions{Base: "main", Head: "feature", Comparison: DiffComparisonDirect}); err != nil {
+		t.Fatalf("compare diff error: %v", err)
+	}
+	if _, err := repo.GetBranchDiff(nil, GetBranchDiffOptions{Branch: "feature"}); err != nil {
+		t.Fatalf("branch diff error: %v", err)
+	}
+	if strings.Join(comparisons, ",") != "merge_base,direct," {
+		t.Fatalf("unexpected comparison params: %q", comparisons)
+	}
+
+	if _, err := repo.GetBranchDiff(nil, GetBranchDiffOptions{Branch: "feature", Comparison: "triple"}); err == nil {
+		t.Fatalf("expected invalid comparison error")
+	}
+}
+
+func TestDiffRenameDetection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("detect_renames") != "true" || q.Get("detect_copies") != "true" || q.Get("rename_threshold") != "60" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"sha":"abc","stats":{"files":2},"files":[{"path":"new.go","state":"R087","old_path":"old.go","raw":"@@ -1 +1 @@\n-a\n+b\n"},{"path":"copy.go","state":"C100","raw":"diff --git a/src.go b/copy.go\nsimilarity index 100%\ncopy from src.go\ncopy to copy.go\n"}],"filtered_files":[{"path":"big.go","state":"R050","old_path":"huge.go"}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.GetCommitDiff(nil, GetCommitDiffOptions{SHA: "abc", RenameDetectionOptions: RenameDetectionOptions{
+		DetectRenames:   boolPtr(true),
+		DetectCopies:    true,
+		RenameThreshold: 60,
+	}})
+	if err != nil {
+		t.Fatalf("commit diff error: %v", err)
+	}
+	renamed := result.Files[0]
+	if renamed.State != DiffStateRenamed || renamed.OldPath != "old.go" || renamed.Similarity != 87 {
+		t.Fatalf("unexpected rename: %+v", renamed)
+	}
+	copied := result.Files[1]
+	if copied.State != DiffStateCopied || copied.OldPath != "src.go" || copied.Similarity != 100 {
+		t.Fatalf("unexpected copy: %+v", copied)
+	}
+	if filtered := result.FilteredFiles[0]; filtered.State != DiffStateRenamed || filtered.OldPath != "huge.go" || filtered.Similarity != 50 {
+		t.Fatalf("unexpected filtered rename: %+v", filtered)
+	}
+
+	invalid := []RenameDetectionOptions{
+		{RenameThreshold: 101},
+		{RenameThreshold: -1},
+		{DetectRenames: boolPtr(false), DetectCopies: true},
+		{DetectRenames: boolPtr(false), RenameThreshold: 50},
+	}
+	for _, options := range invalid {
+		if _, err := repo.GetBranchDiff(nil, GetBranchDiffOptions{Branch: "feature", RenameDetectionOptions: options}); err == nil {
+			t.Fatalf("expected validation error for %+v", options)
+		}
+	}
+}
+
 func TestRemoteURLPermissionsAndTTL(t *testing.T) {
 	client, err := NewClient(Options{Name: "acme", Key: testKey, StorageBaseURL: "acme.code.storage"})
 	if err != nil {
@@ -708,23 +921,19 @@ func TestGetNote(t *testing.T) {
 	}
 }
 
-func TestFileStreamEphemeral(t *testing.T) {
+func TestGetNotesBatch(t *testing.T) {
+	var requests []getNotesRequest
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/api/v1/repos/file" {
-			t.Fatalf("unexpected path: %s", r.URL.Path)
-		}
-		q := r.URL.Query()
-		if q.Get("path") != "docs/readme.md" {
-			t.Fatalf("unexpected path")
-		}
-		if q.Get("ref") != "feature/demo" {
-			t.Fatalf("unexpected ref")
+		if r.URL.Path != "/api/v1/repos/notes/batch" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
 		}
-		if q.Get("ephemeral") != "true" {
-			t.Fatalf("unexpected ephemeral")
+		var body getNotesRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
 		}
+		requests = append(requests, body)
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{}`))
+		_, _ = w.Write([]byte(`{"notes":[{"sha":"abc","note":"first"},{"sha":"def","note":"second"}],"ref_sha":"n1"}`))
 	}))
 	defer server.Close()
 
@@ -734,25 +943,58 @@ func TestFileStreamEphemeral(t *testing.T) {
 	}
 	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
 
-	flag := true
-	resp, err := repo.FileStream(nil, GetFileOptions{Path: "docs/readme.md", Ref: "feature/demo", Ephemeral: &flag})
+	result, err := repo.GetNotes(nil, GetNotesOptions{SHAs: []string{"abc", " def ", "abc", "fed"}})
 	if err != nil {
-		t.Fatalf("file stream error: %v", err)
+		t.Fatalf("get notes error: %v", err)
+	}
+	if len(result.Notes) != 2 || result.Notes["abc"] != "first" || result.Notes["def"] != "second" || result.RefSHA != "n1" {
+		t.Fatalf("unexpected notes result: %+v", result)
+	}
+	if _, ok := result.Notes["fed"]; ok {
+		t.Fatalf("expected commits without notes to be absent")
+	}
+	if _, err := repo.GetNotes(nil, GetNotesOptions{Base: "main", Head: "feature"}); err != nil {
+		t.Fatalf("get notes range error: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	if got := requests[0].SHAs; len(got) != 3 || got[1] != "def" {
+		t.Fatalf("unexpected shas: %v", got)
+	}
+	if requests[1].Base != "main" || requests[1].Head != "feature" || len(requests[1].SHAs) != 0 {
+		t.Fatalf("unexpected range request: %+v", requests[1])
+	}
+
+	invalid := []GetNotesOptions{
+		{},
+		{Base: "main"},
+		{SHAs: []string{"abc"}, Head: "feature"},
+		{SHAs: []string{""}},
+	}
+	for _, options := range invalid {
+		if _, err := repo.GetNotes(nil, options); err == nil {
+			t.Fatalf("expected validation error for %+v", options)
+		}
 	}
-	_ = resp.Body.Close()
 }
 
-func TestFileStreamEphemeralBase(t *testing.T) {
+func TestNotesOnBlobsAndTrees(t *testing.T) {
+	var written noteWriteRequest
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/api/v1/repos/file" {
-			t.Fatalf("unexpected path: %s", r.URL.Path)
-		}
-		q := r.URL.Query()
-		if q.Get("ephemeral_base") != "true" {
-			t.Fatalf("unexpected ephemeral_base")
-		}
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{}`))
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Query().Get("object_type") != "blob" {
+				t.Errorf("unexpected query: %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`{"sha":"b10b","object_type":"blob","note":"generated","ref_sha":"n1"}`))
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&written); err != nil {
+				t.Errorf("decode body: %v", err)
+			}
+			_, _ = w.Write([]byte(`{"sha":"7ree","target_ref":"refs/notes/commits","new_ref_sha":"n2","result":{"success":true,"status":"ok"}}`))
+		}
 	}))
 	defer server.Close()
 
@@ -762,43 +1004,81 @@ func TestFileStreamEphemeralBase(t *testing.T) {
 	}
 	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
 
-	flag := true
-	resp, err := repo.FileStream(nil, GetFileOptions{Path: "docs/readme.md", EphemeralBase: &flag})
+	result, err := repo.GetNote(nil, GetNoteOptions{SHA: "b10b", ObjectType: NoteObjectBlob})
 	if err != nil {
-		t.Fatalf("file stream error: %v", err)
+		t.Fatalf("get note error: %v", err)
+	}
+	if result.ObjectType != NoteObjectBlob || result.Note != "generated" {
+		t.Fatalf("unexpected note: %+v", result)
+	}
+	if _, err := repo.CreateNote(nil, CreateNoteOptions{SHA: "7ree", ObjectType: "Tree", Note: "owned by platform"}); err != nil {
+		t.Fatalf("create note error: %v", err)
+	}
+	if written.ObjectType != "tree" || written.SHA != "7ree" {
+		t.Fatalf("unexpected write: %+v", written)
+	}
+	if _, err := repo.CreateNote(nil, CreateNoteOptions{SHA: "7ree", ObjectType: "tag", Note: "x"}); err == nil {
+		t.Fatalf("expected objectType validation error")
+	}
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set(blobSHAHeader, " b10b ")
+	if sha := FileStreamBlobSHA(resp); sha != "b10b" {
+		t.Fatalf("unexpected blob sha: %q", sha)
 	}
-	_ = resp.Body.Close()
 }
 
-func TestArchiveStream(t *testing.T) {
+func TestListNotes(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/api/v1/repos/archive" {
-			t.Fatalf("unexpected path: %s", r.URL.Path)
-		}
-		if r.Method != http.MethodPost {
-			t.Fatalf("unexpected method: %s", r.Method)
-		}
-		var payload archiveRequest
-		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-			t.Fatalf("decode payload: %v", err)
+		if r.URL.Path != "/api/v1/repos/notes/list" || r.Method != http.MethodGet {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
 		}
-		if payload.Ref != "main" {
-			t.Fatalf("unexpected ref: %s", payload.Ref)
-		}
-		if len(payload.IncludeGlobs) != 1 || payload.IncludeGlobs[0] != "README.md" {
-			t.Fatalf("unexpected include globs: %v", payload.IncludeGlobs)
-		}
-		if len(payload.ExcludeGlobs) != 1 || payload.ExcludeGlobs[0] != "vendor/**" {
-			t.Fatalf("unexpected exclude globs: %v", payload.ExcludeGlobs)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("cursor") == "" {
+			if r.URL.Query().Get("limit") != "1" {
+				t.Errorf("unexpected limit: %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`{"notes":[{"sha":"abc","note":"reviewed"}],"ref_sha":"n1","next_cursor":"c2","has_more":true}`))
+			return
 		}
-		if payload.MaxBlobSize == nil || *payload.MaxBlobSize != 1024 {
-			t.Fatalf("unexpected max blob size: %v", payload.MaxBlobSize)
+		_, _ = w.Write([]byte(`{"notes":[{"sha":"def","note":"approved"}],"ref_sha":"n1","has_more":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	first, err := repo.ListNotes(nil, ListNotesOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("list notes error: %v", err)
+	}
+	if len(first.Notes) != 1 || first.Notes[0].SHA != "abc" || first.Notes[0].Note != "reviewed" || first.RefSHA != "n1" || !first.HasMore {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+	second, err := repo.ListNotes(nil, ListNotesOptions{Cursor: first.NextCursor, Limit: 1})
+	if err != nil {
+		t.Fatalf("list notes page error: %v", err)
+	}
+	if len(second.Notes) != 1 || second.Notes[0].SHA != "def" || second.HasMore {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+}
+
+func TestGetCommitPatch(t *testing.T) {
+	patch := "From abc123 Mon Sep 17 00:00:00 2001\nFrom: Tester <test@example.com>\nDate: Mon, 20 Jan 2025 10:30:00 +0000\nSubject: [PATCH] Update readme\n\n---\n README.md | 2 +-\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/patch" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
 		}
-		if payload.Archive == nil || payload.Archive.Prefix != "repo/" {
-			t.Fatalf("unexpected archive prefix")
+		q := r.URL.Query()
+		if q.Get("sha") != "abc123" || q.Get("base_sha") != "def456" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
 		}
-		w.Header().Set("Content-Type", "application/gzip")
-		_, _ = w.Write([]byte("ok"))
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(patch))
 	}))
 	defer server.Close()
 
@@ -807,28 +1087,39 @@ func TestArchiveStream(t *testing.T) {
 		t.Fatalf("client error: %v", err)
 	}
 	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
-	maxBlobSize := int64(1024)
 
-	resp, err := repo.ArchiveStream(nil, ArchiveOptions{
-		Ref:           "main",
-		IncludeGlobs:  []string{"README.md"},
-		ExcludeGlobs:  []string{"vendor/**"},
-		MaxBlobSize:   &maxBlobSize,
-		ArchivePrefix: "repo/",
-	})
+	resp, err := repo.GetCommitPatch(nil, GetCommitPatchOptions{SHA: "abc123", BaseSHA: "def456"})
 	if err != nil {
-		t.Fatalf("archive stream error: %v", err)
+		t.Fatalf("get commit patch error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read patch: %v", err)
+	}
+	if string(body) != patch {
+		t.Fatalf("unexpected patch: %q", body)
+	}
+
+	if _, err := repo.GetCommitPatch(nil, GetCommitPatchOptions{}); err == nil {
+		t.Fatalf("expected sha validation error")
 	}
-	_ = resp.Body.Close()
 }
 
-func TestListCommitsDateParsing(t *testing.T) {
+func TestSearchHistory(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/api/v1/repos/commits" {
-			t.Fatalf("unexpected path: %s", r.URL.Path)
+		if r.URL.Path != "/api/v1/repos/history/search" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var body searchHistoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		if body.Pattern != "MAX_RETRIES" || !body.Regex || body.Ref != "main" || len(body.Paths) != 1 || body.Paths[0] != "src" || body.Limit != 5 {
+			t.Errorf("unexpected body: %+v", body)
 		}
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{"commits":[{"sha":"abc123","message":"feat: add endpoint","author_name":"Jane Doe","author_email":"jane@example.com","committer_name":"Jane Doe","committer_email":"jane@example.com","date":"2024-01-15T14:32:18Z"}],"has_more":false}`))
+		_, _ = w.Write([]byte(`{"ref":"main","matches":[{"commit":{"sha":"abc","message":"Raise retries","author_name":"Tester","author_email":"test@example.com","date":"2024-01-20T10:30:00Z"},"paths":["src/config.go"]}],"next_cursor":"c2","has_more":true}`))
 	}))
 	defer server.Close()
 
@@ -838,31 +1129,33 @@ func TestListCommitsDateParsing(t *testing.T) {
 	}
 	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
 
-	result, err := repo.ListCommits(nil, ListCommitsOptions{})
+	result, err := repo.SearchHistory(nil, SearchHistoryOptions{Pattern: "MAX_RETRIES", Regex: true, Ref: "main", Paths: []string{"src", " "}, Limit: 5})
 	if err != nil {
-		t.Fatalf("list commits error: %v", err)
+		t.Fatalf("search history error: %v", err)
 	}
-	if len(result.Commits) != 1 {
-		t.Fatalf("expected one commit")
+	if len(result.Matches) != 1 || result.Matches[0].Commit.SHA != "abc" || result.Matches[0].Paths[0] != "src/config.go" {
+		t.Fatalf("unexpected matches: %+v", result.Matches)
 	}
-	commit := result.Commits[0]
-	if commit.RawDate != "2024-01-15T14:32:18Z" {
-		t.Fatalf("unexpected raw date")
+	if result.Matches[0].Commit.Date.IsZero() || result.NextCursor != "c2" || !result.HasMore {
+		t.Fatalf("unexpected result: %+v", result)
 	}
-	if commit.Date.IsZero() {
-		t.Fatalf("expected parsed date")
+
+	if _, err := repo.SearchHistory(nil, SearchHistoryOptions{}); err == nil {
+		t.Fatalf("expected pattern validation error")
 	}
 }
 
-func TestListCommitsUserAgentHeader(t *testing.T) {
-	var headerAgent string
+func TestSearchFiles(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/api/v1/repos/commits" {
-			t.Fatalf("unexpected path: %s", r.URL.Path)
+		if r.URL.Path != "/api/v1/repos/files/search" || r.Method != http.MethodGet {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("query") != "rpgo" || q.Get("ref") != "main" || q.Get("path_prefix") != "src" || q.Get("limit") != "10" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
 		}
-		headerAgent = r.Header.Get("Code-Storage-Agent")
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write([]byte(`{"commits":[],"has_more":false}`))
+		_, _ = w.Write([]byte(`{"ref":"main","commit_sha":"abc","matches":[{"path":"src/repo.go","score":0.92,"positions":[4,6,9,10]},{"path":"src/report.go","score":0.5,"positions":[4,6,10,11]}]}`))
 	}))
 	defer server.Close()
 
@@ -872,15 +1165,1484 @@ func TestListCommitsUserAgentHeader(t *testing.T) {
 	}
 	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
 
-	_, err = repo.ListCommits(nil, ListCommitsOptions{})
+	result, err := repo.SearchFiles(nil, SearchFilesOptions{Query: " rpgo ", Ref: "main", PathPrefix: "/src/", Limit: 10})
 	if err != nil {
-		t.Fatalf("list commits error: %v", err)
+		t.Fatalf("search files error: %v", err)
 	}
-	if headerAgent == "" || !strings.Contains(headerAgent, "code-storage-go-sdk/") {
-		t.Fatalf("missing Code-Storage-Agent header")
+	if result.CommitSHA != "abc" || len(result.Matches) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if first := result.Matches[0]; first.Path != "src/repo.go" || first.Score != 0.92 || len(first.Positions) != 4 {
+		t.Fatalf("unexpected first match: %+v", first)
 	}
-}
+
+	if _, err := repo.SearchFiles(nil, SearchFilesOptions{Query: "  "}); err == nil {
+		t.Fatalf("expected query validation error")
+	}
+}
+
+func TestResolveSubmodules(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("resolve_submodules") != "true" {
+			t.Errorf("missing resolve_submodules on %s", r.URL.Path)
+		}
+		claims := parseJWTFromToken(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if repos, _ := claims["submodule_repos"].([]interface{}); len(repos) != 1 || repos[0] != "shared-lib" || claims["repo"] != "repo" {
+			t.Errorf("unexpected token claims on %s: %v", r.URL.Path, claims)
+		}
+		switch r.URL.Path {
+		case "/api/v1/repos/file":
+			w.Header().Set("Code-Storage-Submodule-Repo", "shared-lib")
+			_, _ = w.Write([]byte("package lib\n"))
+		case "/api/v1/repos/files/metadata":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"files":[{"path":"vendor/lib/lib.go","mode":"100644","size":12,"last_commit_sha":"def","submodule_repo":"shared-lib"}],"commits":{},"ref":"main"}`))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	if _, err := repo.FileStream(nil, GetFileOptions{Path: "vendor/lib/lib.go", ResolveSubmodules: true}); err == nil || !strings.Contains(err.Error(), "submoduleRepos is required") {
+		t.Fatalf("expected submoduleRepos error, got %v", err)
+	}
+	if _, err := repo.ListFiles(nil, ListFilesOptions{ResolveSubmodules: true}); err == nil || !strings.Contains(err.Error(), "submoduleRepos is required") {
+		t.Fatalf("expected submoduleRepos error, got %v", err)
+	}
+
+	resp, err := repo.FileStream(nil, GetFileOptions{Path: "vendor/lib/lib.go", ResolveSubmodules: true, SubmoduleRepos: []string{"shared-lib"}})
+	if err != nil {
+		t.Fatalf("file stream error: %v", err)
+	}
+	resp.Body.Close()
+	if got := FileStreamSubmoduleRepo(resp); got != "shared-lib" {
+		t.Fatalf("unexpected submodule repo: %q", got)
+	}
+
+	result, err := repo.ListFilesWithMetadata(nil, ListFilesWithMetadataOptions{ResolveSubmodules: true, SubmoduleRepos: []string{"shared-lib"}})
+	if err != nil {
+		t.Fatalf("list files error: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].SubmoduleRepo != "shared-lib" {
+		t.Fatalf("unexpected files: %+v", result.Files)
+	}
+}
+
+func TestFileStreamEphemeral(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/file" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("path") != "docs/readme.md" {
+			t.Fatalf("unexpected path")
+		}
+		if q.Get("ref") != "feature/demo" {
+			t.Fatalf("unexpected ref")
+		}
+		if q.Get("ephemeral") != "true" {
+			t.Fatalf("unexpected ephemeral")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	flag := true
+	resp, err := repo.FileStream(nil, GetFileOptions{Path: "docs/readme.md", Ref: "feature/demo", Ephemeral: &flag})
+	if err != nil {
+		t.Fatalf("file stream error: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestFileStreamEphemeralBase(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/file" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("ephemeral_base") != "true" {
+			t.Fatalf("unexpected ephemeral_base")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	flag := true
+	resp, err := repo.FileStream(nil, GetFileOptions{Path: "docs/readme.md", EphemeralBase: &flag})
+	if err != nil {
+		t.Fatalf("file stream error: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestArchiveStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/archive" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Method != http.MethodPost {
+			t.Fatalf("unexpected method: %s", r.Method)
+		}
+		var payload archiveRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		if payload.Ref != "main" {
+			t.Fatalf("unexpected ref: %s", payload.Ref)
+		}
+		if len(payload.IncludeGlobs) != 1 || payload.IncludeGlobs[0] != "README.md" {
+			t.Fatalf("unexpected include globs: %v", payload.IncludeGlobs)
+		}
+		if len(payload.ExcludeGlobs) != 1 || payload.ExcludeGlobs[0] != "vendor/**" {
+			t.Fatalf("unexpected exclude globs: %v", payload.ExcludeGlobs)
+		}
+		if payload.MaxBlobSize == nil || *payload.MaxBlobSize != 1024 {
+			t.Fatalf("unexpected max blob size: %v", payload.MaxBlobSize)
+		}
+		if payload.Archive == nil || payload.Archive.Prefix != "repo/" {
+			t.Fatalf("unexpected archive prefix")
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+	maxBlobSize := int64(1024)
+
+	resp, err := repo.ArchiveStream(nil, ArchiveOptions{
+		Ref:           "main",
+		IncludeGlobs:  []string{"README.md"},
+		ExcludeGlobs:  []string{"vendor/**"},
+		MaxBlobSize:   &maxBlobSize,
+		ArchivePrefix: "repo/",
+	})
+	if err != nil {
+		t.Fatalf("archive stream error: %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestListCommitsDateParsing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/commits" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"commits":[{"sha":"abc123","message":"feat: add endpoint","author_name":"Jane Doe","author_email":"jane@example.com","committer_name":"Jane Doe","committer_email":"jane@example.com","date":"2024-01-15T14:32:18Z"}],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.ListCommits(nil, ListCommitsOptions{})
+	if err != nil {
+		t.Fatalf("list commits error: %v", err)
+	}
+	if len(result.Commits) != 1 {
+		t.Fatalf("expected one commit")
+	}
+	commit := result.Commits[0]
+	if commit.RawDate != "2024-01-15T14:32:18Z" {
+		t.Fatalf("unexpected raw date")
+	}
+	if commit.Date.IsZero() {
+		t.Fatalf("expected parsed date")
+	}
+}
+
+func TestListCommitsUserAgentHeader(t *testing.T) {
+	var headerAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/commits" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		headerAgent = r.Header.Get("Code-Storage-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"commits":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	_, err = repo.ListCommits(nil, ListCommitsOptions{})
+	if err != nil {
+		t.Fatalf("list commits error: %v", err)
+	}
+	if headerAgent == "" || !strings.Contains(headerAgent, "code-storage-go-sdk/") {
+		t.Fatalf("missing Code-Storage-Agent header")
+	}
+}
 
 func intPtr(value int) *int {
 	return &value
 }
+
+func TestGetBlob(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/blob" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("sha") != "0123456789abcdef0123456789abcdef01234567" {
+			t.Fatalf("unexpected sha: %s", r.URL.RawQuery)
+		}
+		if r.URL.Query().Get("ref") != "" {
+			t.Fatalf("expected no ref")
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write([]byte("blob contents"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, err := repo.GetBlob(nil, GetBlobOptions{SHA: " 0123456789abcdef0123456789abcdef01234567 "})
+	if err != nil {
+		t.Fatalf("get blob error: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if string(data) != "blob contents" {
+		t.Fatalf("unexpected blob body: %s", data)
+	}
+
+	if _, err := repo.GetBlob(nil, GetBlobOptions{}); err == nil || !strings.Contains(err.Error(), "sha is required") {
+		t.Fatalf("expected sha validation error, got %v", err)
+	}
+}
+
+func TestFileStreamFollowSymlinks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("follow_symlinks") != "false" {
+			t.Fatalf("unexpected follow_symlinks: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Code-Storage-File-Mode", "120000")
+		_, _ = w.Write([]byte("../target.txt"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, err := repo.FileStream(nil, GetFileOptions{Path: "docs/link", FollowSymlinks: boolPtr(false)})
+	if err != nil {
+		t.Fatalf("file stream error: %v", err)
+	}
+	defer resp.Body.Close()
+	if entryType := FileStreamEntryType(resp); entryType != FileEntryTypeSymlink {
+		t.Fatalf("unexpected entry type: %s", entryType)
+	}
+}
+
+func TestDiffBinaryMetadata(t *testing.T) {
+	result := transformBranchDiff(branchDiffResponse{
+		Branch: "feature",
+		Files: []fileDiffRaw{
+			{Path: "logo.png", State: "M", Raw: "diff --git a/logo.png b/logo.png\nindex 1111111..2222222 100644\nBinary files a/logo.png and b/logo.png differ\n"},
+			{Path: "data.bin", State: "A", IsBinary: true, ContentType: "application/octet-stream"},
+			{Path: "main.go", State: "M", Raw: "@@ -1 +1 @@\n-Binary files x and y differ\n+b\n", ContentType: "text/x-go"},
+		},
+		FilteredFiles: []filteredFileRaw{{Path: "big.zip", State: "A", IsBinary: true, ContentType: "application/zip"}},
+	})
+	if !result.Files[0].IsBinary {
+		t.Fatalf("expected binary marker to be detected")
+	}
+	if !result.Files[1].IsBinary || result.Files[1].ContentType != "application/octet-stream" {
+		t.Fatalf("expected server binary flag: %+v", result.Files[1])
+	}
+	if result.Files[2].IsBinary || result.Files[2].ContentType != "text/x-go" {
+		t.Fatalf("expected text file: %+v", result.Files[2])
+	}
+	if !result.FilteredFiles[0].IsBinary || result.FilteredFiles[0].ContentType != "application/zip" {
+		t.Fatalf("expected filtered binary flag: %+v", result.FilteredFiles[0])
+	}
+}
+
+func TestGrepMaxFileBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body grepRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		if body.FileFilters == nil || body.FileFilters.MaxFileBytes == nil || *body.FileFilters.MaxFileBytes != 65536 {
+			t.Fatalf("expected max_file_bytes filter")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"query":{"pattern":"TODO","case_sensitive":true},"repo":{"ref":"main","commit":"deadbeef"},"matches":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	maxFileBytes := int64(65536)
+	_, err = repo.Grep(nil, GrepOptions{
+		Query:       GrepQuery{Pattern: "TODO"},
+		FileFilters: &GrepFileFilters{MaxFileBytes: &maxFileBytes},
+	})
+	if err != nil {
+		t.Fatalf("grep error: %v", err)
+	}
+}
+
+func TestGrepFileTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body grepRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		if body.FileFilters == nil || strings.Join(body.FileFilters.IncludeGlobs, ",") != "*.go,*.md,*.markdown,*.mdx" {
+			t.Errorf("unexpected include globs: %+v", body.FileFilters)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"query":{"pattern":"TODO","case_sensitive":true},"repo":{"ref":"main","commit":"deadbeef"},"matches":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	globs := make([]string, 1, 8)
+	globs[0] = "*.go"
+	_, err = repo.Grep(nil, GrepOptions{
+		Query:       GrepQuery{Pattern: "TODO"},
+		FileFilters: &GrepFileFilters{IncludeGlobs: globs, Types: []string{"Go", "markdown"}},
+	})
+	if err != nil {
+		t.Fatalf("grep error: %v", err)
+	}
+	if spare := globs[:cap(globs)]; spare[1] != "" {
+		t.Fatalf("expected caller's include globs to be left alone, got %v", spare)
+	}
+
+	if _, err := repo.Grep(nil, GrepOptions{Query: GrepQuery{Pattern: "TODO"}, FileFilters: &GrepFileFilters{Types: []string{"cobol"}}}); err == nil {
+		t.Fatalf("expected unknown type error")
+	}
+	if types := GrepFileTypes(); len(types) == 0 || types[0] != "c" {
+		t.Fatalf("unexpected file types: %v", types)
+	}
+}
+
+func TestGrepInvertAndFilesWithoutMatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body grepRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		if !body.Query.InvertMatch || !body.FilesWithoutMatch {
+			t.Errorf("unexpected body: %+v", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"query":{"pattern":"X-Security-Header","case_sensitive":true,"invert_match":true},"repo":{"ref":"main","commit":"deadbeef"},"matches":[{"path":"services/billing/main.go","lines":[]}],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.Grep(nil, GrepOptions{Query: GrepQuery{Pattern: "X-Security-Header", InvertMatch: true}, FilesWithoutMatch: true})
+	if err != nil {
+		t.Fatalf("grep error: %v", err)
+	}
+	if !result.Query.InvertMatch || len(result.Matches) != 1 || result.Matches[0].Path != "services/billing/main.go" || len(result.Matches[0].Lines) != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestReadPreferenceHeader(t *testing.T) {
+	var preference string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		preference = r.Header.Get("Code-Storage-Read-Preference")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"commits":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	_, err = repo.ListCommits(nil, ListCommitsOptions{InvocationOptions: InvocationOptions{ReadPreference: ReadPreferenceReplicaOK}})
+	if err != nil {
+		t.Fatalf("list commits error: %v", err)
+	}
+	if preference != "replica-ok" {
+		t.Fatalf("unexpected read preference header: %q", preference)
+	}
+
+	if _, err := repo.ListCommits(nil, ListCommitsOptions{}); err != nil {
+		t.Fatalf("list commits error: %v", err)
+	}
+	if preference != "" {
+		t.Fatalf("expected no read preference header by default, got %q", preference)
+	}
+}
+
+func TestFileAttributesInDiffAndMetadata(t *testing.T) {
+	diff := transformCommitDiff(commitDiffResponse{
+		SHA:   "abc",
+		Files: []fileDiffRaw{{Path: "gen/api.pb.go", State: "A", Attributes: map[string]string{"generator": "protoc"}}},
+	})
+	if diff.Files[0].Attributes["generator"] != "protoc" {
+		t.Fatalf("unexpected diff attributes: %+v", diff.Files[0].Attributes)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"files":[{"path":"gen/api.pb.go","mode":"100644","size":11,"attributes":{"source-checksum":"sha256:ff"}}],"commits":{},"ref":"main"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+	result, err := repo.ListFilesWithMetadata(nil, ListFilesWithMetadataOptions{})
+	if err != nil {
+		t.Fatalf("list files error: %v", err)
+	}
+	if result.Files[0].Attributes["source-checksum"] != "sha256:ff" {
+		t.Fatalf("unexpected file attributes: %+v", result.Files[0].Attributes)
+	}
+}
+
+func TestTreeStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/tree/stats" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("path") == "" {
+			if q.Get("depth") != "0" {
+				t.Errorf("expected explicit depth=0, got %s", r.URL.RawQuery)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ref":"main","commit_sha":"abc","directories":[{"path":"","file_count":30,"dir_count":2,"bytes":4096,"depth":0}]}`))
+			return
+		}
+		if q.Get("ref") != "main" || q.Get("path") != "services" || q.Get("depth") != "1" {
+			t.Fatalf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ref":"main","commit_sha":"abc","directories":[` +
+			`{"path":"services","file_count":30,"dir_count":2,"bytes":4096,"depth":0},` +
+			`{"path":"services/api","file_count":20,"dir_count":0,"bytes":3072,"depth":1}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.TreeStats(nil, TreeStatsOptions{Ref: "main", Path: "/services/", Depth: 1})
+	if err != nil {
+		t.Fatalf("tree stats error: %v", err)
+	}
+	if result.CommitSHA != "abc" || len(result.Directories) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if dir := result.Directories[1]; dir.Path != "services/api" || dir.FileCount != 20 || dir.Bytes != 3072 || dir.Depth != 1 {
+		t.Fatalf("unexpected directory stats: %+v", dir)
+	}
+
+	if root, err := repo.TreeStats(nil, TreeStatsOptions{}); err != nil || len(root.Directories) != 1 {
+		t.Fatalf("unexpected root stats: %+v (%v)", root, err)
+	}
+
+	if _, err := repo.TreeStats(nil, TreeStatsOptions{Depth: -1}); err == nil {
+		t.Fatalf("expected negative depth error")
+	}
+}
+
+func TestBranchMetadata(t *testing.T) {
+	values := map[string]string{"owner": "alice"}
+	version := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/branches/metadata" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Query().Get("branch") != "feature" {
+				t.Errorf("unexpected branch: %s", r.URL.RawQuery)
+			}
+		case http.MethodPut:
+			var body setBranchMetadataRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decode body: %v", err)
+			}
+			if body.ExpectedVersion != strconv.Itoa(version) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"error":"metadata version mismatch"}`))
+				return
+			}
+			for key, value := range body.Set {
+				values[key] = value
+			}
+			for _, key := range body.Delete {
+				delete(values, key)
+			}
+			version++
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"branch":     "feature",
+			"metadata":   values,
+			"version":    strconv.Itoa(version),
+			"updated_at": "2024-01-20T10:30:00Z",
+		})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	current, err := repo.GetBranchMetadata(nil, GetBranchMetadataOptions{Branch: "feature"})
+	if err != nil {
+		t.Fatalf("get metadata error: %v", err)
+	}
+	if current.Values["owner"] != "alice" || current.Version != "1" || current.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected metadata: %+v", current)
+	}
+
+	updated, err := repo.SetBranchMetadata(nil, SetBranchMetadataOptions{
+		Branch:          "feature",
+		Values:          map[string]string{"ticket": "ENG-42"},
+		Delete:          []string{"owner"},
+		ExpectedVersion: current.Version,
+	})
+	if err != nil {
+		t.Fatalf("set metadata error: %v", err)
+	}
+	if updated.Values["ticket"] != "ENG-42" || updated.Values["owner"] != "" || updated.Version != "2" {
+		t.Fatalf("unexpected updated metadata: %+v", updated)
+	}
+
+	_, err = repo.SetBranchMetadata(nil, SetBranchMetadataOptions{Branch: "feature", Values: map[string]string{"env": "staging"}, ExpectedVersion: current.Version})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	if _, err := repo.SetBranchMetadata(nil, SetBranchMetadataOptions{Branch: "feature", Values: map[string]string{"a": "1"}, Delete: []string{"a"}}); err == nil {
+		t.Fatalf("expected set/delete overlap error")
+	}
+}
+
+func TestCreateBranchIfExists(t *testing.T) {
+	var ifExists []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decode body: %v", err)
+		}
+		value, _ := body["if_exists"].(string)
+		ifExists = append(ifExists, value)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"message":"branch exists","target_branch":"feature/demo","commit_sha":"abc123","already_existed":true}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.CreateBranch(nil, CreateBranchOptions{
+		BaseBranch:   "main",
+		TargetBranch: "feature/demo",
+		IfExists:     BranchIfExistsRequireSameHead,
+	})
+	if err != nil {
+		t.Fatalf("create branch error: %v", err)
+	}
+	if !result.AlreadyExisted || result.CommitSHA != "abc123" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if _, err := repo.CreateBranch(nil, CreateBranchOptions{BaseBranch: "main", TargetBranch: "feature/demo", IfExists: BranchIfExistsError}); err != nil {
+		t.Fatalf("create branch error: %v", err)
+	}
+	if len(ifExists) != 2 || ifExists[0] != "require_same_head" || ifExists[1] != "" {
+		t.Fatalf("unexpected if_exists values: %v", ifExists)
+	}
+
+	if _, err := repo.CreateBranch(nil, CreateBranchOptions{BaseBranch: "main", TargetBranch: "x", IfExists: "overwrite"}); err == nil {
+		t.Fatalf("expected invalid ifExists error")
+	}
+}
+
+func TestCreateTag(t *testing.T) {
+	tags := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/tags/create" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var body createTagRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if existing, ok := tags[body.Name]; ok {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":"tag exists","name":"` + body.Name + `","target_sha":"` + existing + `"}`))
+			return
+		}
+		tags[body.Name] = body.SHA
+		if body.Annotated && (body.Message != "Release 1.0" || body.Tagger == nil || body.Tagger.Name != "Releaser") {
+			t.Errorf("unexpected annotated body: %+v", body)
+		}
+		tagSHA := ""
+		if body.Annotated {
+			tagSHA = "7a9"
+		}
+		_, _ = w.Write([]byte(`{"name":"` + body.Name + `","target_sha":"` + body.SHA + `","tag_sha":"` + tagSHA + `","annotated":` + strconv.FormatBool(body.Annotated) + `}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.CreateTag(nil, CreateTagOptions{Name: "refs/tags/v1.0.0", SHA: "abc", Annotated: true, Message: "Release 1.0", Tagger: &CommitSignature{Name: "Releaser", Email: "release@example.com"}})
+	if err != nil {
+		t.Fatalf("create tag error: %v", err)
+	}
+	if result.Name != "v1.0.0" || result.TargetSHA != "abc" || result.TagSHA != "7a9" || !result.Annotated {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if lightweight, err := repo.CreateTag(nil, CreateTagOptions{Name: "nightly", SHA: "def"}); err != nil || lightweight.TagSHA != "" || lightweight.Annotated {
+		t.Fatalf("unexpected lightweight tag: %+v (%v)", lightweight, err)
+	}
+
+	_, err = repo.CreateTag(nil, CreateTagOptions{Name: "v1.0.0", SHA: "fff"})
+	var exists *TagExistsError
+	if !errors.As(err, &exists) || exists.Name != "v1.0.0" || exists.TargetSHA != "abc" {
+		t.Fatalf("expected TagExistsError, got %v", err)
+	}
+
+	invalid := []CreateTagOptions{
+		{SHA: "abc"},
+		{Name: "v2", SHA: ""},
+		{Name: "refs/heads/main", SHA: "abc"},
+		{Name: "v2", SHA: "abc", Annotated: true},
+		{Name: "v2", SHA: "abc", Message: "needs annotated"},
+	}
+	for _, options := range invalid {
+		if _, err := repo.CreateTag(nil, options); err == nil {
+			t.Fatalf("expected validation error for %+v", options)
+		}
+	}
+}
+
+func TestDeleteTag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/tags" || r.Method != http.MethodDelete {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var body deleteTagRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case body.Name == "missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"tag not found"}`))
+		case body.ExpectedSHA != "abc":
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_, _ = w.Write([]byte(`{"name":"v1.0.0","result":{"ref":"refs/tags/v1.0.0","old_sha":"abc","success":false,"status":"precondition_failed","message":"tag moved"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"name":"v1.0.0","result":{"ref":"refs/tags/v1.0.0","old_sha":"abc","new_sha":"0000000000000000000000000000000000000000","success":true,"status":"ok"}}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.DeleteTag(nil, DeleteTagOptions{Name: "refs/tags/v1.0.0", ExpectedSHA: "abc"})
+	if err != nil {
+		t.Fatalf("delete tag error: %v", err)
+	}
+	if result.Name != "v1.0.0" || result.RefUpdate.Branch != "refs/tags/v1.0.0" || result.RefUpdate.OldSHA != "abc" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	_, err = repo.DeleteTag(nil, DeleteTagOptions{Name: "v1.0.0", ExpectedSHA: "stale"})
+	var refErr *RefUpdateError
+	if !errors.As(err, &refErr) || refErr.Reason != RefUpdateReasonPreconditionFailed || refErr.RefUpdate == nil || refErr.RefUpdate.OldSHA != "abc" {
+		t.Fatalf("expected precondition RefUpdateError, got %v", err)
+	}
+	_, err = repo.DeleteTag(nil, DeleteTagOptions{Name: "missing"})
+	if !errors.As(err, &refErr) || refErr.Reason != RefUpdateReasonNotFound {
+		t.Fatalf("expected not found RefUpdateError, got %v", err)
+	}
+	if _, err := repo.DeleteTag(nil, DeleteTagOptions{Name: " "}); err == nil {
+		t.Fatalf("expected name validation error")
+	}
+}
+
+func TestGetTag(t *testing.T) {
+	signedPayload := "object abc\ntype commit\ntag v1.0.0\ntagger Releaser <release@example.com> 1705746600 +0000\n\nRelease 1.0\n"
+	signature := "-----BEGIN PGP SIGNATURE-----\n...\n-----END PGP SIGNATURE-----\n"
+	object := signedPayload + signature
+	sum := sha1.Sum([]byte("tag " + strconv.Itoa(len(object)) + "\x00" + object))
+	tagSHA := hex.EncodeToString(sum[:])
+	body, _ := json.Marshal(map[string]any{
+		"name": "v1.0.0", "target_sha": "abc", "target_type": "commit", "commit_sha": "abc", "tag_sha": tagSHA,
+		"message": "Release 1.0\n", "tagger": map[string]string{"name": "Releaser", "email": "release@example.com"},
+		"tagged_at": "2024-01-20T10:30:00Z", "signature": signature, "signed_payload": signedPayload,
+		"verification": map[string]any{"verified": true, "key_id": "ABCD1234", "signer": "release@example.com"},
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/tag" || r.Method != http.MethodGet {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("name") == "nightly" {
+			_, _ = w.Write([]byte(`{"name":"nightly","target_sha":"def","target_type":"commit","commit_sha":"def"}`))
+			return
+		}
+		if r.URL.Query().Get("name") != "v1.0.0" || r.URL.Query().Get("verify") != "true" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	tag, err := repo.GetTag(nil, GetTagOptions{Name: "refs/tags/v1.0.0", Verify: true})
+	if err != nil {
+		t.Fatalf("get tag error: %v", err)
+	}
+	if tag.CommitSHA != "abc" || tag.TagSHA != tagSHA || tag.Tagger == nil || tag.TaggedAt.IsZero() {
+		t.Fatalf("unexpected tag: %+v", tag)
+	}
+	if tag.Verification == nil || !tag.Verification.Verified || tag.Verification.KeyID != "ABCD1234" {
+		t.Fatalf("unexpected verification: %+v", tag.Verification)
+	}
+	var verifiedPayload string
+	if err := tag.VerifySignature(func(payload, signature []byte) error {
+		verifiedPayload = string(payload)
+		return nil
+	}); err != nil || !strings.HasPrefix(verifiedPayload, "object abc") {
+		t.Fatalf("unexpected verify call: %q (%v)", verifiedPayload, err)
+	}
+
+	// A payload lifted from another tag, or one that does not hash to the
+	// tag object, never reaches the verifier.
+	forged := []TagDetails{tag, tag, tag}
+	forged[0].SignedPayload = strings.Replace(signedPayload, "tag v1.0.0", "tag v0.9.0", 1)
+	forged[1].SignedPayload = strings.Replace(signedPayload, "object abc", "object def", 1)
+	forged[2].SignedPayload = signedPayload + "trailing\n"
+	for i, details := range forged {
+		if err := details.VerifySignature(func(payload, signature []byte) error {
+			t.Fatalf("forged payload %d reached the verifier", i)
+			return nil
+		}); !errors.Is(err, ErrTagPayloadMismatch) {
+			t.Fatalf("forged payload %d: expected ErrTagPayloadMismatch, got %v", i, err)
+		}
+	}
+
+	lightweight, err := repo.GetTag(nil, GetTagOptions{Name: "nightly"})
+	if err != nil {
+		t.Fatalf("get lightweight tag error: %v", err)
+	}
+	if err := lightweight.VerifySignature(func(payload, signature []byte) error { return nil }); !errors.Is(err, ErrTagUnsigned) {
+		t.Fatalf("expected ErrTagUnsigned, got %v", err)
+	}
+}
+
+func TestListTags(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/tags" || r.Method != http.MethodGet {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if r.URL.Query().Get("cursor") != "c1" || r.URL.Query().Get("limit") != "2" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"tags":[{"cursor":"c2","name":"v1.0.0","target_sha":"abc","tag_sha":"7a9","message":"Release 1.0","tagger":{"name":"Releaser","email":"release@example.com"},"created_at":"2024-01-20T10:30:00Z"},{"cursor":"c3","name":"nightly","target_sha":"def","created_at":"2024-01-21T00:00:00Z"}],"next_cursor":"c3","has_more":true}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.ListTags(nil, ListTagsOptions{Cursor: "c1", Limit: 2})
+	if err != nil {
+		t.Fatalf("list tags error: %v", err)
+	}
+	if len(result.Tags) != 2 || result.NextCursor != "c3" || !result.HasMore {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	annotated := result.Tags[0]
+	if annotated.Name != "v1.0.0" || annotated.TagSHA != "7a9" || annotated.Message != "Release 1.0" || annotated.Tagger == nil || annotated.Tagger.Email != "release@example.com" {
+		t.Fatalf("unexpected annotated tag: %+v", annotated)
+	}
+	if lightweight := result.Tags[1]; lightweight.Tagger != nil || lightweight.TargetSHA != "def" || lightweight.CreatedAt == "" {
+		t.Fatalf("unexpected lightweight tag: %+v", lightweight)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/languages" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("ref") != "main" {
+			t.Fatalf("unexpected ref: %s", r.URL.Query().Get("ref"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ref":"main","commit_sha":"abc","languages":{"Shell":100,"Go":700,"TypeScript":200}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.Languages(nil, LanguagesOptions{Ref: "main"})
+	if err != nil {
+		t.Fatalf("languages error: %v", err)
+	}
+	if result.TotalBytes != 1000 || len(result.Languages) != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Languages[0].Name != "Go" || result.Languages[0].Percentage != 70 || result.Languages[2].Name != "Shell" {
+		t.Fatalf("unexpected ordering: %+v", result.Languages)
+	}
+}
+
+func TestRemoteURLSchemeAndPortOverrides(t *testing.T) {
+	client, err := NewClient(Options{Name: "acme", Key: testKey, StorageBaseURL: "localhost:8080", StorageScheme: "http", StoragePort: 9418})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo-1", DefaultBranch: "main", client: client}
+
+	remote, err := repo.RemoteURL(nil, RemoteURLOptions{})
+	if err != nil {
+		t.Fatalf("remote url error: %v", err)
+	}
+	parsed, err := url.Parse(remote)
+	if err != nil {
+		t.Fatalf("parse remote: %v", err)
+	}
+	if parsed.Scheme != "http" || parsed.Host != "localhost:9418" || parsed.Path != "/repo-1.git" {
+		t.Fatalf("unexpected remote url: %s", remote)
+	}
+
+	client, err = NewClient(Options{Name: "acme", Key: testKey, StorageBaseURL: "git.local:3000"})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo = &Repo{ID: "repo-1", DefaultBranch: "main", client: client}
+	remote, err = repo.EphemeralRemoteURL(nil, RemoteURLOptions{})
+	if err != nil {
+		t.Fatalf("remote url error: %v", err)
+	}
+	if !strings.HasPrefix(remote, "https://t:") || !strings.Contains(remote, "@git.local:3000/repo-1+ephemeral.git") {
+		t.Fatalf("unexpected ephemeral remote url: %s", remote)
+	}
+
+	if _, err := NewClient(Options{Name: "acme", Key: testKey, StorageScheme: "ssh"}); err == nil {
+		t.Fatalf("expected unsupported scheme error")
+	}
+}
+
+func TestArchiveStreamZipFormat(t *testing.T) {
+	var payloads []archiveRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload archiveRequest
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+		}
+		payloads = append(payloads, payload)
+		w.Header().Set("Content-Type", "application/zip")
+		_, _ = w.Write([]byte("PK"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, err := repo.ArchiveStream(nil, ArchiveOptions{Format: ArchiveFormatZip})
+	if err != nil {
+		t.Fatalf("archive error: %v", err)
+	}
+	resp.Body.Close()
+	resp, err = repo.ArchiveStream(nil, ArchiveOptions{Format: ArchiveFormatTarGz})
+	if err != nil {
+		t.Fatalf("archive error: %v", err)
+	}
+	resp.Body.Close()
+
+	if payloads[0].Archive == nil || payloads[0].Archive.Format != "zip" {
+		t.Fatalf("expected zip format: %+v", payloads[0])
+	}
+	if payloads[1].Archive != nil {
+		t.Fatalf("expected default format to be omitted: %+v", payloads[1])
+	}
+
+	if _, err := repo.ArchiveStream(nil, ArchiveOptions{Format: "rar"}); err == nil {
+		t.Fatalf("expected unsupported format error")
+	}
+}
+
+func TestArchiveStreamZstdFallback(t *testing.T) {
+	supportsZstd := true
+	var compressions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload archiveRequest
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+		}
+		compression := ""
+		if payload.Archive != nil {
+			compression = payload.Archive.Compression
+		}
+		compressions = append(compressions, compression)
+		if compression == "zstd" {
+			if !strings.Contains(r.Header.Get("Accept"), "application/zstd") {
+				t.Fatalf("expected zstd accept header")
+			}
+			if !supportsZstd {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"unsupported compression"}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/zstd")
+			_, _ = w.Write([]byte("zstd"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write([]byte("gzip"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, err := repo.ArchiveStream(nil, ArchiveOptions{Compression: ArchiveCompressionZstd})
+	if err != nil {
+		t.Fatalf("archive error: %v", err)
+	}
+	resp.Body.Close()
+	if ArchiveStreamCompression(resp) != ArchiveCompressionZstd {
+		t.Fatalf("expected zstd response")
+	}
+
+	supportsZstd = false
+	resp, err = repo.ArchiveStream(nil, ArchiveOptions{Compression: ArchiveCompressionZstd})
+	if err != nil {
+		t.Fatalf("archive fallback error: %v", err)
+	}
+	resp.Body.Close()
+	if ArchiveStreamCompression(resp) != ArchiveCompressionGzip {
+		t.Fatalf("expected gzip fallback response")
+	}
+	if strings.Join(compressions, ",") != "zstd,zstd," {
+		t.Fatalf("unexpected compression requests: %v", compressions)
+	}
+
+	if _, err := repo.ArchiveStream(nil, ArchiveOptions{Format: ArchiveFormatZip, Compression: ArchiveCompressionZstd}); err == nil {
+		t.Fatalf("expected zip compression error")
+	}
+}
+
+func TestArchiveStreamSubdirectory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload archiveRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		if payload.Path != "services/api" {
+			t.Fatalf("unexpected archive path: %q", payload.Path)
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, err := repo.ArchiveStream(nil, ArchiveOptions{Path: "/services/api/"})
+	if err != nil {
+		t.Fatalf("archive error: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := repo.ArchiveStream(nil, ArchiveOptions{Path: "services/../secrets"}); err == nil {
+		t.Fatalf("expected invalid path error")
+	}
+}
+
+func TestListCommitsOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("order") != "topo" {
+			t.Fatalf("unexpected order: %q", r.URL.Query().Get("order"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"commits":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	if _, err := repo.ListCommits(nil, ListCommitsOptions{Branch: "main", Order: CommitOrderTopo}); err != nil {
+		t.Fatalf("list commits error: %v", err)
+	}
+	if _, err := repo.ListCommits(nil, ListCommitsOptions{Order: "random"}); err == nil {
+		t.Fatalf("expected invalid order error")
+	}
+}
+
+func TestArchiveStreamConditional(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"tree-abc"`)
+		if r.Header.Get("If-None-Match") == `"tree-abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		_, _ = w.Write([]byte("archive"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, err := repo.ArchiveStream(nil, ArchiveOptions{Ref: "main"})
+	if err != nil {
+		t.Fatalf("archive error: %v", err)
+	}
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag != `"tree-abc"` {
+		t.Fatalf("unexpected first response: %d %s", resp.StatusCode, etag)
+	}
+
+	resp, err = repo.ArchiveStream(nil, ArchiveOptions{Ref: "main", IfNoneMatch: etag})
+	if err != nil {
+		t.Fatalf("conditional archive error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", resp.StatusCode)
+	}
+
+	download, err := repo.DownloadArchive(nil, DownloadArchiveOptions{ArchiveOptions: ArchiveOptions{Ref: "main", IfNoneMatch: etag}})
+	if err != nil {
+		t.Fatalf("download archive error: %v", err)
+	}
+	defer download.Close()
+	if download.StatusCode() != http.StatusNotModified {
+		t.Fatalf("expected 304 from download, got %d", download.StatusCode())
+	}
+}
+
+func TestArchiveStreamPrefixTemplate(t *testing.T) {
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	var prefix, pinned string
+	commitLookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/commits":
+			commitLookups++
+			if r.URL.Query().Get("branch") != "feature/x" || r.URL.Query().Get("limit") != "1" || r.URL.Query().Get("order") != "topo" {
+				t.Errorf("unexpected commits query: %s", r.URL.RawQuery)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"commits":[{"sha":"` + sha + `","message":"tip"}],"has_more":true}`))
+		case "/api/v1/repos/archive":
+			var payload archiveRequest
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("decode payload: %v", err)
+			}
+			if payload.Archive != nil {
+				prefix = payload.Archive.Prefix
+			}
+			pinned = payload.SHA
+			if payload.Ref != "" && payload.SHA != "" {
+				t.Errorf("expected either ref or sha, got %+v", payload)
+			}
+			_, _ = w.Write([]byte("ok"))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, err := repo.ArchiveStream(nil, ArchiveOptions{Ref: "feature/x", ArchivePrefix: "{repo}-{ref}-{short_sha}/"})
+	if err != nil {
+		t.Fatalf("archive stream error: %v", err)
+	}
+	resp.Body.Close()
+	if prefix != "repo-feature-x-0123456/" || commitLookups != 1 {
+		t.Fatalf("unexpected prefix %q after %d lookups", prefix, commitLookups)
+	}
+	if pinned != sha {
+		t.Fatalf("expected archive pinned to the resolved commit, got %q", pinned)
+	}
+
+	resp, err = repo.ArchiveStream(nil, ArchiveOptions{ArchivePrefix: "{repo}@{ref}/"})
+	if err != nil {
+		t.Fatalf("archive stream error: %v", err)
+	}
+	resp.Body.Close()
+	if prefix != "repo@main/" || commitLookups != 1 {
+		t.Fatalf("unexpected prefix %q after %d lookups", prefix, commitLookups)
+	}
+
+	if _, err := repo.ArchiveStream(nil, ArchiveOptions{ArchivePrefix: "{branch}/"}); err == nil {
+		t.Fatalf("expected unknown placeholder error")
+	}
+}
+
+func TestArchiveStreamPinnedSHA(t *testing.T) {
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload archiveRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		if payload.SHA != sha || payload.Ref != "" {
+			t.Errorf("unexpected sha/ref: %q %q", payload.SHA, payload.Ref)
+		}
+		w.Header().Set("Code-Storage-Commit-Sha", payload.SHA)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, err := repo.ArchiveStream(nil, ArchiveOptions{SHA: strings.ToUpper(sha)})
+	if err != nil {
+		t.Fatalf("archive stream error: %v", err)
+	}
+	resp.Body.Close()
+	if got := ArchiveStreamCommitSHA(resp); got != sha {
+		t.Fatalf("unexpected commit sha: %q", got)
+	}
+
+	if _, err := repo.ArchiveStream(nil, ArchiveOptions{SHA: "abc123"}); err == nil {
+		t.Fatalf("expected short sha error")
+	}
+	if _, err := repo.ArchiveStream(nil, ArchiveOptions{Ref: "main", SHA: sha}); err == nil {
+		t.Fatalf("expected ref and sha error")
+	}
+}
+
+func TestListSubmodules(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/submodules" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("ref") != "release" {
+			t.Errorf("unexpected ref: %s", r.URL.Query().Get("ref"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ref":"release","commit_sha":"abc","submodules":[{"path":"vendor/lib","sha":"def","url":"https://example.com/lib.git","resolved":true},{"path":"third_party/x","sha":"fed"}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.ListSubmodules(nil, ListSubmodulesOptions{Ref: "release"})
+	if err != nil {
+		t.Fatalf("list submodules error: %v", err)
+	}
+	if result.CommitSHA != "abc" || len(result.Submodules) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	first, second := result.Submodules[0], result.Submodules[1]
+	if first.Path != "vendor/lib" || first.SHA != "def" || first.URL != "https://example.com/lib.git" || !first.Resolved {
+		t.Fatalf("unexpected first submodule: %+v", first)
+	}
+	if second.Resolved || second.URL != "" {
+		t.Fatalf("unexpected second submodule: %+v", second)
+	}
+}
+
+func TestArchiveStreamIncludeSubmodules(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload archiveRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		if payload.Archive == nil || !payload.Archive.IncludeSubmodules {
+			t.Errorf("expected include_submodules")
+		}
+		claims := parseJWTFromToken(t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if repos, _ := claims["submodule_repos"].([]interface{}); len(repos) != 1 || repos[0] != "shared-lib" {
+			t.Errorf("unexpected submodule_repos claim: %v", claims["submodule_repos"])
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, err := repo.ArchiveStream(nil, ArchiveOptions{IncludeSubmodules: true, SubmoduleRepos: []string{"shared-lib"}})
+	if err != nil {
+		t.Fatalf("archive stream error: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestListDirectories(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/directories" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		query := r.URL.Query()
+		if query.Get("ref") != "main" || query.Get("root") != "src" || query.Get("depth") != "2" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ref":"main","commit_sha":"abc","directories":[{"path":"src/api","name":"api","depth":1,"file_count":4,"dir_count":1},{"path":"src/api/v1","name":"v1","depth":2,"file_count":2,"dir_count":0}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.ListDirectories(nil, ListDirectoriesOptions{Ref: "main", Root: "/src/", Depth: 2})
+	if err != nil {
+		t.Fatalf("list directories error: %v", err)
+	}
+	if result.CommitSHA != "abc" || len(result.Directories) != 2 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Directories[0] != (DirectoryEntry{Path: "src/api", Name: "api", Depth: 1, FileCount: 4, DirCount: 1}) {
+		t.Fatalf("unexpected directory: %+v", result.Directories[0])
+	}
+
+	if _, err := repo.ListDirectories(nil, ListDirectoriesOptions{Depth: -1}); err == nil {
+		t.Fatalf("expected depth error")
+	}
+}
+
+func TestArchiveStreamPaths(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload archiveRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		if strings.Join(payload.Paths, ",") != "go.mod,cmd/main.go,internal/app.go" {
+			t.Errorf("unexpected paths: %v", payload.Paths)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	resp, err := repo.ArchiveStream(nil, ArchiveOptions{Paths: []string{"go.mod", "/cmd/main.go", "internal/app.go", "go.mod"}})
+	if err != nil {
+		t.Fatalf("archive stream error: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := repo.ArchiveStream(nil, ArchiveOptions{Paths: []string{"../secret"}}); err == nil {
+		t.Fatalf("expected traversal error")
+	}
+	if _, err := repo.ArchiveStream(nil, ArchiveOptions{Path: "src", Paths: []string{"a.go"}}); err == nil {
+		t.Fatalf("expected path and paths error")
+	}
+}
+
+func TestGrepBinaryMode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body grepRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		if body.Binary != "match" {
+			t.Errorf("unexpected binary mode: %q", body.Binary)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"query":{"pattern":"TODO","case_sensitive":true},"repo":{"ref":"main","commit":"deadbeef"},"matches":[{"path":"assets/logo.png","lines":[],"is_binary":true},{"path":"main.go","lines":[{"line_number":3,"text":"// TODO","type":"match"}]}],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.Grep(nil, GrepOptions{Query: GrepQuery{Pattern: "TODO"}, Binary: GrepBinaryMatch})
+	if err != nil {
+		t.Fatalf("grep error: %v", err)
+	}
+	if len(result.Matches) != 2 || !result.Matches[0].IsBinary || result.Matches[1].IsBinary {
+		t.Fatalf("unexpected matches: %+v", result.Matches)
+	}
+
+	if _, err := repo.Grep(nil, GrepOptions{Query: GrepQuery{Pattern: "TODO"}, Binary: "hex"}); err == nil {
+		t.Fatalf("expected binary mode error")
+	}
+}
+
+func TestGrepMultipleRefs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body grepRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		if body.Ref != "" || len(body.Refs) != 2 || body.Refs[0] != (grepRefPayload{Ref: "main"}) || body.Refs[1] != (grepRefPayload{Ref: "agent/fix", Ephemeral: true}) {
+			t.Errorf("unexpected refs: %+v", body.Refs)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"query":{"pattern":"TODO","case_sensitive":true},"repo":{},"refs":[{"ref":"main","commit":"aaa"},{"ref":"agent/fix","commit":"bbb"}],"matches":[{"path":"main.go","ref":"main","commit":"aaa","lines":[{"line_number":3,"text":"// TODO","type":"match"}]},{"path":"main.go","ref":"agent/fix","commit":"bbb","lines":[{"line_number":4,"text":"// TODO","type":"match"}]}],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.Grep(nil, GrepOptions{
+		Query: GrepQuery{Pattern: "TODO"},
+		Refs:  []GrepRef{{Ref: "main"}, {Ref: "agent/fix", Ephemeral: true}, {Ref: " main "}},
+	})
+	if err != nil {
+		t.Fatalf("grep error: %v", err)
+	}
+	if len(result.Refs) != 2 || result.Refs[1].Commit != "bbb" {
+		t.Fatalf("unexpected refs: %+v", result.Refs)
+	}
+	if len(result.Matches) != 2 || result.Matches[0].Ref != "main" || result.Matches[1].Ref != "agent/fix" || result.Matches[1].Commit != "bbb" {
+		t.Fatalf("unexpected matches: %+v", result.Matches)
+	}
+
+	if _, err := repo.Grep(nil, GrepOptions{Query: GrepQuery{Pattern: "TODO"}, Ref: "main", Refs: []GrepRef{{Ref: "dev"}}}); err == nil {
+		t.Fatalf("expected ref/refs conflict error")
+	}
+}
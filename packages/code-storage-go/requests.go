@@ -4,6 +4,11 @@ package storage
 type createRepoRequest struct {
 	BaseRepo      *baseRepoPayload `json:"base_repo,omitempty"`
 	DefaultBranch string           `json:"default_branch,omitempty"`
+	// DefaultBranchFallback marks DefaultBranch as the SDK's "main"
+	// fallback, which the org default branch replaces when one is set.
+	DefaultBranchFallback bool `json:"default_branch_fallback,omitempty"`
+	// SkipOrgDefaults stops the server from applying OrgRepoDefaults.
+	SkipOrgDefaults bool `json:"skip_org_defaults,omitempty"`
 }
 
 type baseRepoPayload struct {
@@ -25,37 +30,128 @@ type authPayload struct {
 // noteWriteRequest is the JSON body for note write operations.
 type noteWriteRequest struct {
 	SHA            string      `json:"sha"`
+	ObjectType     string      `json:"object_type,omitempty"`
 	Action         string      `json:"action,omitempty"`
 	Note           string      `json:"note,omitempty"`
 	ExpectedRefSHA string      `json:"expected_ref_sha,omitempty"`
 	Author         *authorInfo `json:"author,omitempty"`
 }
 
+// setBranchMetadataRequest is the JSON body for SetBranchMetadata.
+type setBranchMetadataRequest struct {
+	Branch          string            `json:"branch"`
+	Set             map[string]string `json:"set,omitempty"`
+	Delete          []string          `json:"delete,omitempty"`
+	ExpectedVersion string            `json:"expected_version,omitempty"`
+}
+
+// orgRepoDefaultsPayload is the JSON body for SetOrgRepoDefaults and the
+// response of both org defaults endpoints.
+type orgRepoDefaultsPayload struct {
+	DefaultBranch    string                `json:"default_branch,omitempty"`
+	BranchProtection []branchProtectionRaw `json:"branch_protection,omitempty"`
+	Webhooks         []webhookEndpointRaw  `json:"webhooks,omitempty"`
+	Retention        *retentionPolicyRaw   `json:"retention,omitempty"`
+	UpdatedAt        string                `json:"updated_at,omitempty"`
+}
+
+type branchProtectionRaw struct {
+	Pattern              string `json:"pattern"`
+	BlockForcePush       bool   `json:"block_force_push,omitempty"`
+	BlockDeletion        bool   `json:"block_deletion,omitempty"`
+	RequireLinearHistory bool   `json:"require_linear_history,omitempty"`
+}
+
+type webhookEndpointRaw struct {
+	ID     string   `json:"id,omitempty"`
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+type retentionPolicyRaw struct {
+	EphemeralBranchTTLSeconds   int64 `json:"ephemeral_branch_ttl_seconds,omitempty"`
+	DeletedRepoRetentionSeconds int64 `json:"deleted_repo_retention_seconds,omitempty"`
+}
+
+// webhookPingRequest is the JSON body for SendWebhookPing.
+type webhookPingRequest struct {
+	WebhookID string `json:"webhook_id"`
+}
+
+// rotateWebhookSecretRequest is the JSON body for RotateWebhookSecret.
+type rotateWebhookSecretRequest struct {
+	WebhookID          string `json:"webhook_id"`
+	GracePeriodSeconds int64  `json:"grace_period_seconds,omitempty"`
+}
+
+// createCommitStatusRequest is the JSON body for CreateCommitStatus.
+type createCommitStatusRequest struct {
+	SHA         string `json:"sha"`
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// legalHoldRequest is the JSON body for PlaceLegalHold and RemoveLegalHold.
+type legalHoldRequest struct {
+	Reason    string `json:"reason,omitempty"`
+	Reference string `json:"reference,omitempty"`
+}
+
+// getNotesRequest is the JSON body for GetNotes.
+type getNotesRequest struct {
+	SHAs []string `json:"shas,omitempty"`
+	Base string   `json:"base,omitempty"`
+	Head string   `json:"head,omitempty"`
+}
+
 type authorInfo struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
 }
 
+// searchHistoryRequest is the JSON body for SearchHistory.
+type searchHistoryRequest struct {
+	Pattern string   `json:"pattern"`
+	Regex   bool     `json:"regex,omitempty"`
+	Ref     string   `json:"ref,omitempty"`
+	Paths   []string `json:"paths,omitempty"`
+	Limit   int      `json:"limit,omitempty"`
+	Cursor  string   `json:"cursor,omitempty"`
+}
+
 // grepRequest is the JSON body for Grep.
 type grepRequest struct {
-	Query       grepQueryPayload       `json:"query"`
-	Ref         string                 `json:"ref,omitempty"`
-	Paths       []string               `json:"paths,omitempty"`
-	FileFilters *grepFileFilterPayload `json:"file_filters,omitempty"`
-	Context     *grepContextPayload    `json:"context,omitempty"`
-	Limits      *grepLimitsPayload     `json:"limits,omitempty"`
-	Pagination  *grepPaginationPayload `json:"pagination,omitempty"`
+	Query             grepQueryPayload       `json:"query"`
+	Ref               string                 `json:"ref,omitempty"`
+	Refs              []grepRefPayload       `json:"refs,omitempty"`
+	Paths             []string               `json:"paths,omitempty"`
+	FileFilters       *grepFileFilterPayload `json:"file_filters,omitempty"`
+	Context           *grepContextPayload    `json:"context,omitempty"`
+	Limits            *grepLimitsPayload     `json:"limits,omitempty"`
+	Pagination        *grepPaginationPayload `json:"pagination,omitempty"`
+	Binary            string                 `json:"binary,omitempty"`
+	FilesWithoutMatch bool                   `json:"files_without_match,omitempty"`
+}
+
+type grepRefPayload struct {
+	Ref       string `json:"ref"`
+	Ephemeral bool   `json:"ephemeral,omitempty"`
 }
 
 type grepQueryPayload struct {
 	Pattern       string `json:"pattern"`
 	CaseSensitive *bool  `json:"case_sensitive,omitempty"`
+	InvertMatch   bool   `json:"invert_match,omitempty"`
 }
 
 type grepFileFilterPayload struct {
 	IncludeGlobs     []string `json:"include_globs,omitempty"`
 	ExcludeGlobs     []string `json:"exclude_globs,omitempty"`
 	ExtensionFilters []string `json:"extension_filters,omitempty"`
+	MaxFileBytes     *int64   `json:"max_file_bytes,omitempty"`
 }
 
 type grepContextPayload struct {
@@ -81,6 +177,9 @@ type pullUpstreamRequest struct {
 // archiveRequest is the JSON body for ArchiveStream.
 type archiveRequest struct {
 	Ref          string          `json:"ref,omitempty"`
+	SHA          string          `json:"sha,omitempty"`
+	Path         string          `json:"path,omitempty"`
+	Paths        []string        `json:"paths,omitempty"`
 	IncludeGlobs []string        `json:"include_globs,omitempty"`
 	ExcludeGlobs []string        `json:"exclude_globs,omitempty"`
 	MaxBlobSize  *int64          `json:"max_blob_size,omitempty"`
@@ -88,7 +187,12 @@ type archiveRequest struct {
 }
 
 type archiveOptions struct {
-	Prefix string `json:"prefix,omitempty"`
+	Prefix            string `json:"prefix,omitempty"`
+	Format            string `json:"format,omitempty"`
+	Compression       string `json:"compression,omitempty"`
+	IncludeSubmodules bool   `json:"include_submodules,omitempty"`
+	MaxVolumeBytes    int64  `json:"max_volume_bytes,omitempty"`
+	Volume            *int   `json:"volume,omitempty"`
 }
 
 // createBranchRequest is the JSON body for CreateBranch.
@@ -97,6 +201,22 @@ type createBranchRequest struct {
 	TargetBranch      string `json:"target_branch"`
 	BaseIsEphemeral   bool   `json:"base_is_ephemeral,omitempty"`
 	TargetIsEphemeral bool   `json:"target_is_ephemeral,omitempty"`
+	IfExists          string `json:"if_exists,omitempty"`
+}
+
+// createTagRequest is the JSON body for CreateTag.
+type createTagRequest struct {
+	Name      string      `json:"name"`
+	SHA       string      `json:"sha"`
+	Annotated bool        `json:"annotated,omitempty"`
+	Message   string      `json:"message,omitempty"`
+	Tagger    *authorInfo `json:"tagger,omitempty"`
+}
+
+// deleteTagRequest is the JSON body for DeleteTag.
+type deleteTagRequest struct {
+	Name        string `json:"name"`
+	ExpectedSHA string `json:"expected_sha,omitempty"`
 }
 
 // commitMetadataPayload is the JSON body for commit metadata.
@@ -109,14 +229,20 @@ type commitMetadataPayload struct {
 	BaseBranch      string             `json:"base_branch,omitempty"`
 	Ephemeral       bool               `json:"ephemeral,omitempty"`
 	EphemeralBase   bool               `json:"ephemeral_base,omitempty"`
+	EphemeralTTL    int64              `json:"ephemeral_ttl_seconds,omitempty"`
+	Parents         []string           `json:"parents,omitempty"`
 	Files           []fileEntryPayload `json:"files,omitempty"`
 }
 
 type fileEntryPayload struct {
-	Path      string `json:"path"`
-	ContentID string `json:"content_id"`
-	Operation string `json:"operation"`
-	Mode      string `json:"mode,omitempty"`
+	Path            string            `json:"path"`
+	ContentID       string            `json:"content_id"`
+	Operation       string            `json:"operation"`
+	Mode            string            `json:"mode,omitempty"`
+	Attributes      map[string]string `json:"attributes,omitempty"`
+	FailIfExists    bool              `json:"fail_if_exists,omitempty"`
+	FailIfMissing   bool              `json:"fail_if_missing,omitempty"`
+	ExpectedBlobSHA string            `json:"expected_blob_sha,omitempty"`
 }
 
 type metadataEnvelope struct {
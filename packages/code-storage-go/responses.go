@@ -1,21 +1,29 @@
 package storage
 
 type listFilesResponse struct {
-	Paths []string `json:"paths"`
-	Ref   string   `json:"ref"`
+	Paths     []string `json:"paths"`
+	Ref       string   `json:"ref"`
+	TreeSHA   string   `json:"tree_sha"`
+	CommitSHA string   `json:"commit_sha"`
 }
 
 type listFilesWithMetadataResponse struct {
-	Files   []fileWithMetadataRaw        `json:"files"`
-	Commits map[string]commitMetadataRaw `json:"commits"`
-	Ref     string                       `json:"ref"`
+	Files     []fileWithMetadataRaw        `json:"files"`
+	Commits   map[string]commitMetadataRaw `json:"commits"`
+	Ref       string                       `json:"ref"`
+	TreeSHA   string                       `json:"tree_sha"`
+	CommitSHA string                       `json:"commit_sha"`
 }
 
 type fileWithMetadataRaw struct {
-	Path          string `json:"path"`
-	Mode          string `json:"mode"`
-	Size          int64  `json:"size"`
-	LastCommitSHA string `json:"last_commit_sha"`
+	Path          string            `json:"path"`
+	Mode          string            `json:"mode"`
+	Size          int64             `json:"size"`
+	LastCommitSHA string            `json:"last_commit_sha"`
+	IsBinary      bool              `json:"is_binary"`
+	ContentType   string            `json:"content_type"`
+	Attributes    map[string]string `json:"attributes"`
+	SubmoduleRepo string            `json:"submodule_repo"`
 }
 
 type commitMetadataRaw struct {
@@ -24,6 +32,53 @@ type commitMetadataRaw struct {
 	Message string `json:"message"`
 }
 
+type treeStatsResponse struct {
+	Ref         string              `json:"ref"`
+	CommitSHA   string              `json:"commit_sha"`
+	Directories []directoryStatsRaw `json:"directories"`
+}
+
+type directoryStatsRaw struct {
+	Path      string `json:"path"`
+	FileCount int64  `json:"file_count"`
+	DirCount  int64  `json:"dir_count"`
+	Bytes     int64  `json:"bytes"`
+	Depth     int    `json:"depth"`
+}
+
+type listDirectoriesResponse struct {
+	Ref         string              `json:"ref"`
+	CommitSHA   string              `json:"commit_sha"`
+	Directories []directoryEntryRaw `json:"directories"`
+}
+
+type directoryEntryRaw struct {
+	Path      string `json:"path"`
+	Name      string `json:"name"`
+	Depth     int    `json:"depth"`
+	FileCount int64  `json:"file_count"`
+	DirCount  int64  `json:"dir_count"`
+}
+
+type languagesResponse struct {
+	Ref       string           `json:"ref"`
+	CommitSHA string           `json:"commit_sha"`
+	Languages map[string]int64 `json:"languages"`
+}
+
+type listSubmodulesResponse struct {
+	Ref        string             `json:"ref"`
+	CommitSHA  string             `json:"commit_sha"`
+	Submodules []submoduleInfoRaw `json:"submodules"`
+}
+
+type submoduleInfoRaw struct {
+	Path     string `json:"path"`
+	SHA      string `json:"sha"`
+	URL      string `json:"url"`
+	Resolved bool   `json:"resolved"`
+}
+
 type listBranchesResponse struct {
 	Branches   []branchInfoRaw `json:"branches"`
 	NextCursor string          `json:"next_cursor"`
@@ -37,6 +92,22 @@ type branchInfoRaw struct {
 	CreatedAt string `json:"created_at"`
 }
 
+type listTagsResponse struct {
+	Tags       []tagInfoRaw `json:"tags"`
+	NextCursor string       `json:"next_cursor"`
+	HasMore    bool         `json:"has_more"`
+}
+
+type tagInfoRaw struct {
+	Cursor    string      `json:"cursor"`
+	Name      string      `json:"name"`
+	TargetSHA string      `json:"target_sha"`
+	TagSHA    string      `json:"tag_sha"`
+	Message   string      `json:"message"`
+	Tagger    *authorInfo `json:"tagger"`
+	CreatedAt string      `json:"created_at"`
+}
+
 type listCommitsResponse struct {
 	Commits    []commitInfoRaw `json:"commits"`
 	NextCursor string          `json:"next_cursor"`
@@ -53,6 +124,108 @@ type commitInfoRaw struct {
 	Date           string `json:"date"`
 }
 
+type branchMetadataResponse struct {
+	Branch    string            `json:"branch"`
+	Metadata  map[string]string `json:"metadata"`
+	Version   string            `json:"version"`
+	UpdatedAt string            `json:"updated_at"`
+}
+
+type webhookPongResponse struct {
+	Pong       bool   `json:"pong"`
+	WebhookID  string `json:"webhook_id"`
+	DeliveryID string `json:"delivery_id,omitempty"`
+}
+
+type webhookPingResponse struct {
+	DeliveryID string `json:"delivery_id"`
+	Delivered  bool   `json:"delivered"`
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type rotateWebhookSecretResponse struct {
+	WebhookID               string `json:"webhook_id"`
+	Secret                  string `json:"secret"`
+	PreviousSecretExpiresAt string `json:"previous_secret_expires_at"`
+}
+
+type commitStatusRaw struct {
+	ID          string `json:"id"`
+	SHA         string `json:"sha"`
+	State       string `json:"state"`
+	Context     string `json:"context"`
+	TargetURL   string `json:"target_url"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+type listCommitStatusesResponse struct {
+	SHA        string            `json:"sha"`
+	State      string            `json:"state"`
+	Statuses   []commitStatusRaw `json:"statuses"`
+	NextCursor string            `json:"next_cursor"`
+	HasMore    bool              `json:"has_more"`
+}
+
+type activityEventRaw struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	RepoID     string `json:"repo_id"`
+	Ref        string `json:"ref"`
+	Before     string `json:"before"`
+	After      string `json:"after"`
+	Actor      string `json:"actor"`
+	OccurredAt string `json:"occurred_at"`
+}
+
+type listActivityResponse struct {
+	Events     []activityEventRaw `json:"events"`
+	NextCursor string             `json:"next_cursor"`
+	HasMore    bool               `json:"has_more"`
+}
+
+type searchHistoryResponse struct {
+	Ref        string            `json:"ref"`
+	Matches    []historyMatchRaw `json:"matches"`
+	NextCursor string            `json:"next_cursor"`
+	HasMore    bool              `json:"has_more"`
+}
+
+type historyMatchRaw struct {
+	Commit commitInfoRaw `json:"commit"`
+	Paths  []string      `json:"paths"`
+}
+
+type searchFilesResponse struct {
+	Ref       string               `json:"ref"`
+	CommitSHA string               `json:"commit_sha"`
+	Matches   []fileSearchMatchRaw `json:"matches"`
+}
+
+type fileSearchMatchRaw struct {
+	Path      string  `json:"path"`
+	Score     float64 `json:"score"`
+	Positions []int   `json:"positions"`
+}
+
+type churnStatsResponse struct {
+	Ref         string          `json:"ref"`
+	Files       []churnStatsRaw `json:"files"`
+	Directories []churnStatsRaw `json:"directories"`
+}
+
+type churnStatsRaw struct {
+	Path          string `json:"path"`
+	Commits       int    `json:"commits"`
+	Authors       int    `json:"authors"`
+	Additions     int    `json:"additions"`
+	Deletions     int    `json:"deletions"`
+	LastChangedAt string `json:"last_changed_at"`
+}
+
 type listReposResponse struct {
 	Repos      []repoInfoRaw `json:"repos"`
 	NextCursor string        `json:"next_cursor"`
@@ -65,6 +238,13 @@ type repoInfoRaw struct {
 	DefaultBranch string        `json:"default_branch"`
 	CreatedAt     string        `json:"created_at"`
 	BaseRepo      *repoBaseInfo `json:"base_repo"`
+	LegalHold     *legalHoldRaw `json:"legal_hold"`
+}
+
+type legalHoldRaw struct {
+	Reason    string `json:"reason"`
+	Reference string `json:"reference"`
+	PlacedAt  string `json:"placed_at"`
 }
 
 type repoBaseInfo struct {
@@ -74,9 +254,28 @@ type repoBaseInfo struct {
 }
 
 type noteReadResponse struct {
-	SHA    string `json:"sha"`
-	Note   string `json:"note"`
-	RefSHA string `json:"ref_sha"`
+	SHA        string `json:"sha"`
+	ObjectType string `json:"object_type"`
+	Note       string `json:"note"`
+	RefSHA     string `json:"ref_sha"`
+}
+
+type notesReadResponse struct {
+	Notes  []noteEntryRaw `json:"notes"`
+	RefSHA string         `json:"ref_sha"`
+}
+
+type noteEntryRaw struct {
+	SHA        string `json:"sha"`
+	ObjectType string `json:"object_type"`
+	Note       string `json:"note"`
+}
+
+type listNotesResponse struct {
+	Notes      []noteEntryRaw `json:"notes"`
+	RefSHA     string         `json:"ref_sha"`
+	NextCursor string         `json:"next_cursor"`
+	HasMore    bool           `json:"has_more"`
 }
 
 type noteWriteResponse struct {
@@ -101,30 +300,43 @@ type diffStatsRaw struct {
 }
 
 type fileDiffRaw struct {
-	Path      string `json:"path"`
-	State     string `json:"state"`
-	OldPath   string `json:"old_path"`
-	Raw       string `json:"raw"`
-	Bytes     int    `json:"bytes"`
-	IsEOF     bool   `json:"is_eof"`
-	Additions int    `json:"additions"`
-	Deletions int    `json:"deletions"`
+	Path        string            `json:"path"`
+	State       string            `json:"state"`
+	OldPath     string            `json:"old_path"`
+	Raw         string            `json:"raw"`
+	Bytes       int               `json:"bytes"`
+	IsEOF       bool              `json:"is_eof"`
+	Additions   int               `json:"additions"`
+	Deletions   int               `json:"deletions"`
+	IsBinary    bool              `json:"is_binary"`
+	ContentType string            `json:"content_type"`
+	Attributes  map[string]string `json:"attributes"`
 }
 
 type filteredFileRaw struct {
-	Path    string `json:"path"`
-	State   string `json:"state"`
-	OldPath string `json:"old_path"`
-	Bytes   int    `json:"bytes"`
-	IsEOF   bool   `json:"is_eof"`
+	Path        string `json:"path"`
+	State       string `json:"state"`
+	OldPath     string `json:"old_path"`
+	Bytes       int    `json:"bytes"`
+	IsEOF       bool   `json:"is_eof"`
+	IsBinary    bool   `json:"is_binary"`
+	ContentType string `json:"content_type"`
 }
 
 type branchDiffResponse struct {
 	Branch        string            `json:"branch"`
 	Base          string            `json:"base"`
+	MergeBaseSHA  string            `json:"merge_base_sha"`
 	Stats         diffStatsRaw      `json:"stats"`
 	Files         []fileDiffRaw     `json:"files"`
 	FilteredFiles []filteredFileRaw `json:"filtered_files"`
+	NextCursor    string            `json:"next_cursor"`
+	HasMore       bool              `json:"has_more"`
+}
+
+type compareDiffResponse struct {
+	branchDiffResponse
+	Head string `json:"head"`
 }
 
 type commitDiffResponse struct {
@@ -132,6 +344,8 @@ type commitDiffResponse struct {
 	Stats         diffStatsRaw      `json:"stats"`
 	Files         []fileDiffRaw     `json:"files"`
 	FilteredFiles []filteredFileRaw `json:"filtered_files"`
+	NextCursor    string            `json:"next_cursor"`
+	HasMore       bool              `json:"has_more"`
 }
 
 type createBranchResponse struct {
@@ -139,25 +353,74 @@ type createBranchResponse struct {
 	TargetBranch      string `json:"target_branch"`
 	TargetIsEphemeral bool   `json:"target_is_ephemeral"`
 	CommitSHA         string `json:"commit_sha"`
+	AlreadyExisted    bool   `json:"already_existed"`
+}
+
+type tagDetailsResponse struct {
+	Name          string      `json:"name"`
+	TargetSHA     string      `json:"target_sha"`
+	TargetType    string      `json:"target_type"`
+	CommitSHA     string      `json:"commit_sha"`
+	TagSHA        string      `json:"tag_sha"`
+	Message       string      `json:"message"`
+	Tagger        *authorInfo `json:"tagger"`
+	TaggedAt      string      `json:"tagged_at"`
+	Signature     string      `json:"signature"`
+	SignedPayload string      `json:"signed_payload"`
+	Verification  *struct {
+		Verified bool   `json:"verified"`
+		Reason   string `json:"reason"`
+		KeyID    string `json:"key_id"`
+		Signer   string `json:"signer"`
+	} `json:"verification"`
+}
+
+type createTagResponse struct {
+	Name      string `json:"name"`
+	TargetSHA string `json:"target_sha"`
+	TagSHA    string `json:"tag_sha"`
+	Annotated bool   `json:"annotated"`
+}
+
+type deleteTagResponse struct {
+	Name   string `json:"name"`
+	Result struct {
+		Ref     string `json:"ref"`
+		OldSHA  string `json:"old_sha"`
+		NewSHA  string `json:"new_sha"`
+		Success bool   `json:"success"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"result"`
 }
 
 type grepResponse struct {
 	Query struct {
 		Pattern       string `json:"pattern"`
 		CaseSensitive bool   `json:"case_sensitive"`
+		InvertMatch   bool   `json:"invert_match"`
 	} `json:"query"`
 	Repo struct {
 		Ref    string `json:"ref"`
 		Commit string `json:"commit"`
 	} `json:"repo"`
+	Refs       []grepRefRaw       `json:"refs"`
 	Matches    []grepFileMatchRaw `json:"matches"`
 	NextCursor string             `json:"next_cursor"`
 	HasMore    bool               `json:"has_more"`
 }
 
+type grepRefRaw struct {
+	Ref    string `json:"ref"`
+	Commit string `json:"commit"`
+}
+
 type grepFileMatchRaw struct {
-	Path  string        `json:"path"`
-	Lines []grepLineRaw `json:"lines"`
+	Path     string        `json:"path"`
+	Lines    []grepLineRaw `json:"lines"`
+	IsBinary bool          `json:"is_binary"`
+	Ref      string        `json:"ref"`
+	Commit   string        `json:"commit"`
 }
 
 type grepLineRaw struct {
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const resumeTokenVersion = 1
+
+// ResumeTokenKind identifies the listing a ResumeToken belongs to.
+type ResumeTokenKind string
+
+const (
+	ResumeTokenCommits  ResumeTokenKind = "commits"
+	ResumeTokenBranches ResumeTokenKind = "branches"
+	ResumeTokenRepos    ResumeTokenKind = "repos"
+)
+
+// ErrResumeRefMoved is returned when a commit listing is resumed after the
+// listed branch moved, so the remaining pages would no longer line up with
+// the ones already consumed.
+var ErrResumeRefMoved = errors.New("resume token is stale: the listed ref has moved")
+
+// ResumeToken checkpoints a paginated listing so it can be continued after a
+// process restart. Its string form is stable, URL-safe, and round-trips
+// through String and ParseResumeToken, or MarshalText and UnmarshalText.
+type ResumeToken struct {
+	Kind   ResumeTokenKind
+	RepoID string
+	Cursor string
+	// Branch and Order pin the commit listing being resumed.
+	Branch string
+	Order  CommitOrder
+	// FirstSHA is the first commit of the listing when it started. Resuming
+	// fails with ErrResumeRefMoved when it no longer matches.
+	FirstSHA string
+}
+
+type resumeTokenPayload struct {
+	Version  int             `json:"v"`
+	Kind     ResumeTokenKind `json:"k"`
+	RepoID   string          `json:"r,omitempty"`
+	Cursor   string          `json:"c"`
+	Branch   string          `json:"b,omitempty"`
+	Order    CommitOrder     `json:"o,omitempty"`
+	FirstSHA string          `json:"f,omitempty"`
+}
+
+// String encodes the token for storage.
+func (t ResumeToken) String() string {
+	data, _ := json.Marshal(resumeTokenPayload{
+		Version:  resumeTokenVersion,
+		Kind:     t.Kind,
+		RepoID:   t.RepoID,
+		Cursor:   t.Cursor,
+		Branch:   t.Branch,
+		Order:    t.Order,
+		FirstSHA: t.FirstSHA,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseResumeToken decodes a token produced by ResumeToken.String.
+func ParseResumeToken(value string) (ResumeToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return ResumeToken{}, fmt.Errorf("invalid resume token: %w", err)
+	}
+	var payload resumeTokenPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return ResumeToken{}, fmt.Errorf("invalid resume token: %w", err)
+	}
+	if payload.Version != resumeTokenVersion {
+		return ResumeToken{}, fmt.Errorf("unsupported resume token version %d", payload.Version)
+	}
+	switch payload.Kind {
+	case ResumeTokenCommits, ResumeTokenBranches, ResumeTokenRepos:
+	default:
+		return ResumeToken{}, fmt.Errorf("invalid resume token kind %q", payload.Kind)
+	}
+	if payload.Cursor == "" {
+		return ResumeToken{}, errors.New("invalid resume token: cursor is empty")
+	}
+	return ResumeToken{
+		Kind:     payload.Kind,
+		RepoID:   payload.RepoID,
+		Cursor:   payload.Cursor,
+		Branch:   payload.Branch,
+		Order:    payload.Order,
+		FirstSHA: payload.FirstSHA,
+	}, nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t ResumeToken) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *ResumeToken) UnmarshalText(text []byte) error {
+	parsed, err := ParseResumeToken(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// checkResumeToken verifies a token belongs to the listing it resumes.
+func checkResumeToken(token *ResumeToken, kind ResumeTokenKind, repoID string, cursor string) error {
+	if token.Kind != kind {
+		return fmt.Errorf("resume token is for %s, not %s", token.Kind, kind)
+	}
+	if token.RepoID != repoID {
+		return errors.New("resume token belongs to a different repository")
+	}
+	if cursor != "" {
+		return errors.New("resume token and cursor are mutually exclusive")
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResumeTokenRoundTrip(t *testing.T) {
+	token := ResumeToken{Kind: ResumeTokenCommits, RepoID: "repo", Cursor: "c2", Branch: "main", Order: CommitOrderTopo, FirstSHA: "abc"}
+	parsed, err := ParseResumeToken(token.String())
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if parsed != token {
+		t.Fatalf("unexpected token: %+v", parsed)
+	}
+
+	data, err := json.Marshal(struct {
+		Token ResumeToken `json:"token"`
+	}{token})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	var decoded struct {
+		Token ResumeToken `json:"token"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if decoded.Token != token {
+		t.Fatalf("unexpected decoded token: %+v", decoded.Token)
+	}
+
+	if _, err := ParseResumeToken("not a token"); err == nil {
+		t.Fatalf("expected parse error")
+	}
+}
+
+func TestListCommitsResumeToken(t *testing.T) {
+	head := "head1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("branch") != "main" || query.Get("order") != "topo" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case query.Get("limit") == "1":
+			_, _ = w.Write([]byte(`{"commits":[{"sha":"` + head + `"}],"next_cursor":"x","has_more":true}`))
+		case query.Get("cursor") == "":
+			_, _ = w.Write([]byte(`{"commits":[{"sha":"head1"},{"sha":"c1"}],"next_cursor":"page2","has_more":true}`))
+		case query.Get("cursor") == "page2":
+			_, _ = w.Write([]byte(`{"commits":[{"sha":"c2"}],"has_more":false}`))
+		default:
+			t.Errorf("unexpected cursor: %s", query.Get("cursor"))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	first, err := repo.ListCommits(nil, ListCommitsOptions{Branch: "main", Order: CommitOrderTopo})
+	if err != nil {
+		t.Fatalf("list commits error: %v", err)
+	}
+	if first.ResumeToken == nil || first.ResumeToken.FirstSHA != "head1" || first.ResumeToken.Cursor != "page2" {
+		t.Fatalf("unexpected resume token: %+v", first.ResumeToken)
+	}
+
+	token, err := ParseResumeToken(first.ResumeToken.String())
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	second, err := repo.ListCommits(nil, ListCommitsOptions{Resume: &token})
+	if err != nil {
+		t.Fatalf("resume error: %v", err)
+	}
+	if len(second.Commits) != 1 || second.Commits[0].SHA != "c2" || second.ResumeToken != nil {
+		t.Fatalf("unexpected resumed page: %+v", second)
+	}
+
+	head = "head2"
+	if _, err := repo.ListCommits(nil, ListCommitsOptions{Resume: &token}); !errors.Is(err, ErrResumeRefMoved) {
+		t.Fatalf("expected ErrResumeRefMoved, got %v", err)
+	}
+
+	other := &Repo{ID: "other", client: client}
+	if _, err := other.ListCommits(nil, ListCommitsOptions{Resume: &token}); err == nil {
+		t.Fatalf("expected repository mismatch error")
+	}
+	if _, err := repo.ListBranches(nil, ListBranchesOptions{Resume: &token}); err == nil {
+		t.Fatalf("expected kind mismatch error")
+	}
+}
+
+func TestListBranchesResumeToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("cursor") == "" {
+			_, _ = w.Write([]byte(`{"branches":[{"cursor":"b1","name":"main","head_sha":"abc","created_at":"2024-01-01T00:00:00Z"}],"next_cursor":"b1","has_more":true}`))
+			return
+		}
+		if r.URL.Query().Get("cursor") != "b1" {
+			t.Errorf("unexpected cursor: %s", r.URL.Query().Get("cursor"))
+		}
+		_, _ = w.Write([]byte(`{"branches":[],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	first, err := repo.ListBranches(nil, ListBranchesOptions{})
+	if err != nil {
+		t.Fatalf("list branches error: %v", err)
+	}
+	if first.ResumeToken == nil || first.ResumeToken.Kind != ResumeTokenBranches {
+		t.Fatalf("unexpected resume token: %+v", first.ResumeToken)
+	}
+	if _, err := repo.ListBranches(nil, ListBranchesOptions{Resume: first.ResumeToken}); err != nil {
+		t.Fatalf("resume error: %v", err)
+	}
+	if _, err := repo.ListBranches(nil, ListBranchesOptions{Resume: first.ResumeToken, Cursor: "b1"}); err == nil {
+		t.Fatalf("expected cursor conflict error")
+	}
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// reservedTokenClaims are set by the SDK and cannot be overridden through
+// MintTokenOptions.ExtraClaims.
+var reservedTokenClaims = map[string]bool{"iss": true, "sub": true, "repo": true, "scopes": true, "iat": true, "exp": true}
+
+// MintTokenOptions configures MintToken.
+type MintTokenOptions struct {
+	// RepoID is the repository the token grants access to, or "org" for
+	// org-level operations such as ListRepos.
+	RepoID string
+	// Scopes defaults to git:read and git:write.
+	Scopes []Permission
+	// TTL defaults to Options.DefaultTTL, or one year when unset.
+	TTL time.Duration
+	// Actor is the token's subject, such as the end user or agent acting
+	// through it. It defaults to the client's WithActor actor, or
+	// "@pierre/storage".
+	Actor string
+	// ExtraClaims are added to the token. The standard claims iss, sub,
+	// repo, scopes, iat, and exp cannot be set here.
+	ExtraClaims map[string]interface{}
+}
+
+// MintedToken is a signed API token.
+type MintedToken struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// MintToken signs an API token with the client's key, the same way the SDK
+// authenticates its own requests. Use it to call the HTTP API directly or
+// to hand scoped credentials to another process.
+func (c *Client) MintToken(ctx context.Context, options MintTokenOptions) (MintedToken, error) {
+	if ctx != nil && ctx.Err() != nil {
+		return MintedToken{}, ctx.Err()
+	}
+	repoID := strings.TrimSpace(options.RepoID)
+	if repoID == "" {
+		return MintedToken{}, errors.New("mintToken repoID is required")
+	}
+	if options.TTL < 0 {
+		return MintedToken{}, errors.New("mintToken ttl must not be negative")
+	}
+	for key := range options.ExtraClaims {
+		if reservedTokenClaims[key] {
+			return MintedToken{}, errors.New("mintToken extraClaims must not set " + key)
+		}
+	}
+
+	token, expiresAt, err := c.signJWT(repoID, options.Scopes, options.TTL, options.Actor, options.ExtraClaims)
+	if err != nil {
+		return MintedToken{}, err
+	}
+	return MintedToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// VerifyTokenOptions configures VerifyToken. Empty fields are not checked.
+type VerifyTokenOptions struct {
+	// RepoID is the repository, or "org", the token must be for.
+	RepoID string
+	// Scopes lists permissions the token must all grant.
+	Scopes []Permission
+	// Issuer is the org name that must have minted the token.
+	Issuer string
+	// Leeway tolerates clock skew when checking exp and iat.
+	Leeway time.Duration
+}
+
+// TokenClaims are the claims of a verified token. Extra holds any claims
+// beyond the standard ones, such as MintTokenOptions.ExtraClaims.
+type TokenClaims struct {
+	Issuer    string
+	Subject   string
+	RepoID    string
+	Scopes    []Permission
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	Extra     map[string]interface{}
+}
+
+// HasScope reports whether the token grants permission.
+func (c TokenClaims) HasScope(permission Permission) bool {
+	for _, scope := range c.Scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyToken checks a token minted by MintToken or another SDK client
+// against the minting key's public half: its ES256 signature, expiry, and
+// the repository, scopes, and issuer in options. Signature and expiry
+// failures wrap the jwt package's errors, such as jwt.ErrTokenExpired.
+func VerifyToken(token string, publicKey crypto.PublicKey, options VerifyTokenOptions) (TokenClaims, error) {
+	key, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok || key == nil {
+		return TokenClaims{}, errors.New("verifyToken publicKey must be an ECDSA P-256 public key")
+	}
+
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(options.Leeway),
+	}
+	if options.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(options.Issuer))
+	}
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(strings.TrimSpace(token), claims, func(*jwt.Token) (interface{}, error) {
+		return key, nil
+	}, parserOptions...); err != nil {
+		return TokenClaims{}, fmt.Errorf("verifyToken: %w", err)
+	}
+
+	result := TokenClaims{Extra: map[string]interface{}{}}
+	result.Issuer, _ = claims["iss"].(string)
+	result.Subject, _ = claims["sub"].(string)
+	result.RepoID, _ = claims["repo"].(string)
+	if issuedAt, err := claims.GetIssuedAt(); err == nil && issuedAt != nil {
+		result.IssuedAt = issuedAt.Time
+	}
+	if expiresAt, err := claims.GetExpirationTime(); err == nil && expiresAt != nil {
+		result.ExpiresAt = expiresAt.Time
+	}
+	scopes, _ := claims["scopes"].([]interface{})
+	for _, scope := range scopes {
+		if value, ok := scope.(string); ok {
+			result.Scopes = append(result.Scopes, Permission(value))
+		}
+	}
+	for key, value := range claims {
+		if !reservedTokenClaims[key] {
+			result.Extra[key] = value
+		}
+	}
+
+	if options.RepoID != "" && result.RepoID != options.RepoID {
+		return TokenClaims{}, fmt.Errorf("verifyToken token is for repo %q, not %q", result.RepoID, options.RepoID)
+	}
+	for _, scope := range options.Scopes {
+		if !result.HasScope(scope) {
+			return TokenClaims{}, fmt.Errorf("verifyToken token lacks scope %s", scope)
+		}
+	}
+	return result, nil
+}
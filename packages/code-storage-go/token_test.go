@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestMintToken(t *testing.T) {
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: "https://api.example.com"})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+
+	minted, err := client.MintToken(nil, MintTokenOptions{
+		RepoID:      "repo_1",
+		Scopes:      []Permission{PermissionGitRead},
+		TTL:         time.Hour,
+		ExtraClaims: map[string]interface{}{"session": "sess_1"},
+	})
+	if err != nil {
+		t.Fatalf("mint token error: %v", err)
+	}
+	claims := parseJWTFromToken(t, minted.Token)
+	scopes, _ := claims["scopes"].([]interface{})
+	if claims["iss"] != "acme" || claims["repo"] != "repo_1" || len(scopes) != 1 || scopes[0] != "git:read" || claims["session"] != "sess_1" {
+		t.Fatalf("unexpected claims: %v", claims)
+	}
+	if exp, _ := claims["exp"].(float64); int64(exp) != minted.ExpiresAt.Unix() {
+		t.Fatalf("expected ExpiresAt to match exp claim, got %v and %v", exp, minted.ExpiresAt)
+	}
+	if remaining := time.Until(minted.ExpiresAt); remaining < 59*time.Minute || remaining > time.Hour {
+		t.Fatalf("unexpected expiry: %v", minted.ExpiresAt)
+	}
+
+	if _, err := client.MintToken(nil, MintTokenOptions{RepoID: "repo_1", ExtraClaims: map[string]interface{}{"repo": "other"}}); err == nil {
+		t.Fatalf("expected reserved claim error")
+	}
+	if _, err := client.MintToken(nil, MintTokenOptions{}); err == nil {
+		t.Fatalf("expected repo id error")
+	}
+}
+
+func TestDelegatedActorTokens(t *testing.T) {
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: "https://api.example.com", StorageBaseURL: "git.example.com"})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	subject := func(remote string) interface{} {
+		t.Helper()
+		u, err := url.Parse(remote)
+		if err != nil {
+			t.Fatalf("parse remote url: %v", err)
+		}
+		password, _ := u.User.Password()
+		return parseJWTFromToken(t, password)["sub"]
+	}
+
+	agent := client.WithActor("agent-7")
+	repo, err := agent.Repo(RepoOptions{ID: "repo_1"})
+	if err != nil {
+		t.Fatalf("repo error: %v", err)
+	}
+	remote, err := repo.RemoteURL(nil, RemoteURLOptions{})
+	if err != nil {
+		t.Fatalf("remote url error: %v", err)
+	}
+	if sub := subject(remote); sub != "agent-7" {
+		t.Fatalf("expected client actor as subject, got %v", sub)
+	}
+	if remote, _ = repo.RemoteURL(nil, RemoteURLOptions{Actor: "user_42"}); subject(remote) != "user_42" {
+		t.Fatalf("expected per-URL actor to override the client actor")
+	}
+
+	minted, err := client.MintToken(nil, MintTokenOptions{RepoID: "repo_1", Actor: "user_42"})
+	if err != nil {
+		t.Fatalf("mint token error: %v", err)
+	}
+	if sub := parseJWTFromToken(t, minted.Token)["sub"]; sub != "user_42" {
+		t.Fatalf("expected minted subject, got %v", sub)
+	}
+
+	original, _ := client.Repo(RepoOptions{ID: "repo_1"})
+	if remote, _ = original.RemoteURL(nil, RemoteURLOptions{}); subject(remote) != "@pierre/storage" {
+		t.Fatalf("expected WithActor not to modify the original client")
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: "https://api.example.com"})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	privateKey, err := parseECPrivateKey([]byte(testKey))
+	if err != nil {
+		t.Fatalf("parse key: %v", err)
+	}
+	publicKey := &privateKey.PublicKey
+
+	minted, err := client.MintToken(nil, MintTokenOptions{RepoID: "repo_1", Scopes: []Permission{PermissionGitRead, PermissionGitWrite}, TTL: time.Hour, Actor: "agent-7", ExtraClaims: map[string]interface{}{"session": "sess_1"}})
+	if err != nil {
+		t.Fatalf("mint token error: %v", err)
+	}
+	claims, err := VerifyToken(minted.Token, publicKey, VerifyTokenOptions{RepoID: "repo_1", Scopes: []Permission{PermissionGitWrite}, Issuer: "acme"})
+	if err != nil {
+		t.Fatalf("verify token error: %v", err)
+	}
+	if claims.Issuer != "acme" || claims.Subject != "agent-7" || claims.RepoID != "repo_1" || !claims.HasScope(PermissionGitRead) || !claims.ExpiresAt.Equal(minted.ExpiresAt) || claims.IssuedAt.IsZero() {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+	if len(claims.Extra) != 1 || claims.Extra["session"] != "sess_1" {
+		t.Fatalf("unexpected extra claims: %v", claims.Extra)
+	}
+
+	for name, options := range map[string]VerifyTokenOptions{
+		"repo":   {RepoID: "repo_2"},
+		"scope":  {Scopes: []Permission{PermissionOrgWrite}},
+		"issuer": {Issuer: "other"},
+	} {
+		if _, err := VerifyToken(minted.Token, publicKey, options); err == nil {
+			t.Fatalf("expected %s mismatch to fail", name)
+		}
+	}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	if _, err := VerifyToken(minted.Token, &otherKey.PublicKey, VerifyTokenOptions{}); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"iss": "acme", "repo": "repo_1", "iat": time.Now().Add(-2 * time.Hour).Unix(), "exp": time.Now().Add(-time.Hour).Unix()}).SignedString(privateKey)
+	if err != nil {
+		t.Fatalf("sign expired token: %v", err)
+	}
+	if _, err := VerifyToken(expired, publicKey, VerifyTokenOptions{}); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Fatalf("expected expiry error, got %v", err)
+	}
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"repo": "repo_1", "exp": time.Now().Add(time.Hour).Unix()}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("sign forged token: %v", err)
+	}
+	if _, err := VerifyToken(forged, publicKey, VerifyTokenOptions{}); err == nil {
+		t.Fatalf("expected non-ES256 token to fail")
+	}
+}
@@ -17,6 +17,7 @@ const (
 	PermissionGitWrite  Permission = "git:write"
 	PermissionRepoWrite Permission = "repo:write"
 	PermissionOrgRead   Permission = "org:read"
+	PermissionOrgWrite  Permission = "org:write"
 )
 
 // Options configure the Git storage client.
@@ -25,20 +26,82 @@ type Options struct {
 	Key            string
 	APIBaseURL     string
 	StorageBaseURL string
-	APIVersion     int
-	DefaultTTL     time.Duration
-	HTTPClient     *http.Client
+	// StorageScheme overrides the git remote URL scheme (default https),
+	// e.g. http for local emulators.
+	StorageScheme string
+	// StoragePort overrides the git remote URL port. Zero keeps the port,
+	// if any, from StorageBaseURL.
+	StoragePort int
+	APIVersion  int
+	DefaultTTL  time.Duration
+	HTTPClient  *http.Client
+	RateLimiter RateLimiter
+	// BlobCache serves and stores Repo.GetBlob contents. Path-based reads
+	// such as FileStream are not cached.
+	BlobCache BlobCache
+	// Endpoints lists secondary API regions used when APIBaseURL is
+	// unreachable. Reads fail over automatically; writes only when
+	// FailoverWrites is set.
+	Endpoints        []EndpointConfig
+	FailoverWrites   bool
+	FailoverCooldown time.Duration
+	// DeduplicateReads shares one in-flight response among concurrent
+	// identical GET requests for the same repository. Shared response
+	// bodies are buffered in memory, so streaming reads such as FileStream,
+	// GetBlob, GetCommitPatch, and GetLFSObject are never shared.
+	DeduplicateReads bool
+	// DryRun validates mutating calls and returns them as *DryRunResult
+	// errors instead of sending them. Reads still reach the API.
+	DryRun bool
+	// OnDryRun, if set, receives every request skipped by DryRun.
+	OnDryRun func(DryRunResult)
 }
 
 // RemoteURLOptions configure token generation for remote URLs.
 type RemoteURLOptions struct {
 	Permissions []Permission
 	TTL         time.Duration
+	// Actor names the end user or agent the URL is issued for, so pushes
+	// and fetches through it are attributed to them. It overrides the
+	// client's WithActor actor.
+	Actor string
 }
 
+// DiffComparison selects how a branch or ref diff compares its two sides.
+type DiffComparison string
+
+const (
+	// DiffComparisonMergeBase diffs the head against its merge base with the
+	// base, like git diff base...head, so only the head's own changes appear.
+	DiffComparisonMergeBase DiffComparison = "merge_base"
+	// DiffComparisonDirect diffs the two tips directly, like git diff
+	// base..head, so changes made on the base since it diverged show up
+	// reversed.
+	DiffComparisonDirect DiffComparison = "direct"
+)
+
+// ReadPreference selects which replicas may serve a read.
+type ReadPreference string
+
+const (
+	ReadPreferencePrimary   ReadPreference = "primary"
+	ReadPreferenceReplicaOK ReadPreference = "replica-ok"
+	ReadPreferenceNearest   ReadPreference = "nearest"
+)
+
 // InvocationOptions holds common request options.
 type InvocationOptions struct {
 	TTL time.Duration
+	// TTLFromContext, when TTL is unset, expires the token shortly after the
+	// context deadline so short operations get short-lived credentials.
+	// Without a deadline the token lives for MaxTTL.
+	TTLFromContext bool
+	// MaxTTL caps a context-derived TTL. Defaults to the operation's usual
+	// token lifetime.
+	MaxTTL time.Duration
+	// ReadPreference lets latency-tolerant reads be served by replicas.
+	// Ignored by write endpoints.
+	ReadPreference ReadPreference
 }
 
 // FindOneOptions identifies a repository by ID.
@@ -111,6 +174,8 @@ type RepoInfo struct {
 	DefaultBranch string
 	CreatedAt     string
 	BaseRepo      *RepoBaseInfo
+	// LegalHold is set while the repository is under legal hold.
+	LegalHold *LegalHold
 }
 
 // ListReposOptions controls list repos.
@@ -118,6 +183,9 @@ type ListReposOptions struct {
 	InvocationOptions
 	Cursor string
 	Limit  int
+	// Resume continues a listing from a persisted ResumeToken instead of
+	// Cursor.
+	Resume *ResumeToken
 }
 
 // ListReposResult returns paginated repos.
@@ -125,6 +193,8 @@ type ListReposResult struct {
 	Repos      []RepoInfo
 	NextCursor string
 	HasMore    bool
+	// ResumeToken checkpoints the next page; nil on the last page.
+	ResumeToken *ResumeToken
 }
 
 // CreateRepoOptions controls repo creation.
@@ -133,6 +203,20 @@ type CreateRepoOptions struct {
 	ID            string
 	BaseRepo      BaseRepo
 	DefaultBranch string
+	// WaitReady makes CreateRepo poll until the default branch of a forked
+	// or imported repository is readable. It has no effect without BaseRepo.
+	WaitReady *WaitReadyOptions
+	// SkipOrgDefaults creates the repository without applying the
+	// organization's OrgRepoDefaults.
+	SkipOrgDefaults bool
+}
+
+// WaitReadyOptions configures CreateRepo readiness polling.
+type WaitReadyOptions struct {
+	// Timeout bounds the total wait. Defaults to 60s.
+	Timeout time.Duration
+	// PollInterval is the delay between checks. Defaults to 500ms.
+	PollInterval time.Duration
 }
 
 // DeleteRepoOptions controls repo deletion.
@@ -154,16 +238,180 @@ type GetFileOptions struct {
 	Ref           string
 	Ephemeral     *bool
 	EphemeralBase *bool
+	// FollowSymlinks reads the resolved target content when true, and the
+	// link target path when false. The server default applies when nil.
+	FollowSymlinks *bool
+	// ResolveSubmodules reads paths inside submodules that point at another
+	// repository in the same organization, at the pinned commit.
+	// FileStreamSubmoduleRepo reports which repository served it. It
+	// requires SubmoduleRepos.
+	ResolveSubmodules bool
+	// SubmoduleRepos lists the repositories submodules may be read from.
+	// The request token is granted git:read on each of them; a path inside
+	// a submodule pointing anywhere else is not found.
+	SubmoduleRepos []string
+}
+
+// FileEntryType describes the kind of tree entry at a path.
+type FileEntryType string
+
+const (
+	FileEntryTypeFile      FileEntryType = "file"
+	FileEntryTypeSymlink   FileEntryType = "symlink"
+	FileEntryTypeSubmodule FileEntryType = "submodule"
+	FileEntryTypeUnknown   FileEntryType = "unknown"
+)
+
+// GetBlobOptions configures raw blob download by object SHA.
+type GetBlobOptions struct {
+	InvocationOptions
+	SHA string
+}
+
+// GetCommitPatchOptions configures GetCommitPatch.
+type GetCommitPatchOptions struct {
+	InvocationOptions
+	SHA string
+	// BaseSHA, when set, produces one patch per commit in BaseSHA..SHA
+	// instead of a single patch for SHA.
+	BaseSHA string
+}
+
+// LFSPointer identifies a Git LFS object referenced by a pointer file.
+type LFSPointer struct {
+	Version string
+	OID     string
+	Size    int64
+}
+
+// GetLFSObjectOptions configures LFS object download.
+type GetLFSObjectOptions struct {
+	InvocationOptions
+	OID string
 }
 
 // ArchiveOptions configures repository archive download.
 type ArchiveOptions struct {
 	InvocationOptions
-	Ref           string
-	IncludeGlobs  []string
-	ExcludeGlobs  []string
-	MaxBlobSize   *int64
+	Ref string
+	// SHA pins the archive to a full commit SHA for reproducible builds. It
+	// cannot be combined with Ref. ArchiveStreamCommitSHA reports the
+	// commit that was archived.
+	SHA string
+	// Path limits the archive to a subtree. Entry paths are rebased so the
+	// subtree contents sit at the archive root (before ArchivePrefix).
+	Path string
+	// Paths limits the archive to an explicit list of files, sent in the
+	// request body so thousands of entries fit in one call. It cannot be
+	// combined with Path.
+	Paths        []string
+	IncludeGlobs []string
+	ExcludeGlobs []string
+	MaxBlobSize  *int64
+	// ArchivePrefix may contain {repo}, {ref}, {sha}, and {short_sha}
+	// placeholders, expanded by the SDK before the request is sent.
 	ArchivePrefix string
+	// Format selects the archive container. Defaults to ArchiveFormatTarGz.
+	Format ArchiveFormat
+	// Compression selects tarball compression. When the server does not
+	// support the requested codec the request is retried with gzip; use
+	// ArchiveStreamCompression to see which codec was served.
+	Compression ArchiveCompression
+	// IncludeSubmodules inlines the contents of submodules the backend can
+	// resolve, recursively. Submodules in other repositories are inlined
+	// only when listed in SubmoduleRepos. Others stay empty directories;
+	// ListSubmodules reports their paths and pinned SHAs.
+	IncludeSubmodules bool
+	// SubmoduleRepos lists the repositories IncludeSubmodules may read
+	// from. The request token is granted git:read on each of them.
+	SubmoduleRepos []string
+	// IfNoneMatch makes the request conditional on the ETag of a previous
+	// archive. When it still matches, ArchiveStream returns a response with
+	// status 304 Not Modified and an empty body.
+	IfNoneMatch string
+	// MaxVolumeBytes asks the server to split the archive into sequential
+	// volumes of at most this many bytes and return the one selected by
+	// Volume. ArchiveStreamVolumeCount reports how many volumes there are;
+	// DownloadArchiveVolumes fetches them all.
+	MaxVolumeBytes int64
+	// Volume is the zero-based volume to return when MaxVolumeBytes is set.
+	Volume int
+}
+
+// ArchiveCompression is the compression codec of a tar archive.
+type ArchiveCompression string
+
+const (
+	ArchiveCompressionGzip ArchiveCompression = "gzip"
+	ArchiveCompressionZstd ArchiveCompression = "zstd"
+)
+
+// ArchiveFormat is the container format of a repository archive.
+type ArchiveFormat string
+
+const (
+	ArchiveFormatTarGz ArchiveFormat = "tar.gz"
+	ArchiveFormatZip   ArchiveFormat = "zip"
+)
+
+// DownloadRetryPolicy controls how resumable downloads retry after a
+// dropped connection or a retryable server error.
+type DownloadRetryPolicy struct {
+	// MaxRetries bounds the retries made over the whole download. Defaults to 3.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry. Defaults to 250ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponential backoff delay. Defaults to 5s.
+	MaxBackoff time.Duration
+}
+
+// DownloadProgressFunc reports bytes received so far against the total
+// size from Content-Length, or -1 when the size is unknown.
+type DownloadProgressFunc func(received int64, total int64)
+
+// DownloadFileOptions configures a resumable file download.
+type DownloadFileOptions struct {
+	GetFileOptions
+	Retry      DownloadRetryPolicy
+	OnProgress DownloadProgressFunc
+}
+
+// DownloadArchiveOptions configures a resumable archive download.
+type DownloadArchiveOptions struct {
+	ArchiveOptions
+	Retry      DownloadRetryPolicy
+	OnProgress DownloadProgressFunc
+}
+
+// ParallelArchiveDownloadOptions configures DownloadArchiveParallel.
+type ParallelArchiveDownloadOptions struct {
+	ArchiveOptions
+	// Segments is the number of concurrent range requests. Defaults to 4.
+	Segments int
+	// MinSegmentSize keeps small archives from being split into tiny
+	// requests. Defaults to 8 MiB.
+	MinSegmentSize int64
+	// Retry applies to each segment independently.
+	Retry      DownloadRetryPolicy
+	OnProgress DownloadProgressFunc
+}
+
+// ParallelDownloadResult describes a completed DownloadArchiveParallel call.
+type ParallelDownloadResult struct {
+	// Size is the number of bytes written.
+	Size int64
+	// Segments is the number of ranges fetched, or 1 when the server did not
+	// support ranges and the archive was streamed sequentially.
+	Segments int
+	// NotModified is set when ArchiveOptions.IfNoneMatch matched and nothing
+	// was written.
+	NotModified bool
+	// Header holds the headers of the first response.
+	Header http.Header
+	// SHA256 is the server-provided archive digest, or "" when there is
+	// none. It has been verified against the written bytes unless the
+	// archive was fetched in segments into a dst that is not an io.ReaderAt.
+	SHA256 string
 }
 
 // PullUpstreamOptions configures pull-upstream.
@@ -177,12 +425,54 @@ type ListFilesOptions struct {
 	InvocationOptions
 	Ref       string
 	Ephemeral *bool
+	// ResolveSubmodules lists the contents of same-organization submodules
+	// in place of their gitlink entries, as if they were one tree. It
+	// requires SubmoduleRepos.
+	ResolveSubmodules bool
+	// SubmoduleRepos lists the repositories submodules may be read from.
+	// The request token is granted git:read on each of them; submodules
+	// pointing anywhere else stay gitlink entries.
+	SubmoduleRepos []string
 }
 
 // ListFilesResult describes file list.
 type ListFilesResult struct {
 	Paths []string
 	Ref   string
+	// TreeSHA and CommitSHA identify the resolved snapshot and are suitable
+	// as immutable cache keys.
+	TreeSHA   string
+	CommitSHA string
+}
+
+// SearchFilesOptions configures SearchFiles.
+type SearchFilesOptions struct {
+	InvocationOptions
+	// Query is matched fuzzily against file paths, so "rpgo" finds
+	// "repo.go" and "src/rgo" finds "src/router/go.mod".
+	Query     string
+	Ref       string
+	Ephemeral *bool
+	// PathPrefix restricts the search to a directory.
+	PathPrefix string
+	// Limit caps the number of matches. Zero uses the server default.
+	Limit int
+}
+
+// SearchFilesResult lists paths ranked by how well they match the query,
+// best first.
+type SearchFilesResult struct {
+	Ref       string
+	CommitSHA string
+	Matches   []FileSearchMatch
+}
+
+// FileSearchMatch is a path found by SearchFiles. Positions holds the byte
+// offsets in Path of the matched query characters, for highlighting.
+type FileSearchMatch struct {
+	Path      string
+	Score     float64
+	Positions []int
 }
 
 // ListFilesWithMetadataOptions configures list files with metadata.
@@ -190,14 +480,30 @@ type ListFilesWithMetadataOptions struct {
 	InvocationOptions
 	Ref       string
 	Ephemeral *bool
+	// ResolveSubmodules lists the contents of same-organization submodules
+	// in place of their gitlink entries, as if they were one tree. It
+	// requires SubmoduleRepos.
+	ResolveSubmodules bool
+	// SubmoduleRepos lists the repositories submodules may be read from.
+	// The request token is granted git:read on each of them; submodules
+	// pointing anywhere else stay gitlink entries.
+	SubmoduleRepos []string
 }
 
 // FileWithMetadata describes a file metadata entry.
 type FileWithMetadata struct {
 	Path          string
 	Mode          string
+	Type          FileEntryType
 	Size          int64
 	LastCommitSHA string
+	IsBinary      bool
+	ContentType   string
+	Attributes    map[string]string
+	// SubmoduleRepo is the ID of the repository the file was read from when
+	// it lies inside a resolved submodule; LastCommitSHA then refers to a
+	// commit in that repository. Empty for files of this repo.
+	SubmoduleRepo string
 }
 
 // CommitMetadata describes commit metadata for the files metadata response.
@@ -210,9 +516,113 @@ type CommitMetadata struct {
 
 // ListFilesWithMetadataResult describes files metadata response.
 type ListFilesWithMetadataResult struct {
-	Files   []FileWithMetadata
-	Commits map[string]CommitMetadata
-	Ref     string
+	Files     []FileWithMetadata
+	Commits   map[string]CommitMetadata
+	Ref       string
+	TreeSHA   string
+	CommitSHA string
+}
+
+// TreeStatsOptions configures directory size statistics.
+type TreeStatsOptions struct {
+	InvocationOptions
+	Ref  string
+	Path string
+	// Depth limits how many directory levels below Path are reported. Zero
+	// reports only Path itself.
+	Depth     int
+	Ephemeral *bool
+}
+
+// DirectoryStats aggregates every file beneath a directory, recursively.
+type DirectoryStats struct {
+	Path      string
+	FileCount int64
+	DirCount  int64
+	Bytes     int64
+	Depth     int
+}
+
+// TreeStatsResult describes directory size statistics.
+type TreeStatsResult struct {
+	Ref         string
+	CommitSHA   string
+	Directories []DirectoryStats
+}
+
+// ListDirectoriesOptions configures directory-only listing.
+type ListDirectoriesOptions struct {
+	InvocationOptions
+	Ref  string
+	Root string
+	// Depth limits how many levels below Root are listed. Defaults to 1,
+	// the immediate subdirectories.
+	Depth     int
+	Ephemeral *bool
+}
+
+// DirectoryEntry describes a directory and its immediate children.
+type DirectoryEntry struct {
+	Path  string
+	Name  string
+	Depth int
+	// FileCount and DirCount count direct children only.
+	FileCount int64
+	DirCount  int64
+}
+
+// ListDirectoriesResult describes directories under a root, ordered by path.
+type ListDirectoriesResult struct {
+	Ref         string
+	CommitSHA   string
+	Directories []DirectoryEntry
+}
+
+// LanguagesOptions configures language statistics.
+type LanguagesOptions struct {
+	InvocationOptions
+	Ref string
+}
+
+// LanguageStat describes the bytes attributed to one language.
+type LanguageStat struct {
+	Name  string
+	Bytes int64
+	// Percentage is the share of classified bytes, from 0 to 100.
+	Percentage float64
+}
+
+// LanguagesResult describes linguist-style language statistics, ordered by
+// descending byte count.
+type LanguagesResult struct {
+	Ref        string
+	CommitSHA  string
+	TotalBytes int64
+	Languages  []LanguageStat
+}
+
+// ListSubmodulesOptions configures submodule listing.
+type ListSubmodulesOptions struct {
+	InvocationOptions
+	Ref string
+}
+
+// Submodule describes a gitlink entry and the commit it pins.
+type Submodule struct {
+	Path string
+	SHA  string
+	// URL is the submodule URL from .gitmodules, when declared.
+	URL string
+	// Resolved reports whether the backend can serve the pinned commit, i.e.
+	// whether IncludeSubmodules will inline its contents.
+	Resolved bool
+}
+
+// ListSubmodulesResult describes the submodules at a ref, ordered by path.
+type ListSubmodulesResult struct {
+	Ref        string
+	CommitSHA  string
+	Submodules []Submodule
 }
 
 // ListBranchesOptions configures list branches.
@@ -220,6 +630,9 @@ type ListBranchesOptions struct {
 	InvocationOptions
 	Cursor string
 	Limit  int
+	// Resume continues a listing from a persisted ResumeToken instead of
+	// Cursor.
+	Resume *ResumeToken
 }
 
 // BranchInfo describes a branch.
@@ -235,6 +648,8 @@ type ListBranchesResult struct {
 	Branches   []BranchInfo
 	NextCursor string
 	HasMore    bool
+	// ResumeToken checkpoints the next page; nil on the last page.
+	ResumeToken *ResumeToken
 }
 
 // CreateBranchOptions configures branch creation.
@@ -244,23 +659,197 @@ type CreateBranchOptions struct {
 	TargetBranch      string
 	BaseIsEphemeral   bool
 	TargetIsEphemeral bool
+	// IfExists controls what happens when TargetBranch already exists.
+	// Defaults to BranchIfExistsError.
+	IfExists BranchIfExists
+}
+
+// ListTagsOptions configures list tags.
+type ListTagsOptions struct {
+	InvocationOptions
+	Cursor string
+	Limit  int
 }
 
+// TagInfo describes a tag. Message, Tagger and TagSHA are only set for
+// annotated tags.
+type TagInfo struct {
+	Cursor    string
+	Name      string
+	TargetSHA string
+	TagSHA    string
+	Message   string
+	Tagger    *CommitSignature
+	CreatedAt string
+}
+
+// ListTagsResult describes tags list.
+type ListTagsResult struct {
+	Tags       []TagInfo
+	NextCursor string
+	HasMore    bool
+}
+
+// GetTagOptions configures GetTag.
+type GetTagOptions struct {
+	InvocationOptions
+	Name string
+	// Verify asks the server to check the tag signature against the keys
+	// registered for the organization and fill in TagDetails.Verification.
+	Verify bool
+}
+
+// TagDetails describes a tag. For lightweight tags TagSHA, Message,
+// Tagger and the signature fields are empty.
+type TagDetails struct {
+	Name string
+	// TargetSHA is the object the tag points at and TargetType its kind,
+	// usually "commit". CommitSHA is the commit reached by peeling nested
+	// tags, or "" when the tag does not lead to a commit.
+	TargetSHA   string
+	TargetType  string
+	CommitSHA   string
+	TagSHA      string
+	Message     string
+	Tagger      *CommitSignature
+	TaggedAt    time.Time
+	RawTaggedAt string
+	// Signature is the armored signature of a signed tag and SignedPayload
+	// the tag object bytes it covers, for VerifySignature.
+	Signature     string
+	SignedPayload string
+	// Verification is set when GetTagOptions.Verify was requested.
+	Verification *TagVerification
+}
+
+// TagVerification is the server's verdict on a tag signature. Reason
+// explains a failed check, e.g. "unsigned" or "unknown_key".
+type TagVerification struct {
+	Verified bool
+	Reason   string
+	KeyID    string
+	Signer   string
+}
+
+// CreateTagOptions configures tag creation. SHA is the object the tag
+// points at, usually a commit.
+type CreateTagOptions struct {
+	InvocationOptions
+	Name string
+	SHA  string
+	// Annotated creates a tag object carrying Message and Tagger instead of
+	// a lightweight ref.
+	Annotated bool
+	Message   string
+	Tagger    *CommitSignature
+}
+
+// CreateTagResult describes a created tag. TagSHA is the tag object for
+// annotated tags and empty for lightweight ones.
+type CreateTagResult struct {
+	Name      string
+	TargetSHA string
+	TagSHA    string
+	Annotated bool
+}
+
+// DeleteTagOptions configures tag deletion.
+type DeleteTagOptions struct {
+	InvocationOptions
+	Name string
+	// ExpectedSHA guards the delete: it fails with a *RefUpdateError when
+	// the tag no longer points at this object. Empty deletes
+	// unconditionally.
+	ExpectedSHA string
+}
+
+// DeleteTagResult describes a deleted tag. RefUpdate.Branch holds the full
+// tag ref, e.g. "refs/tags/v1.0.0", and OldSHA the object it pointed at.
+type DeleteTagResult struct {
+	Name      string
+	RefUpdate RefUpdate
+}
+
+// BranchIfExists is the policy for creating a branch that already exists.
+type BranchIfExists string
+
+const (
+	// BranchIfExistsError fails the request.
+	BranchIfExistsError BranchIfExists = "error"
+	// BranchIfExistsReturnExisting succeeds and returns the existing branch.
+	BranchIfExistsReturnExisting BranchIfExists = "return_existing"
+	// BranchIfExistsRequireSameHead succeeds only when the existing branch
+	// points at the current head of BaseBranch.
+	BranchIfExistsRequireSameHead BranchIfExists = "require_same_head"
+)
+
 // CreateBranchResult describes branch creation result.
 type CreateBranchResult struct {
 	Message           string
 	TargetBranch      string
 	TargetIsEphemeral bool
 	CommitSHA         string
+	// AlreadyExisted reports that the branch existed and was left unchanged.
+	AlreadyExisted bool
+}
+
+// GetBranchMetadataOptions configures GetBranchMetadata.
+type GetBranchMetadataOptions struct {
+	InvocationOptions
+	Branch string
+}
+
+// SetBranchMetadataOptions configures SetBranchMetadata. Values are merged
+// into the existing metadata; keys in Delete are removed.
+type SetBranchMetadataOptions struct {
+	InvocationOptions
+	Branch string
+	Values map[string]string
+	Delete []string
+	// ExpectedVersion rejects the write with a 409 Conflict *APIError when
+	// the metadata changed since it was read. Empty writes unconditionally.
+	ExpectedVersion string
+}
+
+// BranchMetadata is the key/value metadata attached to a branch. It is
+// deleted together with the branch. Version changes on every write.
+type BranchMetadata struct {
+	Branch       string
+	Values       map[string]string
+	Version      string
+	UpdatedAt    time.Time
+	RawUpdatedAt string
 }
 
 // ListCommitsOptions configures list commits.
 type ListCommitsOptions struct {
 	InvocationOptions
 	Branch string
-	Cursor string
-	Limit  int
-}
+	// Exclude omits commits reachable from any of these revisions, like
+	// git log Branch --not Exclude. It is not stored in ResumeTokens, so
+	// pass it again when resuming.
+	Exclude []string
+	Cursor  string
+	Limit   int
+	// Order selects commit ordering. The server default applies when empty.
+	Order CommitOrder
+	// Resume continues a listing from a persisted ResumeToken instead of
+	// Cursor. Branch and Order default to the token's values. Resuming
+	// returns ErrResumeRefMoved if the listing's first commit changed.
+	Resume *ResumeToken
+}
+
+// CommitOrder selects how ListCommits orders history.
+type CommitOrder string
+
+const (
+	// CommitOrderDate orders by committer date, newest first.
+	CommitOrderDate CommitOrder = "date"
+	// CommitOrderTopo never shows a parent before all of its children.
+	CommitOrderTopo CommitOrder = "topo"
+	// CommitOrderAuthorDate orders by author date, newest first.
+	CommitOrderAuthorDate CommitOrder = "author-date"
+)
 
 // CommitInfo describes a commit entry.
 type CommitInfo struct {
@@ -279,6 +868,37 @@ type ListCommitsResult struct {
 	Commits    []CommitInfo
 	NextCursor string
 	HasMore    bool
+	// ResumeToken checkpoints the next page; nil on the last page.
+	ResumeToken *ResumeToken
+}
+
+// SearchHistoryOptions configures SearchHistory.
+type SearchHistoryOptions struct {
+	InvocationOptions
+	Pattern string
+	// Regex matches Pattern as a regular expression against added and
+	// removed lines instead of counting literal occurrences.
+	Regex bool
+	// Ref is where the history walk starts. Defaults to the default branch.
+	Ref    string
+	Paths  []string
+	Limit  int
+	Cursor string
+}
+
+// SearchHistoryResult lists commits whose changes match a pattern.
+type SearchHistoryResult struct {
+	Ref        string
+	Matches    []HistoryMatch
+	NextCursor string
+	HasMore    bool
+}
+
+// HistoryMatch is a commit found by SearchHistory. Paths lists the files
+// whose changes matched.
+type HistoryMatch struct {
+	Commit CommitInfo
+	Paths  []string
 }
 
 // NoteAuthor identifies note author.
@@ -287,23 +907,79 @@ type NoteAuthor struct {
 	Email string
 }
 
+// NoteObjectType is the kind of git object a note is attached to.
+type NoteObjectType string
+
+const (
+	NoteObjectCommit NoteObjectType = "commit"
+	NoteObjectTree   NoteObjectType = "tree"
+	NoteObjectBlob   NoteObjectType = "blob"
+)
+
 // GetNoteOptions configures get note.
 type GetNoteOptions struct {
 	InvocationOptions
 	SHA string
+	// ObjectType declares what SHA names. Empty means a commit; set it to
+	// NoteObjectTree or NoteObjectBlob to annotate a directory or a file
+	// version, e.g. the blob SHA from FileStreamBlobSHA.
+	ObjectType NoteObjectType
 }
 
 // GetNoteResult describes note read.
 type GetNoteResult struct {
-	SHA    string
-	Note   string
+	SHA        string
+	ObjectType NoteObjectType
+	Note       string
+	RefSHA     string
+}
+
+// GetNotesOptions configures a batch note read. Set SHAs, or Head (and
+// optionally Base) to read the notes of every commit in Base..Head.
+type GetNotesOptions struct {
+	InvocationOptions
+	SHAs []string
+	Base string
+	Head string
+}
+
+// GetNotesResult maps commit SHAs to their notes. Commits without a note
+// are absent from Notes.
+type GetNotesResult struct {
+	Notes  map[string]string
 	RefSHA string
 }
 
+// ListNotesOptions configures ListNotes.
+type ListNotesOptions struct {
+	InvocationOptions
+	Cursor string
+	Limit  int
+}
+
+// ListNotesResult lists the notes under refs/notes/commits, ordered by
+// object SHA.
+type ListNotesResult struct {
+	Notes []NoteEntry
+	// RefSHA is the notes ref commit the listing was read from.
+	RefSHA     string
+	NextCursor string
+	HasMore    bool
+}
+
+// NoteEntry is a note and the object it annotates.
+type NoteEntry struct {
+	SHA        string
+	ObjectType NoteObjectType
+	Note       string
+}
+
 // CreateNoteOptions configures note creation.
 type CreateNoteOptions struct {
 	InvocationOptions
-	SHA            string
+	SHA string
+	// ObjectType declares what SHA names; empty means a commit.
+	ObjectType     NoteObjectType
 	Note           string
 	ExpectedRefSHA string
 	Author         *NoteAuthor
@@ -312,7 +988,9 @@ type CreateNoteOptions struct {
 // AppendNoteOptions configures note append.
 type AppendNoteOptions struct {
 	InvocationOptions
-	SHA            string
+	SHA string
+	// ObjectType declares what SHA names; empty means a commit.
+	ObjectType     NoteObjectType
 	Note           string
 	ExpectedRefSHA string
 	Author         *NoteAuthor
@@ -321,7 +999,9 @@ type AppendNoteOptions struct {
 // DeleteNoteOptions configures note delete.
 type DeleteNoteOptions struct {
 	InvocationOptions
-	SHA            string
+	SHA string
+	// ObjectType declares what SHA names; empty means a commit.
+	ObjectType     NoteObjectType
 	ExpectedRefSHA string
 	Author         *NoteAuthor
 }
@@ -366,25 +1046,53 @@ type DiffStats struct {
 
 // FileDiff describes a diffed file.
 type FileDiff struct {
-	Path      string
-	State     DiffFileState
-	RawState  string
-	OldPath   string
-	Raw       string
-	Bytes     int
-	IsEOF     bool
-	Additions int
-	Deletions int
-}
-
-// FilteredFile describes a filtered diff file.
-type FilteredFile struct {
 	Path     string
 	State    DiffFileState
 	RawState string
 	OldPath  string
-	Bytes    int
-	IsEOF    bool
+	// Similarity is the rename or copy similarity percentage, taken from
+	// states such as "R087"; zero for other states.
+	Similarity  int
+	Raw         string
+	Bytes       int
+	IsEOF       bool
+	Additions   int
+	Deletions   int
+	IsBinary    bool
+	ContentType string
+	// LFSPointer is set when the new side of the file is a Git LFS pointer.
+	LFSPointer *LFSPointer
+	// Attributes holds the per-file attributes recorded at commit time.
+	Attributes map[string]string
+	// Owners is set by CodeOwners.AnnotateDiff.
+	Owners []string
+}
+
+// FilteredFile describes a filtered diff file.
+type FilteredFile struct {
+	Path        string
+	State       DiffFileState
+	RawState    string
+	OldPath     string
+	Similarity  int
+	Bytes       int
+	IsEOF       bool
+	IsBinary    bool
+	ContentType string
+}
+
+// RenameDetectionOptions controls how diffs pair deleted and added files.
+// Detected renames and copies are reported with State DiffStateRenamed or
+// DiffStateCopied and the source path in OldPath.
+type RenameDetectionOptions struct {
+	// DetectRenames enables or disables rename detection; nil keeps the
+	// server default.
+	DetectRenames *bool
+	// DetectCopies also reports files copied from other changed files.
+	DetectCopies bool
+	// RenameThreshold is the minimum similarity percentage (1-100) for a
+	// pair to count as a rename or copy; zero keeps the server default.
+	RenameThreshold int
 }
 
 // GetBranchDiffOptions configures branch diff.
@@ -395,15 +1103,53 @@ type GetBranchDiffOptions struct {
 	Ephemeral     *bool
 	EphemeralBase *bool
 	Paths         []string
+	// Cursor and Limit page through the diff by file. Without them the
+	// server returns as many files as fit its response limits.
+	Cursor string
+	Limit  int
+	// ContextLines sets the number of unchanged lines around each change;
+	// nil keeps the server default of 3.
+	ContextLines *int
+	// States limits the diff to files in these states, e.g. only
+	// DiffStateDeleted. Empty returns every file.
+	States []DiffFileState
+	// Comparison picks merge-base or direct comparison. Empty keeps the
+	// server default, which is merge-base for branches.
+	Comparison DiffComparison
+	RenameDetectionOptions
 }
 
 // GetBranchDiffResult describes branch diff.
 type GetBranchDiffResult struct {
-	Branch        string
-	Base          string
+	Branch string
+	Base   string
+	// MergeBaseSHA is the commit the head was compared against when the
+	// diff used merge-base comparison. Empty for direct comparisons.
+	MergeBaseSHA  string
 	Stats         DiffStats
 	Files         []FileDiff
 	FilteredFiles []FilteredFile
+	NextCursor    string
+	HasMore       bool
+}
+
+// CompareDiffOptions configures a diff between two arbitrary refs. Base and
+// Head accept branch names, tags, or commit SHAs.
+type CompareDiffOptions struct {
+	InvocationOptions
+	Base string
+	Head string
+	// EphemeralBase and EphemeralHead resolve the corresponding ref in the
+	// ephemeral namespace.
+	EphemeralBase bool
+	EphemeralHead bool
+	Paths         []string
+	Cursor        string
+	Limit         int
+	ContextLines  *int
+	States        []DiffFileState
+	Comparison    DiffComparison
+	RenameDetectionOptions
 }
 
 // GetCommitDiffOptions configures commit diff.
@@ -412,6 +1158,12 @@ type GetCommitDiffOptions struct {
 	SHA     string
 	BaseSHA string
 	Paths   []string
+	// Cursor and Limit page through the diff by file.
+	Cursor       string
+	Limit        int
+	ContextLines *int
+	States       []DiffFileState
+	RenameDetectionOptions
 }
 
 // GetCommitDiffResult describes commit diff.
@@ -420,6 +1172,8 @@ type GetCommitDiffResult struct {
 	Stats         DiffStats
 	Files         []FileDiff
 	FilteredFiles []FilteredFile
+	NextCursor    string
+	HasMore       bool
 }
 
 // GrepOptions configures grep.
@@ -427,26 +1181,62 @@ type GrepOptions struct {
 	InvocationOptions
 	Ref string
 	// Deprecated: use Ref instead.
-	Rev         string
+	Rev string
+	// Refs searches several refs in one request; matches are annotated
+	// with the ref and commit they came from. It cannot be combined with
+	// Ref.
+	Refs        []GrepRef
 	Query       GrepQuery
 	Paths       []string
 	FileFilters *GrepFileFilters
 	Context     *GrepContext
 	Limits      *GrepLimits
 	Pagination  *GrepPagination
+	// Binary controls how binary files are searched. The server default
+	// applies when empty.
+	Binary GrepBinaryMode
+	// FilesWithoutMatch returns the files that do not match the query, like
+	// grep -L, instead of matching lines. Matches then carry no Lines.
+	FilesWithoutMatch bool
+}
+
+// GrepRef is a ref searched by a multi-ref grep.
+type GrepRef struct {
+	Ref       string
+	Ephemeral bool
 }
 
+// GrepBinaryMode selects how grep treats binary files.
+type GrepBinaryMode string
+
+const (
+	// GrepBinarySkip excludes binary files from results.
+	GrepBinarySkip GrepBinaryMode = "skip"
+	// GrepBinaryMatch reports matching binary files with IsBinary set and
+	// no line records.
+	GrepBinaryMatch GrepBinaryMode = "match"
+	// GrepBinaryText searches binary files as if they were text.
+	GrepBinaryText GrepBinaryMode = "text"
+)
+
 // GrepQuery describes grep query.
 type GrepQuery struct {
 	Pattern       string
 	CaseSensitive *bool
+	// InvertMatch selects lines that do not match Pattern, like grep -v.
+	InvertMatch bool
 }
 
 // GrepFileFilters describes file filters for grep.
 type GrepFileFilters struct {
+	// Types adds the include globs of named file types such as "go", "ts",
+	// or "docs", like ripgrep's --type. See GrepFileTypes for the list.
+	Types            []string
 	IncludeGlobs     []string
 	ExcludeGlobs     []string
 	ExtensionFilters []string
+	// MaxFileBytes skips files larger than this size server-side.
+	MaxFileBytes *int64
 }
 
 // GrepContext configures context lines.
@@ -478,12 +1268,21 @@ type GrepLine struct {
 type GrepFileMatch struct {
 	Path  string
 	Lines []GrepLine
+	// IsBinary reports that the file was detected as binary.
+	IsBinary bool
+	// Ref and Commit identify where the match was found. They are only set
+	// for searches with GrepOptions.Refs.
+	Ref    string
+	Commit string
 }
 
 // GrepResult describes grep results.
 type GrepResult struct {
-	Query      GrepQuery
-	Repo       GrepRepo
+	Query GrepQuery
+	Repo  GrepRepo
+	// Refs lists each ref searched with GrepOptions.Refs and the commit it
+	// resolved to, in request order.
+	Refs       []GrepRepo
 	Matches    []GrepFileMatch
 	NextCursor string
 	HasMore    bool
@@ -514,6 +1313,29 @@ const (
 // CommitFileOptions configures file operations.
 type CommitFileOptions struct {
 	Mode GitFileMode
+	// Attributes attaches machine-readable metadata to the file entry, such
+	// as a generator name or source checksum. It is returned by the diff and
+	// file metadata APIs.
+	Attributes map[string]string
+	// FailIfExists rejects the commit when the path already exists on the
+	// base tree, so new files never overwrite ones added concurrently.
+	FailIfExists bool
+	// FailIfMissing rejects the commit when the path does not exist on the
+	// base tree, so updates never resurrect concurrently deleted files.
+	FailIfMissing bool
+	// ExpectedBlobSHA rejects the commit with a *FileConflictError when the
+	// file's current blob SHA differs, or the file no longer exists.
+	ExpectedBlobSHA string
+}
+
+// DeletePathOptions configures delete operations.
+type DeletePathOptions struct {
+	// FailIfMissing rejects the commit when the path does not exist on the
+	// base tree.
+	FailIfMissing bool
+	// ExpectedBlobSHA rejects the commit with a *FileConflictError when the
+	// file's current blob SHA differs.
+	ExpectedBlobSHA string
 }
 
 // CommitTextFileOptions configures text files.
@@ -530,6 +1352,31 @@ type CommitResult struct {
 	PackBytes    int
 	BlobCount    int
 	RefUpdate    RefUpdate
+	// Timing reports server-side latency; nil when the server omits it.
+	Timing *CommitTiming
+	// LFSFiles lists files committed as LFS pointers because they exceeded
+	// CommitOptions.LargeFileThreshold.
+	LFSFiles []CommitLFSFile
+	// IgnoredPaths lists staged files matched by .gitignore rules when
+	// CommitOptions.CheckGitignore is GitignoreCheckWarn.
+	IgnoredPaths []IgnoredPath
+}
+
+// CommitLFSFile describes a file converted to an LFS pointer on commit.
+type CommitLFSFile struct {
+	Path    string
+	Pointer LFSPointer
+}
+
+// CommitTiming breaks down the server-side latency of a commit. Fields the
+// server does not report are zero.
+type CommitTiming struct {
+	// Queue is the time the commit waited before processing started.
+	Queue time.Duration
+	// PackVerification is the time spent validating the uploaded pack.
+	PackVerification time.Duration
+	// Processing is the total server-side time, excluding the upload.
+	Processing time.Duration
 }
 
 // RefUpdate describes ref update details.
@@ -561,6 +1408,26 @@ type CommitOptions struct {
 	EphemeralBase   bool
 	Author          CommitSignature
 	Committer       *CommitSignature
+	// EphemeralTTL expires an ephemeral commit's ref after this duration,
+	// rounded up to whole seconds. It requires Ephemeral.
+	EphemeralTTL time.Duration
+	// Parents records a merge commit with these parent SHAs, in order. It
+	// requires ExpectedHeadSHA, which must be one of the parents.
+	Parents []string
+	// LargeFileThreshold, when positive, uploads files larger than this many
+	// bytes as Git LFS objects and commits pointer files in their place.
+	// Sources without a Len method are buffered up to the threshold to
+	// measure them. The repository's .gitattributes must route the paths
+	// through the lfs filter for git clients to resolve the pointers. It is
+	// ignored under Options.DryRun, which previews the commit with large
+	// files inline.
+	LargeFileThreshold int64
+	// CheckGitignore compares staged files with the .gitignore files at
+	// BaseBranch, or TargetBranch when BaseBranch is empty, to catch build
+	// output and dependency directories before they are committed. Rules
+	// come from the base ref, not from .gitignore files staged in the same
+	// commit. Empty disables the check.
+	CheckGitignore GitignoreCheck
 }
 
 // CommitFromDiffOptions configures diff commit.
@@ -575,6 +1442,9 @@ type CommitFromDiffOptions struct {
 	EphemeralBase   bool
 	Author          CommitSignature
 	Committer       *CommitSignature
+	// EphemeralTTL expires an ephemeral commit's ref after this duration,
+	// rounded up to whole seconds. It requires Ephemeral.
+	EphemeralTTL time.Duration
 }
 
 // RestoreCommitOptions configures restore commit.
@@ -600,6 +1470,22 @@ type RestoreCommitResult struct {
 // WebhookValidationOptions controls webhook validation.
 type WebhookValidationOptions struct {
 	MaxAgeSeconds int
+	// MaxBodyBytes caps the request bodies read by ValidateRequest,
+	// WebhookHandler, and WebhookRouter. Zero uses 1 MiB.
+	MaxBodyBytes int64
+	// ReplayStore, when set, rejects deliveries whose timestamp and
+	// payload validated before. ValidateWebhookSignature only checks the
+	// signature and does not consult it; use ValidateWebhook or
+	// ValidateWebhookWithKeys.
+	ReplayStore ReplayStore
+	// PreviousSecrets are also accepted for HMAC signatures, so deliveries
+	// signed before a RotateWebhookSecret keep validating during the grace
+	// period.
+	PreviousSecrets []string
+	// SignatureHeader and EventHeader override the X-Pierre-Signature and
+	// X-Pierre-Event header names for deployments whose proxy renames them.
+	SignatureHeader string
+	EventHeader     string
 }
 
 // WebhookValidationResult describes signature validation.
@@ -614,12 +1500,16 @@ type WebhookValidationResult struct {
 type WebhookValidation struct {
 	WebhookValidationResult
 	Payload *WebhookEventPayload
+	// CloudEvent is set when the delivery was a CloudEvents envelope.
+	CloudEvent *CloudEvent
 }
 
 // ParsedWebhookSignature represents parsed signature header.
 type ParsedWebhookSignature struct {
 	Timestamp string
 	Signature string
+	// Algorithm is the digest of Signature: "sha512" or "sha256".
+	Algorithm string
 }
 
 // WebhookPushEvent describes a push webhook.
@@ -632,6 +1522,19 @@ type WebhookPushEvent struct {
 	CustomerID  string
 	PushedAt    time.Time
 	RawPushedAt string
+	// Sequence is the per-repository delivery sequence number, or 0 when the
+	// payload does not carry one.
+	Sequence int64
+}
+
+// WebhookPingEvent is the test event sent by SendWebhookPing.
+type WebhookPingEvent struct {
+	Type       string
+	WebhookID  string
+	DeliveryID string
+	CustomerID string
+	SentAt     time.Time
+	RawSentAt  string
 }
 
 // WebhookRepository describes webhook repo.
@@ -649,6 +1552,7 @@ type WebhookUnknownEvent struct {
 // WebhookEventPayload represents a validated event.
 type WebhookEventPayload struct {
 	Push    *WebhookPushEvent
+	Ping    *WebhookPingEvent
 	Unknown *WebhookUnknownEvent
 }
 
@@ -665,4 +1569,5 @@ type Client struct {
 	options    Options
 	api        *apiFetcher
 	privateKey *ecdsa.PrivateKey
+	actor      string
 }
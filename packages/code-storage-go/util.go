@@ -2,8 +2,11 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +16,12 @@ func itoa(value int) string {
 	return strconv.Itoa(value)
 }
 
+// durationSecondsCeil converts a positive duration to whole seconds,
+// rounding up so sub-second values are not truncated to zero.
+func durationSecondsCeil(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
+
 func decodeJSON(resp *http.Response, target interface{}) error {
 	decoder := json.NewDecoder(resp.Body)
 	return decoder.Decode(target)
@@ -31,6 +40,25 @@ func parseTime(value string) time.Time {
 	return time.Time{}
 }
 
+const (
+	fileModeHeader      = "Code-Storage-File-Mode"
+	fileEntryTypeHeader = "Code-Storage-Entry-Type"
+	submoduleRepoHeader = "Code-Storage-Submodule-Repo"
+)
+
+func fileEntryTypeFromMode(mode string) FileEntryType {
+	switch strings.TrimSpace(mode) {
+	case string(GitFileModeRegular), string(GitFileModeExecutable):
+		return FileEntryTypeFile
+	case string(GitFileModeSymlink):
+		return FileEntryTypeSymlink
+	case string(GitFileModeSubmodule):
+		return FileEntryTypeSubmodule
+	default:
+		return FileEntryTypeUnknown
+	}
+}
+
 func normalizeDiffState(raw string) DiffFileState {
 	trimmed := strings.TrimSpace(raw)
 	if trimmed == "" {
@@ -57,10 +85,201 @@ func normalizeDiffState(raw string) DiffFileState {
 	}
 }
 
+func newOrgRepoDefaultsPayload(defaults OrgRepoDefaults) *orgRepoDefaultsPayload {
+	payload := &orgRepoDefaultsPayload{DefaultBranch: strings.TrimSpace(defaults.DefaultBranch)}
+	for _, rule := range defaults.BranchProtection {
+		payload.BranchProtection = append(payload.BranchProtection, branchProtectionRaw{
+			Pattern:              strings.TrimSpace(rule.Pattern),
+			BlockForcePush:       rule.BlockForcePush,
+			BlockDeletion:        rule.BlockDeletion,
+			RequireLinearHistory: rule.RequireLinearHistory,
+		})
+	}
+	for _, hook := range defaults.Webhooks {
+		payload.Webhooks = append(payload.Webhooks, webhookEndpointRaw{URL: hook.URL, Events: hook.Events, Secret: hook.Secret})
+	}
+	if retention := defaults.Retention; retention != nil {
+		payload.Retention = &retentionPolicyRaw{
+			EphemeralBranchTTLSeconds:   durationSecondsCeil(retention.EphemeralBranchTTL),
+			DeletedRepoRetentionSeconds: durationSecondsCeil(retention.DeletedRepoRetention),
+		}
+	}
+	return payload
+}
+
+func (p orgRepoDefaultsPayload) defaults() OrgRepoDefaults {
+	defaults := OrgRepoDefaults{
+		DefaultBranch: p.DefaultBranch,
+		UpdatedAt:     parseTime(p.UpdatedAt),
+		RawUpdatedAt:  p.UpdatedAt,
+	}
+	for _, rule := range p.BranchProtection {
+		defaults.BranchProtection = append(defaults.BranchProtection, BranchProtectionRule{
+			Pattern:              rule.Pattern,
+			BlockForcePush:       rule.BlockForcePush,
+			BlockDeletion:        rule.BlockDeletion,
+			RequireLinearHistory: rule.RequireLinearHistory,
+		})
+	}
+	for _, hook := range p.Webhooks {
+		defaults.Webhooks = append(defaults.Webhooks, WebhookEndpoint{ID: hook.ID, URL: hook.URL, Events: hook.Events})
+	}
+	if p.Retention != nil {
+		defaults.Retention = &RetentionPolicy{
+			EphemeralBranchTTL:   time.Duration(p.Retention.EphemeralBranchTTLSeconds) * time.Second,
+			DeletedRepoRetention: time.Duration(p.Retention.DeletedRepoRetentionSeconds) * time.Second,
+		}
+	}
+	return defaults
+}
+
+func (raw *legalHoldRaw) hold() *LegalHold {
+	if raw == nil {
+		return nil
+	}
+	return &LegalHold{
+		Reason:      raw.Reason,
+		Reference:   raw.Reference,
+		PlacedAt:    parseTime(raw.PlacedAt),
+		RawPlacedAt: raw.PlacedAt,
+	}
+}
+
+func transformBranchMetadata(raw branchMetadataResponse) BranchMetadata {
+	values := raw.Metadata
+	if values == nil {
+		values = map[string]string{}
+	}
+	return BranchMetadata{
+		Branch:       raw.Branch,
+		Values:       values,
+		Version:      raw.Version,
+		UpdatedAt:    parseTime(raw.UpdatedAt),
+		RawUpdatedAt: raw.UpdatedAt,
+	}
+}
+
+func transformCommitInfo(raw commitInfoRaw) CommitInfo {
+	return CommitInfo{
+		SHA:            raw.SHA,
+		Message:        raw.Message,
+		AuthorName:     raw.AuthorName,
+		AuthorEmail:    raw.AuthorEmail,
+		CommitterName:  raw.CommitterName,
+		CommitterEmail: raw.CommitterEmail,
+		Date:           parseTime(raw.Date),
+		RawDate:        raw.Date,
+	}
+}
+
+// diffSimilarity extracts the score from rename and copy states like "R087".
+func diffSimilarity(raw string) int {
+	trimmed := strings.TrimSpace(raw)
+	if len(trimmed) < 2 {
+		return 0
+	}
+	switch strings.ToUpper(trimmed[:1]) {
+	case "R", "C":
+	default:
+		return 0
+	}
+	score, err := strconv.Atoi(trimmed[1:])
+	if err != nil || score < 0 || score > 100 {
+		return 0
+	}
+	return score
+}
+
+// diffOldPath falls back to the "rename from" or "copy from" header of the
+// patch when the server omits old_path.
+func diffOldPath(oldPath string, patch string) string {
+	if trimmed := strings.TrimSpace(oldPath); trimmed != "" {
+		return trimmed
+	}
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			break
+		}
+		for _, prefix := range []string{"rename from ", "copy from "} {
+			if strings.HasPrefix(line, prefix) {
+				return strings.TrimSpace(strings.TrimPrefix(line, prefix))
+			}
+		}
+	}
+	return ""
+}
+
+// setContextLinesParam validates the diff context size and adds it to
+// params.
+func setContextLinesParam(params url.Values, contextLines *int, api string) error {
+	if contextLines == nil {
+		return nil
+	}
+	if *contextLines < 0 {
+		return errors.New(api + " contextLines must be non-negative")
+	}
+	params.Set("context_lines", itoa(*contextLines))
+	return nil
+}
+
+// setDiffStatesParam validates a diff state filter and adds it to params.
+func setDiffStatesParam(params url.Values, states []DiffFileState, api string) error {
+	seen := make(map[DiffFileState]bool, len(states))
+	for _, state := range states {
+		switch state {
+		case DiffStateAdded, DiffStateModified, DiffStateDeleted, DiffStateRenamed,
+			DiffStateCopied, DiffStateTypeChanged, DiffStateUnmerged:
+		default:
+			return errors.New(api + " states contains unsupported state " + strconv.Quote(string(state)))
+		}
+		if !seen[state] {
+			seen[state] = true
+			params.Add("state", string(state))
+		}
+	}
+	return nil
+}
+
+// setDiffComparisonParam validates comparison and adds it to params.
+func setDiffComparisonParam(params url.Values, comparison DiffComparison, api string) error {
+	switch comparison {
+	case "":
+	case DiffComparisonMergeBase, DiffComparisonDirect:
+		params.Set("comparison", string(comparison))
+	default:
+		return errors.New(api + " comparison must be merge_base or direct")
+	}
+	return nil
+}
+
+// setRenameDetectionParams validates options and adds them to params.
+func setRenameDetectionParams(params url.Values, options RenameDetectionOptions, api string) error {
+	if options.RenameThreshold < 0 || options.RenameThreshold > 100 {
+		return errors.New(api + " renameThreshold must be between 0 and 100")
+	}
+	disabled := options.DetectRenames != nil && !*options.DetectRenames
+	if disabled && (options.DetectCopies || options.RenameThreshold > 0) {
+		return errors.New(api + " detectCopies and renameThreshold require rename detection")
+	}
+	if options.DetectRenames != nil {
+		params.Set("detect_renames", strconv.FormatBool(*options.DetectRenames))
+	}
+	if options.DetectCopies {
+		params.Set("detect_copies", "true")
+	}
+	if options.RenameThreshold > 0 {
+		params.Set("rename_threshold", itoa(options.RenameThreshold))
+	}
+	return nil
+}
+
 func transformBranchDiff(raw branchDiffResponse) GetBranchDiffResult {
 	result := GetBranchDiffResult{
-		Branch: raw.Branch,
-		Base:   raw.Base,
+		Branch:       raw.Branch,
+		Base:         raw.Base,
+		MergeBaseSHA: raw.MergeBaseSHA,
+		NextCursor:   raw.NextCursor,
+		HasMore:      raw.HasMore,
 		Stats: DiffStats{
 			Files:     raw.Stats.Files,
 			Additions: raw.Stats.Additions,
@@ -71,26 +290,34 @@ func transformBranchDiff(raw branchDiffResponse) GetBranchDiffResult {
 
 	for _, file := range raw.Files {
 		result.Files = append(result.Files, FileDiff{
-			Path:      file.Path,
-			State:     normalizeDiffState(file.State),
-			RawState:  file.State,
-			OldPath:   strings.TrimSpace(file.OldPath),
-			Raw:       file.Raw,
-			Bytes:     file.Bytes,
-			IsEOF:     file.IsEOF,
-			Additions: file.Additions,
-			Deletions: file.Deletions,
+			Path:        file.Path,
+			State:       normalizeDiffState(file.State),
+			RawState:    file.State,
+			OldPath:     diffOldPath(file.OldPath, file.Raw),
+			Similarity:  diffSimilarity(file.State),
+			Raw:         file.Raw,
+			Bytes:       file.Bytes,
+			IsEOF:       file.IsEOF,
+			Additions:   file.Additions,
+			Deletions:   file.Deletions,
+			IsBinary:    file.IsBinary || isBinaryPatch(file.Raw),
+			ContentType: file.ContentType,
+			LFSPointer:  lfsPointerFromPatch(file.Raw),
+			Attributes:  file.Attributes,
 		})
 	}
 
 	for _, file := range raw.FilteredFiles {
 		result.FilteredFiles = append(result.FilteredFiles, FilteredFile{
-			Path:     file.Path,
-			State:    normalizeDiffState(file.State),
-			RawState: file.State,
-			OldPath:  strings.TrimSpace(file.OldPath),
-			Bytes:    file.Bytes,
-			IsEOF:    file.IsEOF,
+			Path:        file.Path,
+			State:       normalizeDiffState(file.State),
+			RawState:    file.State,
+			OldPath:     strings.TrimSpace(file.OldPath),
+			Similarity:  diffSimilarity(file.State),
+			Bytes:       file.Bytes,
+			IsEOF:       file.IsEOF,
+			IsBinary:    file.IsBinary,
+			ContentType: file.ContentType,
 		})
 	}
 
@@ -99,7 +326,9 @@ func transformBranchDiff(raw branchDiffResponse) GetBranchDiffResult {
 
 func transformCommitDiff(raw commitDiffResponse) GetCommitDiffResult {
 	result := GetCommitDiffResult{
-		SHA: raw.SHA,
+		SHA:        raw.SHA,
+		NextCursor: raw.NextCursor,
+		HasMore:    raw.HasMore,
 		Stats: DiffStats{
 			Files:     raw.Stats.Files,
 			Additions: raw.Stats.Additions,
@@ -110,32 +339,54 @@ func transformCommitDiff(raw commitDiffResponse) GetCommitDiffResult {
 
 	for _, file := range raw.Files {
 		result.Files = append(result.Files, FileDiff{
-			Path:      file.Path,
-			State:     normalizeDiffState(file.State),
-			RawState:  file.State,
-			OldPath:   strings.TrimSpace(file.OldPath),
-			Raw:       file.Raw,
-			Bytes:     file.Bytes,
-			IsEOF:     file.IsEOF,
-			Additions: file.Additions,
-			Deletions: file.Deletions,
+			Path:        file.Path,
+			State:       normalizeDiffState(file.State),
+			RawState:    file.State,
+			OldPath:     diffOldPath(file.OldPath, file.Raw),
+			Similarity:  diffSimilarity(file.State),
+			Raw:         file.Raw,
+			Bytes:       file.Bytes,
+			IsEOF:       file.IsEOF,
+			Additions:   file.Additions,
+			Deletions:   file.Deletions,
+			IsBinary:    file.IsBinary || isBinaryPatch(file.Raw),
+			ContentType: file.ContentType,
+			LFSPointer:  lfsPointerFromPatch(file.Raw),
+			Attributes:  file.Attributes,
 		})
 	}
 
 	for _, file := range raw.FilteredFiles {
 		result.FilteredFiles = append(result.FilteredFiles, FilteredFile{
-			Path:     file.Path,
-			State:    normalizeDiffState(file.State),
-			RawState: file.State,
-			OldPath:  strings.TrimSpace(file.OldPath),
-			Bytes:    file.Bytes,
-			IsEOF:    file.IsEOF,
+			Path:        file.Path,
+			State:       normalizeDiffState(file.State),
+			RawState:    file.State,
+			OldPath:     strings.TrimSpace(file.OldPath),
+			Similarity:  diffSimilarity(file.State),
+			Bytes:       file.Bytes,
+			IsEOF:       file.IsEOF,
+			IsBinary:    file.IsBinary,
+			ContentType: file.ContentType,
 		})
 	}
 
 	return result
 }
 
+// isBinaryPatch recognizes git's binary markers for patches produced
+// without an explicit is_binary flag.
+func isBinaryPatch(raw string) bool {
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			return false
+		}
+		if line == "GIT binary patch" || (strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ")) {
+			return true
+		}
+	}
+	return false
+}
+
 func parseNoteWriteResponse(resp *http.Response, method string) (NoteWriteResult, error) {
 	contentType := resp.Header.Get("content-type")
 	var rawBody []byte
@@ -255,3 +506,80 @@ func httpStatusToRestoreStatus(status int) string {
 		return strconv.Itoa(status)
 	}
 }
+
+func transformLanguages(raw languagesResponse) LanguagesResult {
+	result := LanguagesResult{Ref: raw.Ref, CommitSHA: raw.CommitSHA}
+	for name, bytes := range raw.Languages {
+		result.TotalBytes += bytes
+		result.Languages = append(result.Languages, LanguageStat{Name: name, Bytes: bytes})
+	}
+	for i := range result.Languages {
+		if result.TotalBytes > 0 {
+			result.Languages[i].Percentage = float64(result.Languages[i].Bytes) * 100 / float64(result.TotalBytes)
+		}
+	}
+	sort.Slice(result.Languages, func(i, j int) bool {
+		if result.Languages[i].Bytes != result.Languages[j].Bytes {
+			return result.Languages[i].Bytes > result.Languages[j].Bytes
+		}
+		return result.Languages[i].Name < result.Languages[j].Name
+	})
+	return result
+}
+
+func transformChurnStats(raw []churnStatsRaw) []ChurnStats {
+	if len(raw) == 0 {
+		return nil
+	}
+	stats := make([]ChurnStats, 0, len(raw))
+	for _, entry := range raw {
+		stats = append(stats, ChurnStats{
+			Path:          entry.Path,
+			Commits:       entry.Commits,
+			Authors:       entry.Authors,
+			Additions:     entry.Additions,
+			Deletions:     entry.Deletions,
+			LastChangedAt: parseTime(entry.LastChangedAt),
+		})
+	}
+	return stats
+}
+
+func normalizeNoteObjectType(value NoteObjectType, api string) (string, error) {
+	switch NoteObjectType(strings.ToLower(strings.TrimSpace(string(value)))) {
+	case "":
+		return "", nil
+	case NoteObjectCommit:
+		return string(NoteObjectCommit), nil
+	case NoteObjectTree:
+		return string(NoteObjectTree), nil
+	case NoteObjectBlob:
+		return string(NoteObjectBlob), nil
+	default:
+		return "", errors.New(api + " objectType must be commit, tree, or blob")
+	}
+}
+
+// noteObjectType maps a server object type, defaulting to commit for
+// servers that predate tree and blob notes.
+func noteObjectType(value string) NoteObjectType {
+	if value == "" {
+		return NoteObjectCommit
+	}
+	return NoteObjectType(value)
+}
+
+func transformCommitStatus(raw commitStatusRaw) CommitStatus {
+	return CommitStatus{
+		ID:           raw.ID,
+		SHA:          raw.SHA,
+		State:        CommitStatusState(raw.State),
+		Context:      raw.Context,
+		TargetURL:    raw.TargetURL,
+		Description:  raw.Description,
+		CreatedAt:    parseTime(raw.CreatedAt),
+		RawCreatedAt: raw.CreatedAt,
+		UpdatedAt:    parseTime(raw.UpdatedAt),
+		RawUpdatedAt: raw.UpdatedAt,
+	}
+}
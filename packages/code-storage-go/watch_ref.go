@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWatchRefPollInterval    = time.Second
+	defaultWatchRefMaxPollInterval = 30 * time.Second
+)
+
+// WatchRefOptions configures WatchRef.
+type WatchRefOptions struct {
+	InvocationOptions
+	// Ref is the branch to watch.
+	Ref string
+	// FromSHA is the SHA the caller last saw. WatchRef returns once the
+	// ref points anywhere else.
+	FromSHA string
+	// PollInterval is the delay between the first checks. It doubles while
+	// the ref stays put, up to MaxPollInterval. Zero uses one second.
+	PollInterval time.Duration
+	// MaxPollInterval caps the delay between checks. Zero uses 30s.
+	MaxPollInterval time.Duration
+}
+
+// WatchRefResult reports the SHA a watched ref moved to.
+type WatchRefResult struct {
+	Ref string
+	SHA string
+}
+
+// WatchRef blocks until ref no longer points at FromSHA and returns its new
+// SHA. It polls with backoff, retrying rate limits, server errors, and
+// network failures, and returns other errors, including a 404 when the ref
+// is deleted. Bound the wait with ctx.
+func (r *Repo) WatchRef(ctx context.Context, options WatchRefOptions) (WatchRefResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ref := strings.TrimSpace(options.Ref)
+	if ref == "" {
+		return WatchRefResult{}, errors.New("watchRef ref is required")
+	}
+	fromSHA := strings.TrimSpace(options.FromSHA)
+	if fromSHA == "" {
+		return WatchRefResult{}, errors.New("watchRef fromSHA is required")
+	}
+	if options.PollInterval < 0 || options.MaxPollInterval < 0 {
+		return WatchRefResult{}, errors.New("watchRef poll intervals must not be negative")
+	}
+	interval := options.PollInterval
+	if interval == 0 {
+		interval = defaultWatchRefPollInterval
+	}
+	maxInterval := options.MaxPollInterval
+	if maxInterval == 0 {
+		maxInterval = defaultWatchRefMaxPollInterval
+	}
+
+	for {
+		head, err := r.ListCommits(ctx, ListCommitsOptions{InvocationOptions: options.InvocationOptions, Branch: ref, Limit: 1, Order: CommitOrderTopo})
+		switch {
+		case err == nil && len(head.Commits) > 0 && head.Commits[0].SHA != fromSHA:
+			return WatchRefResult{Ref: ref, SHA: head.Commits[0].SHA}, nil
+		case err != nil && !isTransientWatchError(ctx, err):
+			return WatchRefResult{}, err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return WatchRefResult{}, ctx.Err()
+		}
+		if interval *= 2; interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+func isTransientWatchError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status == http.StatusTooManyRequests || apiErr.Status >= 500
+	}
+	return true
+}
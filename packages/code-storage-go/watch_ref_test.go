@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWatchRef(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/commits" || r.URL.Query().Get("branch") != "main" || r.URL.Query().Get("limit") != "1" || r.URL.Query().Get("order") != "topo" {
+			t.Errorf("unexpected request: %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		switch requests {
+		case 1:
+			_, _ = w.Write([]byte(`{"commits":[{"sha":"abc123"}],"has_more":true}`))
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte(`{"commits":[{"sha":"def456"}],"has_more":true}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	repo := &Repo{ID: "repo", DefaultBranch: "main", client: client}
+
+	result, err := repo.WatchRef(nil, WatchRefOptions{Ref: "main", FromSHA: "abc123", PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("watch ref error: %v", err)
+	}
+	if result.SHA != "def456" || result.Ref != "main" || requests != 3 {
+		t.Fatalf("unexpected result after %d requests: %+v", requests, result)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := repo.WatchRef(ctx, WatchRefOptions{Ref: "main", FromSHA: "def456", PollInterval: time.Millisecond}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if _, err := repo.WatchRef(nil, WatchRefOptions{Ref: "main"}); err == nil {
+		t.Fatalf("expected fromSHA error")
+	}
+}
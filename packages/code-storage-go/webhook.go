@@ -3,9 +3,11 @@ package storage
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"hash"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,7 +16,9 @@ import (
 
 const defaultWebhookMaxAgeSeconds = 300
 
-// ParseSignatureHeader parses the X-Pierre-Signature header.
+// ParseSignatureHeader parses the X-Pierre-Signature header. When the
+// header carries both sha512 and sha256 signatures the sha512 one is
+// returned, so a downgrade to the weaker digest is never accepted.
 func ParseSignatureHeader(header string) *ParsedWebhookSignature {
 	header = strings.TrimSpace(header)
 	if header == "" {
@@ -22,7 +26,8 @@ func ParseSignatureHeader(header string) *ParsedWebhookSignature {
 	}
 
 	var timestamp string
-	var signature string
+	var sha256Signature string
+	var sha512Signature string
 
 	parts := strings.Split(header, ",")
 	for _, part := range parts {
@@ -34,18 +39,26 @@ func ParseSignatureHeader(header string) *ParsedWebhookSignature {
 		case "t":
 			timestamp = kv[1]
 		case "sha256":
-			signature = kv[1]
+			sha256Signature = kv[1]
+		case "sha512":
+			sha512Signature = kv[1]
 		}
 	}
 
-	if timestamp == "" || signature == "" {
+	if timestamp == "" {
 		return nil
 	}
-
-	return &ParsedWebhookSignature{Timestamp: timestamp, Signature: signature}
+	switch {
+	case sha512Signature != "":
+		return &ParsedWebhookSignature{Timestamp: timestamp, Signature: sha512Signature, Algorithm: "sha512"}
+	case sha256Signature != "":
+		return &ParsedWebhookSignature{Timestamp: timestamp, Signature: sha256Signature, Algorithm: "sha256"}
+	}
+	return nil
 }
 
-// ValidateWebhookSignature validates the HMAC signature and timestamp.
+// ValidateWebhookSignature validates the HMAC signature and timestamp. The
+// signature may match secret or any of options.PreviousSecrets.
 func ValidateWebhookSignature(payload []byte, signatureHeader string, secret string, options WebhookValidationOptions) WebhookValidationResult {
 	if strings.TrimSpace(secret) == "" {
 		return WebhookValidationResult{Valid: false, Error: "empty secret is not allowed"}
@@ -56,9 +69,53 @@ func ValidateWebhookSignature(payload []byte, signatureHeader string, secret str
 		return WebhookValidationResult{Valid: false, Error: "invalid signature header format"}
 	}
 
-	timestamp, err := strconv.ParseInt(parsed.Timestamp, 10, 64)
+	timestamp, failure := checkWebhookTimestamp(parsed.Timestamp, options)
+	if failure != nil {
+		return *failure
+	}
+
+	provided, err := hex.DecodeString(parsed.Signature)
 	if err != nil {
-		return WebhookValidationResult{Valid: false, Error: "invalid timestamp in signature"}
+		return WebhookValidationResult{Valid: false, Error: "invalid signature", Timestamp: timestamp}
+	}
+
+	for _, candidate := range append([]string{secret}, options.PreviousSecrets...) {
+		if strings.TrimSpace(candidate) == "" {
+			continue
+		}
+		expected := webhookHMAC(payload, candidate, parsed.Timestamp, parsed.Algorithm)
+		if len(expected) == len(provided) && hmac.Equal(expected, provided) {
+			return WebhookValidationResult{Valid: true, Timestamp: timestamp}
+		}
+	}
+	return WebhookValidationResult{Valid: false, Error: "invalid signature", Timestamp: timestamp}
+}
+
+// SignWebhookPayload returns an X-Pierre-Signature header value for
+// payload signed with secret at timestamp (Unix seconds). It is intended
+// for tests of webhook receivers; pass time.Now().Unix() so the signature
+// falls inside the validation window.
+func SignWebhookPayload(payload []byte, secret string, timestamp int64) string {
+	ts := strconv.FormatInt(timestamp, 10)
+	return "t=" + ts + ",sha256=" + hex.EncodeToString(webhookHMAC(payload, secret, ts, "sha256"))
+}
+
+func webhookHMAC(payload []byte, secret string, timestamp string, algorithm string) []byte {
+	digest := sha256.New
+	if algorithm == "sha512" {
+		digest = func() hash.Hash { return sha512.New() }
+	}
+	mac := hmac.New(digest, []byte(secret))
+	_, _ = mac.Write([]byte(timestamp + "." + string(payload)))
+	return mac.Sum(nil)
+}
+
+// checkWebhookTimestamp parses a signature timestamp and enforces the
+// replay window.
+func checkWebhookTimestamp(value string, options WebhookValidationOptions) (int64, *WebhookValidationResult) {
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, &WebhookValidationResult{Valid: false, Error: "invalid timestamp in signature"}
 	}
 
 	maxAge := options.MaxAgeSeconds
@@ -69,48 +126,61 @@ func ValidateWebhookSignature(payload []byte, signatureHeader string, secret str
 		now := time.Now().Unix()
 		age := now - timestamp
 		if age > int64(maxAge) {
-			return WebhookValidationResult{Valid: false, Error: "webhook timestamp too old (" + strconv.FormatInt(age, 10) + " seconds)", Timestamp: timestamp}
+			return timestamp, &WebhookValidationResult{Valid: false, Error: "webhook timestamp too old (" + strconv.FormatInt(age, 10) + " seconds)", Timestamp: timestamp}
 		}
 		if age < -60 {
-			return WebhookValidationResult{Valid: false, Error: "webhook timestamp is in the future", Timestamp: timestamp}
+			return timestamp, &WebhookValidationResult{Valid: false, Error: "webhook timestamp is in the future", Timestamp: timestamp}
 		}
 	}
+	return timestamp, nil
+}
 
-	signedData := parsed.Timestamp + "." + string(payload)
-	mac := hmac.New(sha256.New, []byte(secret))
-	_, _ = mac.Write([]byte(signedData))
-	expected := mac.Sum(nil)
-	provided, err := hex.DecodeString(parsed.Signature)
-	if err != nil {
-		return WebhookValidationResult{Valid: false, Error: "invalid signature", Timestamp: timestamp}
+// ValidateWebhook validates the webhook signature and parses the payload.
+// Deliveries with Content-Type application/cloudevents+json are parsed as
+// CloudEvents envelopes and need no X-Pierre-Event header.
+func ValidateWebhook(payload []byte, headers http.Header, secret string, options WebhookValidationOptions) WebhookValidation {
+	signatureHeader, eventType, failure := webhookHeaders(headers, options)
+	if failure != nil {
+		return *failure
 	}
-
-	if len(expected) != len(provided) || !hmac.Equal(expected, provided) {
-		return WebhookValidationResult{Valid: false, Error: "invalid signature", Timestamp: timestamp}
+	validation := checkWebhookReplay(payload, ValidateWebhookSignature(payload, signatureHeader, secret, options), options)
+	if isCloudEventRequest(headers) {
+		return parseValidatedCloudEvent(payload, validation)
 	}
-
-	return WebhookValidationResult{Valid: true, Timestamp: timestamp}
+	return parseValidatedWebhook(payload, eventType, validation)
 }
 
-// ValidateWebhook validates the webhook signature and parses the payload.
-func ValidateWebhook(payload []byte, headers http.Header, secret string, options WebhookValidationOptions) WebhookValidation {
-	signatureHeader := headers.Get("X-Pierre-Signature")
+func webhookHeaders(headers http.Header, options WebhookValidationOptions) (string, string, *WebhookValidation) {
+	signatureName := webhookHeaderName(options.SignatureHeader, "X-Pierre-Signature")
+	signatureHeader := headers.Get(signatureName)
 	if signatureHeader == "" {
-		signatureHeader = headers.Get("x-pierre-signature")
+		signatureHeader = headers.Get(strings.ToLower(signatureName))
 	}
 	if signatureHeader == "" {
-		return WebhookValidation{WebhookValidationResult: WebhookValidationResult{Valid: false, Error: "missing or invalid X-Pierre-Signature header"}}
+		return "", "", &WebhookValidation{WebhookValidationResult: WebhookValidationResult{Valid: false, Error: "missing or invalid " + signatureName + " header"}}
 	}
 
-	eventType := headers.Get("X-Pierre-Event")
+	eventName := webhookHeaderName(options.EventHeader, "X-Pierre-Event")
+	eventType := headers.Get(eventName)
 	if eventType == "" {
-		eventType = headers.Get("x-pierre-event")
+		eventType = headers.Get(strings.ToLower(eventName))
 	}
-	if eventType == "" {
-		return WebhookValidation{WebhookValidationResult: WebhookValidationResult{Valid: false, Error: "missing or invalid X-Pierre-Event header"}}
+	if eventType == "" && !isCloudEventRequest(headers) {
+		return "", "", &WebhookValidation{WebhookValidationResult: WebhookValidationResult{Valid: false, Error: "missing or invalid " + eventName + " header"}}
 	}
+	return signatureHeader, eventType, nil
+}
 
-	validation := ValidateWebhookSignature(payload, signatureHeader, secret, options)
+func webhookHeaderName(configured string, fallback string) string {
+	if name := strings.TrimSpace(configured); name != "" {
+		return name
+	}
+	return fallback
+}
+
+// parseValidatedWebhook parses the payload once its signature has been
+// checked.
+func parseValidatedWebhook(payload []byte, eventType string, validation WebhookValidationResult) WebhookValidation {
 	if !validation.Valid {
 		return WebhookValidation{WebhookValidationResult: validation}
 	}
@@ -144,6 +214,14 @@ type rawWebhookPushEvent struct {
 	After      string `json:"after"`
 	CustomerID string `json:"customer_id"`
 	PushedAt   string `json:"pushed_at"`
+	Sequence   int64  `json:"sequence"`
+}
+
+type rawWebhookPingEvent struct {
+	WebhookID  string `json:"webhook_id"`
+	DeliveryID string `json:"delivery_id"`
+	CustomerID string `json:"customer_id"`
+	SentAt     string `json:"sent_at"`
 }
 
 func convertWebhookPayload(eventType string, payload []byte) (WebhookEventPayload, error) {
@@ -164,6 +242,25 @@ func convertWebhookPayload(eventType string, payload []byte) (WebhookEventPayloa
 			CustomerID:  raw.CustomerID,
 			PushedAt:    parseTime(raw.PushedAt),
 			RawPushedAt: raw.PushedAt,
+			Sequence:    raw.Sequence,
+		}}, nil
+	}
+
+	if eventType == "ping" {
+		var raw rawWebhookPingEvent
+		if err := json.Unmarshal(payload, &raw); err != nil {
+			return WebhookEventPayload{}, err
+		}
+		if raw.WebhookID == "" || raw.SentAt == "" {
+			return WebhookEventPayload{}, errors.New("invalid ping payload")
+		}
+		return WebhookEventPayload{Ping: &WebhookPingEvent{
+			Type:       "ping",
+			WebhookID:  raw.WebhookID,
+			DeliveryID: raw.DeliveryID,
+			CustomerID: raw.CustomerID,
+			SentAt:     parseTime(raw.SentAt),
+			RawSentAt:  raw.SentAt,
 		}}, nil
 	}
 
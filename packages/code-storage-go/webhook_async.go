@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAsyncWebhookWorkers      = 4
+	defaultAsyncWebhookQueueSize    = 100
+	defaultAsyncWebhookMaxAttempts  = 3
+	defaultAsyncWebhookRetryBackoff = time.Second
+)
+
+// ErrWebhookQueueFull is returned by AsyncWebhookProcessor.Enqueue when
+// the queue has no room. ServeHTTP answers 503 so the sender redelivers.
+var ErrWebhookQueueFull = errors.New("webhook queue is full")
+
+// ErrWebhookProcessorClosed is returned by AsyncWebhookProcessor.Enqueue
+// after Close.
+var ErrWebhookProcessorClosed = errors.New("webhook processor is closed")
+
+// AsyncWebhookOptions configures NewAsyncWebhookProcessor.
+type AsyncWebhookOptions struct {
+	// Workers is the number of deliveries handled at once. Zero uses 4.
+	Workers int
+	// QueueSize bounds the deliveries waiting for a worker. Zero uses 100.
+	QueueSize int
+	// MaxAttempts is the number of times a delivery's handlers run before
+	// it is dead-lettered. Zero uses 3.
+	MaxAttempts int
+	// RetryBackoff is the wait before the first retry, doubling after each
+	// attempt. Zero uses one second.
+	RetryBackoff time.Duration
+	// OnDeadLetter is called from a worker with deliveries that failed
+	// every attempt or were abandoned by Close.
+	OnDeadLetter func(WebhookDeadLetter)
+}
+
+// WebhookDeadLetter is a validated delivery whose handlers never
+// succeeded. Err is the error from the last attempt.
+type WebhookDeadLetter struct {
+	EventType string
+	Event     WebhookEventPayload
+	Attempts  int
+	Err       error
+}
+
+// AsyncWebhookProcessor validates deliveries synchronously and runs the
+// router's handlers on a bounded worker pool, so slow handlers do not make
+// the sender time out and redeliver. Every handler for an event runs again
+// on retry, so handlers should be idempotent.
+type AsyncWebhookProcessor struct {
+	router  *WebhookRouter
+	options AsyncWebhookOptions
+	queue   chan asyncWebhookJob
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+}
+
+type asyncWebhookJob struct {
+	eventType string
+	event     *WebhookEventPayload
+}
+
+// NewAsyncWebhookProcessor starts the workers for router's handlers. Call
+// Close to stop them.
+func NewAsyncWebhookProcessor(router *WebhookRouter, options AsyncWebhookOptions) (*AsyncWebhookProcessor, error) {
+	if router == nil {
+		return nil, errors.New("asyncWebhookProcessor router is required")
+	}
+	if options.Workers < 0 || options.QueueSize < 0 || options.MaxAttempts < 0 || options.RetryBackoff < 0 {
+		return nil, errors.New("asyncWebhookProcessor options must not be negative")
+	}
+	if options.Workers == 0 {
+		options.Workers = defaultAsyncWebhookWorkers
+	}
+	if options.QueueSize == 0 {
+		options.QueueSize = defaultAsyncWebhookQueueSize
+	}
+	if options.MaxAttempts == 0 {
+		options.MaxAttempts = defaultAsyncWebhookMaxAttempts
+	}
+	if options.RetryBackoff == 0 {
+		options.RetryBackoff = defaultAsyncWebhookRetryBackoff
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &AsyncWebhookProcessor{
+		router:  router,
+		options: options,
+		queue:   make(chan asyncWebhookJob, options.QueueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	for i := 0; i < options.Workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p, nil
+}
+
+// Enqueue validates a delivery and queues it for the handlers. It returns
+// a *WebhookValidationError for invalid deliveries, ErrWebhookQueueFull
+// when the queue has no room, and ErrWebhookProcessorClosed after Close.
+func (p *AsyncWebhookProcessor) Enqueue(ctx context.Context, payload []byte, headers http.Header) (*WebhookEventPayload, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	validation, err := p.router.validate(ctx, payload, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.queueJob(asyncWebhookJob{eventType: validation.EventType, event: validation.Payload}); err != nil {
+		forgetWebhookReplay(payload, validation.WebhookValidationResult, p.router.options.Validation)
+		return nil, err
+	}
+	return validation.Payload, nil
+}
+
+func (p *AsyncWebhookProcessor) queueJob(job asyncWebhookJob) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrWebhookProcessorClosed
+	}
+	select {
+	case p.queue <- job:
+		return nil
+	default:
+		return ErrWebhookQueueFull
+	}
+}
+
+// ServeHTTP validates and queues a delivery, answering 202 once it is
+// queued, or 200 with the ping response body for pings. Invalid deliveries
+// get 401, and a full queue or closed processor 503.
+func (p *AsyncWebhookProcessor) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	payload, failure, status := readWebhookRequest(req, p.router.options.Validation.MaxBodyBytes)
+	if failure != nil {
+		http.Error(w, failure.Error, status)
+		return
+	}
+
+	event, err := p.Enqueue(req.Context(), payload, req.Header)
+	var validationErr *WebhookValidationError
+	switch {
+	case err == nil && event.Ping != nil:
+		WriteWebhookPingResponse(w, *event.Ping)
+	case err == nil:
+		w.WriteHeader(http.StatusAccepted)
+	case errors.As(err, &validationErr):
+		http.Error(w, validationErr.Result.Error, http.StatusUnauthorized)
+	default:
+		w.Header().Set("Retry-After", strconv.Itoa(int(p.options.RetryBackoff/time.Second)+1))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	}
+}
+
+// Close stops accepting deliveries and waits for queued ones to finish.
+// If ctx ends first, Close cancels handler contexts and returns ctx's error
+// without waiting; workers dead-letter the remaining deliveries in the
+// background once their handlers return.
+func (p *AsyncWebhookProcessor) Close(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+func (p *AsyncWebhookProcessor) work() {
+	defer p.wg.Done()
+	for job := range p.queue {
+		p.process(job)
+	}
+}
+
+func (p *AsyncWebhookProcessor) process(job asyncWebhookJob) {
+	backoff := p.options.RetryBackoff
+	var err error
+	attempts := 0
+	for attempts < p.options.MaxAttempts && p.ctx.Err() == nil {
+		if attempts > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-p.ctx.Done():
+				timer.Stop()
+			}
+			if p.ctx.Err() != nil {
+				break
+			}
+			backoff *= 2
+		}
+		attempts++
+		if err = p.router.handle(p.ctx, job.eventType, job.event); err == nil {
+			return
+		}
+	}
+	if err == nil {
+		err = p.ctx.Err()
+	}
+	if p.options.OnDeadLetter != nil {
+		p.options.OnDeadLetter(WebhookDeadLetter{EventType: job.eventType, Event: *job.event, Attempts: attempts, Err: err})
+	}
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncWebhookProcessor(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	router, err := NewWebhookRouter(WebhookRouterOptions{Secret: secret, Validation: WebhookValidationOptions{ReplayStore: NewMemoryReplayStore(0)}})
+	if err != nil {
+		t.Fatalf("router error: %v", err)
+	}
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	release := make(chan struct{})
+	router.OnPush(func(ctx context.Context, event WebhookPushEvent) error {
+		mu.Lock()
+		calls[event.After]++
+		n := calls[event.After]
+		mu.Unlock()
+		switch event.After {
+		case "slow":
+			<-release
+		case "flaky":
+			if n == 1 {
+				return errors.New("temporary failure")
+			}
+		case "broken":
+			return errors.New("permanent failure")
+		}
+		return nil
+	})
+
+	var dead []WebhookDeadLetter
+	processor, err := NewAsyncWebhookProcessor(router, AsyncWebhookOptions{
+		Workers:      1,
+		QueueSize:    2,
+		RetryBackoff: time.Millisecond,
+		OnDeadLetter: func(letter WebhookDeadLetter) {
+			mu.Lock()
+			dead = append(dead, letter)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("processor error: %v", err)
+	}
+
+	timestamp := time.Now().Unix()
+	send := func(after string, signingSecret string) int {
+		payload := []byte(`{"repository":{"id":"repo","url":"https://git.example.com/org/repo"},"ref":"main","before":"abc","after":"` + after + `","customer_id":"cust","pushed_at":"2024-01-20T10:30:00Z"}`)
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(payload))
+		req.Header.Set("X-Pierre-Signature", SignWebhookPayload(payload, signingSecret, timestamp))
+		req.Header.Set("X-Pierre-Event", "push")
+		rec := httptest.NewRecorder()
+		processor.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// The slow delivery occupies the only worker, so the next two fill the
+	// queue and a fourth is turned away.
+	if code := send("slow", secret); code != http.StatusAccepted {
+		t.Fatalf("expected 202 while the handler is still running, got %d", code)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		started := calls["slow"] == 1
+		mu.Unlock()
+		if started || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if code := send("flaky", secret); code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", code)
+	}
+	if code := send("broken", secret); code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", code)
+	}
+	if code := send("overflow", secret); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for a full queue, got %d", code)
+	}
+	if code := send("forged", "wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a bad signature, got %d", code)
+	}
+
+	close(release)
+	if err := processor.Close(nil); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	if code := send("late", secret); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 after close, got %d", code)
+	}
+	if code := send("overflow", secret); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected a turned-away delivery not to count as a replay, got %d", code)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["flaky"] != 2 || calls["broken"] != 3 || calls["overflow"] != 0 || calls["forged"] != 0 || calls["late"] != 0 {
+		t.Fatalf("unexpected handler calls: %v", calls)
+	}
+	if len(dead) != 1 || dead[0].Event.Push.After != "broken" || dead[0].Attempts != 3 || dead[0].Err == nil {
+		t.Fatalf("unexpected dead letters: %+v", dead)
+	}
+}
+
+func TestAsyncWebhookProcessorCloseTimeout(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	router, err := NewWebhookRouter(WebhookRouterOptions{Secret: secret})
+	if err != nil {
+		t.Fatalf("router error: %v", err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	router.OnPush(func(ctx context.Context, event WebhookPushEvent) error {
+		close(started)
+		<-release
+		return nil
+	})
+	processor, err := NewAsyncWebhookProcessor(router, AsyncWebhookOptions{Workers: 1})
+	if err != nil {
+		t.Fatalf("processor error: %v", err)
+	}
+
+	payload := []byte(keyWebhookPayload)
+	headers := http.Header{}
+	headers.Set("X-Pierre-Signature", SignWebhookPayload(payload, secret, time.Now().Unix()))
+	headers.Set("X-Pierre-Event", "push")
+	if _, err := processor.Enqueue(nil, payload, headers); err != nil {
+		t.Fatalf("enqueue error: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	closed := make(chan error, 1)
+	go func() { closed <- processor.Close(ctx) }()
+	select {
+	case err := <-closed:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close waited for a handler that ignores its context")
+	}
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+const defaultWebhookMaxBodyBytes = 1 << 20
+
+type webhookContextKey struct{}
+
+// ValidateRequest reads the body of an incoming webhook request and
+// validates it like ValidateWebhook. Bodies larger than
+// options.MaxBodyBytes are rejected without being read in full. The body is
+// replaced with the bytes read so later handlers can read it again.
+func ValidateRequest(r *http.Request, secret string, options WebhookValidationOptions) WebhookValidation {
+	payload, failure, _ := readWebhookRequest(r, options.MaxBodyBytes)
+	if failure != nil {
+		return *failure
+	}
+	return ValidateWebhook(payload, r.Header, secret, options)
+}
+
+// WebhookHandler wraps next with webhook validation. Valid deliveries reach
+// next with the parsed payload available from WebhookFromContext. Invalid
+// ones are answered with 401, and oversized bodies with 413, without
+// calling next.
+func WebhookHandler(secret string, options WebhookValidationOptions, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, failure, status := readWebhookRequest(r, options.MaxBodyBytes)
+		if failure != nil {
+			http.Error(w, failure.Error, status)
+			return
+		}
+		validation := ValidateWebhook(payload, r.Header, secret, options)
+		if !validation.Valid {
+			http.Error(w, validation.Error, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), webhookContextKey{}, validation)))
+	})
+}
+
+// WebhookFromContext returns the validated delivery stored by
+// WebhookHandler.
+func WebhookFromContext(ctx context.Context) (WebhookValidation, bool) {
+	if ctx == nil {
+		return WebhookValidation{}, false
+	}
+	validation, ok := ctx.Value(webhookContextKey{}).(WebhookValidation)
+	return validation, ok
+}
+
+// readWebhookRequest reads at most limit bytes of the body, defaulting to
+// 1 MiB, and returns the HTTP status to answer with on failure.
+func readWebhookRequest(r *http.Request, limit int64) ([]byte, *WebhookValidation, int) {
+	if limit <= 0 {
+		limit = defaultWebhookMaxBodyBytes
+	}
+	if r == nil || r.Body == nil {
+		return nil, &WebhookValidation{WebhookValidationResult: WebhookValidationResult{Valid: false, Error: "missing webhook body"}}, http.StatusBadRequest
+	}
+	payload, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	_ = r.Body.Close()
+	if err != nil {
+		return nil, &WebhookValidation{WebhookValidationResult: WebhookValidationResult{Valid: false, Error: "failed to read webhook body"}}, http.StatusBadRequest
+	}
+	if int64(len(payload)) > limit {
+		return nil, &WebhookValidation{WebhookValidationResult: WebhookValidationResult{Valid: false, Error: "webhook body too large"}}, http.StatusRequestEntityTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+	return payload, nil, 0
+}
@@ -0,0 +1,349 @@
+package storage
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebhookKeySetTTL             = time.Hour
+	defaultWebhookKeySetRefreshInterval = time.Minute
+	defaultWebhookKeySetTimeout         = 10 * time.Second
+)
+
+// WebhookKeyResolver returns the public key that signed a webhook. keyID is
+// the kid from the signature header and may be empty.
+type WebhookKeyResolver interface {
+	WebhookKey(ctx context.Context, keyID string) (crypto.PublicKey, error)
+}
+
+// StaticWebhookKeys resolves webhook keys from a fixed map of key ID to
+// ed25519.PublicKey or *ecdsa.PublicKey. With a single entry, signatures
+// without a key ID use it.
+type StaticWebhookKeys map[string]crypto.PublicKey
+
+// WebhookKey implements WebhookKeyResolver.
+func (k StaticWebhookKeys) WebhookKey(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	return lookupWebhookKey(k, keyID)
+}
+
+// WebhookKeySetOptions configures a JWKS-backed WebhookKeySet.
+type WebhookKeySetOptions struct {
+	// URL of the JSON Web Key Set publishing the signing keys.
+	URL string
+	// HTTPClient fetches the key set. Defaults to http.DefaultClient. Each
+	// fetch is limited to 10 seconds whichever client is used.
+	HTTPClient *http.Client
+	// TTL is how long fetched keys are trusted before refetching. Defaults
+	// to one hour.
+	TTL time.Duration
+	// RefreshInterval is the minimum time between fetches triggered by an
+	// unknown key ID, which bounds traffic from forged signatures. Defaults
+	// to one minute.
+	RefreshInterval time.Duration
+}
+
+// WebhookKeySet fetches and caches webhook signing keys from a JWKS
+// endpoint. Unknown key IDs trigger a refetch, so rotated keys are picked up
+// without a restart. If a refetch fails, previously fetched keys keep
+// working. It is safe for concurrent use: one fetch runs at a time and
+// lookups of cached keys never wait for it.
+type WebhookKeySet struct {
+	options WebhookKeySetOptions
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	// refreshing is closed when the in-flight fetch finishes.
+	refreshing chan struct{}
+	refreshErr error
+}
+
+// NewWebhookKeySet creates a key set for options.URL. Keys are fetched
+// lazily on first use.
+func NewWebhookKeySet(options WebhookKeySetOptions) (*WebhookKeySet, error) {
+	if strings.TrimSpace(options.URL) == "" {
+		return nil, errors.New("webhook key set url is required")
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = http.DefaultClient
+	}
+	if options.TTL <= 0 {
+		options.TTL = defaultWebhookKeySetTTL
+	}
+	if options.RefreshInterval <= 0 {
+		options.RefreshInterval = defaultWebhookKeySetRefreshInterval
+	}
+	return &WebhookKeySet{options: options}, nil
+}
+
+// WebhookKey implements WebhookKeyResolver.
+func (s *WebhookKeySet) WebhookKey(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.mu.Lock()
+	now := time.Now()
+	stale := s.keys == nil || now.Sub(s.fetchedAt) >= s.options.TTL
+	_, known := s.keys[keyID]
+	if stale || (keyID != "" && !known) {
+		refreshing := s.refreshing
+		if refreshing == nil && now.Sub(s.lastAttempt) >= s.options.RefreshInterval {
+			s.lastAttempt = now
+			refreshing = make(chan struct{})
+			s.refreshing = refreshing
+			// The fetch is shared by every caller that waits on it, so one
+			// caller giving up does not cancel it.
+			go s.refresh(context.WithoutCancel(ctx), refreshing)
+		}
+		if refreshing != nil {
+			s.mu.Unlock()
+			select {
+			case <-refreshing:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			s.mu.Lock()
+		}
+	}
+	defer s.mu.Unlock()
+
+	if s.keys == nil && s.refreshErr != nil {
+		return nil, s.refreshErr
+	}
+	return lookupWebhookKey(s.keys, keyID)
+}
+
+func (s *WebhookKeySet) refresh(ctx context.Context, done chan struct{}) {
+	// Bound the fetch whatever client is configured: callers without a
+	// deadline would otherwise wait on a hung endpoint forever.
+	ctx, cancel := context.WithTimeout(ctx, defaultWebhookKeySetTimeout)
+	defer cancel()
+	keys, err := fetchWebhookKeySet(ctx, s.options.HTTPClient, s.options.URL)
+
+	s.mu.Lock()
+	if err == nil {
+		s.keys = keys
+		s.fetchedAt = time.Now()
+	}
+	s.refreshErr = err
+	s.refreshing = nil
+	s.mu.Unlock()
+	close(done)
+}
+
+func lookupWebhookKey(keys map[string]crypto.PublicKey, keyID string) (crypto.PublicKey, error) {
+	if keyID == "" {
+		if len(keys) == 1 {
+			for _, key := range keys {
+				return key, nil
+			}
+		}
+		return nil, errors.New("webhook signature has no key id")
+	}
+	key, ok := keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown webhook signing key %q", keyID)
+	}
+	return key, nil
+}
+
+type jsonWebKey struct {
+	KeyID string `json:"kid"`
+	Type  string `json:"kty"`
+	Curve string `json:"crv"`
+	Use   string `json:"use"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+func fetchWebhookKeySet(ctx context.Context, client *http.Client, url string) (map[string]crypto.PublicKey, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Code-Storage-Agent", userAgent())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch webhook key set: unexpected status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("fetch webhook key set: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(payload.Keys))
+	for _, jwk := range payload.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJSONWebKey(jwk)
+		if err != nil {
+			// Skip key types this SDK does not verify.
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("fetch webhook key set: no supported signing keys")
+	}
+	return keys, nil
+}
+
+func parseJSONWebKey(jwk jsonWebKey) (crypto.PublicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case jwk.Type == "OKP" && jwk.Curve == "Ed25519":
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case jwk.Type == "EC" && jwk.Curve == "P-256":
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 key size")
+		}
+		// ecdh rejects points that are not on the curve.
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s/%s", jwk.Type, jwk.Curve)
+	}
+}
+
+// parsedKeySignature is an asymmetric X-Pierre-Signature header of the form
+// "t=<unix>,kid=<key id>,ed25519=<sig>" or "...,es256=<sig>", where sig is
+// unpadded base64url.
+type parsedKeySignature struct {
+	timestamp string
+	keyID     string
+	algorithm string
+	signature []byte
+}
+
+func parseKeySignatureHeader(header string) *parsedKeySignature {
+	parsed := &parsedKeySignature{}
+	var encoded string
+	for _, part := range strings.Split(strings.TrimSpace(header), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			parsed.timestamp = kv[1]
+		case "kid":
+			parsed.keyID = kv[1]
+		case "ed25519", "es256":
+			parsed.algorithm = kv[0]
+			encoded = kv[1]
+		}
+	}
+	if parsed.timestamp == "" || encoded == "" {
+		return nil
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil
+	}
+	parsed.signature = signature
+	return parsed
+}
+
+// ValidateWebhookSignatureWithKeys validates an Ed25519 or ECDSA P-256
+// signature and its timestamp against keys, so receivers need no shared
+// secret. The signed data is the same "<timestamp>.<payload>" string used by
+// HMAC signatures.
+func ValidateWebhookSignatureWithKeys(ctx context.Context, payload []byte, signatureHeader string, keys WebhookKeyResolver, options WebhookValidationOptions) WebhookValidationResult {
+	if keys == nil {
+		return WebhookValidationResult{Valid: false, Error: "webhook key resolver is required"}
+	}
+	parsed := parseKeySignatureHeader(signatureHeader)
+	if parsed == nil {
+		return WebhookValidationResult{Valid: false, Error: "invalid signature header format"}
+	}
+
+	timestamp, failure := checkWebhookTimestamp(parsed.timestamp, options)
+	if failure != nil {
+		return *failure
+	}
+
+	key, err := keys.WebhookKey(ctx, parsed.keyID)
+	if err != nil {
+		return WebhookValidationResult{Valid: false, Error: err.Error(), Timestamp: timestamp}
+	}
+
+	signedData := []byte(parsed.timestamp + "." + string(payload))
+	if !verifyWebhookKeySignature(key, parsed.algorithm, signedData, parsed.signature) {
+		return WebhookValidationResult{Valid: false, Error: "invalid signature", Timestamp: timestamp}
+	}
+	return WebhookValidationResult{Valid: true, Timestamp: timestamp}
+}
+
+func verifyWebhookKeySignature(key crypto.PublicKey, algorithm string, data []byte, signature []byte) bool {
+	switch pub := key.(type) {
+	case ed25519.PublicKey:
+		return algorithm == "ed25519" && ed25519.Verify(pub, data, signature)
+	case *ecdsa.PublicKey:
+		if algorithm != "es256" || pub.Curve != elliptic.P256() {
+			return false
+		}
+		digest := sha256.Sum256(data)
+		if len(signature) == 64 {
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			return ecdsa.Verify(pub, digest[:], r, s)
+		}
+		return ecdsa.VerifyASN1(pub, digest[:], signature)
+	default:
+		return false
+	}
+}
+
+// ValidateWebhookWithKeys validates a public-key webhook signature and
+// parses the payload, like ValidateWebhook.
+func ValidateWebhookWithKeys(ctx context.Context, payload []byte, headers http.Header, keys WebhookKeyResolver, options WebhookValidationOptions) WebhookValidation {
+	signatureHeader, eventType, failure := webhookHeaders(headers, options)
+	if failure != nil {
+		return *failure
+	}
+	validation := checkWebhookReplay(payload, ValidateWebhookSignatureWithKeys(ctx, payload, signatureHeader, keys, options), options)
+	if isCloudEventRequest(headers) {
+		return parseValidatedCloudEvent(payload, validation)
+	}
+	return parseValidatedWebhook(payload, eventType, validation)
+}
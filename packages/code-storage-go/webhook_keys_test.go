@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const keyWebhookPayload = `{"repository":{"id":"repo","url":"https://git.example.com/org/repo"},"ref":"main","before":"abc","after":"def","customer_id":"cust","pushed_at":"2024-01-20T10:30:00Z"}`
+
+func TestValidateWebhookWithKeys(t *testing.T) {
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key: %v", err)
+	}
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ecdsa key: %v", err)
+	}
+
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"keys":[` +
+			`{"kid":"ed-1","kty":"OKP","crv":"Ed25519","use":"sig","x":"` + base64.RawURLEncoding.EncodeToString(edPublic) + `"},` +
+			`{"kid":"ec-1","kty":"EC","crv":"P-256","x":"` + base64.RawURLEncoding.EncodeToString(ecPrivate.X.FillBytes(make([]byte, 32))) + `","y":"` + base64.RawURLEncoding.EncodeToString(ecPrivate.Y.FillBytes(make([]byte, 32))) + `"},` +
+			`{"kid":"rsa-1","kty":"RSA","n":"AQAB","e":"AQAB"}]}`))
+	}))
+	defer server.Close()
+
+	keys, err := NewWebhookKeySet(WebhookKeySetOptions{URL: server.URL})
+	if err != nil {
+		t.Fatalf("key set error: %v", err)
+	}
+
+	payload := []byte(keyWebhookPayload)
+	stamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signed := []byte(stamp + "." + string(payload))
+
+	edSig := ed25519.Sign(edPrivate, signed)
+	headers := http.Header{}
+	headers.Set("X-Pierre-Signature", "t="+stamp+",kid=ed-1,ed25519="+base64.RawURLEncoding.EncodeToString(edSig))
+	headers.Set("X-Pierre-Event", "push")
+	result := ValidateWebhookWithKeys(nil, payload, headers, keys, WebhookValidationOptions{})
+	if !result.Valid || result.Payload == nil || result.Payload.Push == nil {
+		t.Fatalf("expected valid ed25519 webhook: %+v", result)
+	}
+
+	digest := sha256.Sum256(signed)
+	r, s, err := ecdsa.Sign(rand.Reader, ecPrivate, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	ecSig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	ecHeader := "t=" + stamp + ",kid=ec-1,es256=" + base64.RawURLEncoding.EncodeToString(ecSig)
+	if result := ValidateWebhookSignatureWithKeys(nil, payload, ecHeader, keys, WebhookValidationOptions{}); !result.Valid {
+		t.Fatalf("expected valid es256 signature: %+v", result)
+	}
+
+	tampered := ValidateWebhookSignatureWithKeys(nil, []byte(`{"tampered":true}`), ecHeader, keys, WebhookValidationOptions{})
+	if tampered.Valid || tampered.Error != "invalid signature" {
+		t.Fatalf("expected tampered payload to fail: %+v", tampered)
+	}
+	mismatched := "t=" + stamp + ",kid=ec-1,ed25519=" + base64.RawURLEncoding.EncodeToString(edSig)
+	if result := ValidateWebhookSignatureWithKeys(nil, payload, mismatched, keys, WebhookValidationOptions{}); result.Valid {
+		t.Fatalf("expected algorithm mismatch to fail")
+	}
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	oldSig := ed25519.Sign(edPrivate, []byte(old+"."+string(payload)))
+	if result := ValidateWebhookSignatureWithKeys(nil, payload, "t="+old+",kid=ed-1,ed25519="+base64.RawURLEncoding.EncodeToString(oldSig), keys, WebhookValidationOptions{}); result.Valid {
+		t.Fatalf("expected stale timestamp to fail")
+	}
+
+	// Unknown key IDs refetch at most once per refresh interval.
+	unknown := "t=" + stamp + ",kid=rotated,ed25519=" + base64.RawURLEncoding.EncodeToString(edSig)
+	for i := 0; i < 3; i++ {
+		if result := ValidateWebhookSignatureWithKeys(nil, payload, unknown, keys, WebhookValidationOptions{}); result.Valid {
+			t.Fatalf("expected unknown key to fail")
+		}
+	}
+	if fetches.Load() != 1 {
+		t.Fatalf("expected a single key set fetch, got %d", fetches.Load())
+	}
+}
+
+func TestWebhookKeySetRefreshesOutsideLock(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	x := base64.RawURLEncoding.EncodeToString(public)
+
+	var fetches atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := `{"keys":[{"kid":"ed-1","kty":"OKP","crv":"Ed25519","x":"` + x + `"}]}`
+		if fetches.Add(1) > 1 {
+			close(started)
+			<-release
+			body = `{"keys":[{"kid":"ed-1","kty":"OKP","crv":"Ed25519","x":"` + x + `"},{"kid":"ed-2","kty":"OKP","crv":"Ed25519","x":"` + x + `"}]}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	keys, err := NewWebhookKeySet(WebhookKeySetOptions{URL: server.URL})
+	if err != nil {
+		t.Fatalf("key set error: %v", err)
+	}
+	if _, err := keys.WebhookKey(nil, "ed-1"); err != nil {
+		t.Fatalf("initial fetch error: %v", err)
+	}
+	keys.mu.Lock()
+	keys.lastAttempt = time.Time{}
+	keys.mu.Unlock()
+
+	const callers = 3
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			_, err := keys.WebhookKey(nil, "ed-2")
+			errs <- err
+		}()
+	}
+	<-started
+
+	cached := make(chan error, 1)
+	go func() {
+		_, err := keys.WebhookKey(nil, "ed-1")
+		cached <- err
+	}()
+	select {
+	case err := <-cached:
+		if err != nil {
+			t.Fatalf("cached key error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("cached key lookup waited on the key set fetch")
+	}
+
+	close(release)
+	for i := 0; i < callers; i++ {
+		<-errs
+	}
+	if fetches.Load() != 2 {
+		t.Fatalf("expected one shared refresh, got %d fetches", fetches.Load())
+	}
+	if _, err := keys.WebhookKey(nil, "ed-2"); err != nil {
+		t.Fatalf("expected refreshed key, got %v", err)
+	}
+}
+
+type deadlineTransport struct {
+	deadline time.Time
+	ok       bool
+}
+
+func (d *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	d.deadline, d.ok = req.Context().Deadline()
+	return nil, errors.New("key set unavailable")
+}
+
+func TestWebhookKeySetBoundsCustomClient(t *testing.T) {
+	transport := &deadlineTransport{}
+	keys, err := NewWebhookKeySet(WebhookKeySetOptions{URL: "https://keys.example/jwks", HTTPClient: &http.Client{Transport: transport}})
+	if err != nil {
+		t.Fatalf("key set error: %v", err)
+	}
+	if _, err := keys.WebhookKey(nil, "ed-1"); err == nil {
+		t.Fatalf("expected fetch error")
+	}
+	if !transport.ok || time.Until(transport.deadline) > defaultWebhookKeySetTimeout {
+		t.Fatalf("expected the fetch to carry a %s deadline, got %v (%t)", defaultWebhookKeySetTimeout, transport.deadline, transport.ok)
+	}
+}
+
+func TestStaticWebhookKeys(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	payload := []byte(keyWebhookPayload)
+	stamp := strconv.FormatInt(time.Now().Unix(), 10)
+	sig := base64.RawURLEncoding.EncodeToString(ed25519.Sign(private, []byte(stamp+"."+string(payload))))
+
+	keys := StaticWebhookKeys{"only": public}
+	if result := ValidateWebhookSignatureWithKeys(nil, payload, "t="+stamp+",ed25519="+sig, keys, WebhookValidationOptions{}); !result.Valid {
+		t.Fatalf("expected single key to be used without kid: %+v", result)
+	}
+	if result := ValidateWebhookSignatureWithKeys(nil, payload, "t="+stamp+",sha256=abc", keys, WebhookValidationOptions{}); result.Valid || result.Error != "invalid signature header format" {
+		t.Fatalf("expected HMAC header to be rejected: %+v", result)
+	}
+	if _, err := NewWebhookKeySet(WebhookKeySetOptions{}); err == nil {
+		t.Fatalf("expected url validation error")
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SendWebhookPingOptions configures SendWebhookPing.
+type SendWebhookPingOptions struct {
+	InvocationOptions
+	WebhookID string
+}
+
+// WebhookPingResult reports how the endpoint answered a ping delivery.
+// Delivered is true when it responded with a 2xx status.
+type WebhookPingResult struct {
+	DeliveryID string
+	Delivered  bool
+	// StatusCode is the endpoint's HTTP status, or 0 when it could not be
+	// reached; Error then describes the failure.
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+}
+
+// SendWebhookPing asks the server to deliver a signed ping event to a
+// configured webhook endpoint and waits for the endpoint's response. Receivers
+// parse it with ValidateWebhook as WebhookEventPayload.Ping.
+func (c *Client) SendWebhookPing(ctx context.Context, options SendWebhookPingOptions) (WebhookPingResult, error) {
+	webhookID := strings.TrimSpace(options.WebhookID)
+	if webhookID == "" {
+		return WebhookPingResult{}, errors.New("sendWebhookPing webhookID is required")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := c.generateJWT("org", RemoteURLOptions{Permissions: []Permission{PermissionOrgWrite}, TTL: ttl})
+	if err != nil {
+		return WebhookPingResult{}, err
+	}
+
+	resp, err := c.api.post(ctx, "webhooks/ping", nil, &webhookPingRequest{WebhookID: webhookID}, jwtToken, nil)
+	if err != nil {
+		return WebhookPingResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload webhookPingResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return WebhookPingResult{}, err
+	}
+	return WebhookPingResult{
+		DeliveryID: payload.DeliveryID,
+		Delivered:  payload.Delivered,
+		StatusCode: payload.StatusCode,
+		Error:      payload.Error,
+		Duration:   time.Duration(payload.DurationMS) * time.Millisecond,
+	}, nil
+}
+
+// ResponseBody returns the JSON body a receiver answers a ping with. It
+// echoes the webhook and delivery IDs so the sender can match the reply to
+// the ping it sent.
+func (e WebhookPingEvent) ResponseBody() []byte {
+	data, _ := json.Marshal(webhookPongResponse{Pong: true, WebhookID: e.WebhookID, DeliveryID: e.DeliveryID})
+	return data
+}
+
+// WriteWebhookPingResponse answers a ping with 200 and ResponseBody.
+// WebhookRouter does this automatically.
+func WriteWebhookPingResponse(w http.ResponseWriter, event WebhookPingEvent) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(event.ResponseBody())
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ReplayStore remembers webhook deliveries so a captured request cannot be
+// replayed while its timestamp is still inside the validation window.
+// Seen records key and reports whether it had already been recorded.
+// Forget drops a key so the delivery can be retried; WebhookRouter and
+// AsyncWebhookProcessor call it when a delivery fails or cannot be queued.
+// Implementations must be safe for concurrent use; a shared store such as
+// Redis SETNX is needed when several processes receive webhooks.
+type ReplayStore interface {
+	Seen(key string) bool
+	Forget(key string)
+}
+
+// MemoryReplayStore is an in-process ReplayStore that forgets keys after a
+// TTL.
+type MemoryReplayStore struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	keys map[string]time.Time
+	now  func() time.Time
+}
+
+// NewMemoryReplayStore returns a MemoryReplayStore that keeps keys for ttl.
+// The TTL should cover the validation window; zero uses six minutes, the
+// default five-minute window plus the allowed clock skew.
+func NewMemoryReplayStore(ttl time.Duration) *MemoryReplayStore {
+	if ttl <= 0 {
+		ttl = time.Duration(defaultWebhookMaxAgeSeconds+60) * time.Second
+	}
+	return &MemoryReplayStore{ttl: ttl, keys: make(map[string]time.Time), now: time.Now}
+}
+
+// Seen records key and reports whether it was recorded within the TTL.
+func (s *MemoryReplayStore) Seen(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for k, expires := range s.keys {
+		if !now.Before(expires) {
+			delete(s.keys, k)
+		}
+	}
+	if _, ok := s.keys[key]; ok {
+		return true
+	}
+	s.keys[key] = now.Add(s.ttl)
+	return false
+}
+
+// Forget removes key so a retried delivery is accepted again.
+func (s *MemoryReplayStore) Forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, key)
+}
+
+// checkWebhookReplay rejects a correctly signed delivery that the replay
+// store has already seen. Deliveries are keyed by their timestamp and a
+// digest of the payload, the material the signature covers. The signature
+// header itself is not used: its formatting can be varied without the
+// secret, and ECDSA signatures are malleable.
+func checkWebhookReplay(payload []byte, validation WebhookValidationResult, options WebhookValidationOptions) WebhookValidationResult {
+	if options.ReplayStore == nil || !validation.Valid {
+		return validation
+	}
+	if options.ReplayStore.Seen(webhookReplayKey(validation.Timestamp, payload)) {
+		validation.Valid = false
+		validation.Error = "webhook delivery already processed"
+	}
+	return validation
+}
+
+// forgetWebhookReplay releases the replay key of a validated delivery that
+// was not handled, so the sender's retry is not rejected as a replay.
+func forgetWebhookReplay(payload []byte, validation WebhookValidationResult, options WebhookValidationOptions) {
+	if options.ReplayStore == nil {
+		return
+	}
+	options.ReplayStore.Forget(webhookReplayKey(validation.Timestamp, payload))
+}
+
+func webhookReplayKey(timestamp int64, payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return "payload:" + strconv.FormatInt(timestamp, 10) + ":" + hex.EncodeToString(sum[:])
+}
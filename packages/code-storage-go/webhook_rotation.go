@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// RotateWebhookSecretOptions configures RotateWebhookSecret.
+type RotateWebhookSecretOptions struct {
+	InvocationOptions
+	WebhookID string
+	// GracePeriod is how long the old secret keeps signing deliveries
+	// alongside the new one, rounded up to whole seconds. Zero uses the
+	// server default.
+	GracePeriod time.Duration
+}
+
+// WebhookSecretRotation is the result of RotateWebhookSecret.
+type WebhookSecretRotation struct {
+	WebhookID string
+	// Secret is the new signing secret. It is only returned once.
+	Secret string
+	// PreviousSecretExpiresAt is when the old secret stops being accepted
+	// by the server; receivers should keep it in
+	// WebhookValidationOptions.PreviousSecrets until then.
+	PreviousSecretExpiresAt    time.Time
+	RawPreviousSecretExpiresAt string
+}
+
+// RotateWebhookSecret issues a new signing secret for a webhook. Until
+// PreviousSecretExpiresAt deliveries may be signed with either secret, so
+// deploy the new secret as the primary and the old one in
+// WebhookValidationOptions.PreviousSecrets before the grace period ends.
+func (c *Client) RotateWebhookSecret(ctx context.Context, options RotateWebhookSecretOptions) (WebhookSecretRotation, error) {
+	webhookID := strings.TrimSpace(options.WebhookID)
+	if webhookID == "" {
+		return WebhookSecretRotation{}, errors.New("rotateWebhookSecret webhookID is required")
+	}
+	if options.GracePeriod < 0 {
+		return WebhookSecretRotation{}, errors.New("rotateWebhookSecret gracePeriod must not be negative")
+	}
+
+	ttl := resolveInvocationTTL(ctx, options.InvocationOptions, defaultTokenTTL)
+	jwtToken, err := c.generateJWT("org", RemoteURLOptions{Permissions: []Permission{PermissionOrgWrite}, TTL: ttl})
+	if err != nil {
+		return WebhookSecretRotation{}, err
+	}
+
+	body := &rotateWebhookSecretRequest{WebhookID: webhookID, GracePeriodSeconds: durationSecondsCeil(options.GracePeriod)}
+	resp, err := c.api.post(ctx, "webhooks/secret/rotate", nil, body, jwtToken, nil)
+	if err != nil {
+		return WebhookSecretRotation{}, err
+	}
+	defer resp.Body.Close()
+
+	var payload rotateWebhookSecretResponse
+	if err := decodeJSON(resp, &payload); err != nil {
+		return WebhookSecretRotation{}, err
+	}
+	return WebhookSecretRotation{
+		WebhookID:                  payload.WebhookID,
+		Secret:                     payload.Secret,
+		PreviousSecretExpiresAt:    parseTime(payload.PreviousSecretExpiresAt),
+		RawPreviousSecretExpiresAt: payload.PreviousSecretExpiresAt,
+	}, nil
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// WebhookRouterOptions configures NewWebhookRouter. Set Secret for HMAC
+// signatures or Keys for public-key signatures.
+type WebhookRouterOptions struct {
+	Secret     string
+	Keys       WebhookKeyResolver
+	Validation WebhookValidationOptions
+}
+
+// WebhookValidationError is returned by WebhookRouter.Dispatch when a
+// delivery fails signature or payload validation. No handler runs.
+type WebhookValidationError struct {
+	Result WebhookValidationResult
+}
+
+func (e *WebhookValidationError) Error() string {
+	return "webhook validation failed: " + e.Result.Error
+}
+
+// WebhookPanicError reports a handler panic recovered by WebhookRouter.
+type WebhookPanicError struct {
+	EventType string
+	Value     interface{}
+	Stack     []byte
+}
+
+func (e *WebhookPanicError) Error() string {
+	return fmt.Sprintf("webhook %s handler panicked: %v", e.EventType, e.Value)
+}
+
+// WebhookRouter validates webhook deliveries and dispatches them to typed
+// handlers. Register handlers before serving; every handler registered for
+// an event runs, and their errors are joined.
+type WebhookRouter struct {
+	options WebhookRouterOptions
+	push    []func(context.Context, WebhookPushEvent) error
+	ping    []func(context.Context, WebhookPingEvent) error
+	unknown []func(context.Context, WebhookUnknownEvent) error
+}
+
+// NewWebhookRouter returns a router that validates deliveries with the
+// configured secret or keys.
+func NewWebhookRouter(options WebhookRouterOptions) (*WebhookRouter, error) {
+	if strings.TrimSpace(options.Secret) == "" && options.Keys == nil {
+		return nil, errors.New("webhookRouter secret or keys is required")
+	}
+	return &WebhookRouter{options: options}, nil
+}
+
+// OnPush registers a handler for push events.
+func (r *WebhookRouter) OnPush(handler func(context.Context, WebhookPushEvent) error) *WebhookRouter {
+	r.push = append(r.push, handler)
+	return r
+}
+
+// OnPing registers a handler for ping events.
+func (r *WebhookRouter) OnPing(handler func(context.Context, WebhookPingEvent) error) *WebhookRouter {
+	r.ping = append(r.ping, handler)
+	return r
+}
+
+// OnUnknown registers a handler for event types this SDK does not model.
+func (r *WebhookRouter) OnUnknown(handler func(context.Context, WebhookUnknownEvent) error) *WebhookRouter {
+	r.unknown = append(r.unknown, handler)
+	return r
+}
+
+// Dispatch validates a delivery and runs the handlers for its event.
+// Events without handlers are accepted and ignored. Validation failures
+// return a *WebhookValidationError and handler panics a
+// *WebhookPanicError.
+func (r *WebhookRouter) Dispatch(ctx context.Context, payload []byte, headers http.Header) error {
+	_, err := r.dispatch(ctx, payload, headers)
+	return err
+}
+
+func (r *WebhookRouter) dispatch(ctx context.Context, payload []byte, headers http.Header) (*WebhookEventPayload, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	validation, err := r.validate(ctx, payload, headers)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.handle(ctx, validation.EventType, validation.Payload); err != nil {
+		forgetWebhookReplay(payload, validation.WebhookValidationResult, r.options.Validation)
+		return validation.Payload, err
+	}
+	return validation.Payload, nil
+}
+
+func (r *WebhookRouter) validate(ctx context.Context, payload []byte, headers http.Header) (WebhookValidation, error) {
+	var validation WebhookValidation
+	if r.options.Keys != nil {
+		validation = ValidateWebhookWithKeys(ctx, payload, headers, r.options.Keys, r.options.Validation)
+	} else {
+		validation = ValidateWebhook(payload, headers, r.options.Secret, r.options.Validation)
+	}
+	if !validation.Valid || validation.Payload == nil {
+		return WebhookValidation{}, &WebhookValidationError{Result: validation.WebhookValidationResult}
+	}
+	return validation, nil
+}
+
+// handle runs every handler registered for a validated event.
+func (r *WebhookRouter) handle(ctx context.Context, eventType string, event *WebhookEventPayload) error {
+	var errs []error
+	switch {
+	case event.Push != nil:
+		for _, handler := range r.push {
+			errs = append(errs, runWebhookHandler(eventType, func() error { return handler(ctx, *event.Push) }))
+		}
+	case event.Ping != nil:
+		for _, handler := range r.ping {
+			errs = append(errs, runWebhookHandler(eventType, func() error { return handler(ctx, *event.Ping) }))
+		}
+	case event.Unknown != nil:
+		for _, handler := range r.unknown {
+			errs = append(errs, runWebhookHandler(eventType, func() error { return handler(ctx, *event.Unknown) }))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ServeHTTP reads a delivery and dispatches it. It responds 204 when every
+// handler succeeds, 401 when validation fails so the sender does not
+// retry a forged request, and 500 when a handler fails so it retries.
+// Successful pings are answered with 200 and the ping response body, so a
+// new endpoint verifies without an OnPing handler.
+func (r *WebhookRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	payload, failure, status := readWebhookRequest(req, r.options.Validation.MaxBodyBytes)
+	if failure != nil {
+		http.Error(w, failure.Error, status)
+		return
+	}
+
+	event, err := r.dispatch(req.Context(), payload, req.Header)
+	var validationErr *WebhookValidationError
+	switch {
+	case err == nil && event.Ping != nil:
+		WriteWebhookPingResponse(w, *event.Ping)
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.As(err, &validationErr):
+		http.Error(w, validationErr.Result.Error, http.StatusUnauthorized)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func runWebhookHandler(eventType string, handler func() error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = &WebhookPanicError{EventType: eventType, Value: recovered, Stack: debug.Stack()}
+		}
+	}()
+	return handler()
+}
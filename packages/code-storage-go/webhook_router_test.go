@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookRouter(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	pushPayload := []byte(`{"repository":{"id":"repo_abc123","url":"https://git.example.com/org/repo"},"ref":"main","before":"abc123","after":"def456","customer_id":"cust_123","pushed_at":"2024-01-20T10:30:00Z"}`)
+
+	var pushes []string
+	var unknown []string
+	router, err := NewWebhookRouter(WebhookRouterOptions{Secret: secret})
+	if err != nil {
+		t.Fatalf("router error: %v", err)
+	}
+	router.
+		OnPush(func(ctx context.Context, event WebhookPushEvent) error {
+			pushes = append(pushes, event.After)
+			return nil
+		}).
+		OnPush(func(ctx context.Context, event WebhookPushEvent) error {
+			return errors.New("index unavailable")
+		}).
+		OnPush(func(ctx context.Context, event WebhookPushEvent) error {
+			panic("boom")
+		}).
+		OnUnknown(func(ctx context.Context, event WebhookUnknownEvent) error {
+			unknown = append(unknown, event.Type)
+			return nil
+		})
+
+	deliver := func(event string, payload []byte, signingSecret string) error {
+		headers := http.Header{}
+		headers.Set("X-Pierre-Signature", buildSignatureHeader(t, payload, signingSecret, time.Now().Unix()))
+		headers.Set("X-Pierre-Event", event)
+		return router.Dispatch(nil, payload, headers)
+	}
+
+	err = deliver("push", pushPayload, secret)
+	if len(pushes) != 1 || pushes[0] != "def456" {
+		t.Fatalf("expected every push handler to run, got %v", pushes)
+	}
+	var panicErr *WebhookPanicError
+	if err == nil || !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Fatalf("expected joined handler errors with a recovered panic, got %v", err)
+	}
+	if !bytes.Contains([]byte(err.Error()), []byte("index unavailable")) {
+		t.Fatalf("expected handler error to be aggregated, got %v", err)
+	}
+
+	if err := deliver("branch.created", []byte(`{"branch":"feature"}`), secret); err != nil || len(unknown) != 1 || unknown[0] != "branch.created" {
+		t.Fatalf("expected unknown handler, got %v (%v)", unknown, err)
+	}
+	if err := deliver("ping", []byte(`{"webhook_id":"wh_1","sent_at":"2024-01-20T10:30:00Z"}`), secret); err != nil {
+		t.Fatalf("expected unhandled ping to be ignored, got %v", err)
+	}
+
+	var validationErr *WebhookValidationError
+	if err := deliver("push", pushPayload, "wrong-secret"); !errors.As(err, &validationErr) || len(pushes) != 1 {
+		t.Fatalf("expected validation error without dispatch, got %v", err)
+	}
+
+	if _, err := NewWebhookRouter(WebhookRouterOptions{}); err == nil {
+		t.Fatalf("expected secret or keys error")
+	}
+}
+
+func TestWebhookRouterServeHTTP(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	payload := []byte(`{"webhook_id":"wh_1","delivery_id":"dlv_1","sent_at":"2024-01-20T10:30:00Z"}`)
+	fail := false
+	router, err := NewWebhookRouter(WebhookRouterOptions{Secret: secret, Validation: WebhookValidationOptions{MaxBodyBytes: 128, ReplayStore: NewMemoryReplayStore(0)}})
+	if err != nil {
+		t.Fatalf("router error: %v", err)
+	}
+	router.OnPing(func(ctx context.Context, event WebhookPingEvent) error {
+		if fail {
+			return errors.New("try again")
+		}
+		return nil
+	})
+
+	var body string
+	send := func(payload []byte, signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(payload))
+		req.Header.Set("X-Pierre-Signature", signature)
+		req.Header.Set("X-Pierre-Event", "ping")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		body = rec.Body.String()
+		return rec.Code
+	}
+
+	signature := buildSignatureHeader(t, payload, secret, time.Now().Unix())
+	fail = true
+	if code := send(payload, signature); code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 for handler failure, got %d", code)
+	}
+	fail = false
+	if code := send(payload, signature); code != http.StatusOK || body != `{"pong":true,"webhook_id":"wh_1","delivery_id":"dlv_1"}` {
+		t.Fatalf("expected redelivery after a failure to be handled, got %d %s", code, body)
+	}
+	if code := send(payload, signature); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a replayed delivery, got %d", code)
+	}
+	if code := send(payload, "t=1,sha256=00"); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for bad signature, got %d", code)
+	}
+	if code := send(bytes.Repeat([]byte("x"), 256), signature); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for oversized body, got %d", code)
+	}
+}
+
+func TestWebhookRouterPingWithoutHandler(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	router, err := NewWebhookRouter(WebhookRouterOptions{Secret: secret})
+	if err != nil {
+		t.Fatalf("router error: %v", err)
+	}
+	router.OnUnknown(func(ctx context.Context, event WebhookUnknownEvent) error {
+		t.Errorf("ping must not reach the unknown handler")
+		return nil
+	})
+
+	payload := []byte(`{"webhook_id":"wh_1","sent_at":"2024-01-20T10:30:00Z"}`)
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(payload))
+	req.Header.Set("X-Pierre-Signature", SignWebhookPayload(payload, secret, time.Now().Unix()))
+	req.Header.Set("X-Pierre-Event", "ping")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" || rec.Body.String() != `{"pong":true,"webhook_id":"wh_1"}` {
+		t.Fatalf("unexpected ping response: %d %s", rec.Code, rec.Body.String())
+	}
+}
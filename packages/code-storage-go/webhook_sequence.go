@@ -0,0 +1,90 @@
+package storage
+
+import "sync"
+
+// WebhookSequenceStatus classifies a push delivery against the last seen
+// sequence number for its repository.
+type WebhookSequenceStatus string
+
+const (
+	// WebhookSequenceInOrder is the next expected delivery, or the first
+	// delivery seen for the repository.
+	WebhookSequenceInOrder WebhookSequenceStatus = "in_order"
+	// WebhookSequenceGap skipped one or more sequence numbers.
+	WebhookSequenceGap WebhookSequenceStatus = "gap"
+	// WebhookSequenceStale is a duplicate or late delivery at or below the
+	// last seen sequence number.
+	WebhookSequenceStale WebhookSequenceStatus = "stale"
+	// WebhookSequenceUnsequenced carries no sequence number.
+	WebhookSequenceUnsequenced WebhookSequenceStatus = "unsequenced"
+)
+
+// WebhookSequenceGapInfo describes missing deliveries for a repository. From
+// and To are the inclusive range of sequence numbers that were not seen.
+type WebhookSequenceGapInfo struct {
+	RepositoryID string
+	From         int64
+	To           int64
+}
+
+// WebhookSequencerOptions configures a WebhookSequencer.
+type WebhookSequencerOptions struct {
+	// OnGap is called synchronously when a delivery skips sequence numbers,
+	// so callers can reconcile by re-reading branches or commits.
+	OnGap func(gap WebhookSequenceGapInfo)
+}
+
+// WebhookSequencer tracks the last seen push sequence per repository. It is
+// safe for concurrent use. State is in memory; use LastSequence and
+// SetLastSequence to persist it across restarts.
+type WebhookSequencer struct {
+	mu    sync.Mutex
+	last  map[string]int64
+	onGap func(gap WebhookSequenceGapInfo)
+}
+
+// NewWebhookSequencer creates a sequencer.
+func NewWebhookSequencer(options WebhookSequencerOptions) *WebhookSequencer {
+	return &WebhookSequencer{last: make(map[string]int64), onGap: options.OnGap}
+}
+
+// Observe records a push event and reports how it relates to earlier
+// deliveries. Stale deliveries do not move the last seen sequence.
+func (s *WebhookSequencer) Observe(event *WebhookPushEvent) WebhookSequenceStatus {
+	if event == nil || event.Sequence <= 0 {
+		return WebhookSequenceUnsequenced
+	}
+
+	repoID := event.Repository.ID
+	s.mu.Lock()
+	last, seen := s.last[repoID]
+	if seen && event.Sequence <= last {
+		s.mu.Unlock()
+		return WebhookSequenceStale
+	}
+	s.last[repoID] = event.Sequence
+	s.mu.Unlock()
+
+	if !seen || event.Sequence == last+1 {
+		return WebhookSequenceInOrder
+	}
+	if s.onGap != nil {
+		s.onGap(WebhookSequenceGapInfo{RepositoryID: repoID, From: last + 1, To: event.Sequence - 1})
+	}
+	return WebhookSequenceGap
+}
+
+// LastSequence returns the last seen sequence for a repository.
+func (s *WebhookSequencer) LastSequence(repoID string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.last[repoID]
+	return last, ok
+}
+
+// SetLastSequence restores the last seen sequence for a repository.
+func (s *WebhookSequencer) SetLastSequence(repoID string, sequence int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last[repoID] = sequence
+}
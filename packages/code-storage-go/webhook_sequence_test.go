@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWebhookPushSequenceParsed(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	payload := []byte(`{"repository":{"id":"repo","url":"https://git.example.com/org/repo"},"ref":"main","before":"abc","after":"def","customer_id":"cust","pushed_at":"2024-01-20T10:30:00Z","sequence":42}`)
+	headers := http.Header{}
+	headers.Set("x-pierre-signature", buildSignatureHeader(t, payload, secret, time.Now().Unix()))
+	headers.Set("x-pierre-event", "push")
+
+	result := ValidateWebhook(payload, headers, secret, WebhookValidationOptions{})
+	if !result.Valid || result.Payload == nil || result.Payload.Push == nil {
+		t.Fatalf("expected valid push webhook: %s", result.Error)
+	}
+	if result.Payload.Push.Sequence != 42 {
+		t.Fatalf("unexpected sequence: %d", result.Payload.Push.Sequence)
+	}
+}
+
+func TestWebhookSequencer(t *testing.T) {
+	var gaps []WebhookSequenceGapInfo
+	sequencer := NewWebhookSequencer(WebhookSequencerOptions{
+		OnGap: func(gap WebhookSequenceGapInfo) { gaps = append(gaps, gap) },
+	})
+	push := func(repoID string, sequence int64) *WebhookPushEvent {
+		return &WebhookPushEvent{Repository: WebhookRepository{ID: repoID}, Sequence: sequence}
+	}
+
+	if status := sequencer.Observe(push("a", 5)); status != WebhookSequenceInOrder {
+		t.Fatalf("expected first delivery in order, got %s", status)
+	}
+	if status := sequencer.Observe(push("a", 6)); status != WebhookSequenceInOrder {
+		t.Fatalf("expected in order, got %s", status)
+	}
+	if status := sequencer.Observe(push("a", 9)); status != WebhookSequenceGap {
+		t.Fatalf("expected gap, got %s", status)
+	}
+	if len(gaps) != 1 || gaps[0] != (WebhookSequenceGapInfo{RepositoryID: "a", From: 7, To: 8}) {
+		t.Fatalf("unexpected gaps: %+v", gaps)
+	}
+	if status := sequencer.Observe(push("a", 7)); status != WebhookSequenceStale {
+		t.Fatalf("expected stale, got %s", status)
+	}
+	if last, ok := sequencer.LastSequence("a"); !ok || last != 9 {
+		t.Fatalf("unexpected last sequence: %d %v", last, ok)
+	}
+	if status := sequencer.Observe(push("b", 0)); status != WebhookSequenceUnsequenced {
+		t.Fatalf("expected unsequenced, got %s", status)
+	}
+
+	sequencer.SetLastSequence("b", 10)
+	if status := sequencer.Observe(push("b", 12)); status != WebhookSequenceGap {
+		t.Fatalf("expected gap after restore, got %s", status)
+	}
+	if len(gaps) != 2 || gaps[1].From != 11 || gaps[1].To != 11 {
+		t.Fatalf("unexpected gaps: %+v", gaps)
+	}
+}
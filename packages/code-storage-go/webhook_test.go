@@ -1,10 +1,15 @@
 package storage
 
 import (
+	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"strings"
 	"testing"
@@ -46,6 +51,38 @@ func TestParseSignatureHeader(t *testing.T) {
 	}
 }
 
+func TestValidateWebhookSignatureSHA512(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	payload := []byte(`{"webhook_id":"wh_1","sent_at":"2024-01-20T10:30:00Z"}`)
+	now := time.Now().Unix()
+	ts := strconv.FormatInt(now, 10)
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write([]byte(ts + "." + string(payload)))
+	sha512Signature := hex.EncodeToString(mac.Sum(nil))
+	sha256Header := SignWebhookPayload(payload, secret, now)
+	sha256Signature := strings.SplitN(sha256Header, "sha256=", 2)[1]
+
+	parsed := ParseSignatureHeader("t=" + ts + ",sha256=" + sha256Signature + ",sha512=" + sha512Signature)
+	if parsed == nil || parsed.Algorithm != "sha512" || parsed.Signature != sha512Signature {
+		t.Fatalf("expected sha512 to be preferred, got %+v", parsed)
+	}
+
+	for _, header := range []string{
+		"t=" + ts + ",sha512=" + sha512Signature,
+		"t=" + ts + ",sha256=" + sha256Signature + ",sha512=" + sha512Signature,
+	} {
+		if result := ValidateWebhookSignature(payload, header, secret, WebhookValidationOptions{}); !result.Valid {
+			t.Fatalf("expected %q to validate, got %s", header, result.Error)
+		}
+	}
+
+	// A valid sha256 signature must not rescue a bad sha512 one.
+	header := "t=" + ts + ",sha256=" + sha256Signature + ",sha512=" + strings.Repeat("00", 64)
+	if result := ValidateWebhookSignature(payload, header, secret, WebhookValidationOptions{}); result.Valid {
+		t.Fatalf("expected invalid sha512 signature to fail")
+	}
+}
+
 func TestValidateWebhookSignature(t *testing.T) {
 	secret := "test_webhook_secret_key_123"
 	payload := []byte(`{"repository":{"id":"repo","url":"https://git.example.com/org/repo"},"ref":"main","before":"abc","after":"def","customer_id":"cust","pushed_at":"2024-01-20T10:30:00Z"}`)
@@ -110,6 +147,23 @@ func TestValidateWebhookSignature(t *testing.T) {
 	}
 }
 
+func TestSignWebhookPayload(t *testing.T) {
+	payload := []byte(`{"webhook_id":"wh_1","sent_at":"2024-01-20T10:30:00Z"}`)
+	secret := "test_webhook_secret_key_123"
+	timestamp := time.Now().Unix()
+
+	header := SignWebhookPayload(payload, secret, timestamp)
+	if header != buildSignatureHeader(t, payload, secret, timestamp) {
+		t.Fatalf("unexpected signature header: %s", header)
+	}
+	if result := ValidateWebhookSignature(payload, header, secret, WebhookValidationOptions{}); !result.Valid || result.Timestamp != timestamp {
+		t.Fatalf("expected signed payload to validate, got %+v", result)
+	}
+	if result := ValidateWebhookSignature(payload, header, "other", WebhookValidationOptions{}); result.Valid {
+		t.Fatalf("expected other secret to fail")
+	}
+}
+
 func TestValidateWebhook(t *testing.T) {
 	secret := "test_webhook_secret_key_123"
 	payload := []byte(`{"repository":{"id":"repo_abc123","url":"https://git.example.com/org/repo"},"ref":"main","before":"abc123","after":"def456","customer_id":"cust_123","pushed_at":"2024-01-20T10:30:00Z"}`)
@@ -195,6 +249,223 @@ func TestWebhookEmptyInputs(t *testing.T) {
 	}
 }
 
+func TestWebhookHandler(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	payload := []byte(`{"repository":{"id":"repo_abc123","url":"https://git.example.com/org/repo"},"ref":"main","before":"abc123","after":"def456","customer_id":"cust_123","pushed_at":"2024-01-20T10:30:00Z"}`)
+	newRequest := func(body []byte, signature string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewReader(body))
+		req.Header.Set("X-Pierre-Signature", signature)
+		req.Header.Set("X-Pierre-Event", "push")
+		return req
+	}
+	signature := buildSignatureHeader(t, payload, secret, time.Now().Unix())
+
+	req := newRequest(payload, signature)
+	if result := ValidateRequest(req, secret, WebhookValidationOptions{}); !result.Valid || result.Payload.Push == nil {
+		t.Fatalf("expected valid request, got %+v", result)
+	}
+	if body, _ := io.ReadAll(req.Body); !bytes.Equal(body, payload) {
+		t.Fatalf("expected body to be readable after validation")
+	}
+
+	var got WebhookValidation
+	handler := WebhookHandler(secret, WebhookValidationOptions{MaxBodyBytes: 512}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		if got, ok = WebhookFromContext(r.Context()); !ok {
+			t.Errorf("expected validated webhook in context")
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	serve := func(req *http.Request) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(newRequest(payload, signature)); code != http.StatusAccepted {
+		t.Fatalf("expected next handler to run, got %d", code)
+	}
+	if got.Payload == nil || got.Payload.Push == nil || got.Payload.Push.After != "def456" {
+		t.Fatalf("unexpected payload: %+v", got.Payload)
+	}
+	got = WebhookValidation{}
+	if code := serve(newRequest(payload, "t=1,sha256=00")); code != http.StatusUnauthorized || got.Valid {
+		t.Fatalf("expected 401 without calling next, got %d", code)
+	}
+	if code := serve(newRequest(bytes.Repeat([]byte("x"), 1024), signature)); code != http.StatusRequestEntityTooLarge || got.Valid {
+		t.Fatalf("expected 413 without calling next, got %d", code)
+	}
+}
+
+func TestValidateWebhookReplayStore(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	payload := []byte(`{"webhook_id":"wh_1","sent_at":"2024-01-20T10:30:00Z"}`)
+	store := NewMemoryReplayStore(0)
+	options := WebhookValidationOptions{ReplayStore: store}
+	headers := http.Header{}
+	headers.Set("X-Pierre-Signature", buildSignatureHeader(t, payload, secret, time.Now().Unix()))
+	headers.Set("X-Pierre-Event", "ping")
+
+	if result := ValidateWebhook(payload, headers, secret, options); !result.Valid {
+		t.Fatalf("expected first delivery to be valid, got %s", result.Error)
+	}
+	if result := ValidateWebhook(payload, headers, secret, options); result.Valid || result.Error != "webhook delivery already processed" {
+		t.Fatalf("expected replay to be rejected, got %+v", result.WebhookValidationResult)
+	}
+
+	headers.Set("X-Pierre-Delivery", "dlv_1")
+	if result := ValidateWebhook(payload, headers, secret, options); result.Valid {
+		t.Fatalf("expected an unsigned delivery id not to bypass replay protection")
+	}
+	captured := headers.Get("X-Pierre-Signature")
+	for _, variant := range []string{captured + ",x=1", " " + captured + " "} {
+		headers.Set("X-Pierre-Signature", variant)
+		if result := ValidateWebhook(payload, headers, secret, options); result.Valid {
+			t.Fatalf("expected replay with modified header %q to be rejected", variant)
+		}
+	}
+	timestamp := time.Now().Unix() + 1
+	headers.Set("X-Pierre-Signature", buildSignatureHeader(t, payload, secret, timestamp))
+	if result := ValidateWebhook(payload, headers, secret, options); !result.Valid {
+		t.Fatalf("expected a newly signed delivery to be valid, got %s", result.Error)
+	}
+
+	forgedPayload := []byte(`{"webhook_id":"wh_2","sent_at":"2024-01-20T10:30:00Z"}`)
+	forgedAt := time.Now().Unix()
+	forged := http.Header{}
+	forged.Set("X-Pierre-Signature", buildSignatureHeader(t, forgedPayload, "wrong", forgedAt))
+	forged.Set("X-Pierre-Event", "ping")
+	ValidateWebhook(forgedPayload, forged, secret, options)
+	if store.Seen(webhookReplayKey(forgedAt, forgedPayload)) {
+		t.Fatalf("expected invalid signatures not to be recorded")
+	}
+
+	store.Forget(webhookReplayKey(timestamp, payload))
+	if result := ValidateWebhook(payload, headers, secret, options); !result.Valid {
+		t.Fatalf("expected a forgotten delivery to be accepted again, got %s", result.Error)
+	}
+
+	now := time.Now()
+	store.now = func() time.Time { return now.Add(7 * time.Minute) }
+	if store.Seen(webhookReplayKey(timestamp, payload)) {
+		t.Fatalf("expected keys to expire after the TTL")
+	}
+}
+
+func TestValidateWebhookCloudEvent(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	envelope := []byte(`{"specversion":"1.0","id":"dlv_1","source":"https://git.example.com/org/repo","type":"com.pierre.push","time":"2024-01-20T10:30:01Z","datacontenttype":"application/json","data":{"repository":{"id":"repo_abc123","url":"https://git.example.com/org/repo"},"ref":"main","before":"abc123","after":"def456","customer_id":"cust_123","pushed_at":"2024-01-20T10:30:00Z"}}`)
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	headers.Set("X-Pierre-Signature", SignWebhookPayload(envelope, secret, time.Now().Unix()))
+
+	result := ValidateWebhook(envelope, headers, secret, WebhookValidationOptions{})
+	if !result.Valid || result.EventType != "push" {
+		t.Fatalf("expected valid CloudEvent, got %+v", result.WebhookValidationResult)
+	}
+	if result.Payload == nil || result.Payload.Push == nil || result.Payload.Push.After != "def456" {
+		t.Fatalf("unexpected payload: %+v", result.Payload)
+	}
+	if result.CloudEvent == nil || result.CloudEvent.ID != "dlv_1" || result.CloudEvent.Time.IsZero() {
+		t.Fatalf("unexpected envelope: %+v", result.CloudEvent)
+	}
+
+	headers.Set("X-Pierre-Signature", SignWebhookPayload(envelope, "other", time.Now().Unix()))
+	if result := ValidateWebhook(envelope, headers, secret, WebhookValidationOptions{}); result.Valid {
+		t.Fatalf("expected bad signature to fail")
+	}
+
+	event, err := ParseCloudEvent([]byte(`{"specversion":"1.0","id":"dlv_2","source":"s","type":"branch.created","data":{"branch":"feature"}}`))
+	if err != nil || event.Payload.Unknown == nil || event.Payload.Unknown.Type != "branch.created" {
+		t.Fatalf("expected unknown event, got %+v (%v)", event, err)
+	}
+	if _, err := ParseCloudEvent([]byte(`{"specversion":"0.3","id":"dlv_3","source":"s","type":"com.pierre.ping","data":{}}`)); err == nil {
+		t.Fatalf("expected unsupported specversion error")
+	}
+}
+
+func TestValidateWebhookCustomHeaders(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	payload := []byte(`{"webhook_id":"wh_1","sent_at":"2024-01-20T10:30:00Z"}`)
+	options := WebhookValidationOptions{SignatureHeader: "X-Acme-Signature", EventHeader: "X-Acme-Event", ReplayStore: NewMemoryReplayStore(0)}
+	headers := http.Header{}
+	headers.Set("X-Acme-Signature", SignWebhookPayload(payload, secret, time.Now().Unix()))
+	headers.Set("X-Acme-Event", "ping")
+
+	if result := ValidateWebhook(payload, headers, secret, options); !result.Valid || result.Payload.Ping == nil {
+		t.Fatalf("expected custom headers to validate, got %+v", result.WebhookValidationResult)
+	}
+	if result := ValidateWebhook(payload, headers, secret, options); result.Valid {
+		t.Fatalf("expected custom signature header to key replay protection")
+	}
+	if result := ValidateWebhook(payload, headers, secret, WebhookValidationOptions{}); result.Valid || result.Error != "missing or invalid X-Pierre-Signature header" {
+		t.Fatalf("expected default header names without overrides, got %+v", result.WebhookValidationResult)
+	}
+	headers.Del("X-Acme-Event")
+	if result := ValidateWebhook(payload, headers, secret, options); result.Error != "missing or invalid X-Acme-Event header" {
+		t.Fatalf("expected error to name the configured header, got %q", result.Error)
+	}
+}
+
+func TestSendWebhookPing(t *testing.T) {
+	secret := "test_webhook_secret_key_123"
+	var received *WebhookPingEvent
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		result := ValidateWebhook(payload, r.Header, secret, WebhookValidationOptions{})
+		if !result.Valid || result.Payload == nil || result.Payload.Ping == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received = result.Payload.Ping
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/webhooks/ping" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var body webhookPingRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		payload := []byte(`{"webhook_id":"` + body.WebhookID + `","delivery_id":"dlv_1","customer_id":"cust_123","sent_at":"2024-01-20T10:30:00Z"}`)
+		req, _ := http.NewRequest(http.MethodPost, receiver.URL, bytes.NewReader(payload))
+		req.Header.Set("X-Pierre-Signature", buildSignatureHeader(t, payload, secret, time.Now().Unix()))
+		req.Header.Set("X-Pierre-Event", "ping")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("deliver ping: %v", err)
+			return
+		}
+		resp.Body.Close()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"delivery_id":"dlv_1","delivered":` + strconv.FormatBool(resp.StatusCode < 300) + `,"status_code":` + strconv.Itoa(resp.StatusCode) + `,"duration_ms":42}`))
+	}))
+	defer api.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: api.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+
+	result, err := client.SendWebhookPing(nil, SendWebhookPingOptions{WebhookID: "wh_1"})
+	if err != nil {
+		t.Fatalf("ping error: %v", err)
+	}
+	if !result.Delivered || result.StatusCode != http.StatusNoContent || result.DeliveryID != "dlv_1" || result.Duration != 42*time.Millisecond {
+		t.Fatalf("unexpected ping result: %+v", result)
+	}
+	if received == nil || received.WebhookID != "wh_1" || received.SentAt.IsZero() {
+		t.Fatalf("unexpected ping event: %+v", received)
+	}
+
+	if _, err := client.SendWebhookPing(nil, SendWebhookPingOptions{}); err == nil {
+		t.Fatalf("expected webhook id error")
+	}
+}
+
 func buildSignatureHeader(t *testing.T, payload []byte, secret string, timestamp int64) string {
 	t.Helper()
 	mac := hmac.New(sha256.New, []byte(secret))
@@ -202,3 +473,55 @@ func buildSignatureHeader(t *testing.T, payload []byte, secret string, timestamp
 	signature := hex.EncodeToString(mac.Sum(nil))
 	return "t=" + strconv.FormatInt(timestamp, 10) + ",sha256=" + signature
 }
+
+func TestRotateWebhookSecret(t *testing.T) {
+	wantGrace := int64(86400)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/webhooks/secret/rotate" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		var body rotateWebhookSecretRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		if body.WebhookID != "wh_1" || body.GracePeriodSeconds != wantGrace {
+			t.Errorf("unexpected body: %+v", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"webhook_id":"wh_1","secret":"new_secret","previous_secret_expires_at":"2024-01-21T10:30:00Z"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Options{Name: "acme", Key: testKey, APIBaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("client error: %v", err)
+	}
+	rotation, err := client.RotateWebhookSecret(nil, RotateWebhookSecretOptions{WebhookID: "wh_1", GracePeriod: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("rotate error: %v", err)
+	}
+	if rotation.Secret != "new_secret" || rotation.PreviousSecretExpiresAt.IsZero() {
+		t.Fatalf("unexpected rotation: %+v", rotation)
+	}
+	// A sub-second grace period rounds up instead of becoming the default.
+	wantGrace = 1
+	if _, err := client.RotateWebhookSecret(nil, RotateWebhookSecretOptions{WebhookID: "wh_1", GracePeriod: 500 * time.Millisecond}); err != nil {
+		t.Fatalf("rotate error: %v", err)
+	}
+	if _, err := client.RotateWebhookSecret(nil, RotateWebhookSecretOptions{}); err == nil {
+		t.Fatalf("expected webhook id error")
+	}
+
+	payload := []byte(`{"webhook_id":"wh_1","sent_at":"2024-01-20T10:30:00Z"}`)
+	options := WebhookValidationOptions{PreviousSecrets: []string{"old_secret"}}
+	for _, signingSecret := range []string{"new_secret", "old_secret"} {
+		header := SignWebhookPayload(payload, signingSecret, time.Now().Unix())
+		if result := ValidateWebhookSignature(payload, header, rotation.Secret, options); !result.Valid {
+			t.Fatalf("expected %s signature to validate during the grace period, got %s", signingSecret, result.Error)
+		}
+	}
+	header := SignWebhookPayload(payload, "old_secret", time.Now().Unix())
+	if result := ValidateWebhookSignature(payload, header, rotation.Secret, WebhookValidationOptions{}); result.Valid {
+		t.Fatalf("expected old secret to fail once dropped")
+	}
+}
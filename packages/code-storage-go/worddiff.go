@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxWordDiffTokens bounds the per-line token matrix. Longer lines are
+// reported as changed in full.
+const maxWordDiffTokens = 2000
+
+// DiffRange is a half-open byte range [Start, End) within a line's Content.
+type DiffRange struct {
+	Start int
+	End   int
+}
+
+// WordHunks parses the unified diff of a file like Hunks and also fills
+// DiffLine.Changes with the intra-line differences of modified lines.
+func (f FileDiff) WordHunks() ([]Hunk, error) {
+	return DiffWordHunks(f.Raw)
+}
+
+// DiffWordHunks parses a single-file unified diff into hunks with word-level
+// changes. Within each block of deleted lines followed by added lines, the
+// lines are paired in order and compared word by word; lines without a
+// partner are left without Changes, since they changed in full.
+func DiffWordHunks(raw string) ([]Hunk, error) {
+	hunks, err := DiffHunks(raw)
+	if err != nil {
+		return nil, err
+	}
+	for h := range hunks {
+		lines := hunks[h].Lines
+		for i := 0; i < len(lines); {
+			if lines[i].Kind != DiffLineDeleted {
+				i++
+				continue
+			}
+			delStart := i
+			for i < len(lines) && lines[i].Kind == DiffLineDeleted {
+				i++
+			}
+			addStart := i
+			for i < len(lines) && lines[i].Kind == DiffLineAdded {
+				i++
+			}
+			deleted, added := addStart-delStart, i-addStart
+			for k := 0; k < deleted && k < added; k++ {
+				oldLine, newLine := &lines[delStart+k], &lines[addStart+k]
+				oldLine.Changes, newLine.Changes = WordDiff(oldLine.Content, newLine.Content)
+			}
+		}
+	}
+	return hunks, nil
+}
+
+// WordDiff compares two versions of a line and returns the byte ranges of
+// each that are not shared with the other. Lines are split into words,
+// whitespace runs, and single punctuation characters; changed ranges that
+// are separated only by whitespace are merged for readability.
+func WordDiff(oldText string, newText string) (oldChanges []DiffRange, newChanges []DiffRange) {
+	oldTokens, newTokens := wordTokens(oldText), wordTokens(newText)
+	if len(oldTokens) > maxWordDiffTokens || len(newTokens) > maxWordDiffTokens {
+		return wholeRange(oldText), wholeRange(newText)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// oldTokens[i:] and newTokens[j:].
+	lcs := make([][]int, len(oldTokens)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newTokens)+1)
+	}
+	for i := len(oldTokens) - 1; i >= 0; i-- {
+		for j := len(newTokens) - 1; j >= 0; j-- {
+			if oldText[oldTokens[i].Start:oldTokens[i].End] == newText[newTokens[j].Start:newTokens[j].End] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	oldChanged := make([]bool, len(oldTokens))
+	newChanged := make([]bool, len(newTokens))
+	i, j := 0, 0
+	for i < len(oldTokens) && j < len(newTokens) {
+		switch {
+		case oldText[oldTokens[i].Start:oldTokens[i].End] == newText[newTokens[j].Start:newTokens[j].End]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			oldChanged[i] = true
+			i++
+		default:
+			newChanged[j] = true
+			j++
+		}
+	}
+	for ; i < len(oldTokens); i++ {
+		oldChanged[i] = true
+	}
+	for ; j < len(newTokens); j++ {
+		newChanged[j] = true
+	}
+
+	return changedRanges(oldText, oldTokens, oldChanged), changedRanges(newText, newTokens, newChanged)
+}
+
+func wholeRange(text string) []DiffRange {
+	if text == "" {
+		return nil
+	}
+	return []DiffRange{{Start: 0, End: len(text)}}
+}
+
+type wordTokenClass int
+
+const (
+	wordTokenWord wordTokenClass = iota
+	wordTokenSpace
+	wordTokenPunct
+)
+
+func classifyWordRune(r rune) wordTokenClass {
+	switch {
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+		return wordTokenWord
+	case unicode.IsSpace(r):
+		return wordTokenSpace
+	default:
+		return wordTokenPunct
+	}
+}
+
+func wordTokens(text string) []DiffRange {
+	var tokens []DiffRange
+	for start := 0; start < len(text); {
+		r, size := utf8.DecodeRuneInString(text[start:])
+		class := classifyWordRune(r)
+		end := start + size
+		if class != wordTokenPunct {
+			for end < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[end:])
+				if classifyWordRune(next) != class {
+					break
+				}
+				end += nextSize
+			}
+		}
+		tokens = append(tokens, DiffRange{Start: start, End: end})
+		start = end
+	}
+	return tokens
+}
+
+func isSpaceToken(text string, token DiffRange) bool {
+	r, _ := utf8.DecodeRuneInString(text[token.Start:token.End])
+	return classifyWordRune(r) == wordTokenSpace
+}
+
+func changedRanges(text string, tokens []DiffRange, changed []bool) []DiffRange {
+	var ranges []DiffRange
+	pendingGap := -1
+	for i, token := range tokens {
+		if !changed[i] {
+			// A single unchanged whitespace token between two changes is
+			// folded into them.
+			if pendingGap < 0 && len(ranges) > 0 && ranges[len(ranges)-1].End == token.Start && isSpaceToken(text, token) {
+				pendingGap = i
+				continue
+			}
+			pendingGap = -1
+			continue
+		}
+		if n := len(ranges); n > 0 && (ranges[n-1].End == token.Start || (pendingGap == i-1 && pendingGap >= 0)) {
+			ranges[n-1].End = token.End
+		} else {
+			ranges = append(ranges, token)
+		}
+		pendingGap = -1
+	}
+	return ranges
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWordDiff(t *testing.T) {
+	oldText := "The quick brown fox jumps."
+	newText := "The slow brown fox leaps!"
+	oldChanges, newChanges := WordDiff(oldText, newText)
+
+	var oldWords, newWords []string
+	for _, r := range oldChanges {
+		oldWords = append(oldWords, oldText[r.Start:r.End])
+	}
+	for _, r := range newChanges {
+		newWords = append(newWords, newText[r.Start:r.End])
+	}
+	if !reflect.DeepEqual(oldWords, []string{"quick", "jumps."}) {
+		t.Fatalf("unexpected old changes: %q", oldWords)
+	}
+	if !reflect.DeepEqual(newWords, []string{"slow", "leaps!"}) {
+		t.Fatalf("unexpected new changes: %q", newWords)
+	}
+
+	// Adjacent changed words separated by a space become one range.
+	oldChanges, newChanges = WordDiff("a b c d", "a x y d")
+	if len(oldChanges) != 1 || "a b c d"[oldChanges[0].Start:oldChanges[0].End] != "b c" {
+		t.Fatalf("expected merged old range, got %+v", oldChanges)
+	}
+	if len(newChanges) != 1 || "a x y d"[newChanges[0].Start:newChanges[0].End] != "x y" {
+		t.Fatalf("expected merged new range, got %+v", newChanges)
+	}
+
+	if oldChanges, newChanges := WordDiff("same", "same"); oldChanges != nil || newChanges != nil {
+		t.Fatalf("expected no changes for identical lines")
+	}
+}
+
+func TestDiffWordHunks(t *testing.T) {
+	raw := "@@ -1,3 +1,2 @@\n-Hello wörld\n-gone\n+Hello world\n keep\n"
+	hunks, err := FileDiff{Raw: raw}.WordHunks()
+	if err != nil {
+		t.Fatalf("word hunks error: %v", err)
+	}
+	lines := hunks[0].Lines
+	if len(lines) != 4 {
+		t.Fatalf("unexpected lines: %+v", lines)
+	}
+	if got := lines[0].Content[lines[0].Changes[0].Start:lines[0].Changes[0].End]; got != "wörld" {
+		t.Fatalf("unexpected deleted change: %q", got)
+	}
+	if got := lines[2].Content[lines[2].Changes[0].Start:lines[2].Changes[0].End]; got != "world" {
+		t.Fatalf("unexpected added change: %q", got)
+	}
+	if lines[1].Changes != nil || lines[3].Changes != nil {
+		t.Fatalf("expected unpaired and context lines without changes: %+v", lines)
+	}
+}